@@ -0,0 +1,113 @@
+package cfs
+
+import (
+	"errors"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// Adapt upgrades a minimal fs.FS, one that may only implement Open, with
+// generic ReadDir, ReadFile, Stat, Glob and Sub implementations, so the
+// layer behaves like the standard library filesystems. Directories whose
+// files do not implement fs.ReadDirFile are listed through the layer's
+// Glob method when it has one, as the ReadDir helper does. Methods the
+// layer already implements are used as they are.
+//
+// Adapt the filesystem before naming it with NewLayer, as the adapted
+// layer only exposes the methods listed above.
+func Adapt(fsys fs.FS) fs.FS {
+	if a, ok := fsys.(*adaptedFS); ok {
+		return a
+	}
+	return &adaptedFS{fsys: fsys}
+}
+
+type adaptedFS struct {
+	fsys fs.FS
+}
+
+func (a *adaptedFS) Open(name string) (fs.File, error) {
+	file, err := a.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := file.(fs.ReadDirFile); ok {
+		return file, nil
+	}
+
+	info, err := file.Stat()
+	if err != nil || !info.IsDir() {
+		// not a directory, or one we cannot tell apart from a file
+		return file, nil
+	}
+	file.Close()
+
+	entries, err := a.ReadDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.Unwrap(err)}
+	}
+	return &overlayDirFile{name: name, info: info, entries: entries}, nil
+}
+
+// ReadDir implements fs.ReadDirFS. Entries are sorted by name.
+func (a *adaptedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := ReadDir(a.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (a *adaptedFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(a.fsys, name)
+}
+
+// Stat implements fs.StatFS.
+func (a *adaptedFS) Stat(name string) (fs.FileInfo, error) {
+	return statLayer(a.fsys, name)
+}
+
+// Glob implements fs.GlobFS.
+func (a *adaptedFS) Glob(pattern string) ([]string, error) {
+	if globber, ok := a.fsys.(fs.GlobFS); ok {
+		return globber.Glob(pattern)
+	}
+	// fs.Glob walks the directories through ReadDir above
+	return fs.Glob(struct{ fs.ReadDirFS }{a}, pattern)
+}
+
+// Sub implements fs.SubFS.
+func (a *adaptedFS) Sub(dir string) (fs.FS, error) {
+	if subber, ok := a.fsys.(fs.SubFS); ok {
+		sub, err := subber.Sub(dir)
+		if err != nil {
+			return nil, err
+		}
+		return Adapt(sub), nil
+	}
+	sub, err := fs.Sub(struct{ fs.ReadDirFS }{a}, dir)
+	if err != nil {
+		return nil, err
+	}
+	return Adapt(sub), nil
+}
+
+// escapeMeta quotes the path.Match metacharacters in name.
+func escapeMeta(name string) string {
+	if !strings.ContainsAny(name, `*?[\`) {
+		return name
+	}
+	var b strings.Builder
+	for _, r := range name {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
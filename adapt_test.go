@@ -0,0 +1,110 @@
+package cfs_test
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+// openOnlyFS exposes nothing but Open, and its directories cannot be
+// listed through the returned file.
+type openOnlyFS struct {
+	files fstest.MapFS
+}
+
+type plainFile struct {
+	fs.File
+}
+
+func (o openOnlyFS) Open(name string) (fs.File, error) {
+	file, err := o.files.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return plainFile{file}, nil
+}
+
+// globOnlyFS can list directories through Glob only.
+type globOnlyFS struct {
+	openOnlyFS
+}
+
+func (g globOnlyFS) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var out []string
+	for name := range g.files {
+		for p := name; p != "."; p = path.Dir(p) {
+			if ok, _ := path.Match(pattern, p); ok && !seen[p] {
+				seen[p] = true
+				out = append(out, p)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func TestAdaptConformance(t *testing.T) {
+	files := fstest.MapFS{
+		"index.html":         &fstest.MapFile{Data: []byte("index")},
+		"views/home.html":    &fstest.MapFile{Data: []byte("home")},
+		"views/partials/nav": &fstest.MapFile{Data: []byte("nav")},
+	}
+	adapted := cfs.Adapt(globOnlyFS{openOnlyFS{files: files}})
+
+	if err := fstest.TestFS(adapted, "index.html", "views/home.html", "views/partials/nav"); err != nil {
+		t.Fatalf("TestFS failed: %v", err)
+	}
+
+	sub, err := fs.Sub(adapted, "views")
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	if err := fstest.TestFS(sub, "home.html", "partials/nav"); err != nil {
+		t.Fatalf("TestFS on Sub failed: %v", err)
+	}
+}
+
+func TestAdaptKeepsOverlayMerges(t *testing.T) {
+	minimal := globOnlyFS{openOnlyFS{files: fstest.MapFS{
+		"views/theme.html": &fstest.MapFile{Data: []byte("theme")},
+	}}}
+	base := fstest.MapFS{
+		"views/base.html": &fstest.MapFile{Data: []byte("base")},
+	}
+
+	entries, err := fs.ReadDir(cfs.NewOverlayFS(cfs.Adapt(minimal), base), "views")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected entries from both layers, got %v", entries)
+	}
+}
+
+func TestAdaptUsesExistingMethods(t *testing.T) {
+	files := fstest.MapFS{
+		"b.txt": &fstest.MapFile{Data: []byte("b")},
+		"a.txt": &fstest.MapFile{Data: []byte("a")},
+	}
+	adapted := cfs.Adapt(files)
+	if cfs.Adapt(adapted) != adapted {
+		t.Fatal("Expected adapting twice to return the same layer")
+	}
+	entries, err := fs.ReadDir(adapted, ".")
+	if err != nil || len(entries) != 2 || entries[0].Name() != "a.txt" {
+		t.Fatalf("Expected sorted entries, got %v (%v)", entries, err)
+	}
+
+	// without Glob, unlistable directories are reported as invalid
+	if _, err := fs.ReadDir(cfs.Adapt(openOnlyFS{files: files}), "."); err == nil {
+		t.Fatal("Expected an error listing a directory without ReadDirFile or Glob")
+	}
+}
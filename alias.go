@@ -0,0 +1,78 @@
+package cfs
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// WithAliases returns a copy of the composite where reads of the keys of
+// aliases transparently resolve to their values, for example while
+// content migrates from "css/site.css" to "assets/css/site.css". An
+// alias for a directory also redirects everything below it; the longest
+// matching alias wins. Aliases are applied once, so they never chain,
+// and the target must be visible for the alias to resolve. Writes are
+// not redirected.
+func (cfs *CompositeFS) WithAliases(aliases map[string]string) *CompositeFS {
+	c := cfs.clone()
+	c.aliases = make(map[string]string, len(cfs.aliases)+len(aliases))
+	for from, to := range cfs.aliases {
+		c.aliases[from] = to
+	}
+	for from, to := range aliases {
+		c.aliases[path.Clean(from)] = path.Clean(to)
+	}
+	return c
+}
+
+// alias rewrites name according to the configured aliases. Aliases are
+// written for the root composite, so Sub composites translate through
+// their root and ignore aliases pointing outside of it.
+func (cfs *CompositeFS) alias(name string) string {
+	if len(cfs.aliases) == 0 {
+		return name
+	}
+
+	full := cfs.fullPath(name)
+	for p := full; p != "."; p = path.Dir(p) {
+		if to, ok := cfs.aliases[p]; ok {
+			if rel, ok := cfs.withinRoot(to + full[len(p):]); ok {
+				return rel
+			}
+			return name
+		}
+	}
+	return name
+}
+
+// fullPath returns the root-relative form of a path of this composite.
+func (cfs *CompositeFS) fullPath(name string) string {
+	if cfs.visibility.root == "" {
+		return name
+	}
+	return path.Join(cfs.visibility.root, name)
+}
+
+// withinRoot maps a root-relative path back into a Sub composite,
+// reporting false when it lies outside of it.
+func (cfs *CompositeFS) withinRoot(full string) (string, bool) {
+	root := cfs.visibility.root
+	if root == "" || root == "." {
+		return full, true
+	}
+	if full == root {
+		return ".", true
+	}
+	return strings.CutPrefix(full, root+"/")
+}
+
+// aliasList returns the aliases in a stable order for stack hashes.
+func (cfs *CompositeFS) aliasList() []string {
+	out := make([]string, 0, len(cfs.aliases))
+	for from, to := range cfs.aliases {
+		out = append(out, fmt.Sprintf("%s=%s", from, to))
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,59 @@
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestWithAliases(t *testing.T) {
+	composite := cfs.NewCompositeFS(
+		fstest.MapFS{"assets/css/site.css": &fstest.MapFile{Data: []byte("new css")}},
+		fstest.MapFS{
+			"assets/img/logo.png": &fstest.MapFile{Data: []byte("logo")},
+			"legacy/old.txt":      &fstest.MapFile{Data: []byte("old")},
+		},
+	).WithAliases(map[string]string{
+		"css/site.css": "assets/css/site.css",
+		"images":       "assets/img",
+	})
+
+	testReadFile(t, composite, "css/site.css", "new css")
+	testReadFile(t, composite, "images/logo.png", "logo")
+	testReadFile(t, composite, "legacy/old.txt", "old")
+
+	data, err := composite.ReadFile("css/site.css")
+	if err != nil || string(data) != "new css" {
+		t.Fatalf("Expected ReadFile through alias, got %q (%v)", data, err)
+	}
+	if _, err := composite.Stat("images/logo.png"); err != nil {
+		t.Fatalf("Expected Stat through alias, got %v", err)
+	}
+	entries, err := composite.ReadDir("images")
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Expected ReadDir through alias, got %v (%v)", entries, err)
+	}
+}
+
+func TestWithAliasesRespectsVisibilityAndSub(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{
+		"site/new/page.html": &fstest.MapFile{Data: []byte("page")},
+		"secrets/key.pem":    &fstest.MapFile{Data: []byte("key")},
+	}).WithAliases(map[string]string{
+		"site/old": "site/new",
+		"key":      "secrets/key.pem",
+	}).WithDenied("*.pem")
+
+	if _, err := composite.Open("key"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected alias to denied path to be hidden, got %v", err)
+	}
+
+	sub, err := composite.Sub("site")
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	testReadFile(t, sub, "old/page.html", "page")
+}
@@ -0,0 +1,215 @@
+package cfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+)
+
+// Severity ranks the findings of AnalyzeStack.
+type Severity int
+
+const (
+	// SeverityInfo marks findings that are usually harmless.
+	SeverityInfo Severity = iota
+	// SeverityWarning marks likely misconfigurations.
+	SeverityWarning
+	// SeverityError marks stacks that hide content unexpectedly.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// FindingKind identifies the kind of problem a Finding describes.
+type FindingKind string
+
+const (
+	// FindingEmptyLayer reports a read layer without any entries.
+	FindingEmptyLayer FindingKind = "empty-layer"
+	// FindingNoContribution reports a layer holding directories only.
+	FindingNoContribution FindingKind = "no-contribution"
+	// FindingShadowedLayer reports a layer whose files are all hidden
+	// by upper layers.
+	FindingShadowedLayer FindingKind = "shadowed-layer"
+	// FindingTypeConflict reports a path that is a file in one layer
+	// and a directory in another.
+	FindingTypeConflict FindingKind = "type-conflict"
+	// FindingUnreadable reports a layer that could not be walked.
+	FindingUnreadable FindingKind = "unreadable"
+)
+
+// Finding is a single observation made by AnalyzeStack.
+type Finding struct {
+	Severity Severity
+	Kind     FindingKind
+	// Layers lists the indices of the layers involved, upper first.
+	Layers []int
+	// Path is the affected path, empty for findings about whole layers.
+	Path    string
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s", f.Severity, f.Message)
+}
+
+// StackReport is the result of AnalyzeStack.
+type StackReport struct {
+	// Findings are sorted by descending severity, then by layer and path.
+	Findings []Finding
+}
+
+// Worst returns the highest severity in the report, or -1 when the
+// report has no findings.
+func (r StackReport) Worst() Severity {
+	worst := Severity(-1)
+	for _, f := range r.Findings {
+		worst = max(worst, f.Severity)
+	}
+	return worst
+}
+
+// AnalyzeStack walks every layer and flags likely misconfigurations:
+// empty layers, layers that contribute nothing, layers entirely shadowed
+// by upper layers, and paths that are files in one layer and directories
+// in another. It reads every visible path of every layer, so it is meant
+// to run once at startup, with the findings logged as warnings.
+func (cfs *CompositeFS) AnalyzeStack() StackReport {
+	var report StackReport
+	add := func(f Finding) {
+		report.Findings = append(report.Findings, f)
+	}
+
+	type owner struct {
+		layer int
+		dir   bool
+	}
+	first := make(map[string]owner)
+	conflicts := make(map[string]bool)
+
+	for i, fsys := range cfs.filesystems {
+		name := layerLabel(i, fsys)
+		var files, dirs, winning int
+
+		err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if p == "." && errors.Is(err, fs.ErrNotExist) {
+					return fs.SkipAll
+				}
+				return err
+			}
+			if p == "." {
+				return nil
+			}
+			if !cfs.visible(p) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+
+			if d.IsDir() {
+				dirs++
+			} else {
+				files++
+			}
+
+			prev, seen := first[p]
+			switch {
+			case !seen:
+				first[p] = owner{layer: i, dir: d.IsDir()}
+				if !d.IsDir() {
+					winning++
+				}
+			case prev.dir != d.IsDir() && !conflicts[p]:
+				conflicts[p] = true
+				add(Finding{
+					Severity: SeverityError,
+					Kind:     FindingTypeConflict,
+					Layers:   []int{prev.layer, i},
+					Path:     p,
+					Message: fmt.Sprintf("%s is a %s in %s but a %s in %s",
+						p, kindName(prev.dir), layerLabel(prev.layer, cfs.filesystems[prev.layer]),
+						kindName(d.IsDir()), name),
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			add(Finding{
+				Severity: SeverityError,
+				Kind:     FindingUnreadable,
+				Layers:   []int{i},
+				Message:  fmt.Sprintf("%s could not be read: %v", name, err),
+			})
+			continue
+		}
+
+		switch {
+		case files == 0 && dirs == 0:
+			// write layers usually start out empty
+			if cfs.writer != nil && sameLayer(fsys, cfs.writer) {
+				continue
+			}
+			add(Finding{
+				Severity: SeverityWarning,
+				Kind:     FindingEmptyLayer,
+				Layers:   []int{i},
+				Message:  fmt.Sprintf("%s is empty", name),
+			})
+		case files == 0:
+			add(Finding{
+				Severity: SeverityInfo,
+				Kind:     FindingNoContribution,
+				Layers:   []int{i},
+				Message:  fmt.Sprintf("%s contains directories only", name),
+			})
+		case winning == 0:
+			add(Finding{
+				Severity: SeverityWarning,
+				Kind:     FindingShadowedLayer,
+				Layers:   []int{i},
+				Message:  fmt.Sprintf("every file in %s is shadowed by upper layers", name),
+			})
+		}
+	}
+
+	sort.SliceStable(report.Findings, func(a, b int) bool {
+		fa, fb := report.Findings[a], report.Findings[b]
+		if fa.Severity != fb.Severity {
+			return fa.Severity > fb.Severity
+		}
+		if fa.Layers[0] != fb.Layers[0] {
+			return fa.Layers[0] < fb.Layers[0]
+		}
+		return fa.Path < fb.Path
+	})
+	return report
+}
+
+// layerLabel describes layer i for messages.
+func layerLabel(i int, fsys fs.FS) string {
+	if name := LayerName(fsys); name != "" {
+		return fmt.Sprintf("layer %d (%s)", i, name)
+	}
+	return fmt.Sprintf("layer %d", i)
+}
+
+func kindName(dir bool) string {
+	if dir {
+		return "directory"
+	}
+	return "file"
+}
@@ -0,0 +1,72 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestAnalyzeStack(t *testing.T) {
+	override := cfs.NewLayer("override", fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("override")},
+		"assets":          &fstest.MapFile{Data: []byte("not a dir")},
+	})
+	shadowed := cfs.NewLayer("shadowed", fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("old")},
+	})
+	empty := cfs.NewLayer("empty", fstest.MapFS{})
+	dirsOnly := cfs.NewLayer("dirs", fstest.MapFS{
+		"cache": &fstest.MapFile{Mode: fs.ModeDir | 0o755},
+	})
+	base := cfs.NewLayer("base", fstest.MapFS{
+		"assets/app.css": &fstest.MapFile{Data: []byte("css")},
+		"index.html":     &fstest.MapFile{Data: []byte("index")},
+	})
+
+	report := cfs.NewCompositeFS(override, shadowed, empty, dirsOnly, base).AnalyzeStack()
+
+	want := []struct {
+		severity cfs.Severity
+		kind     cfs.FindingKind
+		layer    int
+		path     string
+	}{
+		{cfs.SeverityError, cfs.FindingTypeConflict, 0, "assets"},
+		{cfs.SeverityWarning, cfs.FindingShadowedLayer, 1, ""},
+		{cfs.SeverityWarning, cfs.FindingEmptyLayer, 2, ""},
+		{cfs.SeverityInfo, cfs.FindingNoContribution, 3, ""},
+	}
+	if len(report.Findings) != len(want) {
+		t.Fatalf("Expected %d findings, got %v", len(want), report.Findings)
+	}
+	for i, w := range want {
+		f := report.Findings[i]
+		if f.Severity != w.severity || f.Kind != w.kind || f.Layers[0] != w.layer || f.Path != w.path {
+			t.Errorf("Finding %d: expected %v %s layer %d %q, got %v", i, w.severity, w.kind, w.layer, w.path, f)
+		}
+	}
+	if got := report.Findings[0].Layers; len(got) != 2 || got[1] != 4 {
+		t.Errorf("Expected the conflict to involve layers 0 and 4, got %v", got)
+	}
+	if report.Worst() != cfs.SeverityError {
+		t.Errorf("Expected worst severity error, got %v", report.Worst())
+	}
+}
+
+func TestAnalyzeStackIgnoresEmptyWriter(t *testing.T) {
+	composite := cfs.NewWritableFS(cfs.NewDirWriteFS(t.TempDir()), fstest.MapFS{
+		"index.html": &fstest.MapFile{Data: []byte("index")},
+	})
+
+	report := composite.AnalyzeStack()
+	if len(report.Findings) != 0 {
+		t.Fatalf("Expected no findings, got %v", report.Findings)
+	}
+	if report.Worst() >= cfs.SeverityInfo {
+		t.Fatalf("Expected no severity, got %v", report.Worst())
+	}
+}
@@ -0,0 +1,291 @@
+package cfs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// archiveOpeners maps archive extensions to the function reading such
+// an archive into a filesystem.
+var archiveOpeners = map[string]func(data []byte) (fs.FS, error){
+	".zip":    openZip,
+	".tar":    openTar,
+	".tar.gz": openTarGz,
+	".tgz":    openTarGz,
+}
+
+// archiveExt returns the archive extension of name, or an empty string
+// if name is not an archive.
+func archiveExt(name string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(lower, ext) && len(lower) > len(ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+func openZip(data []byte) (fs.FS, error) {
+	return zip.NewReader(bytes.NewReader(data), int64(len(data)))
+}
+
+func openTarGz(data []byte) (fs.FS, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return readTar(zr)
+}
+
+func openTar(data []byte) (fs.FS, error) {
+	return readTar(bytes.NewReader(data))
+}
+
+// readTar loads the regular files and directories of a tar stream into
+// memory. Other entry types are ignored.
+func readTar(r io.Reader) (fs.FS, error) {
+	m := newMemFS()
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return m, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if !fs.ValidPath(name) || name == "." {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			m.add(name, nil, fs.ModeDir|hdr.FileInfo().Mode().Perm(), hdr.ModTime)
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			m.add(name, data, hdr.FileInfo().Mode().Perm(), hdr.ModTime)
+		}
+	}
+}
+
+// WithArchives returns a copy of the composite that serves paths inside
+// archives stored in its layers, such as "bundles/theme.zip/css/main.css".
+// The part of the path up to the archive is resolved in the layer and
+// the rest inside the archive. Opened archives are cached in memory and
+// reopened when the archive file changes. The write layer is never
+// searched for archives.
+func (cfs *CompositeFS) WithArchives() *CompositeFS {
+	c := cfs.clone()
+	c.archives = true
+	c.filesystems = c.flatten(cfs.filesystems)
+	return c
+}
+
+// WithArchiveLayers returns a copy of the composite with every archive
+// found in dir (.zip, .tar, .tar.gz or .tgz) mounted as an additional
+// lowest-priority layer named after the archive's stem, in lexical
+// order. Bundles dropped into dir, such as theme packs, become layers
+// without code changes. A missing dir adds no layers.
+func (cfs *CompositeFS) WithArchiveLayers(dir string) (*CompositeFS, error) {
+	entries, err := cfs.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfs.clone(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	layers := append([]fs.FS(nil), cfs.filesystems...)
+	for _, entry := range entries {
+		ext := archiveExt(entry.Name())
+		if ext == "" || entry.IsDir() {
+			continue
+		}
+		name := path.Join(dir, entry.Name())
+		data, err := cfs.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		archive, err := archiveOpeners[ext](data)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		stem := entry.Name()[:len(entry.Name())-len(ext)]
+		layers = append(layers, NewLayer(stem, archive))
+	}
+	return cfs.withLayers(layers), nil
+}
+
+// archiveFS serves the entries of archives contained in a layer in
+// addition to the layer's own files.
+type archiveFS struct {
+	fsys fs.FS
+
+	mu    sync.Mutex
+	cache map[string]*cachedArchive
+}
+
+type cachedArchive struct {
+	fsys    fs.FS
+	size    int64
+	modTime time.Time
+}
+
+func newArchiveFS(fsys fs.FS) *archiveFS {
+	return &archiveFS{fsys: fsys, cache: make(map[string]*cachedArchive)}
+}
+
+// Sub returns the layer rooted at dir, which may lie inside an archive.
+func (a *archiveFS) Sub(dir string) (fs.FS, error) {
+	if archive, inner, ok := a.split(dir + "/."); ok {
+		return fs.Sub(archive, path.Clean(inner))
+	}
+	sub, err := fs.Sub(a.fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return newArchiveFS(sub), nil
+}
+
+// Name implements NamedFS by delegating to the wrapped layer.
+func (a *archiveFS) Name() string {
+	return LayerName(a.fsys)
+}
+
+func (a *archiveFS) Open(name string) (fs.File, error) {
+	file, err := a.fsys.Open(name)
+	if err == nil {
+		return file, nil
+	}
+	if archive, inner, ok := a.split(name); ok {
+		return archive.Open(inner)
+	}
+	return nil, err
+}
+
+func (a *archiveFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := statLayer(a.fsys, name)
+	if err == nil {
+		return info, nil
+	}
+	if archive, inner, ok := a.split(name); ok {
+		return fs.Stat(archive, inner)
+	}
+	return nil, err
+}
+
+func (a *archiveFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := ReadDir(a.fsys, name)
+	if err == nil {
+		return entries, nil
+	}
+	if archive, inner, ok := a.split(name); ok {
+		return fs.ReadDir(archive, inner)
+	}
+	return nil, err
+}
+
+func (a *archiveFS) ReadFile(name string) ([]byte, error) {
+	data, err := fs.ReadFile(a.fsys, name)
+	if err == nil {
+		return data, nil
+	}
+	if archive, inner, ok := a.split(name); ok {
+		return fs.ReadFile(archive, inner)
+	}
+	return nil, err
+}
+
+// InvalidatePaths implements Invalidator. Cached archives are checked
+// against the archive file on every access, so only the wrapped layer
+// needs to know.
+func (a *archiveFS) InvalidatePaths(names ...string) {
+	invalidateLayer(a.fsys, names)
+}
+
+// MayContain implements PathFilter. Paths inside an archive are
+// possible whenever the wrapped layer may contain the archive itself.
+func (a *archiveFS) MayContain(name string) bool {
+	if !skipLayer(a.fsys, name) {
+		return true
+	}
+	if prefix, _, ok := archivePrefix(name); ok {
+		return !skipLayer(a.fsys, prefix)
+	}
+	return false
+}
+
+// split locates the archive along name and returns its filesystem
+// together with the path of name inside it.
+func (a *archiveFS) split(name string) (fs.FS, string, bool) {
+	prefix, inner, ok := archivePrefix(name)
+	if !ok {
+		return nil, "", false
+	}
+	archive, err := a.archive(prefix)
+	if err != nil {
+		return nil, "", false
+	}
+	return archive, inner, true
+}
+
+// archive returns the cached filesystem of the archive at name,
+// reopening it when the file changed.
+func (a *archiveFS) archive(name string) (fs.FS, error) {
+	info, err := statLayer(a.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if cached, ok := a.cache[name]; ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.fsys, nil
+	}
+
+	data, err := fs.ReadFile(a.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := archiveOpeners[archiveExt(name)](data)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	a.cache[name] = &cachedArchive{fsys: archive, size: info.Size(), modTime: info.ModTime()}
+	return archive, nil
+}
+
+// archivePrefix splits name at the first element with an archive
+// extension that is followed by more elements.
+func archivePrefix(name string) (string, string, bool) {
+	elements := strings.Split(name, "/")
+	for i := 0; i < len(elements)-1; i++ {
+		if archiveExt(elements[i]) != "" {
+			return strings.Join(elements[:i+1], "/"), strings.Join(elements[i+1:], "/"), true
+		}
+	}
+	return "", "", false
+}
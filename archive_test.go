@@ -0,0 +1,137 @@
+package cfs_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func zipData(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create zip entry: %v", err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestWithArchivesServesZipEntries(t *testing.T) {
+	bundles := fstest.MapFS{
+		"bundles/theme.zip": &fstest.MapFile{Data: zipData(t, map[string]string{
+			"css/main.css":    "zip css",
+			"views/home.html": "zip home",
+		})},
+	}
+	composite := cfs.NewCompositeFS(
+		fstest.MapFS{"bundles/theme.zip/views/home.html": &fstest.MapFile{Data: []byte("override")}},
+		bundles,
+	).WithArchives()
+
+	testReadFile(t, composite, "bundles/theme.zip/css/main.css", "zip css")
+	testReadFile(t, composite, "bundles/theme.zip/views/home.html", "override")
+
+	data, err := composite.ReadFile("bundles/theme.zip/css/main.css")
+	if err != nil || string(data) != "zip css" {
+		t.Fatalf("Expected ReadFile inside archive, got %q (%v)", data, err)
+	}
+	entries, err := composite.ReadDir("bundles/theme.zip/css")
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Expected archive directory listing, got %v (%v)", entries, err)
+	}
+	if _, err := composite.Stat("bundles/theme.zip/missing.css"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected fs.ErrNotExist, got %v", err)
+	}
+
+	sub, err := composite.Sub("bundles/theme.zip")
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	testReadFile(t, sub, "css/main.css", "zip css")
+}
+
+func TestWithArchivesReloadsChangedArchive(t *testing.T) {
+	layer := fstest.MapFS{
+		"theme.zip": &fstest.MapFile{Data: zipData(t, map[string]string{"a.txt": "v1"})},
+	}
+	composite := cfs.NewCompositeFS(layer).WithArchives()
+
+	testReadFile(t, composite, "theme.zip/a.txt", "v1")
+
+	layer["theme.zip"] = &fstest.MapFile{Data: zipData(t, map[string]string{"a.txt": "version 2"})}
+	testReadFile(t, composite, "theme.zip/a.txt", "version 2")
+}
+
+func tarGzData(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		tw.Write([]byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close gzip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestWithArchiveLayersMountsBundles(t *testing.T) {
+	composite, err := cfs.NewCompositeFS(
+		fstest.MapFS{"views/home.html": &fstest.MapFile{Data: []byte("app home")}},
+		fstest.MapFS{
+			"themes/dark.zip": &fstest.MapFile{Data: zipData(t, map[string]string{"css/theme.css": "dark"})},
+			"themes/light.tar.gz": &fstest.MapFile{Data: tarGzData(t, map[string]string{
+				"css/theme.css":  "light",
+				"views/nav.html": "light nav",
+			})},
+			"themes/README.md": &fstest.MapFile{Data: []byte("drop themes here")},
+		},
+	).WithArchiveLayers("themes")
+	if err != nil {
+		t.Fatalf("WithArchiveLayers failed: %v", err)
+	}
+
+	if composite.LayerCount() != 4 {
+		t.Fatalf("Expected 4 layers, got %d", composite.LayerCount())
+	}
+	testReadFile(t, composite, "views/home.html", "app home")
+	testReadFile(t, composite, "css/theme.css", "dark")
+	testReadFile(t, composite, "views/nav.html", "light nav")
+
+	light := composite.WithoutLayer("dark")
+	testReadFile(t, light, "css/theme.css", "light")
+}
+
+func TestWithArchiveLayersMissingDir(t *testing.T) {
+	composite, err := cfs.NewCompositeFS(fstest.MapFS{}).WithArchiveLayers("themes")
+	if err != nil {
+		t.Fatalf("Expected missing dir to be ignored, got %v", err)
+	}
+	if composite.LayerCount() != 1 {
+		t.Fatalf("Expected 1 layer, got %d", composite.LayerCount())
+	}
+}
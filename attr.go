@@ -0,0 +1,87 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"sync"
+)
+
+// ErrNoAttr is returned by GetAttr for attributes no sidecar sets.
+var ErrNoAttr = errors.New("attribute not set")
+
+// attrMu serializes the read-modify-write cycles of SetAttr.
+var attrMu sync.Mutex
+
+// GetAttr returns the attribute key of the named file, as merged from
+// its metadata sidecars across layers (see SidecarMeta). Values read
+// back as encoding/json decodes them: numbers are float64, arrays
+// []any and objects map[string]any. Attributes no sidecar sets return
+// ErrNoAttr.
+func (cfs *CompositeFS) GetAttr(name, key string) (any, error) {
+	meta, err := cfs.SidecarMeta(name)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := meta[key]
+	if !ok {
+		return nil, &fs.PathError{Op: "getattr", Path: name, Err: ErrNoAttr}
+	}
+	return value, nil
+}
+
+// SetAttr sets the attribute key of the named file, such as an owner or
+// a review status, in the file's sidecar in the write layer, so it
+// travels with the composite view and overrides the value lower layers
+// set. value must encode to JSON. The file may come from any layer;
+// only its sidecar is written, as JSON, keeping the other keys the
+// write layer's sidecar already holds. Composites without a write
+// layer return ErrReadOnly.
+func (cfs *CompositeFS) SetAttr(name, key string, value any) error {
+	if err := cfs.life.enter("setattr", name); err != nil {
+		return err
+	}
+	defer cfs.life.leave()
+
+	name, err := cfs.lookupName("setattr", name)
+	if err != nil {
+		return err
+	}
+	if !cfs.visible(name) {
+		return &fs.PathError{Op: "setattr", Path: name, Err: fs.ErrNotExist}
+	}
+	if _, err := cfs.writePath("setattr", name); err != nil {
+		return err
+	}
+	if _, _, err := cfs.resolve(name); err != nil {
+		return err
+	}
+
+	attrMu.Lock()
+	defer attrMu.Unlock()
+
+	sidecar := name + SidecarExt
+	meta := make(map[string]any)
+	data, err := fs.ReadFile(cfs.writer, sidecar)
+	switch {
+	case err == nil:
+		if meta, err = parseSidecar(data); err != nil {
+			return &fs.PathError{Op: "setattr", Path: sidecar, Err: err}
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+	meta[key] = value
+
+	data, err = json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return &fs.PathError{Op: "setattr", Path: name, Err: err}
+	}
+	if err := cfs.ensureParent(sidecar); err != nil {
+		return err
+	}
+	defer cfs.record("setattr", sidecar)()
+	return cfs.writer.WriteFile(sidecar, append(data, '\n'), 0o644)
+}
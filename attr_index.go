@@ -0,0 +1,161 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// WithAttrIndex returns a copy of the composite that indexes the
+// metadata sidecars of every layer for FindByAttr. The index is built
+// on first use and dropped whenever files change through the composite,
+// Invalidate or a Watcher, or a layer is swapped, so later queries see
+// the change.
+func (cfs *CompositeFS) WithAttrIndex() *CompositeFS {
+	c := cfs.clone()
+	c.attrs = newAttrIndex()
+	return c
+}
+
+// FindByAttr returns the sorted paths of the files whose merged sidecar
+// metadata (see SidecarMeta) sets the attribute key to value, such as
+// every template whose "status" is "needs-review", across all layers.
+// Values are compared by their JSON encoding, so 2 matches a sidecar
+// number 2. Without WithAttrIndex the sidecars of every layer are
+// walked and parsed on each call.
+func (cfs *CompositeFS) FindByAttr(key string, value any) ([]string, error) {
+	if err := cfs.life.enter("findattr", key); err != nil {
+		return nil, err
+	}
+	defer cfs.life.leave()
+
+	want, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("find attribute %q: %w", key, err)
+	}
+	index, err := cfs.attrs.get(cfs.buildAttrIndex)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(index[key][string(want)]), nil
+}
+
+// attrValues maps attribute keys to the JSON encoding of their values
+// and then to the sorted paths of the files that set them.
+type attrValues map[string]map[string][]string
+
+// buildAttrIndex reads the sidecars of every layer and indexes the
+// merged metadata of the visible files they belong to.
+func (cfs *CompositeFS) buildAttrIndex() (attrValues, error) {
+	var names []string
+	for i, fsys := range cfs.filesystems {
+		err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if p == "." && errors.Is(err, fs.ErrNotExist) {
+					return fs.SkipAll
+				}
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(p, SidecarExt) {
+				names = append(names, strings.TrimSuffix(p, SidecarExt))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("filesystem %d: %w", i, err)
+		}
+	}
+	slices.Sort(names)
+	names = slices.Compact(names)
+
+	index := make(attrValues)
+	for _, name := range names {
+		if name == "" || !cfs.visible(name) {
+			continue
+		}
+		if _, _, err := cfs.resolve(name); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// sidecars of files no layer provides annotate nothing
+				continue
+			}
+			return nil, err
+		}
+		meta, err := cfs.sidecarMeta(name)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range meta {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			if index[key] == nil {
+				index[key] = make(map[string][]string)
+			}
+			index[key][string(encoded)] = append(index[key][string(encoded)], name)
+		}
+	}
+	return index, nil
+}
+
+// attrIndex caches the attribute index of a composite.
+type attrIndex struct {
+	mu     sync.Mutex
+	values attrValues
+	gen    uint64 // bumped by invalidate, so stale builds are dropped
+}
+
+func newAttrIndex() *attrIndex {
+	return &attrIndex{}
+}
+
+// fresh returns an empty index, for copies of a composite.
+func (x *attrIndex) fresh() *attrIndex {
+	if x == nil {
+		return nil
+	}
+	return newAttrIndex()
+}
+
+// get returns the index, building it when missing. Without an index,
+// every call builds one.
+func (x *attrIndex) get(build func() (attrValues, error)) (attrValues, error) {
+	if x == nil {
+		return build()
+	}
+	x.mu.Lock()
+	values, gen := x.values, x.gen
+	x.mu.Unlock()
+	if values != nil {
+		return values, nil
+	}
+
+	values, err := build()
+	if err != nil {
+		return nil, err
+	}
+	x.mu.Lock()
+	if x.gen == gen {
+		x.values = values
+	}
+	x.mu.Unlock()
+	return values, nil
+}
+
+// invalidate drops the index. Any change may add or remove sidecars or
+// the files they belong to, so the whole index goes.
+func (x *attrIndex) invalidate() {
+	if x == nil {
+		return
+	}
+	x.mu.Lock()
+	x.values = nil
+	x.gen++
+	x.mu.Unlock()
+}
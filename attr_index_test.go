@@ -0,0 +1,70 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestFindByAttr(t *testing.T) {
+	theme := &countingFS{MapFS: fstest.MapFS{
+		"views/home.html.meta":  &fstest.MapFile{Data: []byte(`{"status": "needs-review"}`)},
+		"views/ghost.html.meta": &fstest.MapFile{Data: []byte(`{"status": "needs-review"}`)},
+	}}
+	base := fstest.MapFS{
+		"views/home.html":       &fstest.MapFile{Data: []byte("home")},
+		"views/about.html":      &fstest.MapFile{Data: []byte("about")},
+		"views/about.html.meta": &fstest.MapFile{Data: []byte("status: needs-review\nrevision: 2\n")},
+		"views/blog.html":       &fstest.MapFile{Data: []byte("blog")},
+		"views/blog.html.meta":  &fstest.MapFile{Data: []byte("status: published\n")},
+	}
+	composite := cfs.NewWritableFS(cfs.NewMemLayer(), theme, base).WithAttrIndex()
+	find := func(key string, value any) []string {
+		t.Helper()
+		names, err := composite.FindByAttr(key, value)
+		if err != nil {
+			t.Fatalf("FindByAttr failed: %v", err)
+		}
+		return names
+	}
+
+	// sidecars without a file are left out
+	if got, want := find("status", "needs-review"), []string{"views/about.html", "views/home.html"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	if got := find("revision", 2); !reflect.DeepEqual(got, []string{"views/about.html"}) {
+		t.Fatalf("Expected numbers to match, got %v", got)
+	}
+	if got := find("owner", "design"); len(got) != 0 {
+		t.Fatalf("Expected no matches, got %v", got)
+	}
+
+	// later queries use the index
+	before := theme.calls.Load()
+	find("status", "published")
+	if calls := theme.calls.Load() - before; calls != 0 {
+		t.Fatalf("Expected the index to answer without reading layers, got %d calls", calls)
+	}
+
+	// changes through the composite are reflected
+	if err := composite.SetAttr("views/blog.html", "status", "needs-review"); err != nil {
+		t.Fatalf("SetAttr failed: %v", err)
+	}
+	if got := find("status", "needs-review"); len(got) != 3 {
+		t.Fatalf("Expected the new attribute to be found, got %v", got)
+	}
+	if got := find("status", "published"); len(got) != 0 {
+		t.Fatalf("Expected the overridden attribute to be gone, got %v", got)
+	}
+
+	// without the index every call reads the sidecars
+	plain := cfs.NewCompositeFS(theme, base)
+	names, err := plain.FindByAttr("status", "published")
+	if err != nil || !reflect.DeepEqual(names, []string{"views/blog.html"}) {
+		t.Fatalf("Expected the published view, got %v, %v", names, err)
+	}
+}
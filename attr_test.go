@@ -0,0 +1,55 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestGetAndSetAttr(t *testing.T) {
+	base := fstest.MapFS{
+		"views/home.html":      &fstest.MapFile{Data: []byte("home")},
+		"views/home.html.meta": &fstest.MapFile{Data: []byte("owner: design\nstatus: published\n")},
+	}
+	writer := cfs.NewMemLayer()
+	composite := cfs.NewWritableFS(writer, base)
+
+	if owner, err := composite.GetAttr("views/home.html", "owner"); err != nil || owner != "design" {
+		t.Fatalf("Expected the base attribute, got %v, %v", owner, err)
+	}
+	if _, err := composite.GetAttr("views/home.html", "reviewer"); !errors.Is(err, cfs.ErrNoAttr) {
+		t.Fatalf("Expected ErrNoAttr, got %v", err)
+	}
+
+	if err := composite.SetAttr("views/home.html", "status", "needs-review"); err != nil {
+		t.Fatalf("SetAttr failed: %v", err)
+	}
+	if err := composite.SetAttr("views/home.html", "revision", 2); err != nil {
+		t.Fatalf("SetAttr failed: %v", err)
+	}
+	meta, err := composite.SidecarMeta("views/home.html")
+	if err != nil {
+		t.Fatalf("SidecarMeta failed: %v", err)
+	}
+	if meta["owner"] != "design" || meta["status"] != "needs-review" || meta["revision"] != float64(2) {
+		t.Fatalf("Expected the attributes merged over the base sidecar, got %v", meta)
+	}
+
+	// only the sidecar lands in the write layer
+	if _, err := fs.Stat(writer, "views/home.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected the file not to be copied up, got %v", err)
+	}
+	testReadFile(t, writer, "views/home.html.meta", "{\n  \"revision\": 2,\n  \"status\": \"needs-review\"\n}\n")
+
+	if err := composite.SetAttr("views/missing.html", "status", "draft"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected ErrNotExist, got %v", err)
+	}
+	if err := cfs.NewCompositeFS(base).SetAttr("views/home.html", "status", "draft"); !errors.Is(err, cfs.ErrReadOnly) {
+		t.Fatalf("Expected ErrReadOnly, got %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package cfs
+
+import (
+	"hash/fnv"
+	"io/fs"
+	"math"
+	"path"
+	"sync/atomic"
+)
+
+// PathFilter is implemented by layers that can cheaply rule out paths
+// they do not contain. The composite skips such layers without any
+// other call when MayContain returns false.
+type PathFilter interface {
+	MayContain(name string) bool
+}
+
+// BloomLayer wraps a static layer with a Bloom filter of its path set,
+// so lookups for paths it does not contain are answered without
+// touching the underlying filesystem. Paths added to the wrapped
+// filesystem after the filter is built stay invisible until they are
+// reported through InvalidatePaths, which a Watcher does automatically.
+type BloomLayer struct {
+	fsys  fs.FS
+	bits  []atomic.Uint64
+	k     uint64
+	count atomic.Int64
+}
+
+// NewBloomLayer walks fsys and builds a Bloom filter of every path it
+// contains, sized for the given false positive rate (e.g. 0.01).
+func NewBloomLayer(fsys fs.FS, falsePositiveRate float64) (*BloomLayer, error) {
+	var paths []string
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.01
+	}
+
+	n := float64(len(paths))
+	if n < 1 {
+		n = 1
+	}
+	m := math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/n*math.Ln2))
+
+	b := &BloomLayer{
+		fsys: fsys,
+		bits: make([]atomic.Uint64, (uint64(m)+63)/64),
+		k:    uint64(k),
+	}
+	for _, p := range paths {
+		b.add(p)
+	}
+	b.count.Store(int64(len(paths)))
+	return b, nil
+}
+
+// Len returns the number of paths indexed by the filter.
+func (b *BloomLayer) Len() int {
+	return int(b.count.Load())
+}
+
+// MayContain reports whether the layer may contain name. A false
+// result is definitive.
+func (b *BloomLayer) MayContain(name string) bool {
+	h1, h2 := bloomHash(path.Clean(name))
+	size := uint64(len(b.bits)) * 64
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % size
+		if b.bits[bit/64].Load()&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *BloomLayer) add(name string) {
+	h1, h2 := bloomHash(name)
+	size := uint64(len(b.bits)) * 64
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % size
+		b.bits[bit/64].Or(1 << (bit % 64))
+	}
+}
+
+// InvalidatePaths implements Invalidator by adding the changed paths
+// and their parents to the filter. Removed paths stay in the filter,
+// which only costs a probe of the wrapped filesystem.
+func (b *BloomLayer) InvalidatePaths(names ...string) {
+	for _, name := range names {
+		for p := path.Clean(name); p != "."; p = path.Dir(p) {
+			if b.MayContain(p) {
+				continue
+			}
+			b.add(p)
+			b.count.Add(1)
+		}
+	}
+}
+
+func bloomHash(name string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	sum := h.Sum64()
+	h1 := sum & 0xffffffff
+	h2 := sum >> 32
+	if h2 == 0 {
+		h2 = 1
+	}
+	return h1, h2
+}
+
+// Open implements fs.FS.
+func (b *BloomLayer) Open(name string) (fs.File, error) {
+	if !b.MayContain(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return b.fsys.Open(name)
+}
+
+// Stat implements fs.StatFS.
+func (b *BloomLayer) Stat(name string) (fs.FileInfo, error) {
+	if !b.MayContain(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return statLayer(b.fsys, name)
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (b *BloomLayer) ReadFile(name string) ([]byte, error) {
+	if !b.MayContain(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return fs.ReadFile(b.fsys, name)
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (b *BloomLayer) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !b.MayContain(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return ReadDir(b.fsys, name)
+}
+
+// Sub returns the layer rooted at dir, still answered by the filter.
+func (b *BloomLayer) Sub(dir string) (fs.FS, error) {
+	return subLayer(b, dir)
+}
+
+// skipLayer reports whether fsys can be skipped for name without
+// probing it.
+func skipLayer(fsys fs.FS, name string) bool {
+	if filter, ok := fsys.(PathFilter); ok {
+		return !filter.MayContain(name)
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package cfs_test
+
+import (
+	"fmt"
+	"io/fs"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+// countingFS counts the calls that reach the wrapped filesystem.
+type countingFS struct {
+	fstest.MapFS
+	calls atomic.Int64
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	c.calls.Add(1)
+	return c.MapFS.Open(name)
+}
+
+func (c *countingFS) Stat(name string) (fs.FileInfo, error) {
+	c.calls.Add(1)
+	return c.MapFS.Stat(name)
+}
+
+func (c *countingFS) ReadFile(name string) ([]byte, error) {
+	c.calls.Add(1)
+	return c.MapFS.ReadFile(name)
+}
+
+func TestBloomLayerSkipsMisses(t *testing.T) {
+	static := fstest.MapFS{}
+	for i := 0; i < 500; i++ {
+		static[fmt.Sprintf("assets/file%03d.css", i)] = &fstest.MapFile{Data: []byte("css")}
+	}
+	counting := &countingFS{MapFS: static}
+
+	bloom, err := cfs.NewBloomLayer(counting, 0.001)
+	if err != nil {
+		t.Fatalf("NewBloomLayer failed: %v", err)
+	}
+	if bloom.Len() != 500+2 {
+		t.Fatalf("Expected 502 indexed paths, got %d", bloom.Len())
+	}
+
+	dev := fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("home")},
+	}
+	composite := cfs.NewCompositeFS(dev, bloom)
+
+	counting.calls.Store(0)
+	for i := 0; i < 100; i++ {
+		composite.Stat(fmt.Sprintf("views/missing%d.html", i))
+	}
+	if calls := counting.calls.Load(); calls > 5 {
+		t.Fatalf("Expected misses to skip the bloom layer, got %d underlying calls", calls)
+	}
+
+	testReadFile(t, composite, "assets/file042.css", "css")
+	testReadFile(t, composite, "views/home.html", "home")
+
+	if !bloom.MayContain("assets") || !bloom.MayContain("assets/file499.css") {
+		t.Fatal("Expected indexed paths to be reported as present")
+	}
+}
+
+func TestNamedBloomLayerIsSkipped(t *testing.T) {
+	counting := &countingFS{MapFS: fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("a")},
+	}}
+	bloom, err := cfs.NewBloomLayer(counting, 0.0001)
+	if err != nil {
+		t.Fatalf("NewBloomLayer failed: %v", err)
+	}
+
+	composite := cfs.NewCompositeFS(cfs.NewLayer("static", bloom))
+
+	counting.calls.Store(0)
+	composite.ReadFile("definitely/not/here.txt")
+	if calls := counting.calls.Load(); calls != 0 {
+		t.Fatalf("Expected no underlying calls, got %d", calls)
+	}
+}
+
+func TestBloomLayerSub(t *testing.T) {
+	counting := &countingFS{MapFS: fstest.MapFS{
+		"assets/app.css": &fstest.MapFile{Data: []byte("css")},
+	}}
+	layer, err := cfs.NewBloomLayer(counting, 0.01)
+	if err != nil {
+		t.Fatalf("NewBloomLayer failed: %v", err)
+	}
+
+	sub, err := cfs.NewCompositeFS(layer).Sub("assets")
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	testReadFile(t, sub, "app.css", "css")
+
+	// the filter still answers misses below the root
+	before := counting.calls.Load()
+	if _, err := fs.Stat(sub, "missing.css"); err == nil {
+		t.Fatal("Expected missing.css not to exist")
+	}
+	if got := counting.calls.Load(); got != before {
+		t.Fatalf("Expected the miss not to reach the layer, got %d calls", got-before)
+	}
+}
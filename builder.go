@@ -0,0 +1,64 @@
+package cfs
+
+import "io/fs"
+
+// Clone returns a copy of the composite with its own layer list. The
+// copy shares the write layer, hooks and journal with the original.
+func (cfs *CompositeFS) Clone() *CompositeFS {
+	c := cfs.clone()
+	c.filesystems = append([]fs.FS(nil), cfs.filesystems...)
+	return c
+}
+
+// WithLayerPrepended returns a copy of the composite with fsys added as
+// the highest priority layer. The original composite is not modified.
+func (cfs *CompositeFS) WithLayerPrepended(fsys fs.FS) *CompositeFS {
+	layers := make([]fs.FS, 0, len(cfs.filesystems)+1)
+	layers = append(layers, fsys)
+	layers = append(layers, cfs.filesystems...)
+	return cfs.withLayers(layers)
+}
+
+// WithLayerAppended returns a copy of the composite with fsys added as
+// the lowest priority layer. The original composite is not modified.
+func (cfs *CompositeFS) WithLayerAppended(fsys fs.FS) *CompositeFS {
+	layers := make([]fs.FS, 0, len(cfs.filesystems)+1)
+	layers = append(layers, cfs.filesystems...)
+	layers = append(layers, fsys)
+	return cfs.withLayers(layers)
+}
+
+// WithoutLayer returns a copy of the composite without the layers named
+// name. Removing the write layer makes the copy read-only.
+func (cfs *CompositeFS) WithoutLayer(name string) *CompositeFS {
+	layers := make([]fs.FS, 0, len(cfs.filesystems))
+	for _, fsys := range cfs.filesystems {
+		if LayerName(fsys) == name {
+			continue
+		}
+		layers = append(layers, fsys)
+	}
+	return cfs.withLayers(layers)
+}
+
+// withLayers returns a copy of the composite probing layers. Its probe
+// rules follow the layers they name to their new indexes.
+func (cfs *CompositeFS) withLayers(layers []fs.FS) *CompositeFS {
+	c := cfs.clone()
+	layers = c.flatten(layers)
+
+	if c.writer != nil {
+		found := false
+		for _, fsys := range layers {
+			if sameLayer(fsys, c.writer) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.writer = nil
+		}
+	}
+	c.setLayers(c.sortByPriority(layers))
+	return c
+}
@@ -0,0 +1,72 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestWithLayerPrependedDoesNotMutateOriginal(t *testing.T) {
+	base := fstest.MapFS{
+		"page.html": &fstest.MapFile{Data: []byte("live")},
+	}
+	preview := fstest.MapFS{
+		"page.html": &fstest.MapFile{Data: []byte("draft")},
+	}
+
+	live := cfs.NewCompositeFS(cfs.NewLayer("base", base))
+	draft := live.WithLayerPrepended(cfs.NewLayer("preview", preview))
+
+	testReadFile(t, draft, "page.html", "draft")
+	testReadFile(t, live, "page.html", "live")
+
+	if live.LayerCount() != 1 || draft.LayerCount() != 2 {
+		t.Fatalf("Unexpected layer counts: live=%d draft=%d", live.LayerCount(), draft.LayerCount())
+	}
+}
+
+func TestWithLayerAppended(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("top")},
+	})
+	fallback := composite.WithLayerAppended(fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("bottom")},
+		"b.txt": &fstest.MapFile{Data: []byte("bottom b")},
+	})
+
+	testReadFile(t, fallback, "a.txt", "top")
+	testReadFile(t, fallback, "b.txt", "bottom b")
+
+	if _, err := composite.Stat("b.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected original to be unchanged, got %v", err)
+	}
+}
+
+func TestWithoutLayer(t *testing.T) {
+	composite := cfs.NewCompositeFS(
+		cfs.NewLayer("dev", fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("dev")}}),
+		cfs.NewLayer("base", fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("base")}}),
+	)
+
+	testReadFile(t, composite.WithoutLayer("dev"), "a.txt", "base")
+	testReadFile(t, composite, "a.txt", "dev")
+}
+
+func TestBuilderKeepsWriteLayer(t *testing.T) {
+	writer := cfs.NewDirWriteFS(t.TempDir())
+	composite := cfs.NewWritableFS(writer).WithLayerPrepended(cfs.NewLayer("preview", fstest.MapFS{}))
+
+	if !composite.Writable() {
+		t.Fatal("Expected prepending a layer to keep the write layer")
+	}
+
+	clone := composite.Clone()
+	if clone.StackHash() != composite.StackHash() {
+		t.Fatal("Expected clone to have the same structure")
+	}
+}
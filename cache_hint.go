@@ -0,0 +1,83 @@
+package cfs
+
+import (
+	"embed"
+	"io/fs"
+	"slices"
+)
+
+// Cache-Control values commonly used as layer cache hints.
+const (
+	// CacheImmutable suits content that never changes for a build, such
+	// as embedded assets. It is the default hint of embed.FS layers.
+	CacheImmutable = "public, max-age=31536000, immutable"
+	// CacheNoCache makes clients revalidate on every request. It is the
+	// default hint of layers tagged with RoleDev.
+	CacheNoCache = "no-cache"
+)
+
+// CacheHintFS is implemented by layers that carry a default
+// Cache-Control value for the files they serve.
+type CacheHintFS interface {
+	fs.FS
+	CacheHint() string
+}
+
+// WithCacheHint sets the Cache-Control value HTTP adapters should send
+// for files served by the layer, overriding the defaults.
+func WithCacheHint(hint string) LayerOption {
+	return func(l *Layer) {
+		l.cacheHint = &hint
+	}
+}
+
+// CacheHint implements CacheHintFS. It returns the hint set with
+// WithCacheHint, CacheNoCache for layers tagged with RoleDev, or the
+// hint of the wrapped filesystem.
+func (l *Layer) CacheHint() string {
+	if l.cacheHint != nil {
+		return *l.cacheHint
+	}
+	if slices.Contains(l.roles, RoleDev) {
+		return CacheNoCache
+	}
+	return layerCacheHint(l.FS(), "")
+}
+
+// CacheHint returns the Cache-Control value of the layer serving the
+// winning version of name, so HTTP adapters can set headers depending on
+// which layer served the file. It is empty when the layer carries no
+// hint.
+func (cfs *CompositeFS) CacheHint(name string) (string, error) {
+	name, err := cfs.lookupName("cachehint", name)
+	if err != nil {
+		return "", err
+	}
+	if !cfs.visible(name) {
+		return "", &fs.PathError{Op: "cachehint", Path: name, Err: fs.ErrNotExist}
+	}
+
+	i, _, err := cfs.resolve(name)
+	if err != nil {
+		return "", err
+	}
+	return layerCacheHint(cfs.filesystems[i], name), nil
+}
+
+// layerCacheHint returns the hint of fsys for name, looking through
+// layer wrappers and into nested composites.
+func layerCacheHint(fsys fs.FS, name string) string {
+	switch v := fsys.(type) {
+	case CacheHintFS:
+		return v.CacheHint()
+	case *CompositeFS:
+		hint, _ := v.CacheHint(name)
+		return hint
+	case embed.FS, *embed.FS:
+		return CacheImmutable
+	}
+	if inner := wrappedLayers(fsys); len(inner) == 1 {
+		return layerCacheHint(inner[0], name)
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func newCacheHintStack() *cfs.CompositeFS {
+	dev := fstest.MapFS{"assets/app.css": &fstest.MapFile{Data: []byte("dev")}}
+	cdn := fstest.MapFS{"assets/logo.svg": &fstest.MapFile{Data: []byte("<svg/>")}}
+	return cfs.NewCompositeFS(
+		cfs.NewLayer("dev", dev, cfs.WithRoles(cfs.RoleDev)),
+		cfs.NewLayer("cdn", cdn, cfs.WithCacheHint("public, max-age=3600")),
+		cfs.NewLayer("embedded", embeddedFS),
+		fstest.MapFS{"robots.txt": &fstest.MapFile{Data: []byte("")}},
+	)
+}
+
+func TestCacheHint(t *testing.T) {
+	composite := newCacheHintStack()
+
+	for name, want := range map[string]string{
+		"assets/app.css":                    cfs.CacheNoCache,
+		"assets/logo.svg":                   "public, max-age=3600",
+		"testdata/embedded/views/home.html": cfs.CacheImmutable,
+		"robots.txt":                        "",
+	} {
+		hint, err := composite.CacheHint(name)
+		if err != nil {
+			t.Fatalf("CacheHint(%s) failed: %v", name, err)
+		}
+		if hint != want {
+			t.Errorf("Expected hint %q for %s, got %q", want, name, hint)
+		}
+	}
+
+	if _, err := composite.CacheHint("missing.txt"); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestListingHandlerSetsCacheControl(t *testing.T) {
+	handler := cfs.NewListingHandler(newCacheHintStack())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/assets/logo.svg", nil))
+	if got := rec.Header().Get("Cache-Control"); got != "public, max-age=3600" {
+		t.Errorf("Expected the cdn cache hint, got %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/robots.txt", nil))
+	if got := rec.Header().Get("Cache-Control"); got != "" {
+		t.Errorf("Expected no Cache-Control header, got %q", got)
+	}
+}
@@ -0,0 +1,121 @@
+package cfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+)
+
+// lifecycle tracks the operations running on a composite so Close can
+// wait for them, and the background work Shutdown stops. Copies of a
+// composite share it, since they share layers. Operations use atomics
+// only, so hot paths never contend on a lock.
+type lifecycle struct {
+	active   atomic.Int64
+	closed   atomic.Bool
+	idle     chan struct{} // closed once no operation runs after closing
+	idleOnce sync.Once
+
+	closeOnce sync.Once
+	closeErr  error
+
+	// mu guards the background work; file operations never take it.
+	mu       sync.Mutex
+	started  bool
+	stopWork func()
+	workDone chan struct{}
+	watchers map[*Watcher]struct{}
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{idle: make(chan struct{})}
+}
+
+// enter registers an operation, failing once the composite is closed.
+func (l *lifecycle) enter(op, name string) error {
+	if l == nil {
+		return nil
+	}
+	l.active.Add(1)
+	if l.closed.Load() {
+		l.leave()
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrClosed}
+	}
+	return nil
+}
+
+func (l *lifecycle) leave() {
+	if l == nil {
+		return
+	}
+	if l.active.Add(-1) == 0 && l.closed.Load() {
+		l.idleOnce.Do(func() { close(l.idle) })
+	}
+}
+
+// stop rejects new operations and returns a channel that is closed once
+// the running ones have finished.
+func (l *lifecycle) stop() <-chan struct{} {
+	l.closed.Store(true)
+	if l.active.Load() == 0 {
+		l.idleOnce.Do(func() { close(l.idle) })
+	}
+	return l.idle
+}
+
+// Close shuts the composite down: new operations fail with fs.ErrClosed,
+// and once the running ones have drained, every layer implementing
+// io.Closer is closed, such as zip readers, database handles or remote
+// clients, looking through the wrappers of this package. Copies of the
+// composite, including those made by the With* methods, Sub and a
+// StackFactory, share its layers and are closed with it. Files opened
+// before Close should be closed first, since their layer may not serve
+// them afterwards. Calling Close again returns the result of the first
+// call. Close is Shutdown without a deadline.
+func (cfs *CompositeFS) Close() error {
+	return cfs.Shutdown(context.Background())
+}
+
+func (cfs *CompositeFS) closeLayers() error {
+	var closed []fs.FS
+	var errs []error
+	for i, fsys := range cfs.filesystems {
+		for _, c := range layerClosers(fsys) {
+			if slicesContainsLayer(closed, c) {
+				continue
+			}
+			closed = append(closed, c)
+			if err := c.(io.Closer).Close(); err != nil {
+				errs = append(errs, fmt.Errorf("filesystem %d: %w", i, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// layerClosers returns the filesystems implementing io.Closer in fsys,
+// looking through wrappers. Closable wrappers, such as nested
+// composites, close what they wrap themselves.
+func layerClosers(fsys fs.FS) []fs.FS {
+	if _, ok := fsys.(io.Closer); ok {
+		return []fs.FS{fsys}
+	}
+	var closers []fs.FS
+	for _, inner := range wrappedLayers(fsys) {
+		closers = append(closers, layerClosers(inner)...)
+	}
+	return closers
+}
+
+func slicesContainsLayer(layers []fs.FS, fsys fs.FS) bool {
+	for _, l := range layers {
+		if sameLayer(l, fsys) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+// closableFS counts Close calls and can hold reads until released.
+type closableFS struct {
+	fstest.MapFS
+	closes  atomic.Int32
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (c *closableFS) ReadFile(name string) ([]byte, error) {
+	if c.release != nil {
+		c.entered <- struct{}{}
+		<-c.release
+	}
+	return c.MapFS.ReadFile(name)
+}
+
+func (c *closableFS) Close() error {
+	c.closes.Add(1)
+	return nil
+}
+
+func TestCloseDrainsAndClosesLayers(t *testing.T) {
+	slow := &closableFS{
+		MapFS:   fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("a")}},
+		entered: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	other := &closableFS{MapFS: fstest.MapFS{}}
+	composite := cfs.NewCompositeFS(cfs.NewLayer("slow", slow), other)
+	preview := composite.WithHideDotfiles()
+
+	readDone := make(chan error)
+	go func() {
+		_, err := composite.ReadFile("a.txt")
+		readDone <- err
+	}()
+	<-slow.entered
+
+	closeDone := make(chan error)
+	go func() {
+		closeDone <- preview.Close()
+	}()
+
+	// new operations are rejected while the running read drains
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, err := composite.Stat("a.txt")
+		if errors.Is(err, fs.ErrClosed) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected operations to fail after Close, got %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-closeDone:
+		t.Fatal("Expected Close to wait for the running read")
+	default:
+	}
+	if slow.closes.Load() != 0 {
+		t.Fatal("Expected layers to stay open while operations run")
+	}
+
+	close(slow.release)
+	if err := <-readDone; err != nil {
+		t.Fatalf("Expected the running read to finish, got %v", err)
+	}
+	if err := <-closeDone; err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if slow.closes.Load() != 1 || other.closes.Load() != 1 {
+		t.Fatalf("Expected each layer closed once, got %d and %d", slow.closes.Load(), other.closes.Load())
+	}
+
+	if err := composite.Close(); err != nil {
+		t.Fatalf("Second Close failed: %v", err)
+	}
+	if slow.closes.Load() != 1 {
+		t.Fatal("Expected layers not to be closed twice")
+	}
+	if _, err := composite.Open("a.txt"); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("Expected ErrClosed, got %v", err)
+	}
+}
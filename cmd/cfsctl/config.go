@@ -0,0 +1,270 @@
+//go:build !tinygo && !cfs_tiny
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+// stackConfig describes a composite, highest priority layer first. It
+// is read from a small subset of YAML:
+//
+//	hide_dotfiles: true
+//	symlink_protection: true
+//	layers:
+//	  - name: override
+//	    path: ./override
+//	    roles: [dev]
+//	  - path: ./theme.zip
+//	    pin: sha256:4f1c...
+//	  - path: ./base
+//
+// Layer paths are directories or archives, relative to the config file.
+// Pinned layers must match their pin, see cfs.WithPin.
+type stackConfig struct {
+	HideDotfiles      bool
+	SymlinkProtection bool
+	Layers            []layerConfig
+}
+
+type layerConfig struct {
+	Name     string
+	Path     string
+	Roles    []string
+	Uncached bool
+	Pin      string
+}
+
+func loadStackConfig(name string) (*stackConfig, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	config, err := parseStackConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	dir := filepath.Dir(name)
+	for i, layer := range config.Layers {
+		if !filepath.IsAbs(layer.Path) {
+			config.Layers[i].Path = filepath.Join(dir, layer.Path)
+		}
+	}
+	return config, nil
+}
+
+func parseStackConfig(data []byte) (*stackConfig, error) {
+	config := &stackConfig{}
+	inLayers := false
+	var layer *layerConfig
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := stripComment(scanner.Text())
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indented := line[0] == ' ' || line[0] == '\t'
+		line = strings.TrimSpace(line)
+
+		if !indented {
+			key, value, err := splitKey(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			inLayers = false
+			switch key {
+			case "layers":
+				if value != "" {
+					return nil, fmt.Errorf("line %d: layers must be a list", n)
+				}
+				inLayers = true
+			case "hide_dotfiles":
+				config.HideDotfiles, err = strconv.ParseBool(value)
+			case "symlink_protection":
+				config.SymlinkProtection, err = strconv.ParseBool(value)
+			default:
+				err = fmt.Errorf("unknown key %q", key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			continue
+		}
+
+		if !inLayers {
+			return nil, fmt.Errorf("line %d: unexpected indentation", n)
+		}
+		if rest, ok := strings.CutPrefix(line, "-"); ok {
+			config.Layers = append(config.Layers, layerConfig{})
+			layer = &config.Layers[len(config.Layers)-1]
+			line = strings.TrimSpace(rest)
+			if line == "" {
+				continue
+			}
+		}
+		if layer == nil {
+			return nil, fmt.Errorf("line %d: expected a list item", n)
+		}
+
+		key, value, err := splitKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		switch key {
+		case "name":
+			layer.Name = value
+		case "path":
+			layer.Path = value
+		case "roles":
+			layer.Roles, err = parseList(value)
+		case "uncached":
+			layer.Uncached, err = strconv.ParseBool(value)
+		case "pin":
+			layer.Pin = value
+		default:
+			err = fmt.Errorf("unknown layer key %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(config.Layers) == 0 {
+		return nil, fmt.Errorf("no layers configured")
+	}
+	for i, layer := range config.Layers {
+		if layer.Path == "" {
+			return nil, fmt.Errorf("layer %d: missing path", i)
+		}
+	}
+	return config, nil
+}
+
+// build opens the configured layers and stacks them.
+func (c *stackConfig) build() (*cfs.CompositeFS, error) {
+	layers := make([]fs.FS, 0, len(c.Layers))
+	for _, lc := range c.Layers {
+		layer, err := openLayer(lc)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+
+	composite := cfs.NewCompositeFS(layers...)
+	if c.HideDotfiles {
+		composite = composite.WithHideDotfiles()
+	}
+	if c.SymlinkProtection {
+		composite = composite.WithSymlinkProtection()
+	}
+	if err := composite.Verify(cfs.Policy{}); err != nil {
+		return nil, err
+	}
+	return composite, nil
+}
+
+func openLayer(lc layerConfig) (fs.FS, error) {
+	info, err := os.Stat(lc.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fsys fs.FS
+	name := filepath.Base(lc.Path)
+	if info.IsDir() {
+		fsys = os.DirFS(lc.Path)
+	} else {
+		// DiscoverLayers opens archives by name, the pattern matches
+		// only this file
+		found, err := cfs.DiscoverLayers(filepath.Dir(lc.Path), escapePattern(name))
+		if err != nil {
+			return nil, err
+		}
+		if len(found) != 1 {
+			return nil, fmt.Errorf("%s: not a directory or supported archive", lc.Path)
+		}
+		fsys = found[0]
+		name = cfs.LayerName(fsys)
+	}
+
+	if lc.Name != "" {
+		name = lc.Name
+	}
+	var opts []cfs.LayerOption
+	if lc.Uncached {
+		opts = append(opts, cfs.Uncached())
+	}
+	if len(lc.Roles) > 0 {
+		opts = append(opts, cfs.WithRoles(lc.Roles...))
+	}
+	if lc.Pin != "" {
+		opts = append(opts, cfs.WithPin(lc.Pin))
+	}
+	return cfs.NewLayer(name, fsys, opts...), nil
+}
+
+func stripComment(line string) string {
+	if i := strings.Index(line, "#"); i >= 0 && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+		line = line[:i]
+	}
+	return strings.TrimRight(line, " \t\r")
+}
+
+func splitKey(line string) (string, string, error) {
+	key, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", "", fmt.Errorf("expected key: value, got %q", line)
+	}
+	return strings.TrimSpace(key), unquote(strings.TrimSpace(value)), nil
+}
+
+func parseList(value string) ([]string, error) {
+	inner, ok := strings.CutPrefix(value, "[")
+	if !ok {
+		return []string{value}, nil
+	}
+	inner, ok = strings.CutSuffix(inner, "]")
+	if !ok {
+		return nil, fmt.Errorf("unterminated list %q", value)
+	}
+	var out []string
+	for _, item := range strings.Split(inner, ",") {
+		if item = unquote(strings.TrimSpace(item)); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+func escapePattern(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,119 @@
+//go:build !tinygo && !cfs_tiny
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestParseStackConfig(t *testing.T) {
+	config, err := parseStackConfig([]byte(`# preview stack
+hide_dotfiles: true
+layers:
+  - name: override   # local edits
+    path: ./override
+    roles: [dev, "theme"]
+    uncached: true
+  - path: './theme.zip'
+    pin: sha256:abc
+  -
+    path: base
+`))
+	if err != nil {
+		t.Fatalf("parseStackConfig failed: %v", err)
+	}
+
+	expected := &stackConfig{
+		HideDotfiles: true,
+		Layers: []layerConfig{
+			{Name: "override", Path: "./override", Roles: []string{"dev", "theme"}, Uncached: true},
+			{Path: "./theme.zip", Pin: "sha256:abc"},
+			{Path: "base"},
+		},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, config)
+	}
+}
+
+func TestParseStackConfigErrors(t *testing.T) {
+	for name, input := range map[string]string{
+		"unknown key":   "colour: blue\nlayers:\n  - path: a\n",
+		"no layers":     "hide_dotfiles: true\n",
+		"missing path":  "layers:\n  - name: a\n",
+		"bad bool":      "hide_dotfiles: maybe\nlayers:\n  - path: a\n",
+		"stray indent":  "  path: a\n",
+		"unterminated":  "layers:\n  - path: a\n    roles: [dev\n",
+		"layers scalar": "layers: a\n",
+	} {
+		if _, err := parseStackConfig([]byte(input)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadStackConfigBuildsStack(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"override/index.html": "override",
+		"base/index.html":     "base",
+		"base/app.css":        "css",
+	} {
+		full := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(dir, "stack.yaml")
+	if err := os.WriteFile(configPath, []byte("layers:\n  - path: override\n  - path: base\n    name: defaults\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := loadStackConfig(configPath)
+	if err != nil {
+		t.Fatalf("loadStackConfig failed: %v", err)
+	}
+	composite, err := config.build()
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	data, err := composite.ReadFile("index.html")
+	if err != nil || string(data) != "override" {
+		t.Fatalf("Expected override index, got %q (%v)", data, err)
+	}
+	m, err := composite.Which("app.css")
+	if err != nil || m.LayerName != "defaults" {
+		t.Fatalf("Expected app.css from defaults, got %+v (%v)", m, err)
+	}
+}
+
+func TestBuildVerifiesPins(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("base"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := cfs.LayerDigest(os.DirFS(dir))
+	if err != nil {
+		t.Fatalf("LayerDigest failed: %v", err)
+	}
+
+	pinned := &stackConfig{Layers: []layerConfig{{Path: dir, Pin: digest}}}
+	if _, err := pinned.build(); err != nil {
+		t.Fatalf("Expected matching pin to build, got %v", err)
+	}
+
+	stale := &stackConfig{Layers: []layerConfig{{Path: dir, Pin: "sha256:0000"}}}
+	if _, err := stale.build(); !errors.Is(err, cfs.ErrPolicyViolation) {
+		t.Fatalf("Expected pin mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+//go:build !tinygo && !cfs_tiny
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// manifestFile is one file of a generated manifest.
+type manifestFile struct {
+	ident string
+	path  string
+	size  int64
+	hash  string
+}
+
+// gen writes a Go file describing every file of the stack, meant to be
+// run from a go:generate directive:
+//
+//	//go:generate cfsctl gen --config stack.yaml --package views --out manifest_gen.go
+func gen(args []string) error {
+	flags := flag.NewFlagSet("gen", flag.ExitOnError)
+	configPath := flags.String("config", "stack.yaml", "stack config file")
+	pkg := flags.String("package", "", "package name of the generated file (default $GOPACKAGE)")
+	out := flags.String("out", "cfs_manifest_gen.go", "output file")
+	prefix := flags.String("prefix", "Path", "prefix of the generated path constants")
+	flags.Parse(args)
+
+	if *pkg == "" {
+		*pkg = os.Getenv("GOPACKAGE")
+	}
+	if *pkg == "" {
+		return fmt.Errorf("gen: --package is required outside of go generate")
+	}
+	if *prefix == "" || !unicode.IsUpper([]rune(*prefix)[0]) {
+		return fmt.Errorf("gen: --prefix must start with an upper case letter")
+	}
+
+	config, err := loadStackConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	composite, err := config.build()
+	if err != nil {
+		return err
+	}
+
+	files, err := collectManifest(composite, *prefix)
+	if err != nil {
+		return err
+	}
+	src, err := renderManifest(*pkg, *configPath, files)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(*out, src, 0o644)
+}
+
+// collectManifest hashes every file of fsys in lexical order.
+func collectManifest(fsys fs.FS, prefix string) ([]manifestFile, error) {
+	var files []manifestFile
+	used := make(map[string]int)
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+
+		ident := prefix + identifier(p)
+		if n := used[ident]; n > 0 {
+			used[ident]++
+			ident += strconv.Itoa(n + 1)
+		} else {
+			used[ident] = 1
+		}
+		files = append(files, manifestFile{
+			ident: ident,
+			path:  p,
+			size:  int64(len(data)),
+			hash:  hex.EncodeToString(sum[:]),
+		})
+		return nil
+	})
+	return files, err
+}
+
+func renderManifest(pkg, config string, files []manifestFile) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by cfsctl gen from %s; DO NOT EDIT.\n\n", config)
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+
+	b.WriteString("// Paths of the files exposed by the stack. Referencing a constant fails\n")
+	b.WriteString("// to compile once the file is removed from the stack.\n")
+	b.WriteString("const (\n")
+	for _, f := range files {
+		fmt.Fprintf(&b, "%s = %q\n", f.ident, f.path)
+	}
+	b.WriteString(")\n\n")
+
+	b.WriteString("// ManifestEntry describes one file of the stack.\n")
+	b.WriteString("type ManifestEntry struct {\nPath string\nSize int64\nSHA256 string\n}\n\n")
+	b.WriteString("// Manifest lists the files exposed by the stack, sorted by path.\n")
+	b.WriteString("var Manifest = []ManifestEntry{\n")
+	for _, f := range files {
+		fmt.Fprintf(&b, "{Path: %s, Size: %d, SHA256: %q},\n", f.ident, f.size, f.hash)
+	}
+	b.WriteString("}\n")
+
+	return format.Source(b.Bytes())
+}
+
+// identifier turns a path into an exported Go identifier suffix, e.g.
+// "views/home.html" becomes "ViewsHomeHTML".
+func identifier(p string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(p, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		switch lower := strings.ToLower(part); lower {
+		case "html", "css", "js", "json", "xml", "svg", "txt", "md", "yaml", "yml", "toml", "id", "url", "api":
+			b.WriteString(strings.ToUpper(part))
+		default:
+			runes := []rune(part)
+			runes[0] = unicode.ToUpper(runes[0])
+			b.WriteString(string(runes))
+		}
+	}
+	if b.Len() == 0 {
+		return "File"
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+//go:build !tinygo && !cfs_tiny
+
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestIdentifier(t *testing.T) {
+	for path, want := range map[string]string{
+		"views/home.html":        "ViewsHomeHTML",
+		"assets/app-main.min.js": "AssetsAppMainMinJS",
+		"2024/report.pdf":        "2024ReportPdf",
+		"---":                    "File",
+	} {
+		if got := identifier(path); got != want {
+			t.Errorf("identifier(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestRenderManifest(t *testing.T) {
+	files, err := collectManifest(fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("home")},
+		"views/home-html": &fstest.MapFile{Data: []byte("clash")},
+		"assets/app.css":  &fstest.MapFile{Data: []byte("css")},
+	}, "Path")
+	if err != nil {
+		t.Fatalf("collectManifest failed: %v", err)
+	}
+	// "views/home-html" sorts first and keeps the plain identifier
+	if len(files) != 3 || files[0].path != "assets/app.css" || files[1].ident != "PathViewsHomeHTML" || files[2].ident != "PathViewsHomeHTML2" {
+		t.Fatalf("Unexpected manifest %+v", files)
+	}
+
+	src, err := renderManifest("views", "stack.yaml", files)
+	if err != nil {
+		t.Fatalf("renderManifest failed: %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "manifest_gen.go", src, 0); err != nil {
+		t.Fatalf("Generated code does not parse: %v\n%s", err, src)
+	}
+	for _, want := range []string{
+		"// Code generated by cfsctl gen from stack.yaml; DO NOT EDIT.",
+		`PathAssetsAppCSS   = "assets/app.css"`,
+		// sha256 of "home"
+		`{Path: PathViewsHomeHTML2, Size: 4, SHA256: "4ea140588150773ce3aace786aeef7f4049ce100fa649c94fbbddb960f1da942"}`,
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("Expected generated code to contain %q\n%s", want, src)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+//go:build !tinygo && !cfs_tiny
+
+// Command cfsctl inspects and serves composite filesystem stacks
+// described by a stack config file.
+//
+// Usage:
+//
+//	cfsctl serve [--config stack.yaml] [--addr :8080] [--poll 500ms]
+//	cfsctl gen [--config stack.yaml] [--package name] [--out file] [--prefix Path]
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `usage: cfsctl <command> [flags]
+
+commands:
+  serve    serve the merged view over HTTP
+  gen      generate a Go manifest of the files in the stack
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "serve":
+		err = serve(os.Args[2:])
+	case "gen":
+		err = gen(os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "cfsctl: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cfsctl: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,101 @@
+//go:build !tinygo && !cfs_tiny
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	configPath := flags.String("config", "stack.yaml", "stack config file")
+	addr := flags.String("addr", ":8080", "address to listen on")
+	poll := flags.Duration("poll", 500*time.Millisecond, "interval between change scans, 0 disables live reload")
+	flags.Parse(args)
+
+	config, err := loadStackConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	composite, err := config.build()
+	if err != nil {
+		return err
+	}
+
+	for _, finding := range composite.AnalyzeStack().Findings {
+		fmt.Printf("cfsctl: %s\n", finding)
+	}
+
+	var watcher *cfs.Watcher
+	if *poll > 0 {
+		watcher = composite.Watch(*poll)
+		defer watcher.Close()
+	}
+
+	fmt.Printf("cfsctl: serving %d layers on %s\n", composite.LayerCount(), *addr)
+	if watcher != nil {
+		fmt.Println(`cfsctl: add <script src="/.cfs/livereload?script"></script> to pages for live reload`)
+	}
+	return http.ListenAndServe(*addr, newServeHandler(composite, watcher))
+}
+
+// newServeHandler serves the composite with directory listings, plus
+// the debug endpoints under /.cfs/. The watcher may be nil.
+func newServeHandler(composite *cfs.CompositeFS, watcher *cfs.Watcher) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", cfs.NewListingHandler(composite))
+	mux.HandleFunc("/.cfs/which", func(w http.ResponseWriter, r *http.Request) {
+		which(composite, w, r)
+	})
+	mux.HandleFunc("/.cfs/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(composite.Status())
+	})
+	if watcher != nil {
+		mux.Handle("/.cfs/livereload", cfs.NewLiveReloadHandler(watcher))
+	}
+	return mux
+}
+
+// whichResponse is the JSON form of cfs.Match.
+type whichResponse struct {
+	Path      string `json:"path"`
+	Layer     int    `json:"layer"`
+	LayerName string `json:"layer_name,omitempty"`
+	Shadows   []int  `json:"shadows,omitempty"`
+}
+
+func which(composite *cfs.CompositeFS, w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Query().Get("path"), "/")
+	if name == "" {
+		http.Error(w, "missing path parameter", http.StatusBadRequest)
+		return
+	}
+
+	m, err := composite.Which(name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(whichResponse{
+		Path:      m.Path,
+		Layer:     m.Layer,
+		LayerName: m.LayerName,
+		Shadows:   m.Shadows,
+	})
+}
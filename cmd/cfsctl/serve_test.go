@@ -0,0 +1,66 @@
+//go:build !tinygo && !cfs_tiny
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestServeWhichEndpoint(t *testing.T) {
+	composite := cfs.NewCompositeFS(
+		cfs.NewLayer("theme", fstest.MapFS{"index.html": &fstest.MapFile{Data: []byte("theme")}}),
+		cfs.NewLayer("base", fstest.MapFS{"index.html": &fstest.MapFile{Data: []byte("base")}}),
+	)
+	handler := newServeHandler(composite, nil)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.cfs/which?path=/index.html", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var got whichResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if got.Layer != 0 || got.LayerName != "theme" || len(got.Shadows) != 1 || got.Shadows[0] != 1 {
+		t.Fatalf("Unexpected response %+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.cfs/which?path=missing.html", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "theme" {
+		t.Fatalf("Expected the merged file, got %d %q", rec.Code, rec.Body)
+	}
+}
+
+func TestServeStatusEndpoint(t *testing.T) {
+	composite := cfs.NewCompositeFS(
+		cfs.NewLayer("theme", fstest.MapFS{"index.html": &fstest.MapFile{Data: []byte("theme")}}),
+	)
+	handler := newServeHandler(composite, nil)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.cfs/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var got cfs.StackStatus
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if !got.Healthy || len(got.Layers) != 1 || got.Layers[0].Name != "theme" {
+		t.Fatalf("Unexpected status %+v", got)
+	}
+}
@@ -0,0 +1,142 @@
+package cfs
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// CompactWriteLayer removes the files of the write layer whose content
+// is identical, by SHA-256, to the version the layers below it provide,
+// so the override directory only keeps true customizations. Modes and
+// times are not compared. Directories left empty by the removals are
+// removed as well; directories that were already empty are kept. It
+// returns the removed files in lexical order.
+func (cfs *CompositeFS) CompactWriteLayer() ([]string, error) {
+	if cfs.writer == nil {
+		return nil, &fs.PathError{Op: "compact", Path: ".", Err: ErrReadOnly}
+	}
+	writerIndex := -1
+	for i, fsys := range cfs.filesystems {
+		if sameLayer(fsys, cfs.writer) {
+			writerIndex = i
+			break
+		}
+	}
+
+	var redundant []string
+	err := fs.WalkDir(cfs.writer, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		lowerSum, ok, err := cfs.parentSum(name, writerIndex)
+		if err != nil || !ok {
+			return err
+		}
+		sum, err := layerSum(cfs.writer, name)
+		if err != nil {
+			return err
+		}
+		if sum == lowerSum {
+			redundant = append(redundant, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	parents := make(map[string]bool)
+	for _, name := range redundant {
+		defer cfs.record("compact", name)()
+		if err := cfs.writer.Remove(name); err != nil {
+			return removed, err
+		}
+		removed = append(removed, name)
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			parents[dir] = true
+		}
+	}
+
+	// deepest first, so emptied parents of emptied directories go too
+	dirs := make([]string, 0, len(parents))
+	for dir := range parents {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		di, dj := strings.Count(dirs[i], "/"), strings.Count(dirs[j], "/")
+		if di != dj {
+			return di > dj
+		}
+		return dirs[i] < dirs[j]
+	})
+	for _, dir := range dirs {
+		entries, err := fs.ReadDir(cfs.writer, dir)
+		if err != nil || len(entries) > 0 {
+			continue
+		}
+		defer cfs.record("compact", dir)()
+		if err := cfs.writer.Remove(dir); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// parentSum returns the SHA-256 of the version of name below the layer
+// at index layer. It reports false when there is no such version or it
+// is not a regular file.
+func (cfs *CompositeFS) parentSum(name string, layer int) (string, bool, error) {
+	lower, err := cfs.OpenParent(name, layer)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	defer lower.Close()
+
+	info, err := lower.Stat()
+	if err != nil {
+		return "", false, err
+	}
+	if !info.Mode().IsRegular() {
+		return "", false, nil
+	}
+	sum, err := hashStream(sha256.New(), lower, name)
+	if err != nil {
+		return "", false, err
+	}
+	return sum, true, nil
+}
+
+// layerSum returns the SHA-256 of the file name in fsys.
+func layerSum(fsys fs.FS, name string) (string, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return hashStream(sha256.New(), file, name)
+}
+
+// topLayer returns the layer customizations are made in, the write
+// layer when there is one and the first layer otherwise, with its index.
+// The composite must have at least one layer.
+func (cfs *CompositeFS) topLayer() (fs.FS, int) {
+	if cfs.writer != nil {
+		for i, fsys := range cfs.filesystems {
+			if sameLayer(fsys, cfs.writer) {
+				return cfs.writer, i
+			}
+		}
+	}
+	return cfs.filesystems[0], 0
+}
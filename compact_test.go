@@ -0,0 +1,68 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestCompactWriteLayerRemovesUnchangedCopies(t *testing.T) {
+	base := fstest.MapFS{
+		"views/home.html":       &fstest.MapFile{Data: []byte("home")},
+		"views/about.html":      &fstest.MapFile{Data: []byte("about")},
+		"views/partials/a.html": &fstest.MapFile{Data: []byte("a")},
+	}
+	writer := cfs.NewMemLayer()
+	composite := cfs.NewWritableFS(writer, base)
+
+	writes := map[string]string{
+		"views/home.html":       "home",
+		"views/about.html":      "custom about",
+		"views/partials/a.html": "a",
+		"views/extra.html":      "extra",
+	}
+	for name, data := range writes {
+		if err := composite.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatalf("WriteFile %s failed: %v", name, err)
+		}
+	}
+	if err := composite.MkdirAll("drafts", 0o755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+
+	removed, err := composite.CompactWriteLayer()
+	if err != nil {
+		t.Fatalf("CompactWriteLayer failed: %v", err)
+	}
+	want := []string{"views/home.html", "views/partials/a.html"}
+	if !slices.Equal(removed, want) {
+		t.Fatalf("Expected removed %v, got %v", want, removed)
+	}
+
+	testReadFile(t, writer, "views/about.html", "custom about")
+	testReadFile(t, writer, "views/extra.html", "extra")
+	if _, err := writer.Stat("views/partials"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected emptied directory to be removed, got %v", err)
+	}
+	if _, err := writer.Stat("drafts"); err != nil {
+		t.Fatalf("Expected directory that was already empty to be kept, got %v", err)
+	}
+
+	// the composite still serves the same content
+	testReadFile(t, composite, "views/home.html", "home")
+	testReadFile(t, composite, "views/partials/a.html", "a")
+	testReadFile(t, composite, "views/about.html", "custom about")
+}
+
+func TestCompactWriteLayerWithoutWriteLayer(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{})
+	if _, err := composite.CompactWriteLayer(); !errors.Is(err, cfs.ErrReadOnly) {
+		t.Fatalf("Expected ErrReadOnly, got %v", err)
+	}
+}
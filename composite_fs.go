@@ -1,11 +1,14 @@
 package cfs
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"path"
+	"sort"
+	"sync"
 	"time"
 )
 
@@ -16,6 +19,34 @@ type CompositeFS struct {
 	filesystems []fs.FS
 	bestEffort  bool
 	mergeDirs   bool
+	writer      WriteFS
+	onCopyUp    func(CopyUpEvent)
+	journal     *journal
+	visibility  visibility
+	limits      Limits
+	statCache   *statCache
+	mimeTypes   map[string]string
+	aliases     map[string]string
+	links       *linkTable
+	archives    bool
+	byPriority  bool
+	jailOS      bool
+	eagerInfo   bool
+	transforms  map[string]layerTransform
+	frontMatter []string
+	permissions PermissionPolicy
+	budget      *ErrorBudget
+	dirMode     fs.FileMode
+	plainFiles  bool
+	probeRules  []probeRule
+	selector    func(ctx context.Context) []int
+	views       *sync.Map
+	reads       *readGroup
+	content     *contentCache
+	copyBuffer  int
+	unsorted    bool
+	attrs       *attrIndex
+	life        *lifecycle
 }
 
 // NewCompositeFS creates a new CompositeFS with the given filesystems.
@@ -37,30 +68,87 @@ func NewOverlayFS(filesystems ...fs.FS) *CompositeFS {
 }
 
 func newCompositeFS(bestEffort bool, mergeDirs bool, filesystems ...fs.FS) *CompositeFS {
-	fsList := make([]fs.FS, len(filesystems))
-	copy(fsList, filesystems)
-	return &CompositeFS{
-		filesystems: fsList,
-		bestEffort:  bestEffort,
-		mergeDirs:   mergeDirs,
+	cfs := &CompositeFS{
+		bestEffort: bestEffort,
+		mergeDirs:  mergeDirs,
+		links:      newLinkTable(),
+		life:       newLifecycle(),
 	}
+	cfs.filesystems = cfs.flatten(filesystems)
+	return cfs
+}
+
+// LayerCount returns the number of layers probed by the composite,
+// after nested composites have been flattened and duplicates removed.
+func (cfs *CompositeFS) LayerCount() int {
+	return len(cfs.filesystems)
+}
+
+// clone returns a shallow copy of the composite. Layer slices are
+// shared, so callers must not mutate them in place. Cached results are
+// not carried over.
+func (cfs *CompositeFS) clone() *CompositeFS {
+	c := *cfs
+	c.statCache = cfs.statCache.fresh()
+	c.reads = cfs.reads.fresh()
+	c.content = cfs.content.fresh()
+	c.attrs = cfs.attrs.fresh()
+	if cfs.views != nil {
+		// views are built from the layers of this composite only
+		c.views = new(sync.Map)
+	}
+	return &c
 }
 
 // Open implements fs.FS.Open by trying each underlying filesystem in order.
 func (cfs *CompositeFS) Open(name string) (fs.File, error) {
-	name = path.Clean(name)
+	if err := cfs.life.enter("open", name); err != nil {
+		return nil, err
+	}
+	defer cfs.life.leave()
+
+	name, err := cfs.lookupName("open", name)
+	if err != nil {
+		return nil, err
+	}
 
+	if !cfs.visible(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	var file fs.File
 	if cfs.mergeDirs {
-		return cfs.openOverlay(name)
+		file, err = cfs.openOverlay(name)
+	} else {
+		file, err = cfs.openFirst(name)
+	}
+	if err != nil {
+		return nil, err
 	}
+	return cfs.wrapDir(name, file)
+}
 
+func (cfs *CompositeFS) openFirst(name string) (fs.File, error) {
+	return cfs.openFrom(name, 0)
+}
+
+// openFrom opens name from the first filesystem probed at or after
+// position start of the probe order.
+func (cfs *CompositeFS) openFrom(name string, start int) (fs.File, error) {
 	var errs []error
 	allNotExist := true
 
-	for i, fsys := range cfs.filesystems {
+	order := cfs.probeOrder(name)
+	for n := start; n < len(cfs.filesystems); n++ {
+		i := layerAt(order, n)
+		fsys := cfs.filesystems[i]
+		if skipLayer(fsys, name) {
+			continue
+		}
+
 		file, err := fsys.Open(name)
 		if err == nil {
-			return file, nil
+			return cfs.layerFile(i, fsys, name, file), nil
 		}
 
 		if errors.Is(err, fs.ErrNotExist) {
@@ -70,7 +158,7 @@ func (cfs *CompositeFS) Open(name string) (fs.File, error) {
 
 		allNotExist = false
 		wrapped := fmt.Errorf("filesystem %d: %w", i, err)
-		if !cfs.bestEffort {
+		if cfs.stopOn(i, name, err) {
 			return nil, wrapped
 		}
 		errs = append(errs, wrapped)
@@ -83,12 +171,18 @@ func (cfs *CompositeFS) openOverlay(name string) (fs.File, error) {
 	var errs []error
 	allNotExist := true
 	var foundDir bool
-	var dirInfo fs.FileInfo
-	var entries []fs.DirEntry
-	var seen map[string]struct{}
+	var dirInfos []fs.FileInfo
+	var merger *entryMerger
 	var foundAnyDirRead bool
 
-	for i, fsys := range cfs.filesystems {
+	order := cfs.probeOrder(name)
+	for n := range cfs.filesystems {
+		i := layerAt(order, n)
+		fsys := cfs.filesystems[i]
+		if skipLayer(fsys, name) {
+			continue
+		}
+
 		file, err := fsys.Open(name)
 		if err == nil {
 			info, statErr := file.Stat()
@@ -101,7 +195,7 @@ func (cfs *CompositeFS) openOverlay(name string) (fs.File, error) {
 
 				allNotExist = false
 				wrapped := fmt.Errorf("filesystem %d: %w", i, statErr)
-				if !cfs.bestEffort {
+				if cfs.stopOn(i, name, statErr) {
 					return nil, wrapped
 				}
 				errs = append(errs, wrapped)
@@ -109,25 +203,26 @@ func (cfs *CompositeFS) openOverlay(name string) (fs.File, error) {
 			}
 
 			if info.IsDir() {
-				foundDir = true
-				if dirInfo == nil {
-					dirInfo = info
+				if err := cfs.checkDepth("open", name); err != nil {
+					file.Close()
+					return nil, err
 				}
+				foundDir = true
 				file.Close()
 
 				dirEntries, err := ReadDir(fsys, name)
 				if err == nil {
 					foundAnyDirRead = true
+					dirInfos = append(dirInfos, info)
 					allNotExist = false
-					if seen == nil {
-						seen = make(map[string]struct{})
+					if merger == nil {
+						merger = cfs.newEntryMerger(name)
 					}
 					for _, entry := range dirEntries {
-						if _, exists := seen[entry.Name()]; exists {
-							continue
-						}
-						seen[entry.Name()] = struct{}{}
-						entries = append(entries, entry)
+						merger.add(entry, cfs.mergeEntry)
+					}
+					if err := cfs.checkEntries("open", name, merger.len()); err != nil {
+						return nil, err
 					}
 					continue
 				}
@@ -139,7 +234,7 @@ func (cfs *CompositeFS) openOverlay(name string) (fs.File, error) {
 
 				allNotExist = false
 				wrapped := fmt.Errorf("filesystem %d: %w", i, err)
-				if !cfs.bestEffort {
+				if cfs.stopOn(i, name, err) {
 					return nil, wrapped
 				}
 				errs = append(errs, wrapped)
@@ -152,7 +247,7 @@ func (cfs *CompositeFS) openOverlay(name string) (fs.File, error) {
 				continue
 			}
 
-			return file, nil
+			return cfs.layerFile(i, fsys, name, file), nil
 		}
 
 		if errors.Is(err, fs.ErrNotExist) {
@@ -162,17 +257,21 @@ func (cfs *CompositeFS) openOverlay(name string) (fs.File, error) {
 
 		allNotExist = false
 		wrapped := fmt.Errorf("filesystem %d: %w", i, err)
-		if !cfs.bestEffort {
+		if cfs.stopOn(i, name, err) {
 			return nil, wrapped
 		}
 		errs = append(errs, wrapped)
 	}
 
 	if foundAnyDirRead {
+		// virtual links shadow layer entries of the same name, as in ReadDir
+		for _, entry := range cfs.linkEntries(name) {
+			merger.set(entry)
+		}
 		return &overlayDirFile{
 			name:    name,
-			info:    dirInfo,
-			entries: entries,
+			info:    cfs.mergeDirInfo(dirInfos),
+			entries: cfs.mergedEntries(name, merger),
 		}, nil
 	}
 
@@ -185,24 +284,47 @@ func (cfs *CompositeFS) openOverlay(name string) (fs.File, error) {
 
 // ReadDir returns the merged contents of the named directory across all filesystems.
 func (cfs *CompositeFS) ReadDir(name string) ([]fs.DirEntry, error) {
-	name = path.Clean(name)
+	if err := cfs.life.enter("readdir", name); err != nil {
+		return nil, err
+	}
+	defer cfs.life.leave()
+
+	name, err := cfs.lookupName("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfs.visible(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	if err := cfs.checkDepth("readdir", name); err != nil {
+		return nil, err
+	}
 
 	// we merge directory entries from all filesystems
-	var allEntries = make(map[string]fs.DirEntry)
+	merger := cfs.newEntryMerger(name)
 	var foundAny bool
 	var errs []error
 	allNotExist := true
 
-	for i, fsys := range cfs.filesystems {
+	order := cfs.probeOrder(name)
+	for n := range cfs.filesystems {
+		i := layerAt(order, n)
+		fsys := cfs.filesystems[i]
+		if skipLayer(fsys, name) {
+			continue
+		}
+
 		entries, err := ReadDir(fsys, name)
 		if err == nil {
 			foundAny = true
 			allNotExist = false
 			// later filesystems dont override earlier ones
 			for _, entry := range entries {
-				if _, exists := allEntries[entry.Name()]; !exists {
-					allEntries[entry.Name()] = entry
-				}
+				merger.add(entry, cfs.mergeEntry)
+			}
+			if err := cfs.checkEntries("readdir", name, merger.len()); err != nil {
+				return nil, err
 			}
 			continue
 		}
@@ -214,99 +336,127 @@ func (cfs *CompositeFS) ReadDir(name string) ([]fs.DirEntry, error) {
 
 		allNotExist = false
 		wrapped := fmt.Errorf("filesystem %d: %w", i, err)
-		if !cfs.bestEffort {
+		if cfs.stopOn(i, name, err) {
 			return nil, wrapped
 		}
 		errs = append(errs, wrapped)
 	}
 
 	if !foundAny {
+		merger.release()
 		return nil, notFoundError("directory", name, errs, allNotExist)
 	}
 
-	result := make([]fs.DirEntry, 0, len(allEntries))
-	for _, entry := range allEntries {
-		result = append(result, entry)
+	// virtual links shadow layer entries of the same name
+	for _, entry := range cfs.linkEntries(name) {
+		merger.set(entry)
 	}
 
-	return result, nil
+	return cfs.mergedEntries(name, merger), nil
 }
 
 // Stat returns file info for the named file from the first
 // filesystem that successfully opens it
 func (cfs *CompositeFS) Stat(name string) (fs.FileInfo, error) {
-	name = path.Clean(name)
+	if err := cfs.life.enter("stat", name); err != nil {
+		return nil, err
+	}
+	defer cfs.life.leave()
+
+	name, err := cfs.lookupName("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfs.visible(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return cfs.stat(name)
+}
 
+// stat returns the info of the visible path name.
+func (cfs *CompositeFS) stat(name string) (fs.FileInfo, error) {
+	i, info, err := cfs.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return cfs.statResult(i, name, info), nil
+}
+
+// statResult returns the info Stat reports for name, found in layer i.
+func (cfs *CompositeFS) statResult(i int, name string, info fs.FileInfo) fs.FileInfo {
+	if cfs.mergeDirs && info.IsDir() {
+		return cfs.statMergedDir(i, name, info)
+	}
+	return info
+}
+
+// resolve returns the index of the first filesystem that provides name
+// together with the file info it reports.
+func (cfs *CompositeFS) resolve(name string) (int, fs.FileInfo, error) {
 	var errs []error
 	allNotExist := true
 
-	for i, fsys := range cfs.filesystems {
-		// fs implements StatFS
-		if statFS, ok := fsys.(fs.StatFS); ok {
-			info, err := statFS.Stat(name)
-			if err == nil {
-				return info, nil
-			}
+	order := cfs.probeOrder(name)
+	for n := range cfs.filesystems {
+		i := layerAt(order, n)
+		fsys := cfs.filesystems[i]
+		if skipLayer(fsys, name) {
+			continue
+		}
 
-			if errors.Is(err, fs.ErrNotExist) {
-				errs = append(errs, fmt.Errorf("filesystem %d: %w", i, err))
-				continue
-			}
+		info, err := cfs.statLayerCached(i, fsys, name)
+		if err == nil {
+			return i, info, nil
+		}
 
-			allNotExist = false
-			wrapped := fmt.Errorf("filesystem %d: %w", i, err)
-			if !cfs.bestEffort {
-				return nil, wrapped
-			}
-			errs = append(errs, wrapped)
+		if errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("filesystem %d: %w", i, err))
 			continue
-		} else {
-			// fallback to Open + Stat
-			file, err := fsys.Open(name)
-			if err == nil {
-				info, err := file.Stat()
-				file.Close()
-				if err == nil {
-					return info, nil
-				}
-
-				if errors.Is(err, fs.ErrNotExist) {
-					errs = append(errs, fmt.Errorf("filesystem %d: %w", i, err))
-					continue
-				}
+		}
 
-				allNotExist = false
-				wrapped := fmt.Errorf("filesystem %d: %w", i, err)
-				if !cfs.bestEffort {
-					return nil, wrapped
-				}
-				errs = append(errs, wrapped)
-				continue
-			}
+		allNotExist = false
+		wrapped := fmt.Errorf("filesystem %d: %w", i, err)
+		if cfs.stopOn(i, name, err) {
+			return -1, nil, wrapped
+		}
+		errs = append(errs, wrapped)
+	}
 
-			if errors.Is(err, fs.ErrNotExist) {
-				errs = append(errs, fmt.Errorf("filesystem %d: %w", i, err))
-				continue
-			}
+	return -1, nil, notFoundError("file", name, errs, allNotExist)
+}
 
-			allNotExist = false
-			wrapped := fmt.Errorf("filesystem %d: %w", i, err)
-			if !cfs.bestEffort {
-				return nil, wrapped
-			}
-			errs = append(errs, wrapped)
-		}
+// statLayer stats name in a single filesystem, using fs.StatFS
+// when available and falling back to Open + Stat otherwise.
+func statLayer(fsys fs.FS, name string) (fs.FileInfo, error) {
+	// fs implements StatFS
+	if statFS, ok := fsys.(fs.StatFS); ok {
+		return statFS.Stat(name)
 	}
 
-	return nil, notFoundError("file", name, errs, allNotExist)
+	// fallback to Open + Stat
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return file.Stat()
 }
 
 // Sub returns a new CompositeFS rooted at dir in each of the
 // underlying filesystems
 func (cfs *CompositeFS) Sub(dir string) (fs.FS, error) {
-	dir = path.Clean(dir)
+	dir, err := cfs.lookupName("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfs.visible(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrNotExist}
+	}
 
 	subFSList := make([]fs.FS, 0, len(cfs.filesystems))
+	var subWriter WriteFS
 	var errs []error
 	allNotExist := true
 
@@ -317,6 +467,12 @@ func (cfs *CompositeFS) Sub(dir string) (fs.FS, error) {
 		}); ok {
 			subFS, err := subber.Sub(dir)
 			if err == nil {
+				// keep the write layer writable when it supports Sub
+				if cfs.writer != nil && sameLayer(fsys, cfs.writer) {
+					if w, ok := subFS.(WriteFS); ok {
+						subWriter = w
+					}
+				}
 				subFSList = append(subFSList, subFS)
 				allNotExist = false
 				continue
@@ -329,7 +485,7 @@ func (cfs *CompositeFS) Sub(dir string) (fs.FS, error) {
 
 			allNotExist = false
 			wrapped := fmt.Errorf("filesystem %d: %w", i, err)
-			if !cfs.bestEffort {
+			if cfs.stopOn(i, dir, err) {
 				return nil, wrapped
 			}
 			errs = append(errs, wrapped)
@@ -340,25 +496,66 @@ func (cfs *CompositeFS) Sub(dir string) (fs.FS, error) {
 		return nil, notFoundError("directory", dir, errs, allNotExist)
 	}
 
-	return newCompositeFS(cfs.bestEffort, cfs.mergeDirs, subFSList...), nil
+	// the sub composite keeps every option of its parent; journals
+	// record parent-relative paths so they are not carried over
+	sub := cfs.clone()
+	sub.filesystems = sub.flatten(subFSList)
+	sub.writer = subWriter
+	sub.journal = nil
+	sub.visibility = cfs.visibility.sub(dir)
+	sub.probeRules = cfs.subProbeRules(dir, len(sub.filesystems))
+	return sub, nil
 }
 
 // ReadFile reads the named file from the first filesystem that
 // successfully opens it
 func (cfs *CompositeFS) ReadFile(name string) ([]byte, error) {
-	name = path.Clean(name)
+	if err := cfs.life.enter("read", name); err != nil {
+		return nil, err
+	}
+	defer cfs.life.leave()
 
+	name, err := cfs.lookupName("read", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfs.visible(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	if cfs.reads != nil {
+		return cfs.reads.do(name, cfs.readFile)
+	}
+	return cfs.readFile(name)
+}
+
+// readFile reads the winning version of the visible path name.
+func (cfs *CompositeFS) readFile(name string) ([]byte, error) {
+	data, _, err := cfs.readLayers(name)
+	return data, err
+}
+
+// readLayers reads the winning version of the visible path name and
+// returns the index of the layer it came from.
+func (cfs *CompositeFS) readLayers(name string) ([]byte, int, error) {
 	var errs []error
 	allNotExist := true
 
-	for i, fsys := range cfs.filesystems {
+	order := cfs.probeOrder(name)
+	for n := range cfs.filesystems {
+		i := layerAt(order, n)
+		fsys := cfs.filesystems[i]
+		if skipLayer(fsys, name) {
+			continue
+		}
+
 		// fs implements ReadFileFS
 		if rfFS, ok := fsys.(interface {
 			ReadFile(name string) ([]byte, error)
 		}); ok {
 			data, err := rfFS.ReadFile(name)
 			if err == nil {
-				return data, nil
+				return data, i, nil
 			}
 
 			if errors.Is(err, fs.ErrNotExist) {
@@ -368,8 +565,8 @@ func (cfs *CompositeFS) ReadFile(name string) ([]byte, error) {
 
 			allNotExist = false
 			wrapped := fmt.Errorf("filesystem %d: %w", i, err)
-			if !cfs.bestEffort {
-				return nil, wrapped
+			if cfs.stopOn(i, name, err) {
+				return nil, -1, wrapped
 			}
 			errs = append(errs, wrapped)
 			continue
@@ -381,7 +578,7 @@ func (cfs *CompositeFS) ReadFile(name string) ([]byte, error) {
 			data, err := io.ReadAll(file)
 			file.Close()
 			if err == nil {
-				return data, nil
+				return data, i, nil
 			}
 
 			if errors.Is(err, fs.ErrNotExist) {
@@ -391,8 +588,8 @@ func (cfs *CompositeFS) ReadFile(name string) ([]byte, error) {
 
 			allNotExist = false
 			wrapped := fmt.Errorf("filesystem %d: %w", i, err)
-			if !cfs.bestEffort {
-				return nil, wrapped
+			if cfs.stopOn(i, name, err) {
+				return nil, -1, wrapped
 			}
 			errs = append(errs, wrapped)
 			continue
@@ -405,17 +602,19 @@ func (cfs *CompositeFS) ReadFile(name string) ([]byte, error) {
 
 		allNotExist = false
 		wrapped := fmt.Errorf("filesystem %d: %w", i, err)
-		if !cfs.bestEffort {
-			return nil, wrapped
+		if cfs.stopOn(i, name, err) {
+			return nil, -1, wrapped
 		}
 		errs = append(errs, wrapped)
 	}
 
-	return nil, notFoundError("file", name, errs, allNotExist)
+	return nil, -1, notFoundError("file", name, errs, allNotExist)
 }
 
 // ReadDir is a helper function to read a directory's contents from an fs.FS
-// It supports both fs.ReadDirFS implementations and regular fs.FS
+// It supports both fs.ReadDirFS implementations and regular fs.FS. When
+// the directory file does not implement fs.ReadDirFile, the listing is
+// synthesized from the layer's Glob method if it implements fs.GlobFS.
 func ReadDir(fsys fs.FS, name string) ([]fs.DirEntry, error) {
 	if rdfs, ok := fsys.(fs.ReadDirFS); ok {
 		return rdfs.ReadDir(name)
@@ -431,9 +630,42 @@ func ReadDir(fsys fs.FS, name string) ([]fs.DirEntry, error) {
 		return dirFile.ReadDir(-1)
 	}
 
+	if globber, ok := fsys.(fs.GlobFS); ok {
+		if info, err := dir.Stat(); err == nil && info.IsDir() {
+			return globDir(globber, name)
+		}
+	}
 	return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
 }
 
+// globDir lists the directory name of fsys through its Glob method.
+func globDir(fsys fs.GlobFS, name string) ([]fs.DirEntry, error) {
+	pattern := "*"
+	if name != "." {
+		pattern = path.Join(escapeMeta(name), "*")
+	}
+	matches, err := fsys.Glob(pattern)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(matches))
+	for _, match := range matches {
+		info, err := statLayer(fsys, match)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
 // Sub is a helper function to get a sub-filesystem
 func Sub(fsys fs.FS, dir string) (fs.FS, error) {
 	// If the filesystem implements SubFS, use that
@@ -446,15 +678,52 @@ func Sub(fsys fs.FS, dir string) (fs.FS, error) {
 	return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
 }
 
-func notFoundError(kind, name string, errs []error, allNotExist bool) error {
-	message := fmt.Sprintf("%s %q not found in any filesystem", kind, name)
-	if len(errs) > 0 {
-		message = fmt.Sprintf("%s: %v", message, errors.Join(errs...))
+// LayerErrors is returned when no filesystem could serve a path. It
+// carries the error of every layer that was tried, each prefixed with
+// the layer index, and unwraps to them so errors.Is and errors.As match
+// any layer error, also through nested composites. When every layer
+// reported a missing path it matches fs.ErrNotExist; otherwise the
+// not-exist errors are left out, so a missing file in one layer does
+// not mask the failure of another.
+type LayerErrors struct {
+	// Kind is "file" or "directory".
+	Kind string
+	// Path is the path that was looked up.
+	Path string
+	// Errors holds the errors of the layers, in probe order.
+	Errors []error
+	// NotExist reports whether every layer reported a missing path.
+	NotExist bool
+}
+
+func (e *LayerErrors) Error() string {
+	message := fmt.Sprintf("%s %q not found in any filesystem", e.Kind, e.Path)
+	if len(e.Errors) > 0 {
+		message = fmt.Sprintf("%s: %v", message, errors.Join(e.Errors...))
+	}
+	if e.NotExist {
+		return fmt.Sprintf("%v: %s", fs.ErrNotExist, message)
+	}
+	return message
+}
+
+// Unwrap returns the layer errors, led by fs.ErrNotExist when every
+// layer reported a missing path.
+func (e *LayerErrors) Unwrap() []error {
+	if e.NotExist {
+		return append([]error{fs.ErrNotExist}, e.Errors...)
 	}
-	if allNotExist {
-		return fmt.Errorf("%w: %s", fs.ErrNotExist, message)
+	out := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		if !errors.Is(err, fs.ErrNotExist) {
+			out = append(out, err)
+		}
 	}
-	return errors.New(message)
+	return out
+}
+
+func notFoundError(kind, name string, errs []error, allNotExist bool) error {
+	return &LayerErrors{Kind: kind, Path: name, Errors: errs, NotExist: allNotExist}
 }
 
 type overlayDirFile struct {
@@ -505,6 +774,35 @@ func (f *overlayDirFile) ReadDir(n int) ([]fs.DirEntry, error) {
 	return entries, nil
 }
 
+// Readdir is the os.File and http.File counterpart of ReadDir, so the
+// directory can be served by frameworks built on http.FileSystem.
+func (f *overlayDirFile) Readdir(count int) ([]fs.FileInfo, error) {
+	entries, err := f.ReadDir(count)
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, infoErr := entry.Info()
+		if infoErr != nil {
+			return infos, infoErr
+		}
+		infos = append(infos, info)
+	}
+	return infos, err
+}
+
+// Seek rewinds the directory listing. Like os.File, only seeking to the
+// start, or querying the current offset, is supported. The offset is the
+// number of entries read so far.
+func (f *overlayDirFile) Seek(offset int64, whence int) (int64, error) {
+	switch {
+	case offset == 0 && whence == io.SeekStart:
+		f.pos = 0
+		return 0, nil
+	case offset == 0 && whence == io.SeekCurrent:
+		return int64(f.pos), nil
+	}
+	return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+}
+
 type dirInfo struct {
 	name string
 }
@@ -5,9 +5,13 @@ import (
 	"errors"
 	"io"
 	"io/fs"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"syscall"
 	"testing"
 	"testing/fstest"
 	"time"
@@ -129,6 +133,34 @@ func TestOverlayFSOpenDirMergesEntries(t *testing.T) {
 	}
 }
 
+func TestOverlayFSOpenDirFallsBackToGlob(t *testing.T) {
+	fs1 := globOnlyFS{openOnlyFS{files: fstest.MapFS{
+		"resources/show.html": &fstest.MapFile{Data: []byte("show")},
+	}}}
+	fs2 := fstest.MapFS{
+		"resources/form.html": &fstest.MapFile{Data: []byte("form")},
+	}
+
+	entries, err := fs.ReadDir(cfs.NewOverlayFS(fs1, fs2), "resources")
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "form.html,show.html" {
+		t.Errorf("Expected form.html,show.html, got %v", names)
+	}
+
+	// without Glob the layer still cannot be listed
+	strict := cfs.NewOverlayFS(openOnlyFS{files: fs1.files}, fs2)
+	if _, err := fs.ReadDir(strict, "resources"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Expected fs.ErrInvalid, got %v", err)
+	}
+}
+
 type permissionFS struct{}
 
 func (permissionFS) Open(name string) (fs.File, error) {
@@ -235,6 +267,89 @@ func TestCompositeFSReadFileBestEffortContinuesOnError(t *testing.T) {
 	}
 }
 
+// errFS fails every operation with err.
+type errFS struct {
+	err error
+}
+
+func (e errFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: e.err}
+}
+
+type bucketError struct {
+	Bucket string
+}
+
+func (e *bucketError) Error() string { return "bucket " + e.Bucket + " unavailable" }
+
+func TestCompositeFSBestEffortErrorUnwrapsLayerErrors(t *testing.T) {
+	// hiding dotfiles keeps the inner composite from being flattened
+	inner := cfs.NewCompositeFSBestEffort(
+		fstest.MapFS{},
+		errFS{err: &bucketError{Bucket: "assets"}},
+	).WithHideDotfiles()
+	composite := cfs.NewCompositeFSBestEffort(
+		errFS{err: syscall.EACCES},
+		inner,
+		fstest.MapFS{},
+	)
+
+	_, err := composite.Open("missing.txt")
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	if !errors.Is(err, syscall.EACCES) || !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Expected the EACCES of layer 0 to match, got %v", err)
+	}
+	var bucketErr *bucketError
+	if !errors.As(err, &bucketErr) || bucketErr.Bucket != "assets" {
+		t.Errorf("Expected the nested bucket error to match, got %v", err)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Did not expect fs.ErrNotExist with layer failures, got %v", err)
+	}
+
+	var layerErrs *cfs.LayerErrors
+	if !errors.As(err, &layerErrs) || layerErrs.Path != "missing.txt" || len(layerErrs.Errors) != 3 {
+		t.Fatalf("Expected LayerErrors for all three layers, got %#v", err)
+	}
+
+	// the same holds for Stat, ReadFile and ReadDir
+	if _, err := composite.Stat("missing.txt"); !errors.As(err, &bucketErr) {
+		t.Errorf("Stat: expected the nested bucket error, got %v", err)
+	}
+	if _, err := composite.ReadFile("missing.txt"); !errors.Is(err, syscall.EACCES) {
+		t.Errorf("ReadFile: expected EACCES, got %v", err)
+	}
+	if _, err := composite.ReadDir("missing"); !errors.Is(err, syscall.EACCES) {
+		t.Errorf("ReadDir: expected EACCES, got %v", err)
+	}
+}
+
+func TestCompositeFSNotExistErrorNested(t *testing.T) {
+	composite := cfs.NewCompositeFSBestEffort(
+		cfs.NewCompositeFSBestEffort(fstest.MapFS{}, fstest.MapFS{}).WithHideDotfiles(),
+		fstest.MapFS{},
+	)
+	_, err := composite.Open("missing.txt")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected fs.ErrNotExist, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "file does not exist: file \"missing.txt\" not found in any filesystem") {
+		t.Errorf("Unexpected message %q", err)
+	}
+}
+
+func writeTestFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+}
+
 func testReadFile(t *testing.T, fsys fs.FS, name, expectedContent string) {
 	t.Helper()
 
@@ -573,3 +688,46 @@ func TestPartialOverride(t *testing.T) {
 		t.Errorf("Expected base footer content, got %q", string(content))
 	}
 }
+
+func TestOverlayDirFileHTTPFile(t *testing.T) {
+	composite := cfs.NewOverlayFS(
+		fstest.MapFS{"views/home.html": &fstest.MapFile{Data: []byte("home")}},
+		fstest.MapFS{"views/about.html": &fstest.MapFile{Data: []byte("about")}},
+	)
+
+	file, err := composite.Open("views")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer file.Close()
+
+	dir, ok := file.(http.File)
+	if !ok {
+		t.Fatalf("Expected merged directory to implement http.File, got %T", file)
+	}
+
+	infos, err := dir.Readdir(1)
+	if err != nil || len(infos) != 1 {
+		t.Fatalf("Expected 1 info, got %d, %v", len(infos), err)
+	}
+	if pos, err := dir.Seek(0, io.SeekCurrent); err != nil || pos != 1 {
+		t.Fatalf("Expected offset 1 after reading an entry, got %d, %v", pos, err)
+	}
+	if _, err := dir.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	infos, err = dir.Readdir(-1)
+	if err != nil || len(infos) != 2 {
+		t.Fatalf("Expected 2 infos after rewinding, got %d, %v", len(infos), err)
+	}
+	if _, err := dir.Seek(10, io.SeekStart); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Expected ErrInvalid seeking past the start, got %v", err)
+	}
+
+	// legacy http.FileSystem handlers can list the directory
+	rec := httptest.NewRecorder()
+	http.FileServer(http.FS(composite)).ServeHTTP(rec, httptest.NewRequest("GET", "/views/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "about.html") {
+		t.Errorf("Expected directory listing, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,254 @@
+package cfs
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// WithContentCache returns a copy of the composite that keeps the
+// content of files of up to maxFileSize bytes in memory for
+// ReadFileShared, evicting the least recently used files once the
+// content held exceeds maxBytes. Content still referenced by
+// ReadOnlyBytes views counts towards maxBytes until the views are
+// released, even after eviction, so the memory pinned by callers stays
+// bounded. Files served by layers whose Cacheable method reports false
+// are never cached. Changes made through the composite and Invalidate
+// drop the affected files.
+func (cfs *CompositeFS) WithContentCache(maxBytes, maxFileSize int64) *CompositeFS {
+	c := cfs.clone()
+	c.content = newContentCache(maxBytes, maxFileSize)
+	return c
+}
+
+// ReadOnlyBytes is an immutable, reference-counted view of file
+// content returned by ReadFileShared. Views of a cached file share one
+// buffer with the cache and with each other: the slice returned by
+// Bytes must never be modified, and must not be used after Release.
+// Copies of a ReadOnlyBytes value are the same view, so each view is
+// released exactly once, by its last user. Use Clone for a private,
+// mutable copy. The zero value is an empty view.
+type ReadOnlyBytes struct {
+	buf *sharedBuf
+}
+
+// sharedBuf is a buffer shared by the views of a file and the cache.
+type sharedBuf struct {
+	data  []byte
+	refs  atomic.Int64
+	cache *contentCache // accounts for the buffer while referenced
+}
+
+// Bytes returns the content. The slice aliases the shared buffer and
+// must not be modified.
+func (b ReadOnlyBytes) Bytes() []byte {
+	if b.buf == nil {
+		return nil
+	}
+	return b.buf.data
+}
+
+// Len returns the length of the content.
+func (b ReadOnlyBytes) Len() int {
+	return len(b.Bytes())
+}
+
+// String returns the content as a string.
+func (b ReadOnlyBytes) String() string {
+	return string(b.Bytes())
+}
+
+// Clone returns a copy of the content the caller may modify.
+func (b ReadOnlyBytes) Clone() []byte {
+	return bytes.Clone(b.Bytes())
+}
+
+// Reader returns a reader over the content.
+func (b ReadOnlyBytes) Reader() *bytes.Reader {
+	return bytes.NewReader(b.Bytes())
+}
+
+// WriteTo implements io.WriterTo.
+func (b ReadOnlyBytes) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(b.Bytes())
+	return int64(n), err
+}
+
+// Release gives the view back. Once every view of an evicted file is
+// released, its buffer no longer counts towards the cache size.
+func (b ReadOnlyBytes) Release() {
+	if b.buf != nil {
+		b.buf.release()
+	}
+}
+
+func (s *sharedBuf) release() {
+	if s.refs.Add(-1) == 0 && s.cache != nil {
+		s.cache.size.Add(-int64(len(s.data)))
+	}
+}
+
+// ReadFileShared reads the winning version of name like ReadFile, but
+// returns a view that shares its buffer instead of a copy, so hot small
+// files are not copied per call. With WithContentCache the view is
+// backed by the content cache; otherwise it wraps a fresh read. See
+// ReadOnlyBytes for the aliasing contract; call Release when done.
+func (cfs *CompositeFS) ReadFileShared(name string) (ReadOnlyBytes, error) {
+	if err := cfs.life.enter("read", name); err != nil {
+		return ReadOnlyBytes{}, err
+	}
+	defer cfs.life.leave()
+
+	name, err := cfs.lookupName("read", name)
+	if err != nil {
+		return ReadOnlyBytes{}, err
+	}
+	if !cfs.visible(name) {
+		return ReadOnlyBytes{}, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+
+	view, gen, ok := cfs.content.get(name)
+	if ok {
+		return view, nil
+	}
+	data, i, err := cfs.readLayers(name)
+	if err != nil {
+		return ReadOnlyBytes{}, err
+	}
+	if cfs.content == nil || !cacheable(cfs.filesystems[i]) {
+		return newReadOnlyBytes(data), nil
+	}
+	return cfs.content.put(name, data, gen), nil
+}
+
+// newReadOnlyBytes returns a view of data no cache accounts for.
+func newReadOnlyBytes(data []byte) ReadOnlyBytes {
+	buf := &sharedBuf{data: data}
+	buf.refs.Store(1)
+	return ReadOnlyBytes{buf: buf}
+}
+
+// contentCache keeps file content for ReadFileShared. Every entry holds
+// a reference to its buffer, released on eviction.
+type contentCache struct {
+	maxBytes    int64
+	maxFileSize int64
+	size        atomic.Int64 // bytes of buffers still referenced
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // of *contentEntry, most recently used first
+	gen     uint64     // bumped by invalidate, so stale reads are dropped
+}
+
+type contentEntry struct {
+	name string
+	buf  *sharedBuf
+}
+
+func newContentCache(maxBytes, maxFileSize int64) *contentCache {
+	return &contentCache{
+		maxBytes:    maxBytes,
+		maxFileSize: maxFileSize,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+	}
+}
+
+// fresh returns an empty cache with the same settings. Copies of a
+// composite may probe different layers, so they never share entries.
+func (c *contentCache) fresh() *contentCache {
+	if c == nil {
+		return nil
+	}
+	return newContentCache(c.maxBytes, c.maxFileSize)
+}
+
+// get returns a new view of the cached content of name. On a miss it
+// returns the generation to pass to put.
+func (c *contentCache) get(name string) (ReadOnlyBytes, uint64, bool) {
+	if c == nil {
+		return ReadOnlyBytes{}, 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[name]
+	if !ok {
+		return ReadOnlyBytes{}, c.gen, false
+	}
+	c.lru.MoveToFront(elem)
+	buf := elem.Value.(*contentEntry).buf
+	buf.refs.Add(1)
+	return ReadOnlyBytes{buf: buf}, c.gen, true
+}
+
+// put caches data as the content of name when it fits, and returns a
+// view of it. Content read at generation gen is not cached when the
+// cache was invalidated since, as the read may have raced with the
+// change.
+func (c *contentCache) put(name string, data []byte, gen uint64) ReadOnlyBytes {
+	size := int64(len(data))
+	if size > c.maxFileSize || size > c.maxBytes {
+		return newReadOnlyBytes(data)
+	}
+
+	c.mu.Lock()
+	if c.gen != gen {
+		c.mu.Unlock()
+		return newReadOnlyBytes(data)
+	}
+	buf := &sharedBuf{data: data, cache: c}
+	buf.refs.Store(2) // the cache and the caller
+	c.size.Add(size)
+	if elem, ok := c.entries[name]; ok {
+		c.remove(elem)
+	}
+	c.entries[name] = c.lru.PushFront(&contentEntry{name: name, buf: buf})
+	// evict down to the limit; buffers pinned by views keep counting
+	for c.size.Load() > c.maxBytes && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+	c.mu.Unlock()
+	return ReadOnlyBytes{buf: buf}
+}
+
+// remove drops the entry held by elem. The caller holds c.mu.
+func (c *contentCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*contentEntry)
+	delete(c.entries, entry.name)
+	entry.buf.release()
+}
+
+// invalidate drops the cached content of names and their descendants,
+// or of every file without names.
+func (c *contentCache) invalidate(names ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for cached, elem := range c.entries {
+		if len(names) == 0 || matchesChanged(cached, names) {
+			c.remove(elem)
+		}
+	}
+}
+
+// matchesChanged reports whether name is one of changed or below one of
+// them.
+func matchesChanged(name string, changed []string) bool {
+	for _, dir := range changed {
+		dir = path.Clean(dir)
+		if dir == "." || name == dir || strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
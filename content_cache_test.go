@@ -0,0 +1,104 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestReadFileShared(t *testing.T) {
+	lower := &countingFS{MapFS: fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("home")},
+	}}
+	composite := cfs.NewWritableFS(cfs.NewMemLayer(), lower).WithContentCache(1<<20, 1<<10)
+
+	first, err := composite.ReadFileShared("views/home.html")
+	if err != nil {
+		t.Fatalf("ReadFileShared failed: %v", err)
+	}
+	second, err := composite.ReadFileShared("views/home.html")
+	if err != nil {
+		t.Fatalf("ReadFileShared failed: %v", err)
+	}
+	if first.String() != "home" || second.String() != "home" {
+		t.Fatalf("Expected the content, got %q and %q", first.String(), second.String())
+	}
+	if &first.Bytes()[0] != &second.Bytes()[0] {
+		t.Fatal("Expected views to share the cached buffer")
+	}
+	private := first.Clone()
+	private[0] = 'H'
+	if second.String() != "home" {
+		t.Fatal("Expected Clone to copy the content")
+	}
+	first.Release()
+	second.Release()
+
+	// writes through the composite drop the cached content
+	if err := composite.WriteFile("views/home.html", []byte("custom"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	view, err := composite.ReadFileShared("views/home.html")
+	if err != nil || view.String() != "custom" {
+		t.Fatalf("Expected the new content, got %q, %v", view.String(), err)
+	}
+	view.Release()
+
+	if _, err := composite.ReadFileShared("views/missing.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected ErrNotExist, got %v", err)
+	}
+}
+
+func TestContentCacheCountsPinnedViews(t *testing.T) {
+	lower := &countingFS{MapFS: fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("aaaaaa")},
+		"b.txt": &fstest.MapFile{Data: []byte("bbbbbb")},
+	}}
+	composite := cfs.NewCompositeFS(lower).WithContentCache(10, 10)
+	read := func(name string) cfs.ReadOnlyBytes {
+		t.Helper()
+		view, err := composite.ReadFileShared(name)
+		if err != nil {
+			t.Fatalf("ReadFileShared failed: %v", err)
+		}
+		return view
+	}
+
+	a := read("a.txt")
+	// a stays pinned by its view, so b does not fit next to it
+	b := read("b.txt")
+	b.Release()
+	before := lower.calls.Load()
+	read("b.txt").Release()
+	if lower.calls.Load() == before {
+		t.Fatal("Expected b.txt not to be cached while a.txt is pinned")
+	}
+
+	a.Release()
+	read("b.txt").Release()
+	before = lower.calls.Load()
+	read("b.txt").Release()
+	if calls := lower.calls.Load() - before; calls != 0 {
+		t.Fatalf("Expected b.txt to be cached once a.txt was released, got %d reads", calls)
+	}
+}
+
+func TestReadFileSharedWithoutCache(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("a")}})
+	view, err := composite.ReadFileShared("a.txt")
+	if err != nil || view.String() != "a" || view.Len() != 1 {
+		t.Fatalf("Expected the content, got %q, %v", view.String(), err)
+	}
+	view.Release()
+
+	var empty cfs.ReadOnlyBytes
+	if empty.Len() != 0 || empty.Bytes() != nil {
+		t.Fatal("Expected the zero value to be empty")
+	}
+	empty.Release()
+}
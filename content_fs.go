@@ -0,0 +1,70 @@
+package cfs
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Renderer renders source files of one extension for NewContentFS.
+type Renderer struct {
+	// Ext is the extension of the rendered output, such as ".html". An
+	// empty Ext keeps the source name.
+	Ext string
+	// Render converts the content of the source file name.
+	Render func(name string, src []byte) ([]byte, error)
+}
+
+// NewContentFS walks src, typically the merged view of a composite, and
+// renders every file whose extension has a renderer, e.g. markdown to
+// HTML. The rendered outputs are returned as an in-memory layer, with
+// the source extension replaced by the renderer's, so they can be
+// stacked on top of the sources for layered static-site generation:
+//
+//	site, err := cfs.NewContentFS(composite, map[string]cfs.Renderer{
+//		".md": {Ext: ".html", Render: renderMarkdown},
+//	})
+//	public := cfs.NewOverlayFS(cfs.NewLayer("rendered", site), composite)
+//
+// Files without a renderer are not copied. The layer is a snapshot;
+// call NewContentFS again after the sources change.
+func NewContentFS(src fs.FS, renderers map[string]Renderer) (fs.FS, error) {
+	out := newMemFS()
+	err := fs.WalkDir(src, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		ext := path.Ext(name)
+		r, ok := renderers[ext]
+		if !ok || r.Render == nil {
+			return nil
+		}
+
+		data, err := fs.ReadFile(src, name)
+		if err != nil {
+			return err
+		}
+		rendered, err := r.Render(name, data)
+		if err != nil {
+			return &fs.PathError{Op: "render", Path: name, Err: err}
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		target := name
+		if r.Ext != "" {
+			target = strings.TrimSuffix(name, ext) + r.Ext
+		}
+		out.add(target, rendered, info.Mode().Perm(), info.ModTime())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,67 @@
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func renderHeadings(name string, src []byte) ([]byte, error) {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(string(src)), "\n") {
+		if title, ok := strings.CutPrefix(line, "# "); ok {
+			b.WriteString("<h1>" + title + "</h1>")
+			continue
+		}
+		b.WriteString("<p>" + line + "</p>")
+	}
+	return []byte(b.String()), nil
+}
+
+func TestNewContentFS(t *testing.T) {
+	theme := fstest.MapFS{
+		"docs/index.md": &fstest.MapFile{Data: []byte("---\ntitle: x\n---\n# Theme\nwelcome")},
+	}
+	base := fstest.MapFS{
+		"docs/index.md":  &fstest.MapFile{Data: []byte("# Base")},
+		"docs/guide.md":  &fstest.MapFile{Data: []byte("# Guide")},
+		"docs/style.css": &fstest.MapFile{Data: []byte("body{}")},
+	}
+	sources := cfs.NewCompositeFS(theme, base).WithFrontMatter()
+
+	site, err := cfs.NewContentFS(sources, map[string]cfs.Renderer{
+		".md": {Ext: ".html", Render: renderHeadings},
+	})
+	if err != nil {
+		t.Fatalf("NewContentFS failed: %v", err)
+	}
+
+	if err := fstest.TestFS(site, "docs/index.html", "docs/guide.html"); err != nil {
+		t.Fatal(err)
+	}
+	testReadFile(t, site, "docs/index.html", "<h1>Theme</h1><p>welcome</p>")
+	if _, err := fs.Stat(site, "docs/style.css"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected files without a renderer to be skipped, got %v", err)
+	}
+
+	// rendered outputs stack on top of the sources
+	public := cfs.NewOverlayFS(cfs.NewLayer("rendered", site), sources)
+	testReadFile(t, public, "docs/guide.html", "<h1>Guide</h1>")
+	testReadFile(t, public, "docs/style.css", "body{}")
+}
+
+func TestNewContentFSRenderError(t *testing.T) {
+	errBad := errors.New("bad markdown")
+	src := fstest.MapFS{"a.md": &fstest.MapFile{Data: []byte("x")}}
+	_, err := cfs.NewContentFS(src, map[string]cfs.Renderer{
+		".md": {Render: func(string, []byte) ([]byte, error) { return nil, errBad }},
+	})
+	var pathErr *fs.PathError
+	if !errors.Is(err, errBad) || !errors.As(err, &pathErr) || pathErr.Path != "a.md" {
+		t.Fatalf("Expected a render error for a.md, got %v", err)
+	}
+}
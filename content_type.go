@@ -0,0 +1,99 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// sniffLen is the number of bytes inspected by content sniffing.
+const sniffLen = 512
+
+// WithContentTypes returns a copy of the composite that uses types, a
+// map from file extension to media type, before the system MIME table
+// in ContentType. Extensions are matched case-insensitively, with or
+// without the leading dot.
+func (cfs *CompositeFS) WithContentTypes(types map[string]string) *CompositeFS {
+	c := cfs.clone()
+	c.mimeTypes = make(map[string]string, len(cfs.mimeTypes)+len(types))
+	for ext, ctype := range cfs.mimeTypes {
+		c.mimeTypes[ext] = ctype
+	}
+	for ext, ctype := range types {
+		c.mimeTypes[normalizeExt(ext)] = ctype
+	}
+	return c
+}
+
+// ContentType returns the media type of the winning version of name.
+// The type is looked up by extension, first in the map configured with
+// WithContentTypes and then in the system MIME table; files with an
+// unknown extension are sniffed.
+func (cfs *CompositeFS) ContentType(name string) (string, error) {
+	file, err := cfs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", &fs.PathError{Op: "contenttype", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if ext := normalizeExt(path.Ext(name)); ext != "" {
+		if ctype, ok := cfs.mimeTypes[ext]; ok {
+			return ctype, nil
+		}
+		if ctype := mime.TypeByExtension(ext); ctype != "" {
+			return ctype, nil
+		}
+	}
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// AllowContentTypes wraps fsys so only files whose extension maps to
+// one of the given MIME types are visible. Types may use a wildcard
+// subtype, as in "image/*". Directories stay visible.
+func AllowContentTypes(fsys fs.FS, types ...string) fs.FS {
+	return newFilterFS(fsys, func(name string, d fs.DirEntry) error {
+		if d.IsDir() {
+			return nil
+		}
+		ctype := mime.TypeByExtension(path.Ext(name))
+		if ctype == "" {
+			return fs.ErrNotExist
+		}
+		if mediaType, _, err := mime.ParseMediaType(ctype); err == nil {
+			ctype = mediaType
+		}
+		for _, t := range types {
+			if matchContentType(t, ctype) {
+				return nil
+			}
+		}
+		return fs.ErrNotExist
+	})
+}
+
+func matchContentType(pattern, ctype string) bool {
+	pattern = strings.ToLower(pattern)
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(ctype, prefix+"/")
+	}
+	return pattern == ctype
+}
@@ -0,0 +1,57 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestContentType(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{
+		"index.html":   &fstest.MapFile{Data: []byte("<p>hi</p>")},
+		"app.WASM":     &fstest.MapFile{Data: []byte("\x00asm")},
+		"page.tmpl":    &fstest.MapFile{Data: []byte("<html><body></body></html>")},
+		"LICENSE":      &fstest.MapFile{Data: []byte("plain text")},
+		"views/a.html": &fstest.MapFile{Data: []byte("a")},
+	}).WithContentTypes(map[string]string{
+		"tmpl":  "text/html; charset=utf-8",
+		".wasm": "application/wasm",
+	})
+
+	cases := map[string]string{
+		"index.html": "text/html",
+		"app.WASM":   "application/wasm",
+		"page.tmpl":  "text/html",
+		"LICENSE":    "text/plain",
+	}
+	for name, expected := range cases {
+		ctype, err := composite.ContentType(name)
+		if err != nil {
+			t.Fatalf("ContentType(%s) failed: %v", name, err)
+		}
+		if !strings.HasPrefix(ctype, expected) {
+			t.Errorf("ContentType(%s): expected %q, got %q", name, expected, ctype)
+		}
+	}
+
+	if _, err := composite.ContentType("views"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Expected fs.ErrInvalid for a directory, got %v", err)
+	}
+	if _, err := composite.ContentType("missing.css"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestStackHashIncludesContentTypes(t *testing.T) {
+	base := cfs.NewCompositeFS(fstest.MapFS{})
+	custom := base.WithContentTypes(map[string]string{".tmpl": "text/html"})
+	if custom.StackHash() == base.StackHash() {
+		t.Fatal("Expected content type overrides to change the stack hash")
+	}
+}
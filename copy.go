@@ -0,0 +1,283 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// CopyProgress reports the state of a CopyAll run after each file.
+type CopyProgress struct {
+	// Path is the file that was just processed.
+	Path string
+	// Err is set when Path could not be copied. The copy continues
+	// with the remaining files.
+	Err error
+	// Files and Bytes count the files processed and bytes written so far.
+	Files int
+	Bytes int64
+	// TotalFiles and TotalBytes describe the whole tree.
+	TotalFiles int
+	TotalBytes int64
+}
+
+// CopyOption configures CopyAll.
+type CopyOption func(*copyConfig)
+
+type copyConfig struct {
+	progress func(CopyProgress)
+	include  []string
+	exclude  []string
+	dryRun   bool
+}
+
+// WithProgress makes CopyAll call fn after each file is processed.
+func WithProgress(fn func(CopyProgress)) CopyOption {
+	return func(c *copyConfig) {
+		c.progress = fn
+	}
+}
+
+// WithInclude makes CopyAll copy only the files matching one of the
+// patterns, such as "public/**". Patterns are matched against paths in
+// the composite with path.Match, where a "**" element matches any
+// number of path elements.
+func WithInclude(patterns ...string) CopyOption {
+	return func(c *copyConfig) {
+		c.include = append(c.include, patterns...)
+	}
+}
+
+// WithExclude makes CopyAll skip the files and directories matching one
+// of the patterns, with the syntax of WithInclude.
+func WithExclude(patterns ...string) CopyOption {
+	return func(c *copyConfig) {
+		c.exclude = append(c.exclude, patterns...)
+	}
+}
+
+// WithDryRun makes CopyAll report what it would copy through the
+// progress callback without writing anything.
+func WithDryRun() CopyOption {
+	return func(c *copyConfig) {
+		c.dryRun = true
+	}
+}
+
+// excluded reports whether name matches an exclude pattern.
+func (c *copyConfig) excluded(name string) bool {
+	for _, pattern := range c.exclude {
+		if matchPattern(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// included reports whether the file name matches the include patterns.
+func (c *copyConfig) included(name string) bool {
+	if len(c.include) == 0 {
+		return true
+	}
+	for _, pattern := range c.include {
+		if matchPattern(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// reachable reports whether files below dir may be included.
+func (c *copyConfig) reachable(dir string) bool {
+	if len(c.include) == 0 {
+		return true
+	}
+	for _, pattern := range c.include {
+		if patternMayMatchBelow(pattern, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// CopyFile writes the contents of the winning version of name to dst
+// and returns the number of bytes written. The content is streamed
+// through a pooled buffer; see WithCopyBuffer.
+func (cfs *CompositeFS) CopyFile(name string, dst io.Writer) (int64, error) {
+	file, err := cfs.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if info.IsDir() {
+		return 0, &fs.PathError{Op: "copy", Path: name, Err: errors.New("is a directory")}
+	}
+	return cfs.copyFile(dst, file)
+}
+
+// CopyAll exports the merged tree below root into the OS directory
+// dstDir, preserving permissions and modification times. Files that
+// fail to copy do not stop the export; their errors are reported to the
+// progress callback and returned joined once every file was attempted.
+// WithInclude and WithExclude bake out part of the tree only, creating
+// just the directories that hold copied files.
+func (cfs *CompositeFS) CopyAll(root, dstDir string, opts ...CopyOption) error {
+	var cfg copyConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	for _, pattern := range append(cfg.include, cfg.exclude...) {
+		if err := validPattern(pattern); err != nil {
+			return fmt.Errorf("copy pattern %q: %w", pattern, err)
+		}
+	}
+	root = path.Clean(root)
+
+	type copyJob struct {
+		name string
+		info fs.FileInfo
+	}
+	var jobs []copyJob
+	var dirs []copyJob
+	var totalBytes int64
+
+	err := fs.WalkDir(cfs, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != root && cfg.excluded(p) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() && !cfg.reachable(p) {
+			return fs.SkipDir
+		}
+		if !d.IsDir() && !cfg.included(p) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			dirs = append(dirs, copyJob{name: p, info: info})
+			return nil
+		}
+		jobs = append(jobs, copyJob{name: p, info: info})
+		totalBytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(cfg.include) > 0 || len(cfg.exclude) > 0 {
+		// only create the directories holding copied files
+		needed := map[string]bool{root: true}
+		for _, job := range jobs {
+			for dir := path.Dir(job.name); !needed[dir]; dir = path.Dir(dir) {
+				needed[dir] = true
+			}
+		}
+		kept := dirs[:0]
+		for _, dir := range dirs {
+			if needed[dir.name] {
+				kept = append(kept, dir)
+			}
+		}
+		dirs = kept
+	}
+
+	if !cfg.dryRun {
+		for _, dir := range dirs {
+			if err := os.MkdirAll(copyTarget(dstDir, root, dir.name), 0o755); err != nil {
+				return err
+			}
+		}
+	}
+
+	progress := CopyProgress{TotalFiles: len(jobs), TotalBytes: totalBytes}
+	var errs []error
+	for _, job := range jobs {
+		var n int64
+		var err error
+		if cfg.dryRun {
+			n = job.info.Size()
+		} else {
+			n, err = cfs.copyTo(job.name, copyTarget(dstDir, root, job.name), job.info)
+		}
+		if err != nil {
+			err = fmt.Errorf("copy %s: %w", job.name, err)
+			errs = append(errs, err)
+		}
+
+		progress.Path = job.name
+		progress.Err = err
+		progress.Files++
+		progress.Bytes += n
+		if cfg.progress != nil {
+			cfg.progress(progress)
+		}
+	}
+
+	if cfg.dryRun {
+		return nil
+	}
+
+	// directory times are restored last, after their contents changed
+	for i := len(dirs) - 1; i >= 0; i-- {
+		mtime := dirs[i].info.ModTime()
+		if !mtime.IsZero() {
+			os.Chtimes(copyTarget(dstDir, root, dirs[i].name), mtime, mtime)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (cfs *CompositeFS) copyTo(name, target string, info fs.FileInfo) (int64, error) {
+	perm := info.Mode().Perm()
+	if perm == 0 {
+		perm = 0o644
+	}
+	dst, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := cfs.CopyFile(name, dst)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return n, err
+	}
+
+	if mtime := info.ModTime(); !mtime.IsZero() {
+		if err := os.Chtimes(target, mtime, mtime); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// copyTarget maps name below root to its location below dstDir.
+func copyTarget(dstDir, root, name string) string {
+	rel := name
+	if root != "." {
+		rel = name[len(root):]
+	}
+	return filepath.Join(dstDir, filepath.FromSlash(rel))
+}
@@ -0,0 +1,54 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// DefaultCopyBuffer is the size of the buffers CopyFile, CopyAll,
+// WriteTar and ExportOverrides stream files through unless
+// WithCopyBuffer sets another.
+const DefaultCopyBuffer = 32 << 10
+
+// copyPools holds a *sync.Pool of buffers per buffer size, shared by
+// every composite.
+var copyPools sync.Map
+
+// WithCopyBuffer returns a copy of the composite that streams files
+// through buffers of size bytes in CopyFile, CopyAll, WriteTar and
+// ExportOverrides. Buffers are pooled per size and reused across calls
+// and composites, so exporting large trees does not allocate a buffer
+// per file. Larger buffers mean fewer reads and writes per file; a size
+// of zero or less restores DefaultCopyBuffer.
+func (cfs *CompositeFS) WithCopyBuffer(size int) *CompositeFS {
+	c := cfs.clone()
+	c.copyBuffer = size
+	return c
+}
+
+// copyFile copies src to dst through a pooled buffer.
+func (cfs *CompositeFS) copyFile(dst io.Writer, src io.Reader) (int64, error) {
+	size := cfs.copyBuffer
+	if size <= 0 {
+		size = DefaultCopyBuffer
+	}
+	pool, _ := copyPools.LoadOrStore(size, &sync.Pool{
+		New: func() any {
+			buf := make([]byte, size)
+			return &buf
+		},
+	})
+	buf := pool.(*sync.Pool).Get().(*[]byte)
+	defer pool.(*sync.Pool).Put(buf)
+
+	if _, ok := src.(*os.File); !ok {
+		// ReadFrom of writers such as *os.File falls back to io.Copy,
+		// which allocates a buffer of its own, unless both ends are
+		// OS files
+		dst = struct{ io.Writer }{dst}
+	}
+	return io.CopyBuffer(dst, src, *buf)
+}
@@ -0,0 +1,84 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+// readSizeFS records the largest read made on its files.
+type readSizeFS struct {
+	fstest.MapFS
+	largest atomic.Int64
+}
+
+type readSizeFile struct {
+	fs.File
+	fsys *readSizeFS
+}
+
+func (r *readSizeFS) Open(name string) (fs.File, error) {
+	file, err := r.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &readSizeFile{File: file, fsys: r}, nil
+}
+
+func (f *readSizeFile) Read(b []byte) (int, error) {
+	for {
+		largest := f.fsys.largest.Load()
+		if int64(len(b)) <= largest || f.fsys.largest.CompareAndSwap(largest, int64(len(b))) {
+			break
+		}
+	}
+	return f.File.Read(b)
+}
+
+func TestWithCopyBuffer(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	layer := &readSizeFS{MapFS: fstest.MapFS{
+		"assets/app.js": &fstest.MapFile{Data: content, Mode: 0o644},
+	}}
+	composite := cfs.NewCompositeFS(layer).WithCopyBuffer(64)
+
+	dst := t.TempDir()
+	if err := composite.CopyAll(".", dst); err != nil {
+		t.Fatalf("CopyAll failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "assets", "app.js"))
+	if err != nil || !bytes.Equal(data, content) {
+		t.Fatalf("Expected the content to be copied, got %d bytes, %v", len(data), err)
+	}
+	if got := layer.largest.Load(); got != 64 {
+		t.Fatalf("Expected reads through a 64 byte buffer, got %d", got)
+	}
+
+	// the default buffer applies to composites without the option
+	layer.largest.Store(0)
+	var buf bytes.Buffer
+	if _, err := cfs.NewCompositeFS(layer).CopyFile("assets/app.js", &buf); err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+	if got := layer.largest.Load(); got != cfs.DefaultCopyBuffer {
+		t.Fatalf("Expected reads through the default buffer, got %d", got)
+	}
+	if !bytes.Equal(buf.Bytes(), content) {
+		t.Fatal("Expected CopyFile to copy the content")
+	}
+}
+
+func TestStackHashIncludesCopyBuffer(t *testing.T) {
+	base := cfs.NewCompositeFS(fstest.MapFS{})
+	if base.WithCopyBuffer(1<<20).StackHash() == base.StackHash() {
+		t.Fatal("Expected the copy buffer size to change the stack hash")
+	}
+}
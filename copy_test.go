@@ -0,0 +1,158 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestCopyFile(t *testing.T) {
+	composite := cfs.NewCompositeFS(
+		fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("top")}},
+		fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("bottom")}},
+	)
+
+	var buf bytes.Buffer
+	n, err := composite.CopyFile("a.txt", &buf)
+	if err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+	if n != 3 || buf.String() != "top" {
+		t.Fatalf("Expected 3 bytes of %q, got %d bytes of %q", "top", n, buf.String())
+	}
+
+	if _, err := composite.CopyFile(".", &buf); err == nil {
+		t.Fatal("Expected error copying a directory")
+	}
+}
+
+func TestCopyAllWithProgress(t *testing.T) {
+	mtime := time.Date(2021, 5, 6, 7, 8, 9, 0, time.UTC)
+	composite := cfs.NewCompositeFS(
+		fstest.MapFS{"site/index.html": &fstest.MapFile{Data: []byte("theme index"), Mode: 0o600, ModTime: mtime}},
+		fstest.MapFS{
+			"site/index.html":    &fstest.MapFile{Data: []byte("base index")},
+			"site/css/style.css": &fstest.MapFile{Data: []byte("css")},
+			"other/ignored.txt":  &fstest.MapFile{Data: []byte("ignored")},
+		},
+	)
+	dst := t.TempDir()
+
+	var events []cfs.CopyProgress
+	err := composite.CopyAll("site", dst, cfs.WithProgress(func(p cfs.CopyProgress) {
+		events = append(events, p)
+	}))
+	if err != nil {
+		t.Fatalf("CopyAll failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dst, "index.html"))
+	if err != nil || string(data) != "theme index" {
+		t.Fatalf("Expected theme index, got %q (%v)", data, err)
+	}
+	info, err := os.Stat(filepath.Join(dst, "index.html"))
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 || !info.ModTime().Equal(mtime) {
+		t.Errorf("Expected mode 0600 and mtime %v, got %v and %v", mtime, info.Mode().Perm(), info.ModTime())
+	}
+	if _, err := os.Stat(filepath.Join(dst, "css", "style.css")); err != nil {
+		t.Errorf("Expected nested file to be copied: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 progress events, got %d", len(events))
+	}
+	last := events[len(events)-1]
+	if last.Files != 2 || last.TotalFiles != 2 || last.Bytes != last.TotalBytes {
+		t.Fatalf("Unexpected final progress %+v", last)
+	}
+}
+
+func TestCopyAllReportsPartialFailures(t *testing.T) {
+	composite := cfs.NewCompositeFS(cfs.RejectLargeFiles(fstest.MapFS{
+		"big.bin":   &fstest.MapFile{Data: bytes.Repeat([]byte("x"), 100)},
+		"small.txt": &fstest.MapFile{Data: []byte("small")},
+	}, 10))
+	dst := t.TempDir()
+
+	var failed []string
+	err := composite.CopyAll(".", dst, cfs.WithProgress(func(p cfs.CopyProgress) {
+		if p.Err != nil {
+			failed = append(failed, p.Path)
+		}
+	}))
+	if !errors.Is(err, cfs.ErrFileTooLarge) {
+		t.Fatalf("Expected ErrFileTooLarge, got %v", err)
+	}
+	if len(failed) != 1 || failed[0] != "big.bin" {
+		t.Fatalf("Expected big.bin to fail, got %v", failed)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "small.txt")); err != nil {
+		t.Fatalf("Expected small.txt to be copied: %v", err)
+	}
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) {
+		t.Fatalf("Expected a path error, got %T", err)
+	}
+}
+
+func TestCopyAllWithPatterns(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{
+		"public/index.html":       &fstest.MapFile{Data: []byte("index")},
+		"public/css/app.css":      &fstest.MapFile{Data: []byte("css")},
+		"public/css/app.css.map":  &fstest.MapFile{Data: []byte("map")},
+		"public/drafts/wip.html":  &fstest.MapFile{Data: []byte("wip")},
+		"templates/layout.html":   &fstest.MapFile{Data: []byte("layout")},
+		"templates/partials/a.md": &fstest.MapFile{Data: []byte("a")},
+	})
+
+	dst := t.TempDir()
+	var copied []string
+	err := composite.CopyAll(".", dst,
+		cfs.WithInclude("public/**"),
+		cfs.WithExclude("**/*.map", "public/drafts"),
+		cfs.WithDryRun(),
+		cfs.WithProgress(func(p cfs.CopyProgress) {
+			copied = append(copied, p.Path)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("CopyAll failed: %v", err)
+	}
+	sort.Strings(copied)
+	if strings.Join(copied, ",") != "public/css/app.css,public/index.html" {
+		t.Errorf("Expected the public files without maps and drafts, got %v", copied)
+	}
+	if entries, _ := os.ReadDir(dst); len(entries) != 0 {
+		t.Errorf("Expected a dry run to write nothing, got %d entries", len(entries))
+	}
+
+	if err := composite.CopyAll(".", dst, cfs.WithInclude("public/**"), cfs.WithExclude("**/*.map", "public/drafts")); err != nil {
+		t.Fatalf("CopyAll failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "public", "css", "app.css")); err != nil {
+		t.Errorf("Expected included file to be copied: %v", err)
+	}
+	for _, skipped := range []string{"templates", "public/drafts", "public/css/app.css.map"} {
+		if _, err := os.Stat(filepath.Join(dst, filepath.FromSlash(skipped))); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Expected %s to be skipped, got %v", skipped, err)
+		}
+	}
+
+	if err := composite.CopyAll(".", dst, cfs.WithInclude("[")); err == nil {
+		t.Error("Expected an error for a malformed pattern")
+	}
+}
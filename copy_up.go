@@ -0,0 +1,87 @@
+package cfs
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// CopyUpEvent describes a file copied from a lower layer into the
+// write layer before being modified.
+type CopyUpEvent struct {
+	// Name is the path that was copied up.
+	Name string
+	// Layer is the index of the lower layer the file was copied from.
+	Layer int
+	// Dir reports whether the copied path is a directory.
+	Dir bool
+}
+
+// WithCopyUpHook returns a copy of the composite that calls fn every
+// time a file is copied up from a lower layer into the write layer.
+func (cfs *CompositeFS) WithCopyUpHook(fn func(CopyUpEvent)) *CompositeFS {
+	c := cfs.clone()
+	c.onCopyUp = fn
+	return c
+}
+
+// CopyUp copies the named file from the lower layer that currently
+// provides it into the write layer. It is a no-op when the write layer
+// already contains the file.
+func (cfs *CompositeFS) CopyUp(name string) error {
+	name, err := cfs.writePath("copyup", name)
+	if err != nil {
+		return err
+	}
+	defer cfs.record("copyup", name)()
+	return cfs.copyUp(name)
+}
+
+// copyUp makes sure name exists in the write layer, copying content,
+// mode and (when supported) modification time from the lower layer
+// that currently resolves it.
+func (cfs *CompositeFS) copyUp(name string) error {
+	if _, err := fs.Stat(cfs.writer, name); err == nil {
+		return nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	layer, info, err := cfs.resolve(name)
+	if err != nil {
+		return err
+	}
+	if sameLayer(cfs.filesystems[layer], cfs.writer) {
+		// the write layer provides it after all
+		return nil
+	}
+
+	event := CopyUpEvent{Name: name, Layer: layer, Dir: info.IsDir()}
+
+	if info.IsDir() {
+		if err := cfs.writer.MkdirAll(name, info.Mode().Perm()|0o700); err != nil {
+			return err
+		}
+	} else {
+		data, err := fs.ReadFile(cfs.filesystems[layer], name)
+		if err != nil {
+			return &fs.PathError{Op: "copyup", Path: name, Err: err}
+		}
+		if err := cfs.ensureParent(name); err != nil {
+			return err
+		}
+		if err := cfs.writer.WriteFile(name, data, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+
+	if meta, ok := cfs.writer.(MetadataFS); ok && !info.ModTime().IsZero() {
+		if err := meta.Chtimes(name, info.ModTime(), info.ModTime()); err != nil {
+			return err
+		}
+	}
+
+	if cfs.onCopyUp != nil {
+		cfs.onCopyUp(event)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestCopyUpOnChmod(t *testing.T) {
+	writeDir := t.TempDir()
+	mtime := time.Date(2021, 5, 6, 7, 8, 9, 0, time.UTC)
+	base := fstest.MapFS{
+		"views/home.html": &fstest.MapFile{
+			Data:    []byte("base home"),
+			Mode:    0o644,
+			ModTime: mtime,
+		},
+	}
+
+	var events []cfs.CopyUpEvent
+	composite := cfs.NewWritableFS(cfs.NewDirWriteFS(writeDir), base).
+		WithCopyUpHook(func(e cfs.CopyUpEvent) {
+			events = append(events, e)
+		})
+
+	if err := composite.Chmod("views/home.html", 0o600); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(writeDir, "views", "home.html"))
+	if err != nil {
+		t.Fatalf("Expected copied-up file on disk: %v", err)
+	}
+	if string(data) != "base home" {
+		t.Fatalf("Expected copied content %q, got %q", "base home", string(data))
+	}
+
+	info, err := composite.Stat("views/home.html")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("Expected preserved modtime %v, got %v", mtime, info.ModTime())
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 copy-up event, got %d", len(events))
+	}
+	if events[0].Name != "views/home.html" || events[0].Layer != 1 {
+		t.Errorf("Unexpected copy-up event: %+v", events[0])
+	}
+
+	// a second modification must not copy up again
+	if err := composite.Chmod("views/home.html", 0o644); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("Expected no further copy-up events, got %d", len(events))
+	}
+}
+
+func TestCopyUpOnRename(t *testing.T) {
+	base := fstest.MapFS{
+		"old.txt": &fstest.MapFile{Data: []byte("content")},
+	}
+
+	composite := cfs.NewWritableFS(cfs.NewDirWriteFS(t.TempDir()), base)
+
+	if err := composite.Rename("old.txt", "new.txt"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	testReadFile(t, composite, "new.txt", "content")
+	testReadFile(t, composite, "old.txt", "content")
+}
+
+func TestCopyUpExplicit(t *testing.T) {
+	writeDir := t.TempDir()
+	base := fstest.MapFS{
+		"a/b.txt": &fstest.MapFile{Data: []byte("b")},
+	}
+
+	composite := cfs.NewWritableFS(cfs.NewDirWriteFS(writeDir), base)
+
+	if err := composite.CopyUp("a/b.txt"); err != nil {
+		t.Fatalf("CopyUp failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(writeDir, "a", "b.txt")); err != nil {
+		t.Fatalf("Expected file in write layer: %v", err)
+	}
+}
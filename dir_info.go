@@ -0,0 +1,67 @@
+package cfs
+
+import (
+	"io/fs"
+	"time"
+)
+
+// WithDirMode returns a copy of the composite reporting perm as the
+// permission bits of directories merged in overlay mode, instead of
+// those of the topmost layer providing the directory.
+func (cfs *CompositeFS) WithDirMode(perm fs.FileMode) *CompositeFS {
+	c := cfs.clone()
+	c.dirMode = perm.Perm()
+	return c
+}
+
+// mergedDirInfo describes a directory merged from several layers. It
+// keeps the info of the topmost layer, reporting the latest ModTime of
+// all contributing layers so caches keyed on it notice changes in any
+// of them.
+type mergedDirInfo struct {
+	fs.FileInfo
+	modTime time.Time
+	mode    fs.FileMode
+}
+
+func (i mergedDirInfo) ModTime() time.Time { return i.modTime }
+func (i mergedDirInfo) Mode() fs.FileMode  { return i.mode }
+
+// mergeDirInfo combines the infos of a directory provided by several
+// layers, topmost first.
+func (cfs *CompositeFS) mergeDirInfo(infos []fs.FileInfo) fs.FileInfo {
+	if len(infos) == 0 {
+		return nil
+	}
+	merged := mergedDirInfo{FileInfo: infos[0], modTime: infos[0].ModTime(), mode: infos[0].Mode()}
+	for _, info := range infos[1:] {
+		if info.ModTime().After(merged.modTime) {
+			merged.modTime = info.ModTime()
+		}
+	}
+	if cfs.dirMode != 0 {
+		merged.mode = fs.ModeDir | cfs.dirMode
+	}
+	if len(infos) == 1 && cfs.dirMode == 0 {
+		return infos[0]
+	}
+	return merged
+}
+
+// statMergedDir merges info, the directory name found in layer i, with
+// the same directory in the layers probed after it.
+func (cfs *CompositeFS) statMergedDir(i int, name string, info fs.FileInfo) fs.FileInfo {
+	infos := []fs.FileInfo{info}
+	order := cfs.probeOrder(name)
+	for n := probePosition(order, i) + 1; n < len(cfs.filesystems); n++ {
+		j := layerAt(order, n)
+		fsys := cfs.filesystems[j]
+		if skipLayer(fsys, name) {
+			continue
+		}
+		if lower, err := cfs.statLayerCached(j, fsys, name); err == nil && lower.IsDir() {
+			infos = append(infos, lower)
+		}
+	}
+	return cfs.mergeDirInfo(infos)
+}
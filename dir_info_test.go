@@ -0,0 +1,87 @@
+package cfs_test
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func newDirInfoStack() *cfs.CompositeFS {
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	top := fstest.MapFS{
+		"views":           &fstest.MapFile{Mode: fs.ModeDir | 0o755, ModTime: older},
+		"views/home.html": &fstest.MapFile{Data: []byte("home")},
+	}
+	bottom := fstest.MapFS{
+		"views":            &fstest.MapFile{Mode: fs.ModeDir | 0o700, ModTime: newer},
+		"views/about.html": &fstest.MapFile{Data: []byte("about")},
+	}
+	return cfs.NewOverlayFS(top, bottom)
+}
+
+func TestOverlayDirInfoModTime(t *testing.T) {
+	composite := newDirInfoStack()
+	want := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	file, err := composite.Open("views")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if !info.ModTime().Equal(want) {
+		t.Errorf("Expected the latest ModTime %v, got %v", want, info.ModTime())
+	}
+	if info.Mode() != fs.ModeDir|0o755 {
+		t.Errorf("Expected the topmost layer's mode, got %v", info.Mode())
+	}
+
+	info, err = composite.Stat("views")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if !info.ModTime().Equal(want) || info.Name() != "views" {
+		t.Errorf("Expected merged info for views, got %v %v", info.Name(), info.ModTime())
+	}
+}
+
+func TestWithDirMode(t *testing.T) {
+	composite := newDirInfoStack().WithDirMode(0o555)
+
+	for _, stat := range []func() (fs.FileInfo, error){
+		func() (fs.FileInfo, error) { return composite.Stat("views") },
+		func() (fs.FileInfo, error) {
+			file, err := composite.Open("views")
+			if err != nil {
+				return nil, err
+			}
+			defer file.Close()
+			return file.Stat()
+		},
+	} {
+		info, err := stat()
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if info.Mode() != fs.ModeDir|0o555 || !info.IsDir() {
+			t.Errorf("Expected mode %v, got %v", fs.ModeDir|0o555, info.Mode())
+		}
+	}
+
+	// files keep their own mode
+	info, err := composite.Stat("views/home.html")
+	if err != nil || info.Mode() != 0 {
+		t.Errorf("Expected the file mode unchanged, got %v, %v", info, err)
+	}
+
+	if composite.StackHash() == newDirInfoStack().StackHash() {
+		t.Error("Expected WithDirMode to change the stack hash")
+	}
+}
@@ -0,0 +1,217 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// DirWriteFS is a disk-backed WriteFS rooted at a directory.
+// WriteFile is atomic: content is written to a temporary file in the
+// target directory which is then renamed into place, so readers never
+// observe partially written files.
+type DirWriteFS struct {
+	root  string
+	fsys  fs.FS
+	roles []string
+}
+
+// NewDirWriteFS creates a DirWriteFS rooted at the given directory.
+func NewDirWriteFS(root string) *DirWriteFS {
+	return &DirWriteFS{
+		root: root,
+		fsys: os.DirFS(root),
+	}
+}
+
+// Root returns the directory the filesystem is rooted at.
+func (d *DirWriteFS) Root() string {
+	return d.root
+}
+
+// WithRoles returns a copy of the filesystem tagged with roles, such as
+// RoleWrite.
+func (d *DirWriteFS) WithRoles(roles ...string) *DirWriteFS {
+	c := *d
+	c.roles = append(slices.Clone(d.roles), roles...)
+	return &c
+}
+
+// Roles implements RoledFS.
+func (d *DirWriteFS) Roles() []string {
+	return slices.Clone(d.roles)
+}
+
+// Open implements fs.FS.
+func (d *DirWriteFS) Open(name string) (fs.File, error) {
+	return d.fsys.Open(name)
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (d *DirWriteFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(d.fsys, name)
+}
+
+// Stat implements fs.StatFS.
+func (d *DirWriteFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(d.fsys, name)
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (d *DirWriteFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(d.fsys, name)
+}
+
+// Sub returns a DirWriteFS rooted at dir.
+func (d *DirWriteFS) Sub(dir string) (fs.FS, error) {
+	full, err := d.join("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(full)
+	if err != nil {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: unwrapPathError(err)}
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: errors.New("not a directory")}
+	}
+	return NewDirWriteFS(full).WithRoles(d.roles...), nil
+}
+
+// WriteFile atomically writes data to the named file, creating it if
+// necessary. The parent directory must exist.
+func (d *DirWriteFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	full, err := d.join("write", name)
+	if err != nil {
+		return err
+	}
+
+	dir, base := filepath.Split(full)
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return &fs.PathError{Op: "write", Path: name, Err: unwrapPathError(err)}
+	}
+	tmpName := tmp.Name()
+
+	// cleanup removes the temporary file on any failure path
+	cleanup := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpName)
+		return &fs.PathError{Op: "write", Path: name, Err: unwrapPathError(err)}
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		return cleanup(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return cleanup(err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return cleanup(err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return &fs.PathError{Op: "write", Path: name, Err: unwrapPathError(err)}
+	}
+	if err := os.Rename(tmpName, full); err != nil {
+		os.Remove(tmpName)
+		return &fs.PathError{Op: "write", Path: name, Err: unwrapPathError(err)}
+	}
+	return nil
+}
+
+// Mkdir creates the named directory.
+func (d *DirWriteFS) Mkdir(name string, perm fs.FileMode) error {
+	full, err := d.join("mkdir", name)
+	if err != nil {
+		return err
+	}
+	if err := os.Mkdir(full, perm); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: unwrapPathError(err)}
+	}
+	return nil
+}
+
+// MkdirAll creates the named directory along with any missing parents.
+func (d *DirWriteFS) MkdirAll(name string, perm fs.FileMode) error {
+	full, err := d.join("mkdir", name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(full, perm); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: unwrapPathError(err)}
+	}
+	return nil
+}
+
+// Remove removes the named file or empty directory.
+func (d *DirWriteFS) Remove(name string) error {
+	full, err := d.join("remove", name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(full); err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: unwrapPathError(err)}
+	}
+	return nil
+}
+
+// Rename renames oldname to newname, replacing newname if it exists.
+func (d *DirWriteFS) Rename(oldname, newname string) error {
+	oldFull, err := d.join("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newFull, err := d.join("rename", newname)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(oldFull, newFull); err != nil {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: unwrapLinkError(err)}
+	}
+	return nil
+}
+
+// Chmod changes the mode of the named file.
+func (d *DirWriteFS) Chmod(name string, mode fs.FileMode) error {
+	full, err := d.join("chmod", name)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(full, mode); err != nil {
+		return &fs.PathError{Op: "chmod", Path: name, Err: unwrapPathError(err)}
+	}
+	return nil
+}
+
+// Chtimes changes the access and modification times of the named file.
+func (d *DirWriteFS) Chtimes(name string, atime, mtime time.Time) error {
+	full, err := d.join("chtimes", name)
+	if err != nil {
+		return err
+	}
+	if err := os.Chtimes(full, atime, mtime); err != nil {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: unwrapPathError(err)}
+	}
+	return nil
+}
+
+func (d *DirWriteFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(d.root, filepath.FromSlash(name)), nil
+}
+
+func unwrapLinkError(err error) error {
+	var linkErr *os.LinkError
+	if errors.As(err, &linkErr) {
+		return linkErr.Err
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DiscoveryManifest is the name of the optional file in a discovery
+// directory that declares layer priority, one layer name per line,
+// highest priority first. Blank lines and lines starting with "#" are
+// ignored.
+const DiscoveryManifest = "layers.txt"
+
+// DiscoverLayers finds the subdirectories and archives of the OS
+// directory dir whose names match pattern (path.Match syntax) and
+// returns them as named layers. Layers are named after the directory,
+// or the archive stem, and ordered as declared in DiscoveryManifest
+// with undeclared layers following in lexical order. Call it again to
+// pick up plugins added or removed since the last scan.
+func DiscoverLayers(dir, pattern string) ([]fs.FS, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type found struct {
+		name  string
+		layer fs.FS
+	}
+	var layers []found
+	for _, entry := range entries {
+		if entry.Name() == DiscoveryManifest {
+			continue
+		}
+		if ok, _ := path.Match(pattern, entry.Name()); !ok {
+			continue
+		}
+
+		full := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(full)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			layers = append(layers, found{name: entry.Name(), layer: os.DirFS(full)})
+			continue
+		}
+
+		ext := archiveExt(entry.Name())
+		if ext == "" {
+			continue
+		}
+		data, err := os.ReadFile(full)
+		if err != nil {
+			return nil, err
+		}
+		archive, err := archiveOpeners[ext](data)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: full, Err: err}
+		}
+		layers = append(layers, found{name: entry.Name()[:len(entry.Name())-len(ext)], layer: archive})
+	}
+
+	order, err := readDiscoveryManifest(filepath.Join(dir, DiscoveryManifest))
+	if err != nil {
+		return nil, err
+	}
+	rank := func(name string) int {
+		if i, ok := order[name]; ok {
+			return i
+		}
+		return len(order)
+	}
+	sort.SliceStable(layers, func(i, j int) bool {
+		ri, rj := rank(layers[i].name), rank(layers[j].name)
+		if ri != rj {
+			return ri < rj
+		}
+		return layers[i].name < layers[j].name
+	})
+
+	out := make([]fs.FS, len(layers))
+	for i, l := range layers {
+		out[i] = NewLayer(l.name, l.layer)
+	}
+	return out, nil
+}
+
+// WithDiscoveredLayers returns a copy of the composite with the layers
+// found by DiscoverLayers appended as the lowest priority layers.
+func (cfs *CompositeFS) WithDiscoveredLayers(dir, pattern string) (*CompositeFS, error) {
+	discovered, err := DiscoverLayers(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	layers := make([]fs.FS, 0, len(cfs.filesystems)+len(discovered))
+	layers = append(layers, cfs.filesystems...)
+	layers = append(layers, discovered...)
+	return cfs.withLayers(layers), nil
+}
+
+// readDiscoveryManifest returns the position of every layer declared in
+// the manifest at name. A missing manifest declares nothing.
+func readDiscoveryManifest(name string) (map[string]int, error) {
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	order := make(map[string]int)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, ok := order[line]; !ok {
+			order[line] = len(order)
+		}
+	}
+	return order, scanner.Err()
+}
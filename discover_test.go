@@ -0,0 +1,40 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestDiscoverLayers(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "plugin-b", "views", "b.html"), []byte("b"))
+	writeTestFile(t, filepath.Join(dir, "plugin-a", "views", "shared.html"), []byte("a"))
+	writeTestFile(t, filepath.Join(dir, "plugin-c.zip"), zipData(t, map[string]string{"views/shared.html": "c"}))
+	writeTestFile(t, filepath.Join(dir, "other", "x.html"), []byte("ignored"))
+
+	layers, err := cfs.DiscoverLayers(dir, "plugin-*")
+	if err != nil {
+		t.Fatalf("DiscoverLayers failed: %v", err)
+	}
+	var names []string
+	for _, layer := range layers {
+		names = append(names, cfs.LayerName(layer))
+	}
+	if !reflect.DeepEqual(names, []string{"plugin-a", "plugin-b", "plugin-c"}) {
+		t.Fatalf("Expected lexical order, got %v", names)
+	}
+
+	writeTestFile(t, filepath.Join(dir, cfs.DiscoveryManifest), []byte("# priority\nplugin-c\n"))
+
+	composite, err := cfs.NewCompositeFS().WithDiscoveredLayers(dir, "plugin-*")
+	if err != nil {
+		t.Fatalf("WithDiscoveredLayers failed: %v", err)
+	}
+	testReadFile(t, composite, "views/shared.html", "c")
+	testReadFile(t, composite, "views/b.html", "b")
+}
@@ -0,0 +1,732 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DiskCacheOption configures a DiskCache.
+type DiskCacheOption func(*DiskCache)
+
+// WithCacheMaxSize bounds the total size in bytes of the cached
+// content. Least recently used entries are evicted once it is exceeded.
+func WithCacheMaxSize(bytes int64) DiskCacheOption {
+	return func(c *DiskCache) {
+		c.maxSize = bytes
+	}
+}
+
+// WithStaleWhileRevalidate serves expired entries immediately and
+// refreshes them from the remote layer in the background. onError, if
+// not nil, is called when a background refresh fails.
+func WithStaleWhileRevalidate(onError func(name string, err error)) DiskCacheOption {
+	return func(c *DiskCache) {
+		c.staleWhileRevalidate = true
+		c.onRefreshError = onError
+	}
+}
+
+// WithCacheCompression stores cached blobs gzip-compressed at the given
+// compress/gzip level, such as gzip.BestSpeed, trading CPU for cache
+// capacity. It is shorthand for WithCacheCodec(GzipCodec(level)). Gzip
+// is used because it is the only codec in the standard library and the
+// module has no dependencies; codecs such as zstd can be plugged in with
+// WithCacheCodec.
+func WithCacheCompression(level int) DiskCacheOption {
+	return WithCacheCodec(GzipCodec(level))
+}
+
+// WithCacheCodec stores cached blobs compressed with codec. Blobs are
+// decompressed transparently, into memory, when they are opened, so only
+// files up to MaxCompressedEntry bytes are compressed; larger ones are
+// stored as is and read from disk. Content that does not shrink, such as
+// images that are compressed already, is stored as is too. Entries
+// cached before compression was enabled stay readable, as do gzip
+// entries; entries written with another codec are fetched again.
+func WithCacheCodec(codec CacheCodec) DiskCacheOption {
+	return func(c *DiskCache) {
+		c.codec = codec
+	}
+}
+
+// CacheCodec compresses the blobs of a DiskCache.
+type CacheCodec interface {
+	// Name identifies the codec in the entry metadata. It must not
+	// change between runs, or the entries are fetched again.
+	Name() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// GzipCodec returns the compress/gzip codec at level.
+func GzipCodec(level int) CacheCodec {
+	return gzipCodec{level: level}
+}
+
+type gzipCodec struct {
+	level int
+}
+
+func (gzipCodec) Name() string { return "gzip" }
+
+func (g gzipCodec) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, g.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decompress(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(zr)
+}
+
+// MaxCompressedEntry is the size of the largest file WithCacheCompression
+// compresses, bounding the memory an open of a compressed entry takes.
+const MaxCompressedEntry = 1 << 20
+
+// WithCompressedAccounting makes Size and WithCacheMaxSize count the
+// bytes the blobs take on disk, compressed or not, instead of the size
+// of the cached content, so the bound applies to the disk space used.
+func WithCompressedAccounting() DiskCacheOption {
+	return func(c *DiskCache) {
+		c.storedSize = true
+	}
+}
+
+// DiskCache is a persistent, read-through cache for slow or remote
+// layers. Files fetched through a wrapped layer are materialized on
+// disk and served locally until their TTL expires. Cache entries
+// survive restarts. Entries are keyed by path, so a cache shared by
+// several remotes must wrap each of them with WrapNamed.
+type DiskCache struct {
+	dir     string
+	ttl     time.Duration
+	maxSize int64
+	now     func() time.Time
+
+	staleWhileRevalidate bool
+	onRefreshError       func(name string, err error)
+
+	codec      CacheCodec
+	storedSize bool
+
+	mu         sync.Mutex
+	entries    map[string]*cacheEntry // by entryKey
+	size       int64
+	loaded     bool
+	refreshing map[string]bool
+	wg         sync.WaitGroup
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// cacheEntry is the metadata persisted next to each cached blob.
+type cacheEntry struct {
+	// Source is the name the remote was wrapped under with WrapNamed,
+	// empty for Wrap.
+	Source   string      `json:"source,omitempty"`
+	Name     string      `json:"name"`
+	Size     int64       `json:"size"`
+	Mode     fs.FileMode `json:"mode"`
+	ModTime  time.Time   `json:"mod_time"`
+	Fetched  time.Time   `json:"fetched"`
+	Accessed time.Time   `json:"-"`
+	// Stored is the size of the blob on disk, Compressed whether it
+	// holds compressed content and Codec the name of the codec, empty
+	// for gzip. Entries persisted without them are uncompressed.
+	Stored     int64  `json:"stored,omitempty"`
+	Compressed bool   `json:"compressed,omitempty"`
+	Codec      string `json:"codec,omitempty"`
+}
+
+// key returns the index key of entry.
+func (e *cacheEntry) key() string {
+	return entryKey(e.Source, e.Name)
+}
+
+// entryKey returns the index key of name cached for source.
+func entryKey(source, name string) string {
+	if source == "" {
+		return name
+	}
+	return source + "\x00" + name
+}
+
+// cost returns the bytes entry counts for towards the cache size.
+func (c *DiskCache) cost(entry *cacheEntry) int64 {
+	if c.storedSize && entry.Stored > 0 {
+		return entry.Stored
+	}
+	return entry.Size
+}
+
+// NewDiskCache creates a cache storing content under dir. Entries older
+// than ttl are fetched again; a ttl of zero never expires entries.
+func NewDiskCache(dir string, ttl time.Duration, opts ...DiskCacheOption) *DiskCache {
+	c := &DiskCache{
+		dir:        dir,
+		ttl:        ttl,
+		now:        time.Now,
+		entries:    make(map[string]*cacheEntry),
+		refreshing: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Wrap returns a layer that serves files from remote through the cache.
+// Directory listings are always read from remote. A cache serves a
+// single remote through Wrap; use WrapNamed for every further one.
+func (c *DiskCache) Wrap(remote fs.FS) fs.FS {
+	return &cachedFS{cache: c, remote: remote}
+}
+
+// WrapNamed is like Wrap, but keeps the entries of remote apart from
+// those of other remotes sharing the cache, under source. source must
+// be a single path element that stays the same across restarts, such
+// as the host of the remote, so persisted entries are found again.
+// WrapNamed panics if source is not a valid path element.
+func (c *DiskCache) WrapNamed(source string, remote fs.FS) fs.FS {
+	if !fs.ValidPath(source) || source == "." || strings.Contains(source, "/") {
+		panic(fmt.Sprintf("cfs: invalid cache source %q", source))
+	}
+	return &cachedFS{cache: c, remote: remote, source: source}
+}
+
+// Size returns the total size in bytes of the cached content, or of the
+// blobs on disk with WithCompressedAccounting.
+func (c *DiskCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	return c.size
+}
+
+// Stats returns the number of files served from the cache and fetched
+// from the wrapped layers.
+func (c *DiskCache) Stats() CacheStats {
+	return newCacheStats(c.hits.Load(), c.misses.Load())
+}
+
+// Wait blocks until every background refresh has finished.
+func (c *DiskCache) Wait() {
+	c.wg.Wait()
+}
+
+// Invalidate drops name from the cache, for every source.
+func (c *DiskCache) Invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	name = path.Clean(name)
+	for key, entry := range c.entries {
+		if entry.Name == name {
+			c.evict(key)
+		}
+	}
+}
+
+// invalidate drops name cached for source.
+func (c *DiskCache) invalidate(source, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	c.evict(entryKey(source, path.Clean(name)))
+}
+
+// Purge drops every cached entry.
+func (c *DiskCache) Purge() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*cacheEntry)
+	c.size = 0
+	c.loaded = true
+	return os.RemoveAll(c.dir)
+}
+
+// sourceDir returns the directory holding the entries of source.
+func (c *DiskCache) sourceDir(source string) string {
+	if source == "" {
+		return c.dir
+	}
+	return filepath.Join(c.dir, "sources", source)
+}
+
+func (c *DiskCache) blobPath(source, name string) string {
+	return filepath.Join(c.sourceDir(source), "data", filepath.FromSlash(name))
+}
+
+func (c *DiskCache) metaPath(source, name string) string {
+	return filepath.Join(c.sourceDir(source), "meta", filepath.FromSlash(name)+".json")
+}
+
+// load reads the persisted index the first time the cache is used.
+// Callers must hold c.mu.
+func (c *DiskCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+
+	metaDirs := []string{filepath.Join(c.dir, "meta")}
+	sources, _ := os.ReadDir(filepath.Join(c.dir, "sources"))
+	for _, source := range sources {
+		metaDirs = append(metaDirs, filepath.Join(c.sourceDir(source.Name()), "meta"))
+	}
+	for _, metaDir := range metaDirs {
+		c.loadMeta(metaDir)
+	}
+}
+
+// loadMeta indexes the entries persisted under metaDir. Callers must
+// hold c.mu.
+func (c *DiskCache) loadMeta(metaDir string) {
+	filepath.WalkDir(metaDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, ".json") {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil
+		}
+		var entry cacheEntry
+		if json.Unmarshal(data, &entry) != nil || entry.Name == "" {
+			return nil
+		}
+		if _, err := os.Stat(c.blobPath(entry.Source, entry.Name)); err != nil {
+			return nil
+		}
+		entry.Accessed = entry.Fetched
+		c.entries[entry.key()] = &entry
+		c.size += c.cost(&entry)
+		return nil
+	})
+}
+
+// lookup returns the entry for name cached for source and whether it
+// is still fresh.
+func (c *DiskCache) lookup(source, name string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+
+	entry, ok := c.entries[entryKey(source, name)]
+	if !ok {
+		return nil, false
+	}
+	entry.Accessed = c.now()
+	fresh := c.ttl <= 0 || c.now().Sub(entry.Fetched) < c.ttl
+	copied := *entry
+	return &copied, fresh
+}
+
+// store materializes data for name cached for source and records its
+// metadata.
+func (c *DiskCache) store(source, name string, data []byte, info fs.FileInfo) (*cacheEntry, error) {
+	entry := &cacheEntry{
+		Source:  source,
+		Name:    name,
+		Size:    int64(len(data)),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+		Fetched: c.now(),
+	}
+	entry.Accessed = entry.Fetched
+
+	blob := data
+	if c.codec != nil && len(data) <= MaxCompressedEntry {
+		compressed, err := c.codec.Compress(data)
+		if err != nil {
+			return nil, err
+		}
+		if len(compressed) < len(data) {
+			blob = compressed
+			entry.Compressed = true
+			if name := c.codec.Name(); name != "gzip" {
+				entry.Codec = name
+			}
+		}
+	}
+	entry.Stored = int64(len(blob))
+
+	meta, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(c.blobPath(source, name), blob); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(c.metaPath(source, name), meta); err != nil {
+		return nil, err
+	}
+
+	key := entry.key()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	if old, ok := c.entries[key]; ok {
+		c.size -= c.cost(old)
+	}
+	c.entries[key] = entry
+	c.size += c.cost(entry)
+	c.enforceMaxSize(key)
+
+	copied := *entry
+	return &copied, nil
+}
+
+// enforceMaxSize evicts least recently used entries, never evicting
+// keep. Callers must hold c.mu.
+func (c *DiskCache) enforceMaxSize(keep string) {
+	if c.maxSize <= 0 || c.size <= c.maxSize {
+		return
+	}
+
+	entries := make([]*cacheEntry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		if entry.key() != keep {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Accessed.Before(entries[j].Accessed)
+	})
+
+	for _, entry := range entries {
+		if c.size <= c.maxSize {
+			return
+		}
+		c.evict(entry.key())
+	}
+}
+
+// evict removes the entry with the given key from the index and disk.
+// Callers must hold c.mu.
+func (c *DiskCache) evict(key string) {
+	entry, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	delete(c.entries, key)
+	c.size -= c.cost(entry)
+	os.Remove(c.blobPath(entry.Source, entry.Name))
+	os.Remove(c.metaPath(entry.Source, entry.Name))
+}
+
+// usable reports whether a cached entry may be served, starting a
+// background refresh when it is stale.
+func (c *DiskCache) usable(remote fs.FS, entry *cacheEntry, fresh bool) bool {
+	if entry == nil {
+		return false
+	}
+	if fresh {
+		return true
+	}
+	if !c.staleWhileRevalidate {
+		return false
+	}
+	c.revalidate(remote, entry.Source, entry.Name)
+	return true
+}
+
+// revalidate refreshes name from remote in the background unless a
+// refresh is already running.
+func (c *DiskCache) revalidate(remote fs.FS, source, name string) {
+	key := entryKey(source, name)
+	c.mu.Lock()
+	if c.refreshing[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshing[key] = true
+	c.wg.Add(1)
+	c.mu.Unlock()
+
+	go func() {
+		defer c.wg.Done()
+		defer func() {
+			c.mu.Lock()
+			delete(c.refreshing, key)
+			c.mu.Unlock()
+		}()
+
+		err := c.fetch(remote, source, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			// the remote copy is gone; stop serving it
+			c.invalidate(source, name)
+			return
+		}
+		if err != nil && c.onRefreshError != nil {
+			c.onRefreshError(name, err)
+		}
+	}()
+}
+
+// fetch reads name from remote and stores it in the cache.
+func (c *DiskCache) fetch(remote fs.FS, source, name string) error {
+	data, err := fs.ReadFile(remote, name)
+	if err != nil {
+		return err
+	}
+	info, err := fs.Stat(remote, name)
+	if err != nil {
+		return err
+	}
+	_, err = c.store(source, name, data, info)
+	return err
+}
+
+func writeFileAtomic(full string, data []byte) error {
+	dir := filepath.Dir(full)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), full); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// cachedFS is a layer reading through a DiskCache.
+type cachedFS struct {
+	cache  *DiskCache
+	remote fs.FS
+	source string
+}
+
+func (f *cachedFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if entry, fresh := f.cache.lookup(f.source, name); f.cache.usable(f.remote, entry, fresh) {
+		if file, err := f.openCached(entry); err == nil {
+			f.cache.hits.Add(1)
+			return file, nil
+		}
+	}
+
+	file, err := f.remote.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		return file, nil
+	}
+	f.cache.misses.Add(1)
+
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	entry, err := f.cache.store(f.source, name, data, info)
+	if err != nil {
+		// serve the fetched content even if it could not be cached
+		return &memFile{name: name, info: info, data: data}, nil
+	}
+	return f.openCached(entry)
+}
+
+func (f *cachedFS) openCached(entry *cacheEntry) (fs.File, error) {
+	file, err := os.Open(f.cache.blobPath(entry.Source, entry.Name))
+	if err != nil {
+		return nil, err
+	}
+	if !entry.Compressed {
+		return &cachedFile{File: file, info: entry.fileInfo()}, nil
+	}
+	defer file.Close()
+	codec, err := f.cache.codecFor(entry)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	data, err := codec.Decompress(blob)
+	if err != nil {
+		return nil, err
+	}
+	return &memFile{name: entry.Name, info: entry.fileInfo(), data: data}, nil
+}
+
+// codecFor returns the codec that decompresses entry.
+func (c *DiskCache) codecFor(entry *cacheEntry) (CacheCodec, error) {
+	switch {
+	case entry.Codec == "":
+		return gzipCodec{}, nil
+	case c.codec != nil && c.codec.Name() == entry.Codec:
+		return c.codec, nil
+	}
+	return nil, fmt.Errorf("cache entry %s: unknown codec %q", entry.Name, entry.Codec)
+}
+
+func (f *cachedFS) Stat(name string) (fs.FileInfo, error) {
+	if entry, fresh := f.cache.lookup(f.source, path.Clean(name)); f.cache.usable(f.remote, entry, fresh) {
+		return entry.fileInfo(), nil
+	}
+	return statLayer(f.remote, name)
+}
+
+func (f *cachedFS) ReadFile(name string) ([]byte, error) {
+	file, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+func (f *cachedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(f.remote, name)
+}
+
+// Sub returns the layer rooted at dir, reading through the same cache
+// entries as the whole layer.
+func (f *cachedFS) Sub(dir string) (fs.FS, error) {
+	return subLayer(f, dir)
+}
+
+// InvalidatePaths implements Invalidator by evicting the cached copies
+// of the changed files.
+func (f *cachedFS) InvalidatePaths(names ...string) {
+	for _, name := range names {
+		f.cache.invalidate(f.source, name)
+	}
+	invalidateLayer(f.remote, names)
+}
+
+func (e *cacheEntry) fileInfo() fs.FileInfo {
+	return &cachedInfo{entry: *e}
+}
+
+// cachedFile reports the metadata of the original remote file.
+type cachedFile struct {
+	*os.File
+	info fs.FileInfo
+}
+
+func (f *cachedFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+type cachedInfo struct {
+	entry cacheEntry
+}
+
+func (i *cachedInfo) Name() string       { return path.Base(i.entry.Name) }
+func (i *cachedInfo) Size() int64        { return i.entry.Size }
+func (i *cachedInfo) Mode() fs.FileMode  { return i.entry.Mode }
+func (i *cachedInfo) ModTime() time.Time { return i.entry.ModTime }
+func (i *cachedInfo) IsDir() bool        { return false }
+func (i *cachedInfo) Sys() interface{}   { return nil }
+
+// diskCacheOf returns the DiskCache fsys reads through, looking through
+// the wrappers of this package.
+func diskCacheOf(fsys fs.FS) *DiskCache {
+	if c, ok := fsys.(*cachedFS); ok {
+		return c.cache
+	}
+	if _, ok := fsys.(*CompositeFS); ok {
+		return nil
+	}
+	for _, inner := range wrappedLayers(fsys) {
+		if cache := diskCacheOf(inner); cache != nil {
+			return cache
+		}
+	}
+	return nil
+}
+
+// diskCacheStats returns the statistics of the DiskCache fsys reads
+// through, if any.
+func diskCacheStats(fsys fs.FS) *CacheStats {
+	cache := diskCacheOf(fsys)
+	if cache == nil {
+		return nil
+	}
+	stats := cache.Stats()
+	return &stats
+}
+
+// waitDiskCaches waits for the background refreshes of the disk caches
+// the layers read through, or until ctx is done.
+func waitDiskCaches(ctx context.Context, layers []fs.FS) error {
+	var caches []*DiskCache
+	for _, fsys := range layers {
+		if cache := diskCacheOf(fsys); cache != nil && !slices.Contains(caches, cache) {
+			caches = append(caches, cache)
+		}
+	}
+	if len(caches) == 0 {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, cache := range caches {
+			cache.Wait()
+		}
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
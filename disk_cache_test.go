@@ -0,0 +1,305 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestDiskCacheServesFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	remote := &countingFS{MapFS: fstest.MapFS{
+		"assets/app.js": &fstest.MapFile{Data: []byte("remote js"), Mode: 0o640},
+	}}
+
+	composite := cfs.NewCompositeFS(fstest.MapFS{}, cfs.NewDiskCache(dir, time.Hour).Wrap(remote))
+
+	testReadFile(t, composite, "assets/app.js", "remote js")
+	fetched := remote.calls.Load()
+
+	testReadFile(t, composite, "assets/app.js", "remote js")
+	if got := remote.calls.Load(); got != fetched {
+		t.Fatalf("Expected cached read not to reach remote, got %d calls after %d", got, fetched)
+	}
+
+	info, err := composite.Stat("assets/app.js")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0o640 || info.Size() != int64(len("remote js")) {
+		t.Fatalf("Expected remote metadata, got mode %v size %d", info.Mode(), info.Size())
+	}
+
+	// a new cache over the same directory reuses the stored content
+	remote.MapFS = fstest.MapFS{}
+	restarted := cfs.NewDiskCache(dir, time.Hour).Wrap(remote)
+	testReadFile(t, restarted, "assets/app.js", "remote js")
+}
+
+func TestDiskCacheTTLExpires(t *testing.T) {
+	remote := fstest.MapFS{
+		"data.json": &fstest.MapFile{Data: []byte("v1")},
+	}
+	layer := cfs.NewDiskCache(t.TempDir(), 10*time.Millisecond).Wrap(remote)
+
+	testReadFile(t, layer, "data.json", "v1")
+	remote["data.json"] = &fstest.MapFile{Data: []byte("v2")}
+	testReadFile(t, layer, "data.json", "v1")
+
+	time.Sleep(20 * time.Millisecond)
+	testReadFile(t, layer, "data.json", "v2")
+}
+
+func TestDiskCacheMaxSizeEvicts(t *testing.T) {
+	remote := fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("aaaaaaaaaa")},
+		"b.txt": &fstest.MapFile{Data: []byte("bbbbbbbbbb")},
+		"c.txt": &fstest.MapFile{Data: []byte("cccccccccc")},
+	}
+	cache := cfs.NewDiskCache(t.TempDir(), 0, cfs.WithCacheMaxSize(25))
+	layer := cache.Wrap(remote)
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if _, err := fs.ReadFile(layer, name); err != nil {
+			t.Fatalf("ReadFile %s failed: %v", name, err)
+		}
+	}
+	if size := cache.Size(); size != 20 {
+		t.Fatalf("Expected 20 cached bytes, got %d", size)
+	}
+
+	delete(remote, "a.txt")
+	if _, err := fs.ReadFile(layer, "a.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected evicted a.txt to be fetched again, got %v", err)
+	}
+	testReadFile(t, layer, "c.txt", "cccccccccc")
+}
+
+func TestDiskCacheCompression(t *testing.T) {
+	dir := t.TempDir()
+	text := bytes.Repeat([]byte("compressible "), 100)
+	remote := fstest.MapFS{
+		"page.html": &fstest.MapFile{Data: text},
+		"tiny.txt":  &fstest.MapFile{Data: []byte("x")},
+	}
+	cache := cfs.NewDiskCache(dir, 0, cfs.WithCacheCompression(gzip.BestSpeed), cfs.WithCompressedAccounting())
+	layer := cache.Wrap(remote)
+
+	testReadFile(t, layer, "page.html", string(text))
+	testReadFile(t, layer, "tiny.txt", "x")
+
+	blob, err := os.ReadFile(filepath.Join(dir, "data", "page.html"))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(blob) >= len(text) {
+		t.Fatalf("Expected a compressed blob, got %d bytes for %d", len(blob), len(text))
+	}
+	if size := cache.Size(); size != int64(len(blob))+1 {
+		t.Fatalf("Expected the size of the blobs on disk, got %d", size)
+	}
+
+	// cached files report the original size and support seeking
+	f, err := layer.Open("page.html")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.Size() != int64(len(text)) {
+		t.Fatalf("Expected the content size, got %v, %v", info, err)
+	}
+	if _, err := f.(io.Seeker).Seek(int64(len(text))-13, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	if rest, err := io.ReadAll(f); err != nil || string(rest) != "compressible " {
+		t.Fatalf("Expected the tail of the content, got %q, %v", rest, err)
+	}
+
+	// a cache without compression reads the compressed entries, and
+	// counts content bytes by default
+	remote = fstest.MapFS{}
+	plain := cfs.NewDiskCache(dir, 0)
+	testReadFile(t, plain.Wrap(remote), "page.html", string(text))
+	if size := plain.Size(); size != int64(len(text))+1 {
+		t.Fatalf("Expected the size of the content, got %d", size)
+	}
+}
+
+func TestDiskCacheStaleWhileRevalidate(t *testing.T) {
+	remote := fstest.MapFS{
+		"theme.css": &fstest.MapFile{Data: []byte("v1")},
+	}
+	cache := cfs.NewDiskCache(t.TempDir(), time.Millisecond, cfs.WithStaleWhileRevalidate(nil))
+	t.Cleanup(cache.Wait)
+	layer := cache.Wrap(remote)
+
+	testReadFile(t, layer, "theme.css", "v1")
+	remote["theme.css"] = &fstest.MapFile{Data: []byte("v2")}
+	time.Sleep(5 * time.Millisecond)
+
+	// the stale copy is served while the refresh runs
+	testReadFile(t, layer, "theme.css", "v1")
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		data, err := fs.ReadFile(layer, "theme.css")
+		if err == nil && string(data) == "v2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected refreshed content, got %q (%v)", data, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDiskCacheRefreshErrorHook(t *testing.T) {
+	remote := &flakyFS{fsys: fstest.MapFS{
+		"theme.css": &fstest.MapFile{Data: []byte("v1")},
+	}}
+	failures := make(chan string, 1)
+	cache := cfs.NewDiskCache(t.TempDir(), time.Millisecond, cfs.WithStaleWhileRevalidate(func(name string, err error) {
+		select {
+		case failures <- name:
+		default:
+		}
+	}))
+	t.Cleanup(cache.Wait)
+	layer := cache.Wrap(remote)
+
+	testReadFile(t, layer, "theme.css", "v1")
+	remote.failing.Store(true)
+	time.Sleep(5 * time.Millisecond)
+
+	testReadFile(t, layer, "theme.css", "v1")
+
+	select {
+	case name := <-failures:
+		if name != "theme.css" {
+			t.Fatalf("Expected failure for theme.css, got %q", name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected refresh failure to be reported")
+	}
+}
+
+func TestDiskCacheSub(t *testing.T) {
+	remote := &countingFS{MapFS: fstest.MapFS{
+		"assets/app.js": &fstest.MapFile{Data: []byte("remote js")},
+	}}
+	composite := cfs.NewCompositeFS(cfs.NewDiskCache(t.TempDir(), time.Hour).Wrap(remote))
+	testReadFile(t, composite, "assets/app.js", "remote js")
+	fetched := remote.calls.Load()
+
+	sub, err := composite.Sub("assets")
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	testReadFile(t, sub, "app.js", "remote js")
+	if got := remote.calls.Load(); got != fetched {
+		t.Fatalf("Expected the sub view to read the cached copy, got %d calls after %d", got, fetched)
+	}
+}
+
+func TestDiskCacheSeparatesNamedSources(t *testing.T) {
+	dir := t.TempDir()
+	eu := fstest.MapFS{"theme.css": &fstest.MapFile{Data: []byte("eu css")}}
+	us := fstest.MapFS{"theme.css": &fstest.MapFile{Data: []byte("us css")}}
+
+	cache := cfs.NewDiskCache(dir, time.Hour)
+	testReadFile(t, cache.WrapNamed("eu", eu), "theme.css", "eu css")
+	testReadFile(t, cache.WrapNamed("us", us), "theme.css", "us css")
+	testReadFile(t, cache.WrapNamed("eu", eu), "theme.css", "eu css")
+
+	// a restarted cache finds the entries of each source again
+	restarted := cfs.NewDiskCache(dir, time.Hour)
+	testReadFile(t, restarted.WrapNamed("eu", fstest.MapFS{}), "theme.css", "eu css")
+	testReadFile(t, restarted.WrapNamed("us", fstest.MapFS{}), "theme.css", "us css")
+	if _, err := fs.ReadFile(restarted.Wrap(fstest.MapFS{}), "theme.css"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected named entries not to be served through Wrap, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected WrapNamed to panic for a source with a slash")
+		}
+	}()
+	cache.WrapNamed("eu/west", eu)
+}
+
+func TestDiskCacheStoresLargeEntriesUncompressed(t *testing.T) {
+	dir := t.TempDir()
+	large := bytes.Repeat([]byte("a"), cfs.MaxCompressedEntry+1)
+	cache := cfs.NewDiskCache(dir, 0, cfs.WithCacheCompression(gzip.BestSpeed))
+	layer := cache.Wrap(fstest.MapFS{"large.txt": &fstest.MapFile{Data: large}})
+
+	data, err := fs.ReadFile(layer, "large.txt")
+	if err != nil || !bytes.Equal(data, large) {
+		t.Fatalf("Expected the large file, got %d bytes, %v", len(data), err)
+	}
+	blob, err := os.ReadFile(filepath.Join(dir, "data", "large.txt"))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !bytes.Equal(blob, large) {
+		t.Fatalf("Expected the large file to be stored uncompressed, got %d bytes", len(blob))
+	}
+}
+
+// flateCodec stands in for codecs outside of the standard library.
+type flateCodec struct{}
+
+func (flateCodec) Name() string { return "flate" }
+
+func (flateCodec) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (flateCodec) Decompress(data []byte) ([]byte, error) {
+	return io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+}
+
+func TestDiskCacheCodec(t *testing.T) {
+	dir := t.TempDir()
+	text := bytes.Repeat([]byte("compressible "), 100)
+	remote := &countingFS{MapFS: fstest.MapFS{"page.html": &fstest.MapFile{Data: text}}}
+	cache := cfs.NewDiskCache(dir, 0, cfs.WithCacheCodec(flateCodec{}))
+	testReadFile(t, cache.Wrap(remote), "page.html", string(text))
+
+	blob, err := os.ReadFile(filepath.Join(dir, "data", "page.html"))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if data, err := (flateCodec{}).Decompress(blob); err != nil || !bytes.Equal(data, text) {
+		t.Fatalf("Expected a flate blob, got %d bytes, %v", len(blob), err)
+	}
+
+	// a cache without the codec fetches the entry again
+	calls := remote.calls.Load()
+	gzipped := cfs.NewDiskCache(dir, 0, cfs.WithCacheCompression(gzip.BestSpeed))
+	testReadFile(t, gzipped.Wrap(remote), "page.html", string(text))
+	if remote.calls.Load() == calls {
+		t.Fatal("Expected the entry to be fetched again")
+	}
+	if blob, err := os.ReadFile(filepath.Join(dir, "data", "page.html")); err != nil || blob[0] != 0x1f || blob[1] != 0x8b {
+		t.Fatalf("Expected a gzip blob, got %v", err)
+	}
+}
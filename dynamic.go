@@ -0,0 +1,191 @@
+package cfs
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"slices"
+	"sync"
+)
+
+// DynamicOption configures a layer created with DynamicLayer.
+type DynamicOption func(*dynamicFS)
+
+// MemoizeBy memoizes the filesystems resolved by a dynamic layer under
+// the key returned for the context of each operation, for example the
+// tenant, so the resolver runs once per key. Failed resolutions are not
+// memoized. Memoized filesystems are kept for the lifetime of the layer.
+func MemoizeBy(key func(ctx context.Context) string) DynamicOption {
+	return func(d *dynamicFS) {
+		d.key = key
+	}
+}
+
+// DynamicLayer returns a layer whose filesystem is resolved by resolve
+// for every operation, such as the bucket prefix of the current tenant.
+// Operations see the context of the view returned by ForContext, which
+// binds the dynamic layers of a composite, directly or wrapped by
+// NewLayer and the composite options, to its context; other operations
+// resolve with context.Background. Resolver errors are returned as
+// *fs.PathError. Composites never cache results from dynamic layers,
+// since they may differ per context.
+func DynamicLayer(resolve func(ctx context.Context) (fs.FS, error), opts ...DynamicOption) fs.FS {
+	d := &dynamicFS{resolve: resolve, memo: new(sync.Map)}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+type dynamicFS struct {
+	resolve func(ctx context.Context) (fs.FS, error)
+	key     func(ctx context.Context) string
+	memo    *sync.Map // shared by bound copies
+	ctx     context.Context
+	dir     string // set by Sub
+}
+
+// current resolves the filesystem for the bound context.
+func (d *dynamicFS) current(op, name string) (fs.FS, error) {
+	ctx := d.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	fsys, err := d.lookup(ctx)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: unwrapPathError(err)}
+	}
+	if d.dir == "" {
+		return fsys, nil
+	}
+	sub, err := fs.Sub(fsys, d.dir)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: unwrapPathError(err)}
+	}
+	return sub, nil
+}
+
+// lookup returns the filesystem for ctx, memoized when a key is set.
+func (d *dynamicFS) lookup(ctx context.Context) (fs.FS, error) {
+	if d.key == nil {
+		return d.resolve(ctx)
+	}
+	key := d.key(ctx)
+	if v, ok := d.memo.Load(key); ok {
+		return v.(fs.FS), nil
+	}
+	fsys, err := d.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v, _ := d.memo.LoadOrStore(key, fsys)
+	return v.(fs.FS), nil
+}
+
+func (d *dynamicFS) Open(name string) (fs.File, error) {
+	fsys, err := d.current("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.Open(name)
+}
+
+func (d *dynamicFS) Stat(name string) (fs.FileInfo, error) {
+	fsys, err := d.current("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return statLayer(fsys, name)
+}
+
+func (d *dynamicFS) ReadFile(name string) ([]byte, error) {
+	fsys, err := d.current("read", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(fsys, name)
+}
+
+func (d *dynamicFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	fsys, err := d.current("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return ReadDir(fsys, name)
+}
+
+// Sub returns the dynamic layer rooted at dir of every resolved
+// filesystem.
+func (d *dynamicFS) Sub(dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	sub := *d
+	sub.dir = path.Join(d.dir, dir)
+	return &sub, nil
+}
+
+// Cacheable implements CacheableFS: results may differ per context.
+func (d *dynamicFS) Cacheable() bool {
+	return false
+}
+
+// bindContext returns a copy of the composite whose dynamic layers
+// resolve with ctx, or the composite itself when it has none.
+func (cfs *CompositeFS) bindContext(ctx context.Context) *CompositeFS {
+	var bound []fs.FS
+	for i, fsys := range cfs.filesystems {
+		b, ok := bindLayer(ctx, fsys)
+		if !ok {
+			continue
+		}
+		if bound == nil {
+			bound = slices.Clone(cfs.filesystems)
+		}
+		bound[i] = b
+	}
+	if bound == nil {
+		return cfs
+	}
+
+	c := cfs.clone()
+	c.selector = nil
+	c.views = nil
+	c.filesystems = bound
+	return c
+}
+
+// bindLayer returns a copy of fsys whose dynamic layers resolve with
+// ctx, looking through NewLayer and the wrappers applied by composite
+// options, and whether fsys holds any.
+func bindLayer(ctx context.Context, fsys fs.FS) (fs.FS, bool) {
+	switch v := fsys.(type) {
+	case *dynamicFS:
+		b := *v
+		b.ctx = ctx
+		return &b, true
+	case *Layer:
+		inner, ok := bindLayer(ctx, v.FS())
+		if !ok {
+			return fsys, false
+		}
+		l := *v
+		l.fsys = newLayerSlot(inner)
+		return &l, true
+	case *budgetFS:
+		inner, ok := bindLayer(ctx, v.fsys)
+		if !ok {
+			return fsys, false
+		}
+		return &budgetFS{fsys: inner, health: v.health}, true
+	case *transformFS:
+		inner, ok := bindLayer(ctx, v.fsys)
+		if !ok {
+			return fsys, false
+		}
+		t := *v
+		t.fsys = inner
+		return &t, true
+	}
+	return fsys, false
+}
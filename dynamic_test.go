@@ -0,0 +1,79 @@
+package cfs_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+type tenantKey struct{}
+
+func TestDynamicLayer(t *testing.T) {
+	buckets := fstest.MapFS{
+		"acme/views/home.html":   &fstest.MapFile{Data: []byte("acme home")},
+		"globex/views/home.html": &fstest.MapFile{Data: []byte("globex home")},
+	}
+	base := fstest.MapFS{
+		"views/home.html":  &fstest.MapFile{Data: []byte("base home")},
+		"views/about.html": &fstest.MapFile{Data: []byte("base about")},
+	}
+
+	var resolves atomic.Int32
+	tenants := cfs.DynamicLayer(func(ctx context.Context) (fs.FS, error) {
+		resolves.Add(1)
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		if tenant == "" {
+			return fstest.MapFS{}, nil
+		}
+		return fs.Sub(buckets, tenant)
+	}, cfs.MemoizeBy(func(ctx context.Context) string {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		return tenant
+	}))
+	composite := cfs.NewCompositeFS(cfs.NewLayer("tenant", tenants), base).WithStatCache(time.Hour)
+
+	acme := composite.ForContext(context.WithValue(context.Background(), tenantKey{}, "acme"))
+	globex := composite.ForContext(context.WithValue(context.Background(), tenantKey{}, "globex"))
+	testReadFile(t, acme, "views/home.html", "acme home")
+	testReadFile(t, globex, "views/home.html", "globex home")
+	testReadFile(t, acme, "views/about.html", "base about")
+	testReadFile(t, composite, "views/home.html", "base home")
+
+	// a new view of the same tenant reuses the memoized filesystem
+	acme = composite.ForContext(context.WithValue(context.Background(), tenantKey{}, "acme"))
+	testReadFile(t, acme, "views/home.html", "acme home")
+	if got := resolves.Load(); got != 3 {
+		t.Fatalf("Expected one resolution per tenant, got %d", got)
+	}
+
+	match, err := globex.Which("views/home.html")
+	if err != nil || match.LayerName != "tenant" {
+		t.Fatalf("Expected the tenant layer to win, got %+v, %v", match, err)
+	}
+}
+
+func TestDynamicLayerResolveError(t *testing.T) {
+	var resolves atomic.Int32
+	boom := errors.New("bucket unavailable")
+	layer := cfs.DynamicLayer(func(ctx context.Context) (fs.FS, error) {
+		resolves.Add(1)
+		return nil, boom
+	}, cfs.MemoizeBy(func(context.Context) string { return "" }))
+
+	for i := 0; i < 2; i++ {
+		_, err := fs.ReadFile(layer, "a.txt")
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) || !errors.Is(err, boom) || pathErr.Path != "a.txt" {
+			t.Fatalf("Expected the resolver error as a path error, got %v", err)
+		}
+	}
+	if got := resolves.Load(); got != 2 {
+		t.Fatalf("Expected failed resolutions not to be memoized, got %d", got)
+	}
+}
@@ -0,0 +1,111 @@
+package cfs
+
+import (
+	"io/fs"
+	"slices"
+	"sync"
+)
+
+// EntryCounter is implemented by layers that can report how many entries
+// a directory holds without listing it. Merged listings use the counts
+// to size their buffers up front.
+type EntryCounter interface {
+	// EntryCount returns the number of entries in the named directory,
+	// or false when it is not known cheaply.
+	EntryCount(name string) (int, bool)
+}
+
+// maxPooledEntries caps the size of buffers returned to the pool, so a
+// single huge directory does not pin its memory.
+const maxPooledEntries = 4096
+
+// entryMerger merges the listings of a directory across layers, keeping
+// the first entry of each name.
+type entryMerger struct {
+	index   map[string]int
+	entries []fs.DirEntry
+}
+
+var entryMergers = sync.Pool{
+	New: func() any { return &entryMerger{index: make(map[string]int)} },
+}
+
+// newEntryMerger returns a pooled merger sized for the listings of name,
+// when the layers report their entry counts.
+func (cfs *CompositeFS) newEntryMerger(name string) *entryMerger {
+	m := entryMergers.Get().(*entryMerger)
+	hint := 0
+	for _, fsys := range cfs.filesystems {
+		if n, ok := entryCount(fsys, name); ok {
+			hint += n
+		}
+	}
+	if hint > 0 {
+		m.entries = slices.Grow(m.entries, hint)
+	}
+	return m
+}
+
+// entryCount asks fsys, or the layer it wraps, for the number of
+// entries in the named directory.
+func entryCount(fsys fs.FS, name string) (int, bool) {
+	for {
+		if counter, ok := fsys.(EntryCounter); ok {
+			return counter.EntryCount(name)
+		}
+		switch v := fsys.(type) {
+		case *Layer:
+			fsys = v.FS()
+		case *jailFS:
+			fsys = v.fsys
+		case *transformFS:
+			fsys = v.fsys
+		case *budgetFS:
+			fsys = v.fsys
+		default:
+			return 0, false
+		}
+	}
+}
+
+// add appends entry unless an entry of the same name was added before.
+func (m *entryMerger) add(entry fs.DirEntry, wrap func(fs.DirEntry) fs.DirEntry) {
+	if _, exists := m.index[entry.Name()]; exists {
+		return
+	}
+	m.index[entry.Name()] = len(m.entries)
+	m.entries = append(m.entries, wrap(entry))
+}
+
+// set adds entry, replacing any entry of the same name.
+func (m *entryMerger) set(entry fs.DirEntry) {
+	if i, exists := m.index[entry.Name()]; exists {
+		m.entries[i] = entry
+		return
+	}
+	m.index[entry.Name()] = len(m.entries)
+	m.entries = append(m.entries, entry)
+}
+
+func (m *entryMerger) len() int {
+	return len(m.entries)
+}
+
+// result returns a copy of the merged entries and releases the merger
+// to the pool; the merger must not be used afterwards.
+func (m *entryMerger) result() []fs.DirEntry {
+	out := slices.Clone(m.entries)
+	m.release()
+	return out
+}
+
+// release returns the merger to the pool.
+func (m *entryMerger) release() {
+	if cap(m.entries) > maxPooledEntries {
+		return
+	}
+	clear(m.entries)
+	m.entries = m.entries[:0]
+	clear(m.index)
+	entryMergers.Put(m)
+}
@@ -0,0 +1,78 @@
+package cfs_test
+
+import (
+	"fmt"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+// countedFS reports entry counts for its directories.
+type countedFS struct {
+	fstest.MapFS
+	asked int
+}
+
+func (c *countedFS) EntryCount(name string) (int, bool) {
+	c.asked++
+	entries, err := c.MapFS.ReadDir(name)
+	return len(entries), err == nil
+}
+
+func TestReadDirUsesEntryCounts(t *testing.T) {
+	top := &countedFS{MapFS: fstest.MapFS{
+		"views/a.html": &fstest.MapFile{Data: []byte("top")},
+		"views/b.html": &fstest.MapFile{Data: []byte("top")},
+	}}
+	bottom := fstest.MapFS{
+		"views/b.html": &fstest.MapFile{Data: []byte("bottom")},
+		"views/c.html": &fstest.MapFile{Data: []byte("bottom")},
+	}
+	composite := cfs.NewCompositeFS(cfs.NewLayer("top", top), bottom)
+
+	entries, err := composite.ReadDir("views")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if top.asked == 0 {
+		t.Error("Expected the layer to be asked for its entry count")
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if fmt.Sprint(names) != "[a.html b.html c.html]" {
+		t.Errorf("Expected merged entries in layer order, got %v", names)
+	}
+
+	// pooled buffers are not shared between listings
+	again, err := composite.ReadDir("views")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(again) != 3 || entries[0].Name() != "a.html" {
+		t.Errorf("Expected earlier results to stay intact, got %v", entries)
+	}
+	testReadFile(t, composite, "views/b.html", "top")
+}
+
+func BenchmarkReadDirMerge(b *testing.B) {
+	layers := make([]fs.FS, 3)
+	for i := range layers {
+		m := fstest.MapFS{}
+		for j := 0; j < 200; j++ {
+			m[fmt.Sprintf("views/%d-%d.html", i, j%150)] = &fstest.MapFile{}
+		}
+		layers[i] = m
+	}
+	composite := cfs.NewCompositeFS(layers...)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := composite.ReadDir("views"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// Names of the layers assembled by NewFromEnv.
+const (
+	OverrideLayerName = "override"
+	ArchiveLayerName  = "archive"
+	DefaultLayerName  = "default"
+)
+
+// NewFromEnv assembles the standard stack from environment variables so
+// environments differ only in configuration:
+//
+//   - <prefix>_OVERRIDE_DIR: a local directory layered on top, e.g. for
+//     development edits
+//   - <prefix>_ARCHIVE: a .zip, .tar or .tar.gz bundle layered below it
+//   - defaults: the embedded content at the bottom of the stack
+//
+// Unset or empty variables leave their layer out. defaults may be nil.
+func NewFromEnv(prefix string, defaults fs.FS) (*CompositeFS, error) {
+	var layers []fs.FS
+
+	if dir := os.Getenv(prefix + "_OVERRIDE_DIR"); dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, fmt.Errorf("%s_OVERRIDE_DIR: %w", prefix, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%s_OVERRIDE_DIR: %q is not a directory", prefix, dir)
+		}
+		layers = append(layers, NewLayer(OverrideLayerName, os.DirFS(dir), Uncached()))
+	}
+
+	if name := os.Getenv(prefix + "_ARCHIVE"); name != "" {
+		ext := archiveExt(name)
+		if ext == "" {
+			return nil, fmt.Errorf("%s_ARCHIVE: unsupported archive %q", prefix, name)
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s_ARCHIVE: %w", prefix, err)
+		}
+		archive, err := archiveOpeners[ext](data)
+		if err != nil {
+			return nil, fmt.Errorf("%s_ARCHIVE: %w", prefix, err)
+		}
+		layers = append(layers, NewLayer(ArchiveLayerName, archive))
+	}
+
+	if defaults != nil {
+		layers = append(layers, NewLayer(DefaultLayerName, defaults))
+	}
+	return NewCompositeFS(layers...), nil
+}
@@ -0,0 +1,62 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestNewFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	override := filepath.Join(dir, "override")
+	writeTestFile(t, filepath.Join(override, "views", "home.html"), []byte("override home"))
+	archive := filepath.Join(dir, "theme.zip")
+	writeTestFile(t, archive, zipData(t, map[string]string{
+		"views/home.html":  "archive home",
+		"views/about.html": "archive about",
+	}))
+
+	defaults := fstest.MapFS{
+		"views/home.html":    &fstest.MapFile{Data: []byte("default home")},
+		"views/contact.html": &fstest.MapFile{Data: []byte("default contact")},
+	}
+
+	t.Setenv("SITE_OVERRIDE_DIR", override)
+	t.Setenv("SITE_ARCHIVE", archive)
+
+	composite, err := cfs.NewFromEnv("SITE", defaults)
+	if err != nil {
+		t.Fatalf("NewFromEnv failed: %v", err)
+	}
+	if composite.LayerCount() != 3 {
+		t.Fatalf("Expected 3 layers, got %d", composite.LayerCount())
+	}
+	testReadFile(t, composite, "views/home.html", "override home")
+	testReadFile(t, composite, "views/about.html", "archive about")
+	testReadFile(t, composite, "views/contact.html", "default contact")
+
+	t.Setenv("SITE_OVERRIDE_DIR", "")
+	t.Setenv("SITE_ARCHIVE", "")
+	composite, err = cfs.NewFromEnv("SITE", defaults)
+	if err != nil {
+		t.Fatalf("NewFromEnv failed: %v", err)
+	}
+	testReadFile(t, composite, "views/home.html", "default home")
+}
+
+func TestNewFromEnvInvalid(t *testing.T) {
+	t.Setenv("SITE_OVERRIDE_DIR", filepath.Join(t.TempDir(), "missing"))
+	if _, err := cfs.NewFromEnv("SITE", nil); err == nil {
+		t.Fatal("Expected error for a missing override directory")
+	}
+
+	t.Setenv("SITE_OVERRIDE_DIR", "")
+	t.Setenv("SITE_ARCHIVE", "theme.rar")
+	if _, err := cfs.NewFromEnv("SITE", nil); err == nil {
+		t.Fatal("Expected error for an unsupported archive")
+	}
+}
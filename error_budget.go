@@ -0,0 +1,271 @@
+package cfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// ErrLayerDisabled is returned by a layer disabled by its error budget.
+var ErrLayerDisabled = errors.New("layer disabled")
+
+// ErrorBudget bounds the errors a layer may produce before it is
+// disabled. Disabled layers are skipped by lookups until ProbeInterval
+// has passed, when a single call is let through to probe the layer; a
+// success enables the layer again. Not-exist and invalid-path errors do
+// not count as failures. Budgets are meant for best-effort composites,
+// where a failing layer otherwise adds latency and noise to every
+// lookup.
+type ErrorBudget struct {
+	// MaxConsecutive disables a layer after that many consecutive
+	// failures. Zero disables the check.
+	MaxConsecutive int
+	// MaxErrorRate disables a layer when the share of failed calls, in
+	// the range 0 to 1, exceeds it over the last Window calls. Zero
+	// disables the check.
+	MaxErrorRate float64
+	// Window is the number of recent calls MaxErrorRate is computed
+	// over. The rate is only checked once Window calls were seen.
+	// Defaults to 100.
+	Window int
+	// ProbeInterval is how long a layer stays disabled before it is
+	// probed. Defaults to 30 seconds.
+	ProbeInterval time.Duration
+	// OnTransition is called when a layer is disabled or enabled again.
+	OnTransition func(LayerTransition)
+}
+
+// LayerTransition describes a layer being disabled or enabled again by
+// its error budget.
+type LayerTransition struct {
+	// LayerName is the name of the layer, if it is named.
+	LayerName string
+	// Disabled reports whether the layer was disabled, rather than
+	// enabled again.
+	Disabled bool
+	// Err is the last error of the layer.
+	Err error
+	// Time is when the transition happened.
+	Time time.Time
+}
+
+func (b ErrorBudget) window() int {
+	if b.Window <= 0 {
+		return 100
+	}
+	return b.Window
+}
+
+func (b ErrorBudget) probeInterval() time.Duration {
+	if b.ProbeInterval <= 0 {
+		return 30 * time.Second
+	}
+	return b.ProbeInterval
+}
+
+// WithErrorBudget returns a copy of the composite tracking the error
+// rate of each read layer and disabling the layers that exceed budget.
+// The write layer is never disabled.
+func (cfs *CompositeFS) WithErrorBudget(budget ErrorBudget) *CompositeFS {
+	c := cfs.clone()
+	c.budget = &budget
+	c.filesystems = c.flatten(cfs.filesystems)
+	return c
+}
+
+// layerHealth tracks the recent calls of a layer.
+type layerHealth struct {
+	budget *ErrorBudget
+	name   string
+
+	mu          sync.Mutex
+	consecutive int
+	recent      []bool // ring of recent outcomes, true for failures
+	next        int
+	seen        int
+	failures    int
+	calls       int64
+	errorCount  int64
+	lastErr     error
+	lastErrAt   time.Time
+	disabled    bool
+	probeAt     time.Time
+	probing     bool
+}
+
+func newLayerHealth(budget *ErrorBudget, name string) *layerHealth {
+	return &layerHealth{budget: budget, name: name, recent: make([]bool, budget.window())}
+}
+
+// allow reports whether a call may reach the layer. A disabled layer
+// lets a single probe through once its probe interval has passed.
+func (h *layerHealth) allow() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.disabled {
+		return true
+	}
+	if h.probing || time.Now().Before(h.probeAt) {
+		return false
+	}
+	h.probing = true
+	return true
+}
+
+// record accounts for the outcome of a call.
+func (h *layerHealth) record(err error) {
+	failed := err != nil && !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrInvalid)
+
+	h.mu.Lock()
+	var transition *LayerTransition
+	now := time.Now()
+
+	h.calls++
+	if failed {
+		h.errorCount++
+		h.consecutive++
+		h.lastErr, h.lastErrAt = err, now
+	} else {
+		h.consecutive = 0
+	}
+	if h.recent[h.next] {
+		h.failures--
+	}
+	h.recent[h.next] = failed
+	if failed {
+		h.failures++
+	}
+	h.next = (h.next + 1) % len(h.recent)
+	if h.seen < len(h.recent) {
+		h.seen++
+	}
+
+	switch {
+	case h.disabled && h.probing:
+		h.probing = false
+		if failed {
+			h.probeAt = now.Add(h.budget.probeInterval())
+			break
+		}
+		h.reset()
+		transition = &LayerTransition{LayerName: h.name, Err: h.lastErr, Time: now}
+	case !h.disabled && failed && h.exceeded():
+		h.disabled = true
+		h.probeAt = now.Add(h.budget.probeInterval())
+		transition = &LayerTransition{LayerName: h.name, Disabled: true, Err: err, Time: now}
+	}
+	h.mu.Unlock()
+
+	if transition != nil && h.budget.OnTransition != nil {
+		h.budget.OnTransition(*transition)
+	}
+}
+
+// exceeded reports whether the recorded failures exceed the budget.
+func (h *layerHealth) exceeded() bool {
+	b := h.budget
+	if b.MaxConsecutive > 0 && h.consecutive >= b.MaxConsecutive {
+		return true
+	}
+	if b.MaxErrorRate > 0 && h.seen == len(h.recent) {
+		return float64(h.failures)/float64(h.seen) > b.MaxErrorRate
+	}
+	return false
+}
+
+// reset enables the layer again with a clean history.
+func (h *layerHealth) reset() {
+	h.disabled = false
+	h.consecutive = 0
+	h.failures = 0
+	h.seen = 0
+	h.next = 0
+	clear(h.recent)
+}
+
+// budgetFS applies an error budget to a layer.
+type budgetFS struct {
+	fsys   fs.FS
+	health *layerHealth
+}
+
+// Name implements NamedFS by delegating to the wrapped layer.
+func (b *budgetFS) Name() string {
+	return LayerName(b.fsys)
+}
+
+// check fails when the layer is disabled.
+func (b *budgetFS) check(op, name string) error {
+	if b.health.allow() {
+		return nil
+	}
+	return &fs.PathError{Op: op, Path: name, Err: ErrLayerDisabled}
+}
+
+func (b *budgetFS) Open(name string) (fs.File, error) {
+	if err := b.check("open", name); err != nil {
+		return nil, err
+	}
+	file, err := b.fsys.Open(name)
+	b.health.record(err)
+	return file, err
+}
+
+func (b *budgetFS) Stat(name string) (fs.FileInfo, error) {
+	if err := b.check("stat", name); err != nil {
+		return nil, err
+	}
+	info, err := statLayer(b.fsys, name)
+	b.health.record(err)
+	return info, err
+}
+
+func (b *budgetFS) ReadFile(name string) ([]byte, error) {
+	if err := b.check("read", name); err != nil {
+		return nil, err
+	}
+	data, err := fs.ReadFile(b.fsys, name)
+	b.health.record(err)
+	return data, err
+}
+
+func (b *budgetFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := b.check("readdir", name); err != nil {
+		return nil, err
+	}
+	entries, err := ReadDir(b.fsys, name)
+	b.health.record(err)
+	return entries, err
+}
+
+// Sub returns the layer rooted at dir, sharing the error budget of the
+// whole layer.
+func (b *budgetFS) Sub(dir string) (fs.FS, error) {
+	sub, err := fs.Sub(b.fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &budgetFS{fsys: sub, health: b.health}, nil
+}
+
+// MayContain implements PathFilter, so lookups skip disabled layers
+// without counting them as failures.
+func (b *budgetFS) MayContain(name string) bool {
+	b.health.mu.Lock()
+	disabled := b.health.disabled && (b.health.probing || time.Now().Before(b.health.probeAt))
+	b.health.mu.Unlock()
+	return !disabled && !skipLayer(b.fsys, name)
+}
+
+// InvalidatePaths implements Invalidator by delegating to the wrapped
+// layer.
+func (b *budgetFS) InvalidatePaths(names ...string) {
+	invalidateLayer(b.fsys, names)
+}
+
+// key describes the budget for stack hashes.
+func (b *ErrorBudget) key() string {
+	return fmt.Sprintf("%d/%g/%d/%s", b.MaxConsecutive, b.MaxErrorRate, b.window(), b.probeInterval())
+}
@@ -0,0 +1,122 @@
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+// flakyFS fails every Open with a permission error once failing is set.
+type flakyFS struct {
+	fsys    fs.FS
+	failing atomic.Bool
+}
+
+func (f *flakyFS) Open(name string) (fs.File, error) {
+	if f.failing.Load() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return f.fsys.Open(name)
+}
+
+// openCounter counts the calls reaching a layer.
+type openCounter struct {
+	fsys  fs.FS
+	calls atomic.Int64
+}
+
+func (c *openCounter) Open(name string) (fs.File, error) {
+	c.calls.Add(1)
+	return c.fsys.Open(name)
+}
+
+type transitionLog struct {
+	mu     sync.Mutex
+	events []cfs.LayerTransition
+}
+
+func (l *transitionLog) add(e cfs.LayerTransition) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, e)
+}
+
+func (l *transitionLog) list() []cfs.LayerTransition {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]cfs.LayerTransition(nil), l.events...)
+}
+
+func TestWithErrorBudgetConsecutive(t *testing.T) {
+	flaky := &flakyFS{fsys: fstest.MapFS{}}
+	flaky.failing.Store(true)
+	counter := &openCounter{fsys: flaky}
+	base := fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("base")}}
+
+	var log transitionLog
+	composite := cfs.NewCompositeFSBestEffort(cfs.NewLayer("zip", counter), base).
+		WithErrorBudget(cfs.ErrorBudget{
+			MaxConsecutive: 3,
+			ProbeInterval:  20 * time.Millisecond,
+			OnTransition:   log.add,
+		})
+
+	for i := 0; i < 5; i++ {
+		testReadFile(t, composite, "a.txt", "base")
+	}
+	if got := counter.calls.Load(); got != 3 {
+		t.Fatalf("Expected the layer to be disabled after 3 calls, got %d calls", got)
+	}
+	events := log.list()
+	if len(events) != 1 || !events[0].Disabled || events[0].LayerName != "zip" || !errors.Is(events[0].Err, fs.ErrPermission) {
+		t.Fatalf("Expected one disable transition, got %+v", events)
+	}
+
+	// the layer is probed again once the interval has passed
+	flaky.failing.Store(false)
+	time.Sleep(30 * time.Millisecond)
+	testReadFile(t, composite, "a.txt", "base")
+	testReadFile(t, composite, "a.txt", "base")
+	if got := counter.calls.Load(); got != 5 {
+		t.Fatalf("Expected the layer to be probed and enabled again, got %d calls", got)
+	}
+	events = log.list()
+	if len(events) != 2 || events[1].Disabled {
+		t.Fatalf("Expected an enable transition, got %+v", events)
+	}
+}
+
+func TestWithErrorBudgetRate(t *testing.T) {
+	flaky := &flakyFS{fsys: fstest.MapFS{"ok.txt": &fstest.MapFile{Data: []byte("layer")}}}
+	counter := &openCounter{fsys: flaky}
+	base := fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("base")}}
+
+	composite := cfs.NewCompositeFSBestEffort(counter, base).
+		WithErrorBudget(cfs.ErrorBudget{MaxErrorRate: 0.5, Window: 4, ProbeInterval: time.Hour})
+
+	// not-exist results count as successes
+	testReadFile(t, composite, "a.txt", "base")
+	flaky.failing.Store(true)
+	testReadFile(t, composite, "a.txt", "base")
+	testReadFile(t, composite, "a.txt", "base")
+	flaky.failing.Store(false)
+	testReadFile(t, composite, "ok.txt", "layer")
+	if got := counter.calls.Load(); got != 4 {
+		t.Fatalf("Expected 4 calls, got %d", got)
+	}
+
+	// 2/4 failures are within the budget, 3/4 exceed it
+	flaky.failing.Store(true)
+	testReadFile(t, composite, "a.txt", "base")
+	testReadFile(t, composite, "a.txt", "base")
+	testReadFile(t, composite, "a.txt", "base")
+	if got := counter.calls.Load(); got != 5 {
+		t.Fatalf("Expected the layer to be disabled after 5 calls, got %d", got)
+	}
+}
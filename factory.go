@@ -0,0 +1,57 @@
+package cfs
+
+import "io/fs"
+
+// StackFactory creates composites that share a common set of immutable
+// lower layers. Each composite gets its own top layer while the base
+// layers, and anything they cache, are shared by every composite the
+// factory creates.
+type StackFactory struct {
+	base *CompositeFS
+}
+
+// NewStackFactory creates a StackFactory using base as the shared lower
+// stack. Options configured on base (best effort, overlay, hooks) are
+// inherited by every composite the factory creates. The write layer of
+// base, if any, is not shared.
+func NewStackFactory(base *CompositeFS) *StackFactory {
+	proto := base.clone()
+	if proto.writer != nil {
+		proto.setLayers(proto.filesystems[1:])
+		proto.writer = nil
+	}
+	proto.filesystems = proto.filesystems[:len(proto.filesystems):len(proto.filesystems)]
+	return &StackFactory{base: proto}
+}
+
+// Base returns the shared lower stack.
+func (f *StackFactory) Base() *CompositeFS {
+	return f.base.clone()
+}
+
+// New returns a composite with top layered over the shared base layers.
+// Top is wrapped like the layers of NewCompositeFS, and probe rules of
+// the base keep applying to the base layers they name.
+func (f *StackFactory) New(top fs.FS) *CompositeFS {
+	return f.stack(top, nil)
+}
+
+// NewWritable returns a composite with writer as its write layer on top
+// of the shared base layers.
+func (f *StackFactory) NewWritable(writer WriteFS) *CompositeFS {
+	return f.stack(writer, writer)
+}
+
+func (f *StackFactory) stack(top fs.FS, writer WriteFS) *CompositeFS {
+	c := f.base.clone()
+	c.writer = writer
+	layers := c.flatten([]fs.FS{top})
+	c.setLayers(append(layers, f.base.filesystems...))
+	// links defined on a tenant never leak into other tenants
+	c.links = f.base.links.copy()
+	if f.base.journal != nil {
+		// journals are per tenant, never shared
+		c.journal = &journal{}
+	}
+	return c
+}
@@ -0,0 +1,103 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestStackFactorySharesBaseLayers(t *testing.T) {
+	base := fstest.MapFS{
+		"views/home.html":  &fstest.MapFile{Data: []byte("base home")},
+		"views/about.html": &fstest.MapFile{Data: []byte("base about")},
+	}
+	factory := cfs.NewStackFactory(cfs.NewCompositeFS(base))
+
+	tenantA := factory.New(fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("tenant A home")},
+	})
+	tenantB := factory.NewWritable(cfs.NewDirWriteFS(t.TempDir()))
+
+	testReadFile(t, tenantA, "views/home.html", "tenant A home")
+	testReadFile(t, tenantA, "views/about.html", "base about")
+	testReadFile(t, tenantB, "views/home.html", "base home")
+
+	if err := tenantB.WriteFile("views/about.html", []byte("tenant B about"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	testReadFile(t, tenantB, "views/about.html", "tenant B about")
+	testReadFile(t, tenantA, "views/about.html", "base about")
+}
+
+func TestStackFactoryInheritsOptions(t *testing.T) {
+	base := fstest.MapFS{
+		"resources/form.html": &fstest.MapFile{Data: []byte("form")},
+	}
+	factory := cfs.NewStackFactory(cfs.NewOverlayFS(base))
+
+	tenant := factory.New(fstest.MapFS{
+		"resources/show.html": &fstest.MapFile{Data: []byte("show")},
+	})
+
+	file, err := tenant.Open("resources")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer file.Close()
+
+	dir, ok := file.(fs.ReadDirFile)
+	if !ok {
+		t.Fatalf("Expected ReadDirFile, got %T", file)
+	}
+	entries, err := dir.ReadDir(-1)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 merged entries, got %d", len(entries))
+	}
+}
+
+func BenchmarkStackFactoryNew(b *testing.B) {
+	factory := cfs.NewStackFactory(cfs.NewCompositeFS(fstest.MapFS{}, fstest.MapFS{}, fstest.MapFS{}))
+	top := fstest.MapFS{}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		factory.New(top)
+	}
+}
+
+func TestStackFactoryWrapsTopAndKeepsProbeOrder(t *testing.T) {
+	theme := fstest.MapFS{"assets/app.css": &fstest.MapFile{Data: []byte("theme css")}}
+	embedded := fstest.MapFS{"assets/app.css": &fstest.MapFile{Data: []byte("embedded css")}}
+	base := cfs.NewCompositeFS(theme, embedded).
+		WithProbeOrder(cfs.ProbeRule{Prefix: "assets", Order: []int{1}}).
+		WithSymlinkProtection()
+	factory := cfs.NewStackFactory(base)
+
+	tenant := factory.New(fstest.MapFS{"assets/app.css": &fstest.MapFile{Data: []byte("tenant css")}})
+	testReadFile(t, tenant, "assets/app.css", "embedded css")
+
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	writeTestFile(t, filepath.Join(dir, "secret.txt"), []byte("secret"))
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(root, "escape.txt")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	jailed := factory.New(os.DirFS(root))
+	if _, err := jailed.ReadFile("escape.txt"); !errors.Is(err, cfs.ErrSymlinkEscape) {
+		t.Fatalf("Expected the top layer to be jailed, got %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+//go:build js && wasm
+
+package cfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall/js"
+)
+
+// NewFetchFS returns a read-only layer for browser WebAssembly builds,
+// which cannot use os.DirFS, serving the files below baseURL through
+// the Fetch API. Since HTTP cannot list directories, the tree is
+// described by manifest, typically recorded with SnapshotManifest at
+// build time and shipped as JSON: files missing from it do not exist,
+// and directory listings are answered from it without requests. Fetched
+// content is checked against the manifest hash and kept in memory, so
+// every file is fetched at most once; Stat of a file fetches it, since
+// the manifest does not record sizes. Like net/http in the browser,
+// calls block on the fetch and must not run inside a js.Func callback.
+func NewFetchFS(baseURL string, manifest Manifest) fs.FS {
+	f := &fetchFS{
+		base:  strings.TrimSuffix(baseURL, "/"),
+		files: manifest,
+		dirs:  map[string][]fetchEntry{".": nil},
+		data:  make(map[string][]byte),
+	}
+	for name := range manifest {
+		if !fs.ValidPath(name) || name == "." {
+			continue
+		}
+		f.addEntry(name, false)
+	}
+	for _, entries := range f.dirs {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].name < entries[j].name
+		})
+	}
+	return f
+}
+
+type fetchFS struct {
+	base  string
+	files Manifest
+	dirs  map[string][]fetchEntry
+
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+// addEntry lists name in its parent directory, creating missing
+// parents.
+func (f *fetchFS) addEntry(name string, dir bool) {
+	parent := path.Dir(name)
+	if _, ok := f.dirs[parent]; !ok {
+		f.addEntry(parent, true)
+	}
+	if dir {
+		f.dirs[name] = nil
+	}
+	f.dirs[parent] = append(f.dirs[parent], fetchEntry{fsys: f, name: name, dir: dir})
+}
+
+func (f *fetchFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if entries, ok := f.dirs[name]; ok {
+		list := make([]fs.DirEntry, len(entries))
+		for i := range entries {
+			list[i] = entries[i]
+		}
+		return &overlayDirFile{name: name, info: fetchDirInfo(name), entries: list}, nil
+	}
+	data, err := f.fetch("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return &memFile{name: name, info: fetchFileInfo(name, data), data: data}, nil
+}
+
+func (f *fetchFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if _, ok := f.dirs[name]; ok {
+		return fetchDirInfo(name), nil
+	}
+	data, err := f.fetch("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fetchFileInfo(name, data), nil
+}
+
+func (f *fetchFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	if _, ok := f.dirs[name]; ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	data, err := f.fetch("read", name)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (f *fetchFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, ok := f.dirs[name]
+	if !ok {
+		err := fs.ErrNotExist
+		if _, isFile := f.files[name]; isFile {
+			err = fs.ErrInvalid
+		}
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	list := make([]fs.DirEntry, len(entries))
+	for i := range entries {
+		list[i] = entries[i]
+	}
+	return list, nil
+}
+
+// Sub returns the layer rooted at dir, fetching from the same base URL
+// and checking against the same manifest.
+func (f *fetchFS) Sub(dir string) (fs.FS, error) {
+	if _, ok := f.dirs[dir]; !ok && fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrNotExist}
+	}
+	return subLayer(f, dir)
+}
+
+// fetch returns the content of the file name, fetching it on first use.
+func (f *fetchFS) fetch(op, name string) ([]byte, error) {
+	sum, ok := f.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	f.mu.Lock()
+	data, ok := f.data[name]
+	f.mu.Unlock()
+	if ok {
+		return data, nil
+	}
+
+	data, status, err := fetchBytes(f.base + "/" + escapePath(name))
+	switch {
+	case err != nil:
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	case status == 404 || status == 410:
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	case status == 401 || status == 403:
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	case status != 200:
+		return nil, &fs.PathError{Op: op, Path: name, Err: fmt.Errorf("fetch: status %d", status)}
+	}
+	if sum != "" {
+		got := sha256.Sum256(data)
+		if hex.EncodeToString(got[:]) != sum {
+			return nil, &fs.PathError{Op: op, Path: name, Err: errors.New("content does not match manifest hash")}
+		}
+	}
+
+	f.mu.Lock()
+	f.data[name] = data
+	f.mu.Unlock()
+	return data, nil
+}
+
+// fetchBytes fetches url and returns the body of a successful response
+// along with the status code.
+func fetchBytes(url string) ([]byte, int, error) {
+	type result struct {
+		data   []byte
+		status int
+		err    error
+	}
+	done := make(chan result, 1)
+
+	var onResponse, onBody, onError js.Func
+	defer func() {
+		onResponse.Release()
+		onBody.Release()
+		onError.Release()
+	}()
+	onError = js.FuncOf(func(_ js.Value, args []js.Value) any {
+		done <- result{err: fmt.Errorf("fetch: %s", args[0].Call("toString").String())}
+		return nil
+	})
+	onBody = js.FuncOf(func(_ js.Value, args []js.Value) any {
+		buf := js.Global().Get("Uint8Array").New(args[0])
+		data := make([]byte, buf.Get("length").Int())
+		js.CopyBytesToGo(data, buf)
+		done <- result{data: data, status: 200}
+		return nil
+	})
+	onResponse = js.FuncOf(func(_ js.Value, args []js.Value) any {
+		resp := args[0]
+		if status := resp.Get("status").Int(); status != 200 {
+			done <- result{status: status}
+			return nil
+		}
+		resp.Call("arrayBuffer").Call("then", onBody, onError)
+		return nil
+	})
+
+	js.Global().Call("fetch", url).Call("then", onResponse, onError)
+	r := <-done
+	return r.data, r.status, r.err
+}
+
+// escapePath escapes every element of name for use in a URL path.
+func escapePath(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// fetchEntry is a directory entry of a fetchFS. Info of a file fetches
+// it to learn its size.
+type fetchEntry struct {
+	fsys *fetchFS
+	name string
+	dir  bool
+}
+
+func (e fetchEntry) Name() string { return path.Base(e.name) }
+func (e fetchEntry) IsDir() bool  { return e.dir }
+
+func (e fetchEntry) Type() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir
+	}
+	return 0
+}
+
+func (e fetchEntry) Info() (fs.FileInfo, error) {
+	return e.fsys.Stat(e.name)
+}
+
+func fetchDirInfo(name string) fs.FileInfo {
+	return memInfo{name: path.Base(name), mode: fs.ModeDir | 0o555}
+}
+
+func fetchFileInfo(name string, data []byte) fs.FileInfo {
+	return memInfo{name: path.Base(name), size: int64(len(data)), mode: 0o444}
+}
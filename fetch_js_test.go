@@ -0,0 +1,109 @@
+//go:build js && wasm
+
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"syscall/js"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+// stubFetch replaces the global fetch with one serving files, recording
+// the requested URLs.
+func stubFetch(t *testing.T, files map[string]string) *[]string {
+	t.Helper()
+	var requests []string
+	original := js.Global().Get("fetch")
+	stub := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		url := args[0].String()
+		requests = append(requests, url)
+		body, ok := files[url]
+		status := 200
+		if !ok {
+			body, status = "not found", 404
+		}
+		resp := js.Global().Get("Response").New(body, map[string]any{"status": status})
+		return js.Global().Get("Promise").Call("resolve", resp)
+	})
+	js.Global().Set("fetch", stub)
+	t.Cleanup(func() {
+		js.Global().Set("fetch", original)
+		stub.Release()
+	})
+	return &requests
+}
+
+func TestFetchFS(t *testing.T) {
+	requests := stubFetch(t, map[string]string{
+		"https://cdn.test/theme/views/home.html":      "home",
+		"https://cdn.test/theme/assets/site%20v2.css": "body{}",
+	})
+	manifest := cfs.Manifest{
+		"views/home.html":    sha256Hex("home"),
+		"assets/site v2.css": sha256Hex("body{}"),
+		"views/missing.html": sha256Hex("missing"),
+	}
+	layer := cfs.NewFetchFS("https://cdn.test/theme/", manifest)
+	composite := cfs.NewCompositeFS(layer)
+
+	testReadFile(t, composite, "views/home.html", "home")
+	testReadFile(t, composite, "assets/site v2.css", "body{}")
+	testReadFile(t, composite, "views/home.html", "home")
+	if len(*requests) != 2 {
+		t.Fatalf("Expected each file fetched once, got %v", *requests)
+	}
+
+	entries, err := fs.ReadDir(composite, "views")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Name() != "home.html" || entries[1].Name() != "missing.html" {
+		t.Fatalf("Expected the listing from the manifest, got %v", entries)
+	}
+	if len(*requests) != 2 {
+		t.Fatalf("Expected listings not to fetch, got %v", *requests)
+	}
+
+	if _, err := composite.Open("views/missing.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected ErrNotExist for a 404, got %v", err)
+	}
+	if _, err := composite.Open("views/other.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected ErrNotExist outside the manifest, got %v", err)
+	}
+	if len(*requests) != 3 {
+		t.Fatalf("Expected files outside the manifest not to be fetched, got %v", *requests)
+	}
+}
+
+func TestFetchFSVerifiesContent(t *testing.T) {
+	stubFetch(t, map[string]string{"https://cdn.test/a.txt": "tampered"})
+	layer := cfs.NewFetchFS("https://cdn.test", cfs.Manifest{"a.txt": sha256Hex("a")})
+	if _, err := fs.ReadFile(layer, "a.txt"); err == nil {
+		t.Fatal("Expected a hash mismatch to fail")
+	}
+	if err := fstest.TestFS(cfs.NewFetchFS("https://cdn.test", cfs.Manifest{"a.txt": ""}), "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFetchFSSub(t *testing.T) {
+	stubFetch(t, map[string]string{
+		"https://cdn.test/theme/views/home.html": "home",
+	})
+	layer := cfs.NewFetchFS("https://cdn.test/theme/", cfs.Manifest{
+		"views/home.html": sha256Hex("home"),
+	})
+
+	sub, err := cfs.NewCompositeFS(layer).Sub("views")
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	testReadFile(t, sub, "home.html", "home")
+	if _, err := fs.Sub(layer, "assets"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected fs.ErrNotExist outside the manifest, got %v", err)
+	}
+}
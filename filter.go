@@ -0,0 +1,274 @@
+package cfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// filterFunc decides whether a path is visible. It returns nil to keep
+// the path, an error matching fs.ErrNotExist to hide it, or any other
+// error to report it when the path is opened.
+type filterFunc func(name string, d fs.DirEntry) error
+
+// filterFS hides paths of a wrapped filesystem according to a
+// filterFunc, enforced consistently on Open, Stat, ReadFile and ReadDir.
+type filterFS struct {
+	fsys  fs.FS
+	check filterFunc
+	// maxRead, when positive, caps the bytes read from any file so a
+	// layer misreporting sizes cannot stream more than the limit
+	maxRead int64
+}
+
+func newFilterFS(fsys fs.FS, check filterFunc) *filterFS {
+	return &filterFS{fsys: fsys, check: check}
+}
+
+func (f *filterFS) Open(name string) (fs.File, error) {
+	file, err := f.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := f.allow("open", name, fs.FileInfoToDirEntry(info)); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return &filterDirFile{File: file, fsys: f, name: name}, nil
+	}
+	if f.maxRead > 0 {
+		return &limitedFile{File: file, name: name, remaining: f.maxRead}, nil
+	}
+	return file, nil
+}
+
+func (f *filterFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := statLayer(f.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.allow("stat", name, fs.FileInfoToDirEntry(info)); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (f *filterFS) ReadFile(name string) ([]byte, error) {
+	// check before reading so hidden files are never loaded
+	if _, err := f.Stat(name); err != nil {
+		return nil, err
+	}
+	if f.maxRead > 0 {
+		file, err := f.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return io.ReadAll(file)
+	}
+	return fs.ReadFile(f.fsys, name)
+}
+
+func (f *filterFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." {
+		if _, err := f.Stat(name); err != nil {
+			return nil, err
+		}
+	}
+	entries, err := ReadDir(f.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return f.filterEntries(name, entries), nil
+}
+
+func (f *filterFS) Sub(dir string) (fs.FS, error) {
+	sub, err := fs.Sub(f.fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &filterFS{fsys: sub, check: f.check, maxRead: f.maxRead}, nil
+}
+
+func (f *filterFS) allow(op, name string, d fs.DirEntry) error {
+	if err := f.check(name, d); err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return nil
+}
+
+// filterEntries drops hidden entries. Entries rejected with errors
+// other than fs.ErrNotExist stay listed and fail when opened.
+func (f *filterFS) filterEntries(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	out := entries[:0:0]
+	for _, entry := range entries {
+		err := f.check(path.Join(dir, entry.Name()), entry)
+		if err != nil && errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+// filterDirFile filters the entries of a directory opened through a
+// filterFS.
+type filterDirFile struct {
+	fs.File
+	fsys *filterFS
+	name string
+}
+
+func (d *filterDirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	dir, ok := d.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: fs.ErrInvalid}
+	}
+
+	for {
+		entries, err := dir.ReadDir(n)
+		filtered := d.fsys.filterEntries(d.name, entries)
+		// keep reading when a whole batch was filtered out so callers
+		// never see an empty, non-final batch
+		if n > 0 && len(filtered) == 0 && err == nil {
+			continue
+		}
+		return filtered, err
+	}
+}
+
+// limitedFile fails reads once more than the allowed number of bytes
+// has been returned.
+type limitedFile struct {
+	fs.File
+	name      string
+	remaining int64
+}
+
+func (l *limitedFile) Read(b []byte) (int, error) {
+	if l.remaining <= 0 {
+		// allow a final read to observe EOF on files of exactly the limit
+		var probe [1]byte
+		n, err := l.File.Read(probe[:])
+		if n > 0 {
+			return 0, &fs.PathError{Op: "read", Path: l.name, Err: ErrFileTooLarge}
+		}
+		return 0, err
+	}
+	if int64(len(b)) > l.remaining {
+		b = b[:l.remaining]
+	}
+	n, err := l.File.Read(b)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// HideModifiedAfter wraps fsys so files modified after cutoff are
+// invisible. Combined with layers that keep historical content it
+// enables "view the site as of time T" composites. Directories are
+// never hidden.
+func HideModifiedAfter(fsys fs.FS, cutoff time.Time) fs.FS {
+	return newFilterFS(fsys, modTimeFilter(func(t time.Time) bool {
+		return t.After(cutoff)
+	}))
+}
+
+// HideModifiedBefore wraps fsys so files modified before cutoff are
+// invisible. Directories are never hidden.
+func HideModifiedBefore(fsys fs.FS, cutoff time.Time) fs.FS {
+	return newFilterFS(fsys, modTimeFilter(func(t time.Time) bool {
+		return t.Before(cutoff)
+	}))
+}
+
+func modTimeFilter(hide func(time.Time) bool) filterFunc {
+	return func(name string, d fs.DirEntry) error {
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if hide(info.ModTime()) {
+			return fs.ErrNotExist
+		}
+		return nil
+	}
+}
+
+// AllowExtensions wraps fsys so only files with one of the given
+// extensions are visible, e.g. AllowExtensions(uploads, ".png", ".jpg").
+// Extensions are matched case-insensitively; directories stay visible.
+func AllowExtensions(fsys fs.FS, exts ...string) fs.FS {
+	allowed := make(map[string]struct{}, len(exts))
+	for _, ext := range exts {
+		allowed[normalizeExt(ext)] = struct{}{}
+	}
+
+	return newFilterFS(fsys, func(name string, d fs.DirEntry) error {
+		if d.IsDir() {
+			return nil
+		}
+		if _, ok := allowed[strings.ToLower(path.Ext(name))]; ok {
+			return nil
+		}
+		return fs.ErrNotExist
+	})
+}
+
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(ext)
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// ErrFileTooLarge is returned for files rejected by RejectLargeFiles.
+var ErrFileTooLarge = errors.New("file exceeds size limit")
+
+// HideLargeFiles wraps fsys so files larger than max bytes are treated
+// as not existing, letting lower layers provide them instead. Reads are
+// capped at max bytes even when the layer misreports file sizes.
+func HideLargeFiles(fsys fs.FS, max int64) fs.FS {
+	f := newFilterFS(fsys, sizeFilter(max, fs.ErrNotExist))
+	f.maxRead = max
+	return f
+}
+
+// RejectLargeFiles wraps fsys so opening files larger than max bytes
+// fails with ErrFileTooLarge. Rejected files remain listed by ReadDir.
+// Reads are capped at max bytes even when the layer misreports sizes.
+func RejectLargeFiles(fsys fs.FS, max int64) fs.FS {
+	f := newFilterFS(fsys, sizeFilter(max, ErrFileTooLarge))
+	f.maxRead = max
+	return f
+}
+
+func sizeFilter(max int64, reject error) filterFunc {
+	return func(name string, d fs.DirEntry) error {
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() > max {
+			return reject
+		}
+		return nil
+	}
+}
@@ -0,0 +1,197 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestHideModifiedAfter(t *testing.T) {
+	cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := fstest.MapFS{
+		"pages/old.html": &fstest.MapFile{Data: []byte("old"), ModTime: cutoff.Add(-time.Hour)},
+		"pages/new.html": &fstest.MapFile{Data: []byte("new"), ModTime: cutoff.Add(time.Hour)},
+	}
+
+	asOf := cfs.HideModifiedAfter(history, cutoff)
+
+	testReadFile(t, asOf, "pages/old.html", "old")
+
+	if _, err := asOf.Open("pages/new.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected new.html to be hidden on Open, got %v", err)
+	}
+	if _, err := fs.Stat(asOf, "pages/new.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected new.html to be hidden on Stat, got %v", err)
+	}
+	if _, err := fs.ReadFile(asOf, "pages/new.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected new.html to be hidden on ReadFile, got %v", err)
+	}
+
+	entries, err := fs.ReadDir(asOf, "pages")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "old.html" {
+		t.Fatalf("Expected only old.html, got %v", entries)
+	}
+}
+
+func TestHideModifiedBeforeInComposite(t *testing.T) {
+	cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	recent := fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("stale"), ModTime: cutoff.Add(-time.Hour)},
+	}
+	base := fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("base")},
+	}
+
+	composite := cfs.NewCompositeFS(cfs.HideModifiedBefore(recent, cutoff), base)
+
+	testReadFile(t, composite, "a.txt", "base")
+}
+
+func TestFilteredDirectoryReadDirBatches(t *testing.T) {
+	cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	layer := fstest.MapFS{
+		"dir/a.txt": &fstest.MapFile{ModTime: cutoff.Add(time.Hour)},
+		"dir/b.txt": &fstest.MapFile{ModTime: cutoff.Add(time.Hour)},
+		"dir/c.txt": &fstest.MapFile{ModTime: cutoff.Add(-time.Hour)},
+	}
+
+	file, err := cfs.HideModifiedAfter(layer, cutoff).Open("dir")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer file.Close()
+
+	entries, err := file.(fs.ReadDirFile).ReadDir(1)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "c.txt" {
+		t.Fatalf("Expected first batch to contain c.txt, got %v", entries)
+	}
+}
+
+func TestAllowExtensions(t *testing.T) {
+	uploads := fstest.MapFS{
+		"uploads/logo.PNG":  &fstest.MapFile{Data: []byte("png")},
+		"uploads/photo.jpg": &fstest.MapFile{Data: []byte("jpg")},
+		"uploads/evil.html": &fstest.MapFile{Data: []byte("<script>")},
+	}
+	base := fstest.MapFS{
+		"uploads/evil.html": &fstest.MapFile{Data: []byte("safe")},
+	}
+
+	composite := cfs.NewCompositeFS(cfs.AllowExtensions(uploads, "png", ".jpg"), base)
+
+	testReadFile(t, composite, "uploads/logo.PNG", "png")
+	testReadFile(t, composite, "uploads/evil.html", "safe")
+
+	entries, err := fs.ReadDir(cfs.AllowExtensions(uploads, ".png", ".jpg"), "uploads")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 visible entries, got %v", entries)
+	}
+}
+
+func TestAllowContentTypes(t *testing.T) {
+	uploads := fstest.MapFS{
+		"a.png":  &fstest.MapFile{},
+		"b.gif":  &fstest.MapFile{},
+		"c.html": &fstest.MapFile{},
+		"d":      &fstest.MapFile{},
+	}
+
+	images := cfs.AllowContentTypes(uploads, "image/*")
+
+	for _, name := range []string{"a.png", "b.gif"} {
+		if _, err := fs.Stat(images, name); err != nil {
+			t.Errorf("Expected %s to be visible, got %v", name, err)
+		}
+	}
+	for _, name := range []string{"c.html", "d"} {
+		if _, err := fs.Stat(images, name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Expected %s to be hidden, got %v", name, err)
+		}
+	}
+}
+
+func TestHideLargeFiles(t *testing.T) {
+	big := fstest.MapFS{
+		"asset.bin": &fstest.MapFile{Data: make([]byte, 2048)},
+		"small.txt": &fstest.MapFile{Data: []byte("small")},
+	}
+	base := fstest.MapFS{
+		"asset.bin": &fstest.MapFile{Data: []byte("fallback")},
+	}
+
+	composite := cfs.NewCompositeFS(cfs.HideLargeFiles(big, 1024), base)
+
+	testReadFile(t, composite, "asset.bin", "fallback")
+	testReadFile(t, composite, "small.txt", "small")
+
+	data, err := composite.ReadFile("asset.bin")
+	if err != nil || string(data) != "fallback" {
+		t.Fatalf("Expected fallback content, got %q, %v", data, err)
+	}
+}
+
+func TestRejectLargeFiles(t *testing.T) {
+	big := fstest.MapFS{
+		"asset.bin": &fstest.MapFile{Data: make([]byte, 2048)},
+	}
+
+	composite := cfs.NewCompositeFS(cfs.RejectLargeFiles(big, 1024))
+
+	_, err := composite.ReadFile("asset.bin")
+	if !errors.Is(err, cfs.ErrFileTooLarge) {
+		t.Fatalf("Expected ErrFileTooLarge, got %v", err)
+	}
+
+	entries, err := composite.ReadDir(".")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected rejected file to stay listed, got %v", entries)
+	}
+}
+
+// lyingFS reports a small size but streams more data than it claims.
+type lyingFS struct {
+	fstest.MapFS
+}
+
+func (l lyingFS) Stat(name string) (fs.FileInfo, error) {
+	return &TestFileInfo{name: name, size: 1}, nil
+}
+
+func (l lyingFS) Open(name string) (fs.File, error) {
+	return &lyingFile{TestFile: &TestFile{name: name, content: "much more than one byte"}}, nil
+}
+
+type lyingFile struct {
+	*TestFile
+}
+
+func (f *lyingFile) Stat() (fs.FileInfo, error) {
+	return &TestFileInfo{name: f.name, size: 1}, nil
+}
+
+func TestLargeFileLimitCapsReads(t *testing.T) {
+	limited := cfs.RejectLargeFiles(lyingFS{}, 4)
+
+	_, err := fs.ReadFile(limited, "file.txt")
+	if !errors.Is(err, cfs.ErrFileTooLarge) {
+		t.Fatalf("Expected ErrFileTooLarge for oversized stream, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package cfs
+
+import (
+	"io/fs"
+	"sort"
+)
+
+// FindOption configures Find.
+type FindOption func(*findConfig)
+
+type findConfig struct {
+	prune func(path string, d fs.DirEntry) bool
+}
+
+// PruneDirs makes Find skip every directory for which prune returns
+// true, without reading its contents. The root is never pruned.
+func PruneDirs(prune func(path string, d fs.DirEntry) bool) FindOption {
+	return func(c *findConfig) {
+		c.prune = prune
+	}
+}
+
+// Find walks the merged tree below root and returns the paths for which
+// pred returns true, sorted. Each directory is listed once across all
+// layers and paths hidden by the composite are never visited.
+func (cfs *CompositeFS) Find(root string, pred func(path string, d fs.DirEntry) bool, opts ...FindOption) ([]string, error) {
+	var cfg findConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var out []string
+	err := fs.WalkDir(cfs, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && p != root && cfg.prune != nil && cfg.prune(p, d) {
+			return fs.SkipDir
+		}
+		if pred(p, d) {
+			out = append(out, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(out)
+	return out, nil
+}
@@ -0,0 +1,75 @@
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestFindAcrossLayers(t *testing.T) {
+	theme := fstest.MapFS{
+		"views/home.html":         &fstest.MapFile{Data: []byte("home")},
+		"views/partials/nav.html": &fstest.MapFile{Data: []byte("nav")},
+	}
+	base := fstest.MapFS{
+		"views/home.html":   &fstest.MapFile{Data: []byte("base home")},
+		"views/about.html":  &fstest.MapFile{Data: []byte("about")},
+		"views/style.css":   &fstest.MapFile{Data: []byte("css")},
+		"node_modules/x.js": &fstest.MapFile{Data: []byte("js")},
+	}
+	composite := cfs.NewCompositeFS(theme, base)
+
+	found, err := composite.Find(".", func(p string, d fs.DirEntry) bool {
+		return !d.IsDir() && path.Ext(p) == ".html"
+	})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+
+	expected := []string{"views/about.html", "views/home.html", "views/partials/nav.html"}
+	if !reflect.DeepEqual(found, expected) {
+		t.Fatalf("Expected %v, got %v", expected, found)
+	}
+}
+
+func TestFindPrunesDirectories(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{
+		"src/app.js":                &fstest.MapFile{Data: []byte("app")},
+		"node_modules/lib/index.js": &fstest.MapFile{Data: []byte("lib")},
+	})
+
+	var visited []string
+	found, err := composite.Find(".", func(p string, d fs.DirEntry) bool {
+		visited = append(visited, p)
+		return strings.HasSuffix(p, ".js")
+	}, cfs.PruneDirs(func(p string, d fs.DirEntry) bool {
+		return d.Name() == "node_modules"
+	}))
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(found, []string{"src/app.js"}) {
+		t.Fatalf("Expected only src/app.js, got %v", found)
+	}
+	for _, p := range visited {
+		if strings.HasPrefix(p, "node_modules") {
+			t.Fatalf("Expected node_modules to be pruned, visited %q", p)
+		}
+	}
+}
+
+func TestFindMissingRoot(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{})
+
+	_, err := composite.Find("missing", func(string, fs.DirEntry) bool { return true })
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected fs.ErrNotExist, got %v", err)
+	}
+}
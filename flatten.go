@@ -0,0 +1,158 @@
+package cfs
+
+import (
+	"io/fs"
+	"reflect"
+)
+
+// flatten returns the layer list for cfs. Nested composites with
+// compatible options are inlined into the parent so lookups probe each
+// underlying layer once, and repeated layer instances are dropped
+// keeping the highest priority occurrence.
+func (cfs *CompositeFS) flatten(filesystems []fs.FS) []fs.FS {
+	out := make([]fs.FS, 0, len(filesystems))
+
+	var add func(fsys fs.FS)
+	add = func(fsys fs.FS) {
+		if child, ok := fsys.(*CompositeFS); ok && cfs.canInline(child) {
+			for _, layer := range child.filesystems {
+				add(layer)
+			}
+			return
+		}
+		fsys = cfs.wrapLayer(fsys)
+		for _, existing := range out {
+			if sameLayer(unwrapLayer(existing), unwrapLayer(fsys)) {
+				return
+			}
+		}
+		out = append(out, fsys)
+	}
+
+	for _, fsys := range filesystems {
+		add(fsys)
+	}
+	return out
+}
+
+// wrapLayer applies the wrappers configured on the composite to a
+// read layer. The write layer is never wrapped.
+func (cfs *CompositeFS) wrapLayer(fsys fs.FS) fs.FS {
+	if cfs.writer != nil && sameLayer(fsys, cfs.writer) {
+		return fsys
+	}
+	// transforms stay the outermost wrapper, so they are peeled off and
+	// applied again with the current configuration
+	var prefix string
+	if t, ok := fsys.(*transformFS); ok {
+		fsys, prefix = t.fsys, t.prefix
+	}
+	// error budgets are applied again too, keeping their history
+	var health *layerHealth
+	if b, ok := fsys.(*budgetFS); ok {
+		fsys, health = b.fsys, b.health
+	}
+	if cfs.jailOS {
+		fsys = jailOSLayer(fsys)
+	}
+	if cfs.archives {
+		if _, ok := fsys.(*archiveFS); !ok {
+			fsys = newArchiveFS(fsys)
+		}
+	}
+	if cfs.budget != nil {
+		if health == nil || health.budget != cfs.budget {
+			health = newLayerHealth(cfs.budget, LayerName(fsys))
+		}
+		fsys = &budgetFS{fsys: fsys, health: health}
+	}
+	if t, ok := cfs.transformFor(LayerName(fsys)); ok {
+		fsys = &transformFS{fsys: fsys, t: t, prefix: prefix}
+	}
+	return fsys
+}
+
+// unwrapLayer returns the layer below the wrappers added by wrapLayer.
+func unwrapLayer(fsys fs.FS) fs.FS {
+	for {
+		switch v := fsys.(type) {
+		case *archiveFS:
+			fsys = v.fsys
+		case *jailFS:
+			fsys = v.fsys
+		case *transformFS:
+			fsys = v.fsys
+		case *budgetFS:
+			fsys = v.fsys
+		default:
+			return fsys
+		}
+	}
+}
+
+// canInline reports whether child behaves exactly like its layers
+// would when placed directly in cfs.
+func (cfs *CompositeFS) canInline(child *CompositeFS) bool {
+	if child == nil {
+		return false
+	}
+	return child.bestEffort == cfs.bestEffort &&
+		child.mergeDirs == cfs.mergeDirs &&
+		child.writer == nil &&
+		child.onCopyUp == nil &&
+		child.journal == nil &&
+		child.visibility.empty() &&
+		child.limits.empty() &&
+		child.statCache == nil &&
+		child.content == nil &&
+		len(child.aliases) == 0 &&
+		child.links.empty() &&
+		(!child.byPriority || cfs.byPriority) &&
+		(!child.eagerInfo || cfs.eagerInfo) &&
+		(child.unsorted || !cfs.unsorted) &&
+		len(child.transforms) == 0 &&
+		len(child.frontMatter) == 0 &&
+		child.permissions.Mode == cfs.permissions.Mode &&
+		child.permissions.Report == nil &&
+		child.budget == nil &&
+		child.dirMode == cfs.dirMode &&
+		len(child.probeRules) == 0 &&
+		child.selector == nil
+}
+
+// sameLayer reports whether a and b are the same layer instance. It
+// never panics on non-comparable layer types such as fstest.MapFS.
+func sameLayer(a, b fs.FS) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() {
+		return false
+	}
+
+	switch va.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return va.Pointer() == vb.Pointer()
+	case reflect.Slice:
+		return va.Pointer() == vb.Pointer() && va.Len() == vb.Len()
+	}
+
+	if va.Type().Comparable() {
+		return safeEqual(a, b)
+	}
+	return false
+}
+
+// safeEqual compares two interface values whose dynamic type is
+// comparable, recovering from panics caused by non-comparable fields
+// held in interface-typed struct fields.
+func safeEqual(a, b fs.FS) (equal bool) {
+	defer func() {
+		if recover() != nil {
+			equal = false
+		}
+	}()
+	return a == b
+}
@@ -0,0 +1,50 @@
+package cfs_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestNestedCompositeIsFlattened(t *testing.T) {
+	fs1 := fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("a1")}}
+	fs2 := fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("a2")}, "b.txt": &fstest.MapFile{Data: []byte("b2")}}
+	fs3 := fstest.MapFS{"c.txt": &fstest.MapFile{Data: []byte("c3")}}
+
+	inner := cfs.NewCompositeFS(fs1, fs2)
+	outer := cfs.NewCompositeFS(inner, fs3, fs2)
+
+	if got := outer.LayerCount(); got != 3 {
+		t.Fatalf("Expected 3 flattened layers, got %d", got)
+	}
+
+	testReadFile(t, outer, "a.txt", "a1")
+	testReadFile(t, outer, "b.txt", "b2")
+	testReadFile(t, outer, "c.txt", "c3")
+}
+
+func TestIncompatibleNestedCompositeIsKept(t *testing.T) {
+	fs1 := fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("a1")}}
+	fs2 := fstest.MapFS{"b.txt": &fstest.MapFile{Data: []byte("b2")}}
+
+	inner := cfs.NewOverlayFS(fs1, fs2)
+	outer := cfs.NewCompositeFS(inner)
+
+	if got := outer.LayerCount(); got != 1 {
+		t.Fatalf("Expected nested overlay to stay a single layer, got %d", got)
+	}
+
+	testReadFile(t, outer, "b.txt", "b2")
+}
+
+func TestDuplicateLayersAreDeduplicated(t *testing.T) {
+	fs1 := fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("a1")}}
+	custom := &TestFs{files: map[string]string{"b.txt": "b"}}
+
+	composite := cfs.NewCompositeFS(fs1, custom, fs1, custom)
+
+	if got := composite.LayerCount(); got != 2 {
+		t.Fatalf("Expected 2 layers after dedupe, got %d", got)
+	}
+}
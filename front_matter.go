@@ -0,0 +1,131 @@
+package cfs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+)
+
+// Front matter formats reported by FrontMatter.Format.
+const (
+	FrontMatterYAML = "yaml"
+	FrontMatterTOML = "toml"
+)
+
+// DefaultFrontMatterExtensions are the file extensions WithFrontMatter
+// strips front matter from when none are given.
+var DefaultFrontMatterExtensions = []string{".md", ".markdown", ".html", ".tmpl", ".gohtml"}
+
+// FrontMatter is the metadata block at the start of a content file,
+// delimited by "---" lines for YAML or "+++" lines for TOML.
+type FrontMatter struct {
+	// Format is FrontMatterYAML or FrontMatterTOML, or empty when the
+	// file has no front matter.
+	Format string
+	// Raw is the content between the delimiters, to be decoded with
+	// the YAML or TOML package of the caller's choice.
+	Raw []byte
+}
+
+// SplitFrontMatter separates the front matter at the start of data from
+// the body that follows it. Data without front matter, or with an
+// unterminated block, is returned unchanged as the body.
+func SplitFrontMatter(data []byte) (FrontMatter, []byte) {
+	var format string
+	var delim []byte
+	switch {
+	case hasDelimLine(data, "---"):
+		format, delim = FrontMatterYAML, []byte("---")
+	case hasDelimLine(data, "+++"):
+		format, delim = FrontMatterTOML, []byte("+++")
+	default:
+		return FrontMatter{}, data
+	}
+
+	rest := data[bytes.IndexByte(data, '\n')+1:]
+	for offset := 0; offset < len(rest); {
+		end := bytes.IndexByte(rest[offset:], '\n')
+		var line []byte
+		next := len(rest)
+		if end >= 0 {
+			line = rest[offset : offset+end]
+			next = offset + end + 1
+		} else {
+			line = rest[offset:]
+		}
+		line = bytes.TrimRight(line, " \t\r")
+		if bytes.Equal(line, delim) || (format == FrontMatterYAML && string(line) == "...") {
+			return FrontMatter{Format: format, Raw: rest[:offset]}, rest[next:]
+		}
+		offset = next
+	}
+	return FrontMatter{}, data
+}
+
+// hasDelimLine reports whether the first line of data is delim.
+func hasDelimLine(data []byte, delim string) bool {
+	line, _, found := bytes.Cut(data, []byte("\n"))
+	return found && string(bytes.TrimRight(line, " \t\r")) == delim
+}
+
+// WithFrontMatter returns a copy of the composite that strips the front
+// matter of files with the given extensions in every read layer, so
+// Open, ReadFile and Stat serve the body only. The front matter stays
+// available through Meta. Without extensions,
+// DefaultFrontMatterExtensions are used.
+func (cfs *CompositeFS) WithFrontMatter(exts ...string) *CompositeFS {
+	if len(exts) == 0 {
+		exts = DefaultFrontMatterExtensions
+	}
+	c := cfs.clone()
+	c.frontMatter = slices.Clone(exts)
+	c.filesystems = c.flatten(cfs.filesystems)
+	return c
+}
+
+// stripFrontMatter is the transform installed by WithFrontMatter.
+func (cfs *CompositeFS) stripFrontMatter(name string, r io.Reader) (io.Reader, error) {
+	if !slices.Contains(cfs.frontMatter, path.Ext(name)) {
+		return r, nil
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	_, body := SplitFrontMatter(data)
+	return bytes.NewReader(body), nil
+}
+
+// Meta returns the front matter of the named file as provided by the
+// winning layer, before any transform. Files without front matter
+// return an empty FrontMatter. It does not require WithFrontMatter.
+func (cfs *CompositeFS) Meta(name string) (FrontMatter, error) {
+	name, err := cfs.lookupName("meta", name)
+	if err != nil {
+		return FrontMatter{}, err
+	}
+	if !cfs.visible(name) {
+		return FrontMatter{}, &fs.PathError{Op: "meta", Path: name, Err: fs.ErrNotExist}
+	}
+
+	i, info, err := cfs.resolve(name)
+	if err != nil {
+		return FrontMatter{}, err
+	}
+	if info.IsDir() {
+		return FrontMatter{}, &fs.PathError{Op: "meta", Path: name, Err: fs.ErrInvalid}
+	}
+
+	fsys := cfs.filesystems[i]
+	if t, ok := fsys.(*transformFS); ok {
+		fsys = t.fsys
+	}
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return FrontMatter{}, err
+	}
+	fm, _ := SplitFrontMatter(data)
+	return fm, nil
+}
@@ -0,0 +1,91 @@
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format string
+		raw    string
+		body   string
+	}{
+		{"yaml", "---\ntitle: Home\n---\n# Home\n", cfs.FrontMatterYAML, "title: Home\n", "# Home\n"},
+		{"yaml dots", "---\ntitle: Home\n...\nbody", cfs.FrontMatterYAML, "title: Home\n", "body"},
+		{"toml", "+++\ntitle = \"Home\"\n+++\r\nbody", cfs.FrontMatterTOML, "title = \"Home\"\n", "body"},
+		{"crlf", "---\r\ntitle: Home\r\n---\r\nbody", cfs.FrontMatterYAML, "title: Home\r\n", "body"},
+		{"empty block", "---\n---\nbody", cfs.FrontMatterYAML, "", "body"},
+		{"none", "# Home\n---\n", "", "", "# Home\n---\n"},
+		{"unterminated", "---\ntitle: Home\n", "", "", "---\ntitle: Home\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, body := cfs.SplitFrontMatter([]byte(tt.data))
+			if fm.Format != tt.format || string(fm.Raw) != tt.raw || string(body) != tt.body {
+				t.Errorf("Expected %q %q %q, got %q %q %q", tt.format, tt.raw, tt.body, fm.Format, fm.Raw, body)
+			}
+		})
+	}
+}
+
+func TestWithFrontMatter(t *testing.T) {
+	theme := fstest.MapFS{
+		"content/home.md": &fstest.MapFile{Data: []byte("---\ntitle: Theme home\n---\nhome body")},
+	}
+	base := fstest.MapFS{
+		"content/about.md": &fstest.MapFile{Data: []byte("+++\ntitle = \"About\"\n+++\nabout body")},
+		"content/data.txt": &fstest.MapFile{Data: []byte("---\nkept\n---\n")},
+	}
+	composite := cfs.NewCompositeFS(cfs.NewLayer("theme", theme), base).WithFrontMatter()
+
+	testReadFile(t, composite, "content/home.md", "home body")
+	testReadFile(t, composite, "content/about.md", "about body")
+	testReadFile(t, composite, "content/data.txt", "---\nkept\n---\n")
+
+	info, err := composite.Stat("content/home.md")
+	if err != nil || info.Size() != int64(len("home body")) {
+		t.Fatalf("Expected the body size, got %v, %v", info, err)
+	}
+
+	fm, err := composite.Meta("content/home.md")
+	if err != nil {
+		t.Fatalf("Meta failed: %v", err)
+	}
+	if fm.Format != cfs.FrontMatterYAML || string(fm.Raw) != "title: Theme home\n" {
+		t.Errorf("Unexpected front matter %+v", fm)
+	}
+	fm, err = composite.Meta("content/about.md")
+	if err != nil || fm.Format != cfs.FrontMatterTOML {
+		t.Errorf("Expected TOML front matter, got %+v, %v", fm, err)
+	}
+
+	// Meta works without stripping too
+	plain := cfs.NewCompositeFS(theme)
+	if fm, err := plain.Meta("content/home.md"); err != nil || fm.Format != cfs.FrontMatterYAML {
+		t.Errorf("Expected front matter, got %+v, %v", fm, err)
+	}
+	if _, err := plain.Meta("content"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Expected fs.ErrInvalid for a directory, got %v", err)
+	}
+	if _, err := plain.Meta("missing.md"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestWithFrontMatterExtensions(t *testing.T) {
+	layer := fstest.MapFS{
+		"a.md":   &fstest.MapFile{Data: []byte("---\nx: 1\n---\nmd")},
+		"b.html": &fstest.MapFile{Data: []byte("---\nx: 1\n---\nhtml")},
+	}
+	composite := cfs.NewCompositeFS(layer).WithFrontMatter(".md")
+
+	testReadFile(t, composite, "a.md", "md")
+	testReadFile(t, composite, "b.html", "---\nx: 1\n---\nhtml")
+}
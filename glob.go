@@ -0,0 +1,102 @@
+package cfs
+
+import (
+	"io/fs"
+	"sort"
+)
+
+// Match is a path matched by GlobDetailed together with its provenance.
+type Match struct {
+	// Path is the matched path.
+	Path string
+	// Layer is the index of the layer that provides Path.
+	Layer int
+	// LayerName is the name of that layer, if it is named.
+	LayerName string
+	// Shadows lists the indices of lower layers that also contain Path
+	// and are hidden by Layer. It is empty when nothing is overridden.
+	Shadows []int
+}
+
+// Shadowing reports whether the match overrides lower layers.
+func (m Match) Shadowing() bool {
+	return len(m.Shadows) > 0
+}
+
+// GlobDetailed returns the paths matching pattern in any layer, sorted
+// by path, recording which layer wins each match and which lower layers
+// it shadows. The pattern syntax is that of path.Match.
+func (cfs *CompositeFS) GlobDetailed(pattern string) ([]Match, error) {
+	byPath := make(map[string]*Match)
+
+	for i, fsys := range cfs.filesystems {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range matches {
+			if !cfs.visible(name) {
+				continue
+			}
+			if m, ok := byPath[name]; ok {
+				m.Shadows = append(m.Shadows, i)
+				continue
+			}
+			byPath[name] = &Match{Path: name, Layer: i, LayerName: LayerName(fsys)}
+		}
+	}
+
+	out := make([]Match, 0, len(byPath))
+	for _, m := range byPath {
+		if len(cfs.probeRules) > 0 {
+			cfs.reorderMatch(m)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Path < out[j].Path
+	})
+	return out, nil
+}
+
+// reorderMatch picks the winner of m by the probe order of its path.
+func (cfs *CompositeFS) reorderMatch(m *Match) {
+	order := cfs.probeOrder(m.Path)
+	layers := append([]int{m.Layer}, m.Shadows...)
+	sort.Slice(layers, func(a, b int) bool {
+		return probePosition(order, layers[a]) < probePosition(order, layers[b])
+	})
+	m.Layer, m.Shadows = layers[0], layers[1:]
+	m.LayerName = LayerName(cfs.filesystems[m.Layer])
+}
+
+// Which reports the layer that provides name and the lower layers it
+// shadows, like a single-path GlobDetailed. Aliases and links are
+// followed, so Path is the resolved path.
+func (cfs *CompositeFS) Which(name string) (Match, error) {
+	name, err := cfs.lookupName("which", name)
+	if err != nil {
+		return Match{}, err
+	}
+	if !cfs.visible(name) {
+		return Match{}, &fs.PathError{Op: "which", Path: name, Err: fs.ErrNotExist}
+	}
+
+	layer, _, err := cfs.resolve(name)
+	if err != nil {
+		return Match{}, err
+	}
+	m := Match{Path: name, Layer: layer, LayerName: LayerName(cfs.filesystems[layer])}
+	order := cfs.probeOrder(name)
+	for n := probePosition(order, layer) + 1; n < len(cfs.filesystems); n++ {
+		i := layerAt(order, n)
+		fsys := cfs.filesystems[i]
+		if skipLayer(fsys, name) {
+			continue
+		}
+		if _, err := statLayer(fsys, name); err == nil {
+			m.Shadows = append(m.Shadows, i)
+		}
+	}
+	return m, nil
+}
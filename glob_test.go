@@ -0,0 +1,79 @@
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestGlobDetailedReportsProvenance(t *testing.T) {
+	theme := fstest.MapFS{
+		"views/home.html":  &fstest.MapFile{Data: []byte("theme home")},
+		"views/about.html": &fstest.MapFile{Data: []byte("theme about")},
+	}
+	base := fstest.MapFS{
+		"views/home.html":    &fstest.MapFile{Data: []byte("base home")},
+		"views/contact.html": &fstest.MapFile{Data: []byte("base contact")},
+		"views/style.css":    &fstest.MapFile{Data: []byte("css")},
+	}
+	composite := cfs.NewCompositeFS(cfs.NewLayer("theme", theme), cfs.NewLayer("base", base))
+
+	matches, err := composite.GlobDetailed("views/*.html")
+	if err != nil {
+		t.Fatalf("GlobDetailed failed: %v", err)
+	}
+
+	expected := []cfs.Match{
+		{Path: "views/about.html", Layer: 0, LayerName: "theme"},
+		{Path: "views/contact.html", Layer: 1, LayerName: "base"},
+		{Path: "views/home.html", Layer: 0, LayerName: "theme", Shadows: []int{1}},
+	}
+	if !reflect.DeepEqual(matches, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, matches)
+	}
+	if !matches[2].Shadowing() || matches[0].Shadowing() {
+		t.Fatal("Expected only home.html to shadow a lower layer")
+	}
+}
+
+func TestGlobDetailedBadPattern(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{})
+
+	if _, err := composite.GlobDetailed("[a"); !errors.Is(err, path.ErrBadPattern) {
+		t.Fatalf("Expected path.ErrBadPattern, got %v", err)
+	}
+}
+
+func TestWhichReportsProvenance(t *testing.T) {
+	theme := fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("theme home")},
+	}
+	base := fstest.MapFS{
+		"views/home.html":  &fstest.MapFile{Data: []byte("base home")},
+		"views/about.html": &fstest.MapFile{Data: []byte("base about")},
+	}
+	composite := cfs.NewCompositeFS(cfs.NewLayer("theme", theme), fstest.MapFS{}, cfs.NewLayer("base", base))
+
+	m, err := composite.Which("views/home.html")
+	if err != nil {
+		t.Fatalf("Which failed: %v", err)
+	}
+	expected := cfs.Match{Path: "views/home.html", Layer: 0, LayerName: "theme", Shadows: []int{2}}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, m)
+	}
+
+	m, err = composite.Which("views/about.html")
+	if err != nil || m.Layer != 2 || m.Shadowing() {
+		t.Fatalf("Expected about.html from layer 2 without shadowing, got %+v (%v)", m, err)
+	}
+
+	if _, err := composite.Which("views/missing.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected fs.ErrNotExist, got %v", err)
+	}
+}
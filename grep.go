@@ -0,0 +1,142 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"regexp"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// GrepOptions configures Grep. The zero value searches every text file
+// using one worker per CPU.
+type GrepOptions struct {
+	// MaxFileSize skips files larger than this many bytes. Zero means
+	// no limit.
+	MaxFileSize int64
+	// IncludeBinary searches files that look binary. By default files
+	// containing a NUL byte in their first 8KB are skipped.
+	IncludeBinary bool
+	// Workers is the number of files searched concurrently.
+	Workers int
+	// Match restricts the search to paths for which it returns true.
+	Match func(path string) bool
+}
+
+// GrepHit is a line matched by Grep.
+type GrepHit struct {
+	Path string
+	// Line is the 1-based line number of the match.
+	Line int
+	Text string
+}
+
+// binarySniffLen is how much of a file is inspected to detect binary
+// content.
+const binarySniffLen = 8 << 10
+
+// grepMaxLine bounds the length of a single searchable line.
+const grepMaxLine = 1 << 20
+
+// Grep searches the files below root in the merged view for lines
+// matching re. Hits are sorted by path and line. Files that cannot be
+// searched are reported in the returned error while the hits from every
+// other file are still returned.
+func (cfs *CompositeFS) Grep(root string, re *regexp.Regexp, opts GrepOptions) ([]GrepHit, error) {
+	files, err := cfs.Find(root, func(p string, d fs.DirEntry) bool {
+		return !d.IsDir() && (opts.Match == nil || opts.Match(p))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	var (
+		mu   sync.Mutex
+		hits []GrepHit
+		errs []error
+		wg   sync.WaitGroup
+	)
+	jobs := make(chan string)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				found, err := cfs.grepFile(name, re, opts)
+				mu.Lock()
+				hits = append(hits, found...)
+				if err != nil {
+					errs = append(errs, err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, name := range files {
+		jobs <- name
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Path != hits[j].Path {
+			return hits[i].Path < hits[j].Path
+		}
+		return hits[i].Line < hits[j].Line
+	})
+	return hits, errors.Join(errs...)
+}
+
+func (cfs *CompositeFS) grepFile(name string, re *regexp.Regexp, opts GrepOptions) ([]GrepHit, error) {
+	file, err := cfs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if opts.MaxFileSize > 0 {
+		info, err := file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() > opts.MaxFileSize {
+			return nil, nil
+		}
+	}
+
+	r := bufio.NewReaderSize(file, binarySniffLen)
+	if !opts.IncludeBinary {
+		head, err := r.Peek(binarySniffLen)
+		if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+		}
+		if bytes.IndexByte(head, 0) >= 0 {
+			return nil, nil
+		}
+	}
+
+	var hits []GrepHit
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), grepMaxLine)
+	for line := 1; scanner.Scan(); line++ {
+		if re.Match(scanner.Bytes()) {
+			hits = append(hits, GrepHit{Path: name, Line: line, Text: scanner.Text()})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return hits, fmt.Errorf("grep %s: %w", name, err)
+	}
+	return hits, nil
+}
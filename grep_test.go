@@ -0,0 +1,66 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestGrepSearchesMergedView(t *testing.T) {
+	theme := fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("<h1>{{ title }}</h1>\n<p>theme</p>\n")},
+	}
+	base := fstest.MapFS{
+		"views/home.html":  &fstest.MapFile{Data: []byte("{{ title }} from base\n")},
+		"views/about.html": &fstest.MapFile{Data: []byte("about\n{{ title }}\n")},
+		"logo.png":         &fstest.MapFile{Data: []byte("\x89PNG\x00{{ title }}")},
+	}
+	composite := cfs.NewCompositeFS(theme, base)
+
+	hits, err := composite.Grep(".", regexp.MustCompile(`\{\{ title \}\}`), cfs.GrepOptions{Workers: 2})
+	if err != nil {
+		t.Fatalf("Grep failed: %v", err)
+	}
+
+	expected := []cfs.GrepHit{
+		{Path: "views/about.html", Line: 2, Text: "{{ title }}"},
+		{Path: "views/home.html", Line: 1, Text: "<h1>{{ title }}</h1>"},
+	}
+	if !reflect.DeepEqual(hits, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, hits)
+	}
+}
+
+func TestGrepGuards(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{
+		"small.txt": &fstest.MapFile{Data: []byte("needle\n")},
+		"large.txt": &fstest.MapFile{Data: []byte(strings.Repeat("x", 100) + "\nneedle\n")},
+		"data.bin":  &fstest.MapFile{Data: []byte("needle\x00")},
+	})
+	re := regexp.MustCompile("needle")
+
+	hits, err := composite.Grep(".", re, cfs.GrepOptions{MaxFileSize: 50})
+	if err != nil {
+		t.Fatalf("Grep failed: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Path != "small.txt" {
+		t.Fatalf("Expected only small.txt, got %+v", hits)
+	}
+
+	hits, err = composite.Grep(".", re, cfs.GrepOptions{
+		IncludeBinary: true,
+		Match:         func(p string) bool { return strings.HasSuffix(p, ".bin") },
+	})
+	if err != nil {
+		t.Fatalf("Grep failed: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Path != "data.bin" {
+		t.Fatalf("Expected data.bin when binaries are included, got %+v", hits)
+	}
+}
@@ -0,0 +1,100 @@
+package cfs
+
+import (
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+)
+
+// HashFile streams the winning version of name through h and returns
+// the hex encoded sum. The file is never loaded into memory as a whole,
+// so multi-gigabyte files can be hashed. h is reset before use.
+func (cfs *CompositeFS) HashFile(name string, h hash.Hash) (string, error) {
+	file, err := cfs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", &fs.PathError{Op: "hash", Path: name, Err: fs.ErrInvalid}
+	}
+	return hashStream(h, file, name)
+}
+
+// HashTree returns the hex encoded algo sums of every file below root in
+// the merged view, keyed by path. Files are streamed one at a time. With
+// a stat cache (see WithStatCache), sums are kept alongside the cached
+// Stat results of the winning layer and reused until those expire or are
+// invalidated. The package implementing algo must be linked into the
+// binary; crypto.SHA256 always is.
+func (cfs *CompositeFS) HashTree(root string, algo crypto.Hash) (map[string]string, error) {
+	if !algo.Available() {
+		return nil, fmt.Errorf("hash algorithm %v is not available", algo)
+	}
+
+	sums := make(map[string]string)
+	err := fs.WalkDir(cfs, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		sum, err := cfs.hashTreeFile(name, algo)
+		if err != nil {
+			return err
+		}
+		sums[name] = sum
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
+
+// hashTreeFile hashes the winning version of name, consulting the sums
+// kept in the stat cache.
+func (cfs *CompositeFS) hashTreeFile(name string, algo crypto.Hash) (string, error) {
+	resolved, err := cfs.lookupName("hash", name)
+	if err != nil {
+		return "", err
+	}
+	i, _, err := cfs.resolve(resolved)
+	if err != nil {
+		return "", err
+	}
+	// uncacheable layers have no cached Stat result to keep sums with
+	if sum, ok := cfs.statCache.getHash(i, resolved, algo.String()); ok {
+		return sum, nil
+	}
+
+	file, err := cfs.filesystems[i].Open(resolved)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	sum, err := hashStream(algo.New(), file, name)
+	if err != nil {
+		return "", err
+	}
+	cfs.statCache.putHash(i, resolved, algo.String(), sum)
+	return sum, nil
+}
+
+// hashStream resets h and feeds it r.
+func hashStream(h hash.Hash, r io.Reader, name string) (string, error) {
+	h.Reset()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", &fs.PathError{Op: "hash", Path: name, Err: err}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,96 @@
+package cfs_test
+
+import (
+	"crypto"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHashFile(t *testing.T) {
+	composite := cfs.NewCompositeFS(
+		fstest.MapFS{"views/home.html": &fstest.MapFile{Data: []byte("theme home")}},
+		fstest.MapFS{"views/home.html": &fstest.MapFile{Data: []byte("base home")}},
+	)
+
+	sum, err := composite.HashFile("views/home.html", sha256.New())
+	if err != nil {
+		t.Fatalf("HashFile failed: %v", err)
+	}
+	if sum != sha256Hex("theme home") {
+		t.Errorf("Expected the sum of the winning version, got %s", sum)
+	}
+
+	file, err := composite.Open("views/home.html")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer file.Close()
+	if hash, err := file.(cfs.LayeredFile).ContentHash(); err != nil || hash != sum {
+		t.Errorf("Expected ContentHash to match HashFile, got %s, %v", hash, err)
+	}
+
+	md5Sum, err := composite.HashFile("views/home.html", md5.New())
+	if err != nil || len(md5Sum) != 32 {
+		t.Errorf("Expected an md5 sum, got %q, %v", md5Sum, err)
+	}
+
+	if _, err := composite.HashFile("views", sha256.New()); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Expected ErrInvalid for a directory, got %v", err)
+	}
+}
+
+func TestHashTree(t *testing.T) {
+	top := fstest.MapFS{"views/home.html": &fstest.MapFile{Data: []byte("theme home")}}
+	base := fstest.MapFS{
+		"views/home.html":       &fstest.MapFile{Data: []byte("base home")},
+		"views/partials/a.html": &fstest.MapFile{Data: []byte("a")},
+		"static/app.css":        &fstest.MapFile{Data: []byte("css")},
+	}
+	composite := cfs.NewCompositeFS(top, base).WithStatCache(time.Minute)
+
+	sums, err := composite.HashTree("views", crypto.SHA256)
+	if err != nil {
+		t.Fatalf("HashTree failed: %v", err)
+	}
+	want := map[string]string{
+		"views/home.html":       sha256Hex("theme home"),
+		"views/partials/a.html": sha256Hex("a"),
+	}
+	if len(sums) != len(want) {
+		t.Fatalf("Expected %d sums, got %v", len(want), sums)
+	}
+	for name, sum := range want {
+		if sums[name] != sum {
+			t.Errorf("Expected %s for %s, got %s", sum, name, sums[name])
+		}
+	}
+
+	// sums live as long as the cached Stat results
+	top["views/home.html"] = &fstest.MapFile{Data: []byte("edited")}
+	sums, _ = composite.HashTree("views", crypto.SHA256)
+	if sums["views/home.html"] != want["views/home.html"] {
+		t.Errorf("Expected the cached sum before invalidation, got %s", sums["views/home.html"])
+	}
+	composite.InvalidateStat("views/home.html")
+	sums, _ = composite.HashTree("views", crypto.SHA256)
+	if sums["views/home.html"] != sha256Hex("edited") {
+		t.Errorf("Expected a fresh sum after invalidation, got %s", sums["views/home.html"])
+	}
+
+	if _, err := composite.HashTree(".", crypto.Hash(0)); err == nil {
+		t.Error("Expected an error for an unavailable algorithm")
+	}
+}
@@ -0,0 +1,235 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// IndexedLayer wraps a large layer with an index of its directory
+// listings that is built incrementally: a directory is listed the first
+// time a lookup reaches it, instead of walking the whole layer up front
+// as NewBloomLayer does. Lookups for paths the index rules out are
+// answered without touching the wrapped filesystem. The progress can be
+// persisted with Save and is loaded again by NewIndexedLayer, so later
+// starts only list the directories not seen before.
+//
+// The index trusts its listings: content added to the wrapped
+// filesystem stays invisible until it is reported through
+// InvalidatePaths, which a Watcher does automatically.
+type IndexedLayer struct {
+	fsys fs.FS
+	file string
+
+	mu    sync.RWMutex
+	dirs  map[string]map[string]bool // directory -> entry name -> is dir
+	dirty bool
+}
+
+// indexState is the persisted form of an IndexedLayer. Entries naming
+// directories end with a slash.
+type indexState struct {
+	Dirs map[string][]string `json:"dirs"`
+}
+
+// NewIndexedLayer wraps fsys with an incremental index. When indexFile
+// is not empty, the progress saved there by Save is loaded; a missing
+// file starts an empty index.
+func NewIndexedLayer(fsys fs.FS, indexFile string) (*IndexedLayer, error) {
+	l := &IndexedLayer{fsys: fsys, file: indexFile, dirs: make(map[string]map[string]bool)}
+	if indexFile == "" {
+		return l, nil
+	}
+
+	data, err := os.ReadFile(indexFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state indexState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("index %s: %w", indexFile, err)
+	}
+	for dir, names := range state.Dirs {
+		entries := make(map[string]bool, len(names))
+		for _, name := range names {
+			entries[strings.TrimSuffix(name, "/")] = strings.HasSuffix(name, "/")
+		}
+		l.dirs[dir] = entries
+	}
+	return l, nil
+}
+
+// Indexed returns the number of directories listed in the index.
+func (l *IndexedLayer) Indexed() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.dirs)
+}
+
+// Save persists the directories indexed so far to the index file. It
+// does nothing when the layer has no index file or nothing changed.
+func (l *IndexedLayer) Save() error {
+	if l.file == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	if !l.dirty {
+		l.mu.Unlock()
+		return nil
+	}
+	state := indexState{Dirs: make(map[string][]string, len(l.dirs))}
+	for dir, entries := range l.dirs {
+		names := make([]string, 0, len(entries))
+		for name, isDir := range entries {
+			if isDir {
+				name += "/"
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		state.Dirs[dir] = names
+	}
+	l.dirty = false
+	l.mu.Unlock()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(l.file, data)
+}
+
+// listing returns the indexed entries of dir, listing it on first use.
+// ok is false when the listing failed for another reason than dir not
+// existing, so nothing can be ruled out.
+func (l *IndexedLayer) listing(dir string) (entries map[string]bool, ok bool) {
+	l.mu.RLock()
+	entries, found := l.dirs[dir]
+	l.mu.RUnlock()
+	if found {
+		return entries, true
+	}
+
+	list, err := ReadDir(l.fsys, dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, false
+	}
+	return l.record(dir, list), true
+}
+
+// record stores the listing of dir in the index.
+func (l *IndexedLayer) record(dir string, list []fs.DirEntry) map[string]bool {
+	entries := make(map[string]bool, len(list))
+	for _, entry := range list {
+		entries[entry.Name()] = entry.IsDir()
+	}
+	l.mu.Lock()
+	l.dirs[dir] = entries
+	l.dirty = true
+	l.mu.Unlock()
+	return entries
+}
+
+// MayContain implements PathFilter, listing the directories along name
+// that are not indexed yet. A false result is definitive.
+func (l *IndexedLayer) MayContain(name string) bool {
+	name = path.Clean(name)
+	if name == "." {
+		return true
+	}
+
+	dir := "."
+	for {
+		elem, rest, more := strings.Cut(name, "/")
+		entries, ok := l.listing(dir)
+		if !ok {
+			return true
+		}
+		isDir, found := entries[elem]
+		if !found {
+			return false
+		}
+		if !more {
+			return true
+		}
+		if !isDir {
+			return false
+		}
+		dir = path.Join(dir, elem)
+		name = rest
+	}
+}
+
+// InvalidatePaths implements Invalidator by dropping the listings of
+// the changed paths and their parents, which are listed again on the
+// next lookup.
+func (l *IndexedLayer) InvalidatePaths(names ...string) {
+	l.mu.Lock()
+	for _, name := range names {
+		for p := path.Clean(name); ; p = path.Dir(p) {
+			if _, ok := l.dirs[p]; ok {
+				delete(l.dirs, p)
+				l.dirty = true
+			}
+			if p == "." {
+				break
+			}
+		}
+	}
+	l.mu.Unlock()
+	invalidateLayer(l.fsys, names)
+}
+
+// Open implements fs.FS.
+func (l *IndexedLayer) Open(name string) (fs.File, error) {
+	if !l.MayContain(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return l.fsys.Open(name)
+}
+
+// Stat implements fs.StatFS.
+func (l *IndexedLayer) Stat(name string) (fs.FileInfo, error) {
+	if !l.MayContain(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return statLayer(l.fsys, name)
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (l *IndexedLayer) ReadFile(name string) ([]byte, error) {
+	if !l.MayContain(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return fs.ReadFile(l.fsys, name)
+}
+
+// ReadDir implements fs.ReadDirFS. Listings read through it are added
+// to the index.
+func (l *IndexedLayer) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !l.MayContain(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	entries, err := ReadDir(l.fsys, name)
+	if err == nil {
+		l.record(path.Clean(name), entries)
+	}
+	return entries, err
+}
+
+// Sub returns the layer rooted at dir, still answered by the index.
+func (l *IndexedLayer) Sub(dir string) (fs.FS, error) {
+	return subLayer(l, dir)
+}
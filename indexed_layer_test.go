@@ -0,0 +1,123 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+// listCountingFS counts directory listings.
+type listCountingFS struct {
+	fstest.MapFS
+	lists int
+}
+
+func (l *listCountingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	l.lists++
+	return l.MapFS.ReadDir(name)
+}
+
+func newLargeLayer() *listCountingFS {
+	return &listCountingFS{MapFS: fstest.MapFS{
+		"assets/css/app.css":  &fstest.MapFile{Data: []byte("css")},
+		"assets/js/app.js":    &fstest.MapFile{Data: []byte("js")},
+		"archive/2020/a.html": &fstest.MapFile{Data: []byte("a")},
+		"archive/2021/b.html": &fstest.MapFile{Data: []byte("b")},
+	}}
+}
+
+func TestIndexedLayerIndexesLazily(t *testing.T) {
+	large := newLargeLayer()
+	indexed, err := cfs.NewIndexedLayer(large, "")
+	if err != nil {
+		t.Fatalf("NewIndexedLayer failed: %v", err)
+	}
+	if indexed.Indexed() != 0 {
+		t.Fatalf("Expected nothing to be indexed up front, got %d", indexed.Indexed())
+	}
+
+	composite := cfs.NewCompositeFS(fstest.MapFS{}, indexed)
+	testReadFile(t, composite, "assets/css/app.css", "css")
+	if indexed.Indexed() != 3 {
+		t.Errorf("Expected the 3 directories along the path to be indexed, got %d", indexed.Indexed())
+	}
+
+	lists := large.lists
+	if _, err := composite.Open("assets/css/missing.css"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected ErrNotExist, got %v", err)
+	}
+	if _, err := composite.Open("assets/img/logo.png"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected ErrNotExist, got %v", err)
+	}
+	if large.lists != lists {
+		t.Errorf("Expected misses in indexed directories to be answered by the index, got %d listings", large.lists-lists)
+	}
+	if indexed.MayContain("archive/2020/a.html") != true || indexed.Indexed() != 5 {
+		t.Errorf("Expected archive to be indexed on first access, got %d directories", indexed.Indexed())
+	}
+
+	// changes are picked up once reported
+	large.MapFS["assets/css/new.css"] = &fstest.MapFile{Data: []byte("new")}
+	if indexed.MayContain("assets/css/new.css") {
+		t.Error("Expected the new file to be hidden until invalidated")
+	}
+	composite.Invalidate("assets/css/new.css")
+	testReadFile(t, composite, "assets/css/new.css", "new")
+}
+
+func TestIndexedLayerPersistsProgress(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "index.json")
+
+	first, err := cfs.NewIndexedLayer(newLargeLayer(), file)
+	if err != nil {
+		t.Fatalf("NewIndexedLayer failed: %v", err)
+	}
+	first.MayContain("assets/js/app.js")
+	if err := first.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	large := newLargeLayer()
+	second, err := cfs.NewIndexedLayer(large, file)
+	if err != nil {
+		t.Fatalf("NewIndexedLayer failed: %v", err)
+	}
+	if second.Indexed() != 3 {
+		t.Fatalf("Expected 3 directories loaded from the index file, got %d", second.Indexed())
+	}
+	if !second.MayContain("assets/js/app.js") || second.MayContain("assets/js/other.js") {
+		t.Error("Expected lookups to be answered by the loaded index")
+	}
+	if large.lists != 0 {
+		t.Errorf("Expected no listings for loaded directories, got %d", large.lists)
+	}
+}
+
+func TestIndexedLayerSub(t *testing.T) {
+	large := newLargeLayer()
+	layer, err := cfs.NewIndexedLayer(large, "")
+	if err != nil {
+		t.Fatalf("NewIndexedLayer failed: %v", err)
+	}
+
+	sub, err := cfs.NewCompositeFS(layer).Sub("assets")
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	testReadFile(t, sub, "css/app.css", "css")
+
+	// the index still answers misses below the root
+	lists := large.lists
+	if _, err := fs.Stat(sub, "css/missing.css"); err == nil {
+		t.Fatal("Expected css/missing.css not to exist")
+	}
+	if large.lists != lists {
+		t.Fatalf("Expected the miss to be answered by the index, got %d listings", large.lists-lists)
+	}
+}
@@ -0,0 +1,173 @@
+package cfs
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrSymlinkEscape is returned when a path resolves, through symbolic
+// links, to a location outside of the root of its layer.
+var ErrSymlinkEscape = errors.New("symlink escapes layer root")
+
+// Jail wraps fsys so every path is validated before it is delegated,
+// for layers that do not enforce the io/fs path rules themselves.
+// Paths that are not valid per fs.ValidPath, such as ones containing
+// ".." or starting with "/", and paths containing a backslash or a NUL
+// byte are rejected with fs.ErrInvalid. For layers with a known OS root
+// (os.DirFS, DirWriteFS or any layer with a Root method), paths whose
+// symlinks resolve outside of the root are rejected with
+// ErrSymlinkEscape.
+func Jail(fsys fs.FS) fs.FS {
+	if j, ok := fsys.(*jailFS); ok {
+		return j
+	}
+	root := osRoot(fsys)
+	return &jailFS{fsys: fsys, root: root, bound: root}
+}
+
+type jailFS struct {
+	fsys fs.FS
+	root string
+	// bound is the directory paths must stay within. It is the root of
+	// the original layer, also for jails created by Sub.
+	bound string
+
+	once     sync.Once
+	realRoot string
+	rootErr  error
+}
+
+// osRoot returns the OS directory backing fsys, or an empty string if
+// it is unknown.
+func osRoot(fsys fs.FS) string {
+	if r, ok := fsys.(interface{ Root() string }); ok {
+		return r.Root()
+	}
+	if rv := reflect.ValueOf(fsys); rv.Kind() == reflect.String && rv.Type().PkgPath() == "os" {
+		return rv.String()
+	}
+	return ""
+}
+
+// check validates name for op.
+func (j *jailFS) check(op, name string) error {
+	if !fs.ValidPath(name) || strings.ContainsAny(name, "\\\x00") {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if j.root == "" {
+		return nil
+	}
+
+	j.once.Do(func() {
+		j.realRoot, j.rootErr = filepath.EvalSymlinks(j.bound)
+	})
+	if j.rootErr != nil {
+		return &fs.PathError{Op: op, Path: name, Err: j.rootErr}
+	}
+
+	real, err := filepath.EvalSymlinks(filepath.Join(j.root, filepath.FromSlash(name)))
+	if err != nil {
+		// missing paths are reported by the layer itself
+		return nil
+	}
+	if real != j.realRoot && !strings.HasPrefix(real, j.realRoot+string(filepath.Separator)) {
+		return &fs.PathError{Op: op, Path: name, Err: ErrSymlinkEscape}
+	}
+	return nil
+}
+
+// Name implements NamedFS by delegating to the wrapped layer.
+func (j *jailFS) Name() string {
+	return LayerName(j.fsys)
+}
+
+func (j *jailFS) Open(name string) (fs.File, error) {
+	if err := j.check("open", name); err != nil {
+		return nil, err
+	}
+	return j.fsys.Open(name)
+}
+
+func (j *jailFS) Stat(name string) (fs.FileInfo, error) {
+	if err := j.check("stat", name); err != nil {
+		return nil, err
+	}
+	return statLayer(j.fsys, name)
+}
+
+func (j *jailFS) ReadFile(name string) ([]byte, error) {
+	if err := j.check("read", name); err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(j.fsys, name)
+}
+
+func (j *jailFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := j.check("readdir", name); err != nil {
+		return nil, err
+	}
+	return ReadDir(j.fsys, name)
+}
+
+func (j *jailFS) InvalidatePaths(names ...string) {
+	invalidateLayer(j.fsys, names)
+}
+
+func (j *jailFS) Sub(dir string) (fs.FS, error) {
+	if err := j.check("sub", dir); err != nil {
+		return nil, err
+	}
+	sub, err := fs.Sub(j.fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	// fs.Sub views of os.DirFS hide their root, so derive it from ours
+	root := osRoot(sub)
+	if j.root != "" {
+		root = filepath.Join(j.root, filepath.FromSlash(dir))
+	}
+	return &jailFS{fsys: sub, root: root, bound: j.bound}, nil
+}
+
+// WithSymlinkProtection returns a copy of the composite that jails
+// every layer backed by an OS directory, such as os.DirFS, so symlinks
+// pointing outside of a layer's root are never served. See Jail.
+func (cfs *CompositeFS) WithSymlinkProtection() *CompositeFS {
+	c := cfs.clone()
+	c.jailOS = true
+	c.filesystems = c.flatten(cfs.filesystems)
+	return c
+}
+
+// jailOSLayer jails fsys when it is backed by an OS directory, looking
+// through the wrappers of this package.
+func jailOSLayer(fsys fs.FS) fs.FS {
+	switch v := fsys.(type) {
+	case *jailFS:
+		return v
+	case *archiveFS:
+		return newArchiveFS(jailOSLayer(v.fsys))
+	case *adaptedFS:
+		if jailed := jailOSLayer(v.fsys); !sameLayer(jailed, v.fsys) {
+			return Adapt(jailed)
+		}
+		return v
+	case *Layer:
+		inner := v.FS()
+		jailed := jailOSLayer(inner)
+		if sameLayer(jailed, inner) {
+			return v
+		}
+		c := *v
+		c.fsys = newLayerSlot(jailed)
+		return &c
+	}
+	if osRoot(fsys) != "" {
+		return Jail(fsys)
+	}
+	return fsys
+}
@@ -0,0 +1,125 @@
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+// permissiveFS serves any name it is asked for, ignoring io/fs path
+// rules like some third-party layers do.
+type permissiveFS struct {
+	fstest.MapFS
+}
+
+func (p permissiveFS) Open(name string) (fs.File, error) {
+	return p.MapFS.Open("secret.txt")
+}
+
+func TestJailRejectsInvalidPaths(t *testing.T) {
+	jailed := cfs.Jail(permissiveFS{fstest.MapFS{
+		"secret.txt": &fstest.MapFile{Data: []byte("secret")},
+	}})
+
+	for _, name := range []string{"../secret.txt", "/etc/passwd", "a/../../b", `a\..\b`, "a\x00b"} {
+		if _, err := jailed.Open(name); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("Open(%q): expected fs.ErrInvalid, got %v", name, err)
+		}
+	}
+	testReadFile(t, jailed, "secret.txt", "secret")
+}
+
+func TestJailRejectsSymlinkEscapes(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	writeTestFile(t, filepath.Join(root, "public", "index.html"), []byte("index"))
+	writeTestFile(t, filepath.Join(base, "outside.txt"), []byte("outside"))
+
+	if err := os.Symlink(filepath.Join(base, "outside.txt"), filepath.Join(root, "escape.txt")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(root, "public", "index.html"), filepath.Join(root, "inside.html")); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+
+	jailed := cfs.Jail(os.DirFS(root))
+	if _, err := jailed.Open("escape.txt"); !errors.Is(err, cfs.ErrSymlinkEscape) {
+		t.Fatalf("Expected ErrSymlinkEscape, got %v", err)
+	}
+	if _, err := fs.ReadFile(jailed, "escape.txt"); !errors.Is(err, cfs.ErrSymlinkEscape) {
+		t.Fatalf("Expected ErrSymlinkEscape from ReadFile, got %v", err)
+	}
+	testReadFile(t, jailed, "inside.html", "index")
+
+	composite := cfs.NewCompositeFS(jailed)
+	if _, err := composite.Open("escape.txt"); !errors.Is(err, cfs.ErrSymlinkEscape) {
+		t.Fatalf("Expected ErrSymlinkEscape through the composite, got %v", err)
+	}
+}
+
+func TestJailSubRejectsSymlinkEscapes(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	writeTestFile(t, filepath.Join(root, "sub", "index.html"), []byte("index"))
+	writeTestFile(t, filepath.Join(base, "outside.txt"), []byte("outside"))
+
+	if err := os.Symlink(filepath.Join(base, "outside.txt"), filepath.Join(root, "sub", "escape.txt")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	sub, err := fs.Sub(cfs.Jail(os.DirFS(root)), "sub")
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	if _, err := sub.Open("escape.txt"); !errors.Is(err, cfs.ErrSymlinkEscape) {
+		t.Fatalf("Expected ErrSymlinkEscape, got %v", err)
+	}
+	testReadFile(t, sub, "index.html", "index")
+}
+
+func TestWithSymlinkProtection(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "public")
+	writeTestFile(t, filepath.Join(root, "index.html"), []byte("index"))
+	writeTestFile(t, filepath.Join(base, "private.key"), []byte("key"))
+	if err := os.Symlink(filepath.Join(base, "private.key"), filepath.Join(root, "key.txt")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	composite := cfs.NewCompositeFS(
+		cfs.NewLayer("public", os.DirFS(root)),
+		fstest.MapFS{"key.txt": &fstest.MapFile{Data: []byte("embedded")}},
+	)
+	testReadFile(t, composite, "key.txt", "key")
+
+	protected := composite.WithSymlinkProtection()
+	if _, err := protected.Open("key.txt"); !errors.Is(err, cfs.ErrSymlinkEscape) {
+		t.Fatalf("Expected ErrSymlinkEscape, got %v", err)
+	}
+	testReadFile(t, protected, "index.html", "index")
+
+	// layers added later are protected too
+	extended := protected.WithLayerPrepended(os.DirFS(root))
+	if _, err := extended.Open("key.txt"); !errors.Is(err, cfs.ErrSymlinkEscape) {
+		t.Fatalf("Expected ErrSymlinkEscape for a prepended layer, got %v", err)
+	}
+}
+
+func TestWithSymlinkProtectionNamedMapLayer(t *testing.T) {
+	composite := cfs.NewCompositeFS(cfs.NewLayer("base", fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("a")},
+	})).WithSymlinkProtection()
+	testReadFile(t, composite, "a.txt", "a")
+}
+
+func TestWithSymlinkProtectionAdaptedMapLayer(t *testing.T) {
+	composite := cfs.NewCompositeFS(cfs.Adapt(fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("a")},
+	})).WithSymlinkProtection()
+	testReadFile(t, composite, "a.txt", "a")
+}
@@ -0,0 +1,191 @@
+package cfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// ErrNoJournal is returned by Rollback when the composite was not
+// configured with WithJournal.
+var ErrNoJournal = errors.New("composite filesystem has no journal")
+
+// JournalEntry describes a single change recorded in the journal.
+type JournalEntry struct {
+	Time time.Time
+	Op   string
+	Name string
+}
+
+// journal records the state of write-layer paths before they are
+// modified so changes can be undone.
+type journal struct {
+	mu      sync.Mutex
+	entries []journalEntry
+}
+
+type journalEntry struct {
+	JournalEntry
+	existed bool
+	dir     bool
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// WithJournal returns a copy of the composite that records every change
+// made to the write layer, enabling Rollback. The journal lives in
+// memory and only covers changes made through the returned composite
+// and the composites derived from it.
+func (cfs *CompositeFS) WithJournal() *CompositeFS {
+	c := cfs.clone()
+	c.journal = &journal{}
+	return c
+}
+
+// Journal returns the changes recorded so far, oldest first.
+func (cfs *CompositeFS) Journal() []JournalEntry {
+	if cfs.journal == nil {
+		return nil
+	}
+	cfs.journal.mu.Lock()
+	defer cfs.journal.mu.Unlock()
+
+	out := make([]JournalEntry, len(cfs.journal.entries))
+	for i, e := range cfs.journal.entries {
+		out[i] = e.JournalEntry
+	}
+	return out
+}
+
+// Revert removes the write-layer override for name so the version
+// provided by lower layers becomes visible again. Directories are
+// removed together with their contents.
+func (cfs *CompositeFS) Revert(name string) error {
+	name, err := cfs.writePath("revert", name)
+	if err != nil {
+		return err
+	}
+	if _, err := fs.Stat(cfs.writer, name); err != nil {
+		return &fs.PathError{Op: "revert", Path: name, Err: unwrapPathError(err)}
+	}
+
+	var paths []string
+	fs.WalkDir(cfs.writer, name, func(p string, d fs.DirEntry, err error) error {
+		if err == nil {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	defer cfs.record("revert", paths...)()
+
+	return removeAll(cfs.writer, name)
+}
+
+// Rollback undoes every journaled change made after the given time,
+// newest first, restoring the write layer to its previous state.
+func (cfs *CompositeFS) Rollback(to time.Time) error {
+	if cfs.writer == nil {
+		return &fs.PathError{Op: "rollback", Path: ".", Err: ErrReadOnly}
+	}
+	if cfs.journal == nil {
+		return ErrNoJournal
+	}
+
+	j := cfs.journal
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for len(j.entries) > 0 {
+		last := j.entries[len(j.entries)-1]
+		if !last.Time.After(to) {
+			break
+		}
+		cfs.forgetCached(last.Name)
+		err := cfs.restore(last)
+		cfs.forgetCached(last.Name)
+		if err != nil {
+			return fmt.Errorf("rollback %s %q: %w", last.Op, last.Name, err)
+		}
+		j.entries = j.entries[:len(j.entries)-1]
+	}
+	return nil
+}
+
+// record snapshots the current write-layer state of name before it is
+// modified and drops cached results for it. Snapshots are only taken
+// when a journal is configured. The returned function drops the cached
+// results again and must run once the change is done, typically
+// deferred, so lookups racing with the change cannot cache the old
+// state.
+func (cfs *CompositeFS) record(op string, names ...string) func() {
+	cfs.forgetCached(names...)
+	done := func() { cfs.forgetCached(names...) }
+	if cfs.journal == nil {
+		return done
+	}
+
+	now := time.Now()
+	snapshots := make([]journalEntry, 0, len(names))
+	for _, name := range names {
+		entry := journalEntry{
+			JournalEntry: JournalEntry{Time: now, Op: op, Name: name},
+		}
+		if info, err := fs.Stat(cfs.writer, name); err == nil {
+			entry.existed = true
+			entry.dir = info.IsDir()
+			entry.mode = info.Mode().Perm()
+			entry.modTime = info.ModTime()
+			if !entry.dir {
+				entry.data, _ = fs.ReadFile(cfs.writer, name)
+			}
+		}
+		snapshots = append(snapshots, entry)
+	}
+
+	cfs.journal.mu.Lock()
+	cfs.journal.entries = append(cfs.journal.entries, snapshots...)
+	cfs.journal.mu.Unlock()
+	return done
+}
+
+// forgetCached drops the cached results for names.
+func (cfs *CompositeFS) forgetCached(names ...string) {
+	cfs.statCache.invalidate(names...)
+	cfs.reads.forget(names...)
+	cfs.content.invalidate(names...)
+	cfs.attrs.invalidate()
+}
+
+// restore puts the write layer back in the state captured by entry.
+func (cfs *CompositeFS) restore(entry journalEntry) error {
+	w := cfs.writer
+	if !entry.existed {
+		return removeAll(w, entry.Name)
+	}
+
+	if entry.dir {
+		if err := w.MkdirAll(entry.Name, entry.mode); err != nil {
+			return err
+		}
+	} else {
+		if err := cfs.ensureParent(entry.Name); err != nil {
+			return err
+		}
+		if err := w.WriteFile(entry.Name, entry.data, entry.mode); err != nil {
+			return err
+		}
+	}
+
+	if meta, ok := w.(MetadataFS); ok {
+		if err := meta.Chmod(entry.Name, entry.mode); err != nil {
+			return err
+		}
+		if !entry.modTime.IsZero() {
+			return meta.Chtimes(entry.Name, entry.modTime, entry.modTime)
+		}
+	}
+	return nil
+}
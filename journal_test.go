@@ -0,0 +1,98 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestRevertRevealsLowerLayer(t *testing.T) {
+	base := fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("default home")},
+	}
+	composite := cfs.NewWritableFS(cfs.NewDirWriteFS(t.TempDir()), base)
+
+	if err := composite.WriteFile("views/home.html", []byte("custom home"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	testReadFile(t, composite, "views/home.html", "custom home")
+
+	if err := composite.Revert("views/home.html"); err != nil {
+		t.Fatalf("Revert failed: %v", err)
+	}
+	testReadFile(t, composite, "views/home.html", "default home")
+
+	if err := composite.Revert("views/home.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected fs.ErrNotExist reverting a path without override, got %v", err)
+	}
+}
+
+func TestRollbackRestoresPreviousState(t *testing.T) {
+	base := fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("default home")},
+	}
+	composite := cfs.NewWritableFS(cfs.NewDirWriteFS(t.TempDir()), base).WithJournal()
+
+	if err := composite.WriteFile("views/about.html", []byte("about v1"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	checkpoint := time.Now()
+
+	if err := composite.WriteFile("views/about.html", []byte("about v2"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := composite.WriteFile("views/home.html", []byte("custom home"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := composite.Rename("views/about.html", "views/info.html"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	if err := composite.Rollback(checkpoint); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	testReadFile(t, composite, "views/about.html", "about v1")
+	testReadFile(t, composite, "views/home.html", "default home")
+	if _, err := composite.Stat("views/info.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected info.html to be rolled back, got %v", err)
+	}
+
+	if got := len(composite.Journal()); got != 1 {
+		t.Fatalf("Expected 1 remaining journal entry, got %d", got)
+	}
+}
+
+func TestRollbackRestoresRevertedDirectory(t *testing.T) {
+	composite := cfs.NewWritableFS(cfs.NewDirWriteFS(t.TempDir())).WithJournal()
+
+	if err := composite.WriteFile("dir/a.txt", []byte("a"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	checkpoint := time.Now()
+
+	if err := composite.Revert("dir"); err != nil {
+		t.Fatalf("Revert failed: %v", err)
+	}
+	if err := composite.Rollback(checkpoint); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	testReadFile(t, composite, "dir/a.txt", "a")
+}
+
+func TestRollbackWithoutJournal(t *testing.T) {
+	composite := cfs.NewWritableFS(cfs.NewDirWriteFS(t.TempDir()))
+
+	if err := composite.Rollback(time.Now()); !errors.Is(err, cfs.ErrNoJournal) {
+		t.Fatalf("Expected ErrNoJournal, got %v", err)
+	}
+}
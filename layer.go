@@ -0,0 +1,123 @@
+package cfs
+
+import (
+	"io/fs"
+	"slices"
+	"sync/atomic"
+)
+
+// NamedFS is implemented by layers that carry a name. Names identify
+// layers in stack hashes, reports and the With* builder methods.
+type NamedFS interface {
+	fs.FS
+	Name() string
+}
+
+// Layer wraps an fs.FS with a name so it can be addressed within a
+// composite. It passes through the optional io/fs interfaces of the
+// wrapped filesystem.
+type Layer struct {
+	name      string
+	fsys      *atomic.Pointer[fs.FS] // replaced by SwapLayer
+	uncached  bool
+	priority  *int
+	roles     []string
+	cacheHint *string
+	pin       string
+}
+
+// LayerOption configures a Layer.
+type LayerOption func(*Layer)
+
+// Uncached marks a layer as volatile, for example a development
+// directory edited while the application runs. Composites never cache
+// results from uncached layers.
+func Uncached() LayerOption {
+	return func(l *Layer) {
+		l.uncached = true
+	}
+}
+
+// NewLayer creates a named layer backed by fsys.
+func NewLayer(name string, fsys fs.FS, opts ...LayerOption) *Layer {
+	l := &Layer{name: name, fsys: newLayerSlot(fsys)}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Name returns the layer name.
+func (l *Layer) Name() string {
+	return l.name
+}
+
+// FS returns the wrapped filesystem.
+func (l *Layer) FS() fs.FS {
+	return *l.fsys.Load()
+}
+
+// Open implements fs.FS.
+func (l *Layer) Open(name string) (fs.File, error) {
+	return l.FS().Open(name)
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (l *Layer) ReadDir(name string) ([]fs.DirEntry, error) {
+	return ReadDir(l.FS(), name)
+}
+
+// Stat implements fs.StatFS.
+func (l *Layer) Stat(name string) (fs.FileInfo, error) {
+	return statLayer(l.FS(), name)
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (l *Layer) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(l.FS(), name)
+}
+
+// Cacheable implements CacheableFS. Layers marked Uncached or tagged
+// with RoleDev are never cached.
+func (l *Layer) Cacheable() bool {
+	return !l.uncached && !slices.Contains(l.roles, RoleDev) && cacheable(l.FS())
+}
+
+// MayContain implements PathFilter by delegating to the wrapped
+// filesystem. Layers that cannot rule paths out report true.
+func (l *Layer) MayContain(name string) bool {
+	return !skipLayer(l.FS(), name)
+}
+
+// InvalidatePaths implements Invalidator by delegating to the wrapped
+// filesystem.
+func (l *Layer) InvalidatePaths(names ...string) {
+	invalidateLayer(l.FS(), names)
+}
+
+// Sub returns the named layer rooted at dir.
+func (l *Layer) Sub(dir string) (fs.FS, error) {
+	sub, err := fs.Sub(l.FS(), dir)
+	if err != nil {
+		return nil, err
+	}
+	// the manifest is out of reach below the root, so keep its priority
+	priority := l.Priority()
+	return &Layer{name: l.name, fsys: newLayerSlot(sub), uncached: l.uncached, priority: &priority, roles: l.roles, cacheHint: l.cacheHint}, nil
+}
+
+// newLayerSlot returns the swappable reference a Layer reads through.
+func newLayerSlot(fsys fs.FS) *atomic.Pointer[fs.FS] {
+	slot := new(atomic.Pointer[fs.FS])
+	slot.Store(&fsys)
+	return slot
+}
+
+// LayerName returns the name of fsys when it implements NamedFS, or an
+// empty string otherwise.
+func LayerName(fsys fs.FS) string {
+	if named, ok := fsys.(NamedFS); ok {
+		return named.Name()
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package cfs
+
+import (
+	"crypto/sha256"
+	"io"
+	"io/fs"
+	"slices"
+	"sync"
+)
+
+// LayerInfo describes the layer a file was served from.
+type LayerInfo struct {
+	// Index is the position of the layer in the composite.
+	Index int
+	// Name is the name of the layer, if it is named.
+	Name string
+	// Roles lists the roles the layer is tagged with.
+	Roles []string
+}
+
+// LayeredFile is implemented by the files CompositeFS.Open returns for
+// regular files. They pass reads through to the file of the winning
+// layer and record where the file came from, so callers do not need to
+// track it separately. The files implement io.Seeker and io.ReaderAt
+// when the layer file does. Directories are returned as they are, since
+// merged listings span several layers, and so are all files of
+// composites created with WithPlainFiles.
+type LayeredFile interface {
+	fs.File
+	// Layer returns the layer the file was served from.
+	Layer() LayerInfo
+	// Path returns the path of the file in the composite, with aliases
+	// and links resolved.
+	Path() string
+	// ContentHash returns the hex encoded SHA-256 of the file content.
+	// It is computed from the layer on first use, without consuming the
+	// file.
+	ContentHash() (string, error)
+}
+
+type layeredFile struct {
+	fs.File
+
+	layer LayerInfo
+	path  string
+	fsys  fs.FS
+
+	hashOnce sync.Once
+	hash     string
+	hashErr  error
+}
+
+type (
+	layeredSeekFile         struct{ *layeredFile }
+	layeredReaderAtFile     struct{ *layeredFile }
+	layeredSeekReaderAtFile struct{ *layeredFile }
+)
+
+func (f layeredSeekFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+func (f layeredReaderAtFile) ReadAt(b []byte, off int64) (int, error) {
+	return f.File.(io.ReaderAt).ReadAt(b, off)
+}
+
+func (f layeredSeekReaderAtFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+func (f layeredSeekReaderAtFile) ReadAt(b []byte, off int64) (int, error) {
+	return f.File.(io.ReaderAt).ReadAt(b, off)
+}
+
+// WithPlainFiles returns a copy of the composite whose Open returns the
+// files of the layers as they are, instead of LayeredFile wrappers. A
+// hit in the first layer then performs no heap allocations beyond those
+// of the layer itself, for hot paths that do not need to know which
+// layer served a file.
+func (cfs *CompositeFS) WithPlainFiles() *CompositeFS {
+	c := cfs.clone()
+	c.plainFiles = true
+	return c
+}
+
+// layerFile returns file, opened from layer index, as Open returns it.
+func (cfs *CompositeFS) layerFile(index int, fsys fs.FS, name string, file fs.File) fs.File {
+	if cfs.plainFiles {
+		return file
+	}
+	return newLayeredFile(index, fsys, name, file)
+}
+
+// newLayeredFile wraps a regular file opened from layer index.
+func newLayeredFile(index int, fsys fs.FS, name string, file fs.File) fs.File {
+	if info, err := file.Stat(); err != nil || info.IsDir() {
+		return file
+	}
+	layer := LayerInfo{Index: index, Name: LayerName(fsys)}
+	if r, ok := unwrapLayer(fsys).(RoledFS); ok {
+		layer.Roles = r.Roles()
+	}
+	f := &layeredFile{File: file, layer: layer, path: name, fsys: fsys}
+
+	_, seeker := file.(io.Seeker)
+	_, readerAt := file.(io.ReaderAt)
+	switch {
+	case seeker && readerAt:
+		return layeredSeekReaderAtFile{f}
+	case seeker:
+		return layeredSeekFile{f}
+	case readerAt:
+		return layeredReaderAtFile{f}
+	}
+	return f
+}
+
+func (f *layeredFile) Layer() LayerInfo {
+	layer := f.layer
+	layer.Roles = slices.Clone(layer.Roles)
+	return layer
+}
+
+func (f *layeredFile) Path() string {
+	return f.path
+}
+
+func (f *layeredFile) ContentHash() (string, error) {
+	f.hashOnce.Do(func() {
+		file, err := f.fsys.Open(f.path)
+		if err != nil {
+			f.hashErr = err
+			return
+		}
+		defer file.Close()
+		f.hash, f.hashErr = hashStream(sha256.New(), file, f.path)
+	})
+	return f.hash, f.hashErr
+}
+
+// Unwrap returns the file opened from the layer.
+func (f *layeredFile) Unwrap() fs.File {
+	return f.File
+}
@@ -0,0 +1,161 @@
+package cfs_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestOpenReturnsLayeredFile(t *testing.T) {
+	theme := fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("theme home")},
+	}
+	base := fstest.MapFS{
+		"views/home.html":  &fstest.MapFile{Data: []byte("base home")},
+		"views/about.html": &fstest.MapFile{Data: []byte("base about")},
+	}
+	composite := cfs.NewCompositeFS(
+		cfs.NewLayer("theme", theme, cfs.WithRoles(cfs.RoleTheme)),
+		cfs.NewLayer("base", base),
+	).WithAliases(map[string]string{"index.html": "views/about.html"})
+
+	file, err := composite.Open("index.html")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer file.Close()
+
+	layered, ok := file.(cfs.LayeredFile)
+	if !ok {
+		t.Fatalf("Expected LayeredFile, got %T", file)
+	}
+	if layered.Path() != "views/about.html" {
+		t.Errorf("Expected resolved path, got %q", layered.Path())
+	}
+	if got := layered.Layer(); !reflect.DeepEqual(got, cfs.LayerInfo{Index: 1, Name: "base"}) {
+		t.Errorf("Expected base layer, got %+v", got)
+	}
+
+	// the hash does not consume the file
+	hash, err := layered.ContentHash()
+	if err != nil {
+		t.Fatalf("ContentHash failed: %v", err)
+	}
+	sum := sha256.Sum256([]byte("base about"))
+	if hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("Unexpected hash %s", hash)
+	}
+	data, err := io.ReadAll(file)
+	if err != nil || string(data) != "base about" {
+		t.Fatalf("Expected content to pass through, got %q, %v", data, err)
+	}
+
+	// seeking is passed through to the layer file
+	if _, err := file.(io.Seeker).Seek(5, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	rest, _ := io.ReadAll(file)
+	if string(rest) != "about" {
+		t.Errorf("Expected %q after seeking, got %q", "about", rest)
+	}
+
+	home, err := composite.Open("views/home.html")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer home.Close()
+	info := home.(cfs.LayeredFile).Layer()
+	if info.Index != 0 || info.Name != "theme" || !reflect.DeepEqual(info.Roles, []string{cfs.RoleTheme}) {
+		t.Errorf("Expected theme layer, got %+v", info)
+	}
+}
+
+func TestOpenLayeredFileCapabilities(t *testing.T) {
+	minimal := openOnlyFS{files: fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("a")},
+	}}
+	composite := cfs.NewCompositeFS(minimal)
+
+	file, err := composite.Open("a.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer file.Close()
+	if _, ok := file.(cfs.LayeredFile); !ok {
+		t.Fatalf("Expected LayeredFile, got %T", file)
+	}
+	if _, ok := file.(io.Seeker); ok {
+		t.Error("Did not expect a Seeker for a layer file without Seek")
+	}
+	if _, ok := file.(io.ReaderAt); ok {
+		t.Error("Did not expect a ReaderAt for a layer file without ReadAt")
+	}
+
+	dir, err := cfs.NewOverlayFS(fstest.MapFS{"d/a.txt": &fstest.MapFile{}}).Open("d")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dir.Close()
+	if _, ok := dir.(cfs.LayeredFile); ok {
+		t.Error("Did not expect directories to be layered files")
+	}
+	if _, ok := dir.(fs.ReadDirFile); !ok {
+		t.Errorf("Expected a ReadDirFile, got %T", dir)
+	}
+}
+
+type staticInfo struct{}
+
+func (staticInfo) Name() string       { return "home.html" }
+func (staticInfo) Size() int64        { return 0 }
+func (staticInfo) Mode() fs.FileMode  { return 0o444 }
+func (staticInfo) ModTime() time.Time { return time.Time{} }
+func (staticInfo) IsDir() bool        { return false }
+func (staticInfo) Sys() any           { return nil }
+
+type staticFile struct{}
+
+func (*staticFile) Stat() (fs.FileInfo, error) { return staticInfo{}, nil }
+func (*staticFile) Read([]byte) (int, error)   { return 0, io.EOF }
+func (*staticFile) Close() error               { return nil }
+
+// staticFS serves the same file for every name without allocating.
+type staticFS struct{ file *staticFile }
+
+func (s staticFS) Open(name string) (fs.File, error) { return s.file, nil }
+
+func TestWithPlainFilesZeroAllocHit(t *testing.T) {
+	composite := cfs.NewCompositeFS(staticFS{&staticFile{}}, staticFS{&staticFile{}})
+
+	allocs := func(c *cfs.CompositeFS) float64 {
+		return testing.AllocsPerRun(100, func() {
+			file, err := c.Open("views/home.html")
+			if err != nil {
+				t.Fatal(err)
+			}
+			file.Close()
+		})
+	}
+
+	if n := allocs(composite.WithPlainFiles()); n != 0 {
+		t.Errorf("Expected a first layer hit to allocate nothing, got %v allocations", n)
+	}
+	if n := allocs(composite); n > 1 {
+		t.Errorf("Expected only the LayeredFile wrapper to be allocated, got %v allocations", n)
+	}
+
+	file, err := composite.WithPlainFiles().Open("views/home.html")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, ok := file.(cfs.LayeredFile); ok {
+		t.Error("Expected a plain layer file")
+	}
+}
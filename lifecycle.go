@@ -0,0 +1,181 @@
+package cfs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"time"
+)
+
+// ErrStarted is returned by Start when the composite was started before.
+var ErrStarted = errors.New("composite filesystem already started")
+
+// Start starts the background work of the composite, for application
+// lifecycle managers: layers disabled by an error budget are probed
+// shortly after their probe interval passes, so they are enabled again
+// without waiting for a lookup to reach them. ctx bounds the start only;
+// the work runs until Shutdown or Close, which also stop the polling of
+// every Watcher created with Watch. Starting a composite twice, or any
+// of its copies, fails with ErrStarted, and starting a closed one with
+// fs.ErrClosed.
+func (cfs *CompositeFS) Start(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	life := cfs.life
+	if life == nil {
+		return nil
+	}
+
+	life.mu.Lock()
+	defer life.mu.Unlock()
+	if life.closed.Load() {
+		return &fs.PathError{Op: "start", Path: ".", Err: fs.ErrClosed}
+	}
+	if life.started {
+		return ErrStarted
+	}
+	life.started = true
+
+	layers := budgetLayers(cfs.filesystems)
+	if len(layers) == 0 {
+		return nil
+	}
+	interval := layers[0].health.budget.probeInterval()
+	for _, b := range layers[1:] {
+		interval = min(interval, b.health.budget.probeInterval())
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	life.stopWork = func() { close(stop) }
+	life.workDone = done
+	go func() {
+		defer close(done)
+		// tick faster than the interval, so probes are not late by a
+		// whole interval
+		ticker := time.NewTicker(max(interval/4, time.Millisecond))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				for _, b := range layers {
+					b.probe()
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops the composite for application lifecycle managers: the
+// background work started by Start and the polling of watchers created
+// with Watch stop, new operations fail with fs.ErrClosed, and once the
+// running operations and the background refreshes of disk caches have
+// finished, cached Stat results and content are dropped and the layers
+// are closed as described for Close. When ctx is done first, Shutdown
+// returns its error and leaves the layers open; calling it again
+// resumes waiting.
+func (cfs *CompositeFS) Shutdown(ctx context.Context) error {
+	life := cfs.life
+	if life == nil {
+		return cfs.closeLayers()
+	}
+	idle := life.stop()
+	life.stopBackground()
+
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := waitDiskCaches(ctx, cfs.filesystems); err != nil {
+		return err
+	}
+
+	life.closeOnce.Do(func() {
+		cfs.statCache.invalidate()
+		cfs.content.invalidate()
+		cfs.attrs.invalidate()
+		life.closeErr = cfs.closeLayers()
+	})
+	return life.closeErr
+}
+
+// stopBackground stops the work started by Start and the watchers, and
+// waits for them. It must be called after stop, so no watcher is
+// tracked afterwards.
+func (l *lifecycle) stopBackground() {
+	l.mu.Lock()
+	stopWork, done := l.stopWork, l.workDone
+	l.stopWork = nil
+	watchers := make([]*Watcher, 0, len(l.watchers))
+	for w := range l.watchers {
+		watchers = append(watchers, w)
+	}
+	l.mu.Unlock()
+
+	if stopWork != nil {
+		stopWork()
+	}
+	if done != nil {
+		<-done
+	}
+	for _, w := range watchers {
+		w.Close()
+	}
+}
+
+// track registers a polling watcher so Shutdown stops it. It reports
+// false once the composite is closed, when the watcher must not poll.
+func (l *lifecycle) track(w *Watcher) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed.Load() {
+		return false
+	}
+	if l.watchers == nil {
+		l.watchers = make(map[*Watcher]struct{})
+	}
+	l.watchers[w] = struct{}{}
+	return true
+}
+
+func (l *lifecycle) untrack(w *Watcher) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	delete(l.watchers, w)
+	l.mu.Unlock()
+}
+
+// probe lets a call through to the layer when it is disabled and due for
+// a probe, so a healthy layer is enabled again.
+func (b *budgetFS) probe() {
+	b.health.mu.Lock()
+	due := b.health.disabled && !b.health.probing && !time.Now().Before(b.health.probeAt)
+	b.health.mu.Unlock()
+	if due {
+		b.Stat(".")
+	}
+}
+
+// budgetLayers returns the layers of layers tracked by an error budget,
+// looking through wrappers and nested composites.
+func budgetLayers(layers []fs.FS) []*budgetFS {
+	var found []*budgetFS
+	for _, fsys := range layers {
+		if b, ok := fsys.(*budgetFS); ok {
+			found = append(found, b)
+			continue
+		}
+		found = append(found, budgetLayers(wrappedLayers(fsys))...)
+	}
+	return found
+}
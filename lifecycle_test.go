@@ -0,0 +1,113 @@
+package cfs_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestStartProbesDisabledLayers(t *testing.T) {
+	flaky := &flakyFS{fsys: fstest.MapFS{}}
+	flaky.failing.Store(true)
+	base := fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("base")}}
+
+	var log transitionLog
+	composite := cfs.NewCompositeFSBestEffort(cfs.NewLayer("remote", flaky), base).
+		WithErrorBudget(cfs.ErrorBudget{
+			MaxConsecutive: 1,
+			ProbeInterval:  20 * time.Millisecond,
+			OnTransition:   log.add,
+		})
+	testReadFile(t, composite, "a.txt", "base")
+	if !composite.Status().Layers[0].Health.Disabled {
+		t.Fatal("Expected the failing layer to be disabled")
+	}
+
+	if err := composite.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { composite.Close() })
+	if err := composite.Start(context.Background()); !errors.Is(err, cfs.ErrStarted) {
+		t.Fatalf("Expected ErrStarted, got %v", err)
+	}
+
+	// the layer recovers without a lookup reaching it
+	flaky.failing.Store(false)
+	deadline := time.Now().Add(time.Second)
+	for composite.Status().Layers[0].Health.Disabled {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the background probe to enable the layer")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if events := log.list(); len(events) != 2 || events[1].Disabled {
+		t.Fatalf("Expected an enable transition, got %+v", events)
+	}
+}
+
+func TestShutdownStopsWatchers(t *testing.T) {
+	lower := &countingFS{MapFS: fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("a")}}}
+	composite := cfs.NewCompositeFS(lower)
+	composite.Watch(time.Millisecond)
+
+	if err := composite.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	before := lower.calls.Load()
+	time.Sleep(20 * time.Millisecond)
+	if calls := lower.calls.Load() - before; calls != 0 {
+		t.Fatalf("Expected polling to stop, got %d calls", calls)
+	}
+
+	// watchers created afterwards do not poll
+	composite.Watch(time.Millisecond)
+	before = lower.calls.Load()
+	time.Sleep(20 * time.Millisecond)
+	if calls := lower.calls.Load() - before; calls != 0 {
+		t.Fatalf("Expected no polling after Shutdown, got %d calls", calls)
+	}
+	if err := composite.Start(context.Background()); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	slow := &closableFS{
+		MapFS:   fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("a")}},
+		entered: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	composite := cfs.NewCompositeFS(slow)
+
+	readDone := make(chan error)
+	go func() {
+		_, err := composite.ReadFile("a.txt")
+		readDone <- err
+	}()
+	<-slow.entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := composite.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the deadline to pass, got %v", err)
+	}
+	if slow.closes.Load() != 0 {
+		t.Fatal("Expected layers to stay open after a timed out Shutdown")
+	}
+
+	close(slow.release)
+	if err := <-readDone; err != nil {
+		t.Fatalf("Expected the running read to finish, got %v", err)
+	}
+	if err := composite.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if slow.closes.Load() != 1 {
+		t.Fatalf("Expected the layer closed once, got %d", slow.closes.Load())
+	}
+}
@@ -0,0 +1,83 @@
+package cfs
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ErrLimitExceeded is matched by every *LimitError.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// Limits bounds the work done when merging directories. Zero values
+// disable the corresponding limit.
+type Limits struct {
+	// MaxDirEntries caps the number of merged entries in a directory.
+	MaxDirEntries int
+	// MaxDepth caps how deep directories can be listed, "." being
+	// depth 0. Since walks list every directory they visit, it also
+	// bounds fs.WalkDir over the composite.
+	MaxDepth int
+}
+
+func (l Limits) empty() bool {
+	return l.MaxDirEntries <= 0 && l.MaxDepth <= 0
+}
+
+// LimitError reports that an operation exceeded one of the configured
+// Limits.
+type LimitError struct {
+	Op    string
+	Path  string
+	Limit string
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s %s: %s limit of %d exceeded", e.Op, e.Path, e.Limit, e.Max)
+}
+
+// Unwrap returns ErrLimitExceeded.
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// WithLimits returns a copy of the composite enforcing the given limits
+// on directory merges, protecting servers from pathological layers such
+// as an accidentally mounted root directory.
+func (cfs *CompositeFS) WithLimits(limits Limits) *CompositeFS {
+	c := cfs.clone()
+	c.limits = limits
+	return c
+}
+
+// checkDepth fails when listing dir would exceed MaxDepth.
+func (cfs *CompositeFS) checkDepth(op, dir string) error {
+	if cfs.limits.MaxDepth <= 0 {
+		return nil
+	}
+	full := dir
+	if cfs.visibility.root != "" {
+		full = path.Join(cfs.visibility.root, dir)
+	}
+	if depth := pathDepth(full); depth > cfs.limits.MaxDepth {
+		return &LimitError{Op: op, Path: dir, Limit: "depth", Max: cfs.limits.MaxDepth}
+	}
+	return nil
+}
+
+// checkEntries fails when a directory listing grows past MaxDirEntries.
+func (cfs *CompositeFS) checkEntries(op, dir string, n int) error {
+	if cfs.limits.MaxDirEntries <= 0 || n <= cfs.limits.MaxDirEntries {
+		return nil
+	}
+	return &LimitError{Op: op, Path: dir, Limit: "entries", Max: cfs.limits.MaxDirEntries}
+}
+
+func pathDepth(name string) int {
+	if name == "." || name == "" {
+		return 0
+	}
+	return strings.Count(name, "/") + 1
+}
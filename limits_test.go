@@ -0,0 +1,77 @@
+package cfs_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestWithLimitsMaxDirEntries(t *testing.T) {
+	fs1 := fstest.MapFS{}
+	fs2 := fstest.MapFS{}
+	for i := 0; i < 3; i++ {
+		fs1[fmt.Sprintf("dir/a%d.txt", i)] = &fstest.MapFile{}
+		fs2[fmt.Sprintf("dir/b%d.txt", i)] = &fstest.MapFile{}
+	}
+
+	for name, composite := range map[string]*cfs.CompositeFS{
+		"composite": cfs.NewCompositeFS(fs1, fs2),
+		"overlay":   cfs.NewOverlayFS(fs1, fs2),
+	} {
+		t.Run(name, func(t *testing.T) {
+			limited := composite.WithLimits(cfs.Limits{MaxDirEntries: 5})
+
+			_, err := fs.ReadDir(limited, "dir")
+			var limitErr *cfs.LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("Expected *LimitError, got %v", err)
+			}
+			if limitErr.Limit != "entries" || !errors.Is(err, cfs.ErrLimitExceeded) {
+				t.Fatalf("Unexpected limit error: %v", err)
+			}
+
+			if _, err := fs.ReadDir(composite.WithLimits(cfs.Limits{MaxDirEntries: 6}), "dir"); err != nil {
+				t.Fatalf("Expected listing within limit to succeed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWithLimitsMaxDirEntriesOpenedDirectory(t *testing.T) {
+	layer := fstest.MapFS{
+		"dir/a.txt": &fstest.MapFile{},
+		"dir/b.txt": &fstest.MapFile{},
+		"dir/c.txt": &fstest.MapFile{},
+	}
+	composite := cfs.NewCompositeFS(layer).WithLimits(cfs.Limits{MaxDirEntries: 2})
+
+	file, err := composite.Open("dir")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.(fs.ReadDirFile).ReadDir(-1); !errors.Is(err, cfs.ErrLimitExceeded) {
+		t.Fatalf("Expected ErrLimitExceeded, got %v", err)
+	}
+}
+
+func TestWithLimitsMaxDepthStopsWalk(t *testing.T) {
+	layer := fstest.MapFS{
+		"a/b/c/d/deep.txt": &fstest.MapFile{},
+	}
+	composite := cfs.NewCompositeFS(layer).WithLimits(cfs.Limits{MaxDepth: 2})
+
+	err := fs.WalkDir(composite, ".", func(path string, d fs.DirEntry, err error) error {
+		return err
+	})
+
+	var limitErr *cfs.LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != "depth" {
+		t.Fatalf("Expected depth LimitError, got %v", err)
+	}
+}
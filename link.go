@@ -0,0 +1,255 @@
+package cfs
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLinkHops bounds how many virtual links are followed while
+// resolving a single path.
+const maxLinkHops = 40
+
+// ErrTooManyLinks is returned when resolving a path follows more than
+// 40 virtual links, usually because of a cycle.
+var ErrTooManyLinks = errors.New("too many levels of virtual links")
+
+// linkTable holds the virtual links of a composite, keyed by their
+// root-relative path. It is shared by the composites derived from the
+// one the links were defined on.
+type linkTable struct {
+	mu    sync.RWMutex
+	links map[string]string
+}
+
+func newLinkTable() *linkTable {
+	return &linkTable{links: make(map[string]string)}
+}
+
+func (t *linkTable) empty() bool {
+	if t == nil {
+		return true
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.links) == 0
+}
+
+func (t *linkTable) get(name string) (string, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	target, ok := t.links[name]
+	return target, ok
+}
+
+// children returns the links located directly in dir.
+func (t *linkTable) children(dir string) map[string]string {
+	if t == nil {
+		return nil
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	out := make(map[string]string)
+	for name, target := range t.links {
+		if path.Dir(name) == dir {
+			out[path.Base(name)] = target
+		}
+	}
+	return out
+}
+
+// list returns the links as sorted "name->target" pairs.
+func (t *linkTable) list() []string {
+	if t == nil {
+		return nil
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	out := make([]string, 0, len(t.links))
+	for name, target := range t.links {
+		out = append(out, name+"->"+target)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (t *linkTable) copy() *linkTable {
+	c := newLinkTable()
+	if t == nil {
+		return c
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for name, target := range t.links {
+		c.links[name] = target
+	}
+	return c
+}
+
+// Link defines a virtual symlink at name pointing to target within the
+// merged namespace, independent of whether any layer supports symlinks.
+// Relative targets are resolved from the directory containing name and
+// targets starting with "/" from the root of the composite. Links take
+// precedence over files provided by layers and are shared with every
+// composite derived from this one.
+func (cfs *CompositeFS) Link(name, target string) error {
+	if !fs.ValidPath(name) || name == "." || target == "" {
+		return &fs.PathError{Op: "link", Path: name, Err: fs.ErrInvalid}
+	}
+	if cfs.links == nil {
+		return &fs.PathError{Op: "link", Path: name, Err: errors.ErrUnsupported}
+	}
+
+	cfs.links.mu.Lock()
+	defer cfs.links.mu.Unlock()
+	cfs.links.links[cfs.fullPath(path.Clean(name))] = target
+	return nil
+}
+
+// Unlink removes the virtual link at name.
+func (cfs *CompositeFS) Unlink(name string) error {
+	full := cfs.fullPath(path.Clean(name))
+	if cfs.links != nil {
+		cfs.links.mu.Lock()
+		defer cfs.links.mu.Unlock()
+		if _, ok := cfs.links.links[full]; ok {
+			delete(cfs.links.links, full)
+			return nil
+		}
+	}
+	return &fs.PathError{Op: "unlink", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadLink returns the target of the virtual link at name.
+func (cfs *CompositeFS) ReadLink(name string) (string, error) {
+	full, err := cfs.linkPath("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if target, ok := cfs.links.get(full); ok {
+		return target, nil
+	}
+	if _, err := cfs.Stat(name); err != nil {
+		return "", err
+	}
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+}
+
+// Lstat is like Stat but describes a virtual link itself instead of the
+// file it points to.
+func (cfs *CompositeFS) Lstat(name string) (fs.FileInfo, error) {
+	full, err := cfs.linkPath("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	if target, ok := cfs.links.get(full); ok {
+		return linkInfo{name: path.Base(full), target: target}, nil
+	}
+	return cfs.Stat(name)
+}
+
+// linkPath resolves every element of name except the last and returns
+// the root-relative result, as used to look up a link itself.
+func (cfs *CompositeFS) linkPath(op, name string) (string, error) {
+	name = cfs.alias(path.Clean(name))
+	if !cfs.visible(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if cfs.links.empty() {
+		return cfs.fullPath(name), nil
+	}
+	full, err := cfs.follow(cfs.fullPath(name), false)
+	if err != nil {
+		return "", &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return full, nil
+}
+
+// lookupName prepares a path received by a read operation: it is
+// cleaned and the aliases and virtual links it goes through are
+// resolved.
+func (cfs *CompositeFS) lookupName(op, name string) (string, error) {
+	name = cfs.alias(path.Clean(name))
+	if cfs.links.empty() {
+		return name, nil
+	}
+
+	full, err := cfs.follow(cfs.fullPath(name), true)
+	if err != nil {
+		return "", &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	rel, ok := cfs.withinRoot(full)
+	if !ok {
+		// the link points outside of this Sub composite
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return rel, nil
+}
+
+// follow resolves the virtual links along full, a root-relative path.
+// The last element is only resolved when followLast is set.
+func (cfs *CompositeFS) follow(full string, followLast bool) (string, error) {
+	for hops := 0; ; {
+		if full == "." {
+			return full, nil
+		}
+
+		elements := strings.Split(full, "/")
+		resolved := true
+		cur := "."
+		for i, el := range elements {
+			next := path.Join(cur, el)
+			last := i == len(elements)-1
+			target, ok := cfs.links.get(next)
+			if !ok || (last && !followLast) {
+				cur = next
+				continue
+			}
+
+			hops++
+			if hops > maxLinkHops {
+				return "", ErrTooManyLinks
+			}
+			if abs, ok := strings.CutPrefix(target, "/"); ok {
+				target = path.Clean(abs)
+			} else {
+				target = path.Join(cur, target)
+			}
+			full = path.Join(append([]string{target}, elements[i+1:]...)...)
+			resolved = false
+			break
+		}
+		if resolved {
+			return full, nil
+		}
+	}
+}
+
+// linkEntries returns directory entries for the links located in dir.
+func (cfs *CompositeFS) linkEntries(dir string) []fs.DirEntry {
+	children := cfs.links.children(cfs.fullPath(dir))
+	entries := make([]fs.DirEntry, 0, len(children))
+	for name, target := range children {
+		entries = append(entries, fs.FileInfoToDirEntry(linkInfo{name: name, target: target}))
+	}
+	return entries
+}
+
+// linkInfo describes a virtual link.
+type linkInfo struct {
+	name   string
+	target string
+}
+
+func (i linkInfo) Name() string       { return i.name }
+func (i linkInfo) Size() int64        { return int64(len(i.target)) }
+func (i linkInfo) Mode() fs.FileMode  { return fs.ModeSymlink | 0o777 }
+func (i linkInfo) ModTime() time.Time { return time.Time{} }
+func (i linkInfo) IsDir() bool        { return false }
+func (i linkInfo) Sys() interface{}   { return nil }
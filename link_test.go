@@ -0,0 +1,117 @@
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestLinkResolvesInMergedNamespace(t *testing.T) {
+	composite := cfs.NewCompositeFS(
+		fstest.MapFS{"releases/v2/app.js": &fstest.MapFile{Data: []byte("v2")}},
+		fstest.MapFS{"releases/v1/app.js": &fstest.MapFile{Data: []byte("v1")}},
+	)
+
+	if err := composite.Link("current", "releases/v2"); err != nil {
+		t.Fatalf("Link failed: %v", err)
+	}
+	if err := composite.Link("releases/previous", "v1"); err != nil {
+		t.Fatalf("Link failed: %v", err)
+	}
+
+	testReadFile(t, composite, "current/app.js", "v2")
+	testReadFile(t, composite, "releases/previous/app.js", "v1")
+
+	info, err := composite.Stat("current")
+	if err != nil || !info.IsDir() {
+		t.Fatalf("Expected Stat to follow the link to a directory, got %v (%v)", info, err)
+	}
+
+	info, err = composite.Lstat("current")
+	if err != nil {
+		t.Fatalf("Lstat failed: %v", err)
+	}
+	if info.Mode()&fs.ModeSymlink == 0 {
+		t.Fatalf("Expected symlink mode, got %v", info.Mode())
+	}
+
+	target, err := composite.ReadLink("current")
+	if err != nil || target != "releases/v2" {
+		t.Fatalf("Expected target releases/v2, got %q (%v)", target, err)
+	}
+	if _, err := composite.ReadLink("releases/v1/app.js"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("Expected fs.ErrInvalid for a regular file, got %v", err)
+	}
+
+	entries, err := composite.ReadDir(".")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	var found bool
+	for _, entry := range entries {
+		if entry.Name() == "current" {
+			found = entry.Type()&fs.ModeSymlink != 0
+		}
+	}
+	if !found {
+		t.Fatal("Expected the link to be listed as a symlink")
+	}
+
+	if err := composite.Unlink("current"); err != nil {
+		t.Fatalf("Unlink failed: %v", err)
+	}
+	if _, err := composite.Stat("current/app.js"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected removed link not to resolve, got %v", err)
+	}
+}
+
+func TestLinkCyclesAndSub(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{
+		"site/v1/index.html": &fstest.MapFile{Data: []byte("index")},
+	})
+	composite.Link("a", "b")
+	composite.Link("b", "a")
+	composite.Link("site/live", "/site/v1")
+
+	if _, err := composite.Open("a"); !errors.Is(err, cfs.ErrTooManyLinks) {
+		t.Fatalf("Expected ErrTooManyLinks, got %v", err)
+	}
+
+	sub, err := composite.Sub("site")
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	testReadFile(t, sub, "live/index.html", "index")
+}
+
+func TestLinkListedByOverlayOpen(t *testing.T) {
+	composite := cfs.NewOverlayFS(
+		fstest.MapFS{"releases/v2/app.js": &fstest.MapFile{Data: []byte("v2")}},
+		fstest.MapFS{"releases/v1/app.js": &fstest.MapFile{Data: []byte("v1")}},
+	)
+	if err := composite.Link("current", "releases/v2"); err != nil {
+		t.Fatalf("Link failed: %v", err)
+	}
+
+	dir, err := composite.Open(".")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dir.Close()
+	entries, err := dir.(fs.ReadDirFile).ReadDir(-1)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	var found bool
+	for _, entry := range entries {
+		if entry.Name() == "current" {
+			found = entry.Type()&fs.ModeSymlink != 0
+		}
+	}
+	if !found {
+		t.Fatalf("Expected the link to be listed as a symlink, got %v", entries)
+	}
+}
@@ -0,0 +1,125 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ListingEntry describes one entry of a rendered directory listing.
+type ListingEntry struct {
+	Name      string    `json:"name"`
+	Dir       bool      `json:"dir"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"mod_time"`
+	Layer     int       `json:"layer"`
+	LayerName string    `json:"layer_name,omitempty"`
+}
+
+// NewListingHandler returns an http.Handler serving the files of cfs.
+// Directories without an index.html are rendered as a listing of the
+// merged entries, each tagged with the layer that provides it. The
+// listing is JSON when the request asks for application/json or has
+// format=json in its query, and HTML otherwise. Files are sent with the
+// Cache-Control header of the layer serving them, see CacheHint.
+// Requests are served from the view of cfs for their context, see
+// WithLayerSelector.
+func NewListingHandler(cfs *CompositeFS) http.Handler {
+	return &listingHandler{cfs: cfs, files: http.FileServerFS(cfs)}
+}
+
+type listingHandler struct {
+	cfs   *CompositeFS
+	files http.Handler
+}
+
+func (h *listingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "."
+	}
+
+	view, files := h.cfs.ForContext(r.Context()), h.files
+	if view != h.cfs {
+		files = http.FileServerFS(view)
+	}
+
+	info, err := view.Stat(name)
+	if err != nil || !info.IsDir() || !strings.HasSuffix(r.URL.Path, "/") || view.Exists(path.Join(name, "index.html")) {
+		if err == nil {
+			served := name
+			if info.IsDir() {
+				served = path.Join(name, "index.html")
+			}
+			if hint, _ := view.CacheHint(served); hint != "" {
+				w.Header().Set("Cache-Control", hint)
+			}
+		}
+		files.ServeHTTP(w, r)
+		return
+	}
+
+	entries, err := listing(view, name)
+	if err != nil {
+		http.Error(w, "failed to list directory", http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	listingTemplate.Execute(w, struct {
+		Path    string
+		Entries []ListingEntry
+	}{Path: "/" + strings.TrimPrefix(name, "."), Entries: entries})
+}
+
+// listing describes the merged entries of dir.
+func listing(cfs *CompositeFS, dir string) ([]ListingEntry, error) {
+	entries, err := cfs.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]ListingEntry, 0, len(entries))
+	for _, entry := range entries {
+		name := path.Join(dir, entry.Name())
+		layer, info, err := cfs.resolve(name)
+		if err != nil {
+			continue
+		}
+		out = append(out, ListingEntry{
+			Name:      entry.Name(),
+			Dir:       info.IsDir(),
+			Size:      info.Size(),
+			ModTime:   info.ModTime(),
+			Layer:     layer,
+			LayerName: LayerName(cfs.filesystems[layer]),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+var listingTemplate = template.Must(template.New("listing").Parse(`<!doctype html>
+<meta charset="utf-8">
+<title>Index of {{.Path}}</title>
+<h1>Index of {{.Path}}</h1>
+<table>
+{{- range .Entries}}
+<tr><td><a href="{{.Name}}{{if .Dir}}/{{end}}">{{.Name}}{{if .Dir}}/{{end}}</a></td><td>{{if not .Dir}}{{.Size}}{{end}}</td><td><span class="layer">{{if .LayerName}}{{.LayerName}}{{else}}layer {{.Layer}}{{end}}</span></td></tr>
+{{- end}}
+</table>
+`))
@@ -0,0 +1,85 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func listingComposite() *cfs.CompositeFS {
+	return cfs.NewCompositeFS(
+		cfs.NewLayer("theme", fstest.MapFS{
+			"assets/app.css":  &fstest.MapFile{Data: []byte("theme css")},
+			"site/index.html": &fstest.MapFile{Data: []byte("site index")},
+		}),
+		cfs.NewLayer("base", fstest.MapFS{
+			"assets/app.css":   &fstest.MapFile{Data: []byte("base css")},
+			"assets/app.js":    &fstest.MapFile{Data: []byte("js")},
+			"assets/img/a.png": &fstest.MapFile{Data: []byte("png")},
+		}),
+	)
+}
+
+func TestListingHandlerJSON(t *testing.T) {
+	handler := cfs.NewListingHandler(listingComposite())
+
+	req := httptest.NewRequest(http.MethodGet, "/assets/?format=json", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	var entries []cfs.ListingEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+
+	expected := []struct {
+		name  string
+		dir   bool
+		layer string
+	}{
+		{"app.css", false, "theme"},
+		{"app.js", false, "base"},
+		{"img", true, "base"},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %+v", len(expected), entries)
+	}
+	for i, e := range expected {
+		if entries[i].Name != e.name || entries[i].Dir != e.dir || entries[i].LayerName != e.layer {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, e, entries[i])
+		}
+	}
+}
+
+func TestListingHandlerHTMLAndFiles(t *testing.T) {
+	handler := cfs.NewListingHandler(listingComposite())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/assets/", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, `<a href="app.css">app.css</a>`) || !strings.Contains(body, "theme") {
+		t.Fatalf("Expected HTML listing with layer badges, got %s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/site/", nil))
+	if rec.Body.String() != "site index" {
+		t.Fatalf("Expected index.html to be served, got %q", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/assets/app.css", nil))
+	if rec.Body.String() != "theme css" {
+		t.Fatalf("Expected file content, got %q", rec.Body.String())
+	}
+}
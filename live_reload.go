@@ -0,0 +1,100 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// LiveReloadScript is a small client for NewLiveReloadHandler. Served
+// at the handler's path with ?script, it reloads the page on every
+// change event; include it with a script tag during development.
+const LiveReloadScript = `(function () {
+  var src = document.currentScript.src.replace(/\?script$/, "");
+  new EventSource(src).addEventListener("change", function () {
+    location.reload();
+  });
+})();
+`
+
+// liveReloadHeartbeat keeps idle connections open through proxies.
+const liveReloadHeartbeat = 30 * time.Second
+
+// liveReloadChange is the JSON form of a Change sent to clients.
+type liveReloadChange struct {
+	Path  string `json:"path"`
+	Layer int    `json:"layer"`
+	Op    string `json:"op"`
+}
+
+// NewLiveReloadHandler returns an http.Handler streaming the changes
+// seen by w as server-sent events, so pages served from the composite
+// can reload without a separate file watcher. Each batch is sent as a
+// "change" event whose data is a JSON array of objects with path, layer
+// and op fields. Slow clients may miss batches, but never the fact that
+// something changed. Requests with a script query parameter receive
+// LiveReloadScript instead.
+func NewLiveReloadHandler(w *Watcher) http.Handler {
+	return &liveReloadHandler{watcher: w}
+}
+
+type liveReloadHandler struct {
+	watcher *Watcher
+}
+
+func (h *liveReloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("script") {
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		fmt.Fprint(w, LiveReloadScript)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	events := make(chan []Change, 1)
+	cancel := h.watcher.Subscribe(func(changes []Change) {
+		select {
+		case events <- changes:
+		default:
+			// a batch is already waiting, which triggers the reload
+		}
+	})
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(liveReloadHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case changes := <-events:
+			payload := make([]liveReloadChange, len(changes))
+			for i, c := range changes {
+				payload[i] = liveReloadChange{Path: c.Path, Layer: c.Layer, Op: c.Op.String()}
+			}
+			data, err := json.Marshal(payload)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: change\ndata: %s\n\n", data)
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,101 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestLiveReloadHandlerStreamsChanges(t *testing.T) {
+	lower := fstest.MapFS{}
+	watcher := cfs.NewCompositeFS(lower).Watch(0)
+	t.Cleanup(func() { watcher.Close() })
+
+	server := httptest.NewServer(cfs.NewLiveReloadHandler(watcher))
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q", ct)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	if line, err := reader.ReadString('\n'); err != nil || !strings.HasPrefix(line, ":") {
+		t.Fatalf("Expected the connection comment, got %q (%v)", line, err)
+	}
+
+	lower["app.css"] = &fstest.MapFile{Data: []byte("css")}
+	watcher.Poll()
+
+	lines := make(chan string)
+	go func() {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				close(lines)
+				return
+			}
+			lines <- strings.TrimSpace(line)
+		}
+	}()
+
+	deadline := time.After(time.Second)
+	var event string
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("Stream closed before a change arrived")
+			}
+			if strings.HasPrefix(line, "event: ") {
+				event = strings.TrimPrefix(line, "event: ")
+			}
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			if event != "change" {
+				t.Fatalf("Expected a change event, got %q", event)
+			}
+			var changes []struct {
+				Path string `json:"path"`
+				Op   string `json:"op"`
+			}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &changes); err != nil {
+				t.Fatalf("Invalid event data %q: %v", line, err)
+			}
+			if len(changes) != 1 || changes[0].Path != "app.css" || changes[0].Op != "created" {
+				t.Fatalf("Expected app.css created, got %+v", changes)
+			}
+			return
+		case <-deadline:
+			t.Fatal("Expected a change event")
+		}
+	}
+}
+
+func TestLiveReloadHandlerServesScript(t *testing.T) {
+	watcher := cfs.NewCompositeFS(fstest.MapFS{}).Watch(0)
+	t.Cleanup(func() { watcher.Close() })
+
+	rec := httptest.NewRecorder()
+	cfs.NewLiveReloadHandler(watcher).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livereload?script", nil))
+
+	body, _ := io.ReadAll(rec.Body)
+	if !strings.Contains(rec.Header().Get("Content-Type"), "javascript") || string(body) != cfs.LiveReloadScript {
+		t.Fatalf("Expected the client script, got %q", body)
+	}
+}
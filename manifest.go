@@ -0,0 +1,60 @@
+package cfs
+
+import (
+	"crypto"
+	"sort"
+)
+
+// Manifest maps every file of a merged view to the hex encoded SHA-256
+// of its content. It marshals to JSON as a plain object, so deploy
+// tooling can store it next to a release.
+type Manifest map[string]string
+
+// SnapshotManifest captures the content of the merged view, for
+// comparing releases with CompareManifests. Files are hashed as by
+// HashTree, reusing sums kept by the stat cache.
+func (cfs *CompositeFS) SnapshotManifest() (Manifest, error) {
+	sums, err := cfs.HashTree(".", crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	return Manifest(sums), nil
+}
+
+// ManifestDiff lists the paths that differ between two manifests, each
+// sorted.
+type ManifestDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Empty reports whether the manifests matched.
+func (d ManifestDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Removed) == 0
+}
+
+// CompareManifests returns the files added, changed and removed going
+// from old to new, so deploy tooling can verify that only the expected
+// templates changed between releases.
+func CompareManifests(old, new Manifest) ManifestDiff {
+	var d ManifestDiff
+	for name, sum := range new {
+		prev, ok := old[name]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, name)
+		case prev != sum:
+			d.Changed = append(d.Changed, name)
+		}
+	}
+	for name := range old {
+		if _, ok := new[name]; !ok {
+			d.Removed = append(d.Removed, name)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Changed)
+	sort.Strings(d.Removed)
+	return d
+}
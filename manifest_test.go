@@ -0,0 +1,60 @@
+package cfs_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestCompareManifests(t *testing.T) {
+	base := fstest.MapFS{
+		"views/home.html":  &fstest.MapFile{Data: []byte("home")},
+		"views/about.html": &fstest.MapFile{Data: []byte("about")},
+		"views/old.html":   &fstest.MapFile{Data: []byte("old")},
+	}
+	release1 := cfs.NewCompositeFS(fstest.MapFS{}, base)
+	old, err := release1.SnapshotManifest()
+	if err != nil {
+		t.Fatalf("SnapshotManifest failed: %v", err)
+	}
+	if len(old) != 3 {
+		t.Fatalf("Expected 3 files in the manifest, got %v", old)
+	}
+
+	theme := fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("new home")},
+		"views/new.html":  &fstest.MapFile{Data: []byte("new")},
+	}
+	delete(base, "views/old.html")
+	release2 := cfs.NewCompositeFS(theme, base)
+	current, err := release2.SnapshotManifest()
+	if err != nil {
+		t.Fatalf("SnapshotManifest failed: %v", err)
+	}
+
+	diff := cfs.CompareManifests(old, current)
+	want := cfs.ManifestDiff{
+		Added:   []string{"views/new.html"},
+		Changed: []string{"views/home.html"},
+		Removed: []string{"views/old.html"},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("Expected %+v, got %+v", want, diff)
+	}
+	if diff.Empty() || !cfs.CompareManifests(current, current).Empty() {
+		t.Error("Expected Empty to report matching manifests only")
+	}
+
+	// manifests round-trip through JSON for storage next to a release
+	data, err := json.Marshal(current)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var loaded cfs.Manifest
+	if err := json.Unmarshal(data, &loaded); err != nil || !reflect.DeepEqual(loaded, current) {
+		t.Errorf("Expected the manifest to round-trip, got %v, %v", loaded, err)
+	}
+}
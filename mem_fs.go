@@ -0,0 +1,180 @@
+package cfs
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+// memFS is an in-memory tree of files and directories.
+type memFS struct {
+	nodes map[string]*memNode
+}
+
+type memNode struct {
+	name    string
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+	spill   string // path of the file holding data, see MemSpillThreshold
+	size    int64  // size of spilled data
+}
+
+func newMemFS() *memFS {
+	return &memFS{nodes: map[string]*memNode{
+		".": {name: ".", mode: fs.ModeDir | 0o755},
+	}}
+}
+
+// add stores a node at name, creating missing parent directories.
+func (m *memFS) add(name string, data []byte, mode fs.FileMode, modTime time.Time) {
+	name = path.Clean(name)
+	for dir := path.Dir(name); ; dir = path.Dir(dir) {
+		if _, ok := m.nodes[dir]; !ok {
+			m.nodes[dir] = &memNode{name: dir, mode: fs.ModeDir | 0o755, modTime: modTime}
+		}
+		if dir == "." {
+			break
+		}
+	}
+	m.nodes[name] = &memNode{name: name, data: data, mode: mode, modTime: modTime}
+}
+
+func (m *memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	node, ok := m.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if node.mode.IsDir() {
+		entries, _ := m.ReadDir(name)
+		return &overlayDirFile{name: name, info: node.info(), entries: entries}, nil
+	}
+	return &memFile{name: name, info: node.info(), data: node.data}, nil
+}
+
+func (m *memFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	node, ok := m.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return node.info(), nil
+}
+
+func (m *memFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	node, ok := m.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	if node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	return append([]byte(nil), node.data...), nil
+}
+
+func (m *memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	node, ok := m.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	if !node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	var entries []fs.DirEntry
+	for p, child := range m.nodes {
+		if p != "." && path.Dir(p) == name {
+			entries = append(entries, fs.FileInfoToDirEntry(child.info()))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+func (n *memNode) info() fs.FileInfo {
+	size := int64(len(n.data))
+	if n.spill != "" {
+		size = n.size
+	}
+	return memInfo{name: path.Base(n.name), size: size, mode: n.mode, modTime: n.modTime}
+}
+
+type memInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) Mode() fs.FileMode  { return i.mode }
+func (i memInfo) ModTime() time.Time { return i.modTime }
+func (i memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memInfo) Sys() interface{}   { return nil }
+
+// memFile is a read-only file backed by a byte slice.
+type memFile struct {
+	name   string
+	info   fs.FileInfo
+	data   []byte
+	offset int64
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+func (f *memFile) Read(b []byte) (int, error) {
+	if f.offset >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.data[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *memFile) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.data[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.data))
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
@@ -0,0 +1,489 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemLayer is a writable in-memory filesystem, meant to be the top layer
+// of a composite in tests and for request-scoped overrides. It is safe
+// for concurrent use and implements WriteFS, MetadataFS, fs.StatFS,
+// fs.ReadFileFS and fs.ReadDirFS. Written data is copied, so callers may
+// reuse their buffers. Files larger than the MemSpillThreshold are kept
+// in a temporary directory instead of memory.
+type MemLayer struct {
+	mu  sync.RWMutex
+	mem *memFS
+
+	spillThreshold int64
+	spillParent    string
+
+	spillMu  sync.Mutex
+	spillDir string // created on the first spill
+}
+
+// MemLayerOption configures a MemLayer.
+type MemLayerOption func(*MemLayer)
+
+// MemSpillThreshold moves files larger than n bytes out of memory into
+// a temporary directory, so huge writes do not exhaust the heap. Reads
+// are served from the spilled files transparently. A threshold of zero,
+// the default, keeps everything in memory.
+func MemSpillThreshold(n int64) MemLayerOption {
+	return func(m *MemLayer) {
+		m.spillThreshold = n
+	}
+}
+
+// MemSpillDir sets the directory the temporary directory for spilled
+// files is created in. It defaults to os.TempDir.
+func MemSpillDir(dir string) MemLayerOption {
+	return func(m *MemLayer) {
+		m.spillParent = dir
+	}
+}
+
+// NewMemLayer creates an empty MemLayer.
+func NewMemLayer(opts ...MemLayerOption) *MemLayer {
+	m := &MemLayer{mem: newMemFS()}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Close removes the files spilled to disk. The layer must not be used
+// afterwards.
+func (m *MemLayer) Close() error {
+	m.spillMu.Lock()
+	defer m.spillMu.Unlock()
+
+	if m.spillDir == "" {
+		return nil
+	}
+	err := os.RemoveAll(m.spillDir)
+	m.spillDir = ""
+	return err
+}
+
+// Open implements fs.FS.
+func (m *MemLayer) Open(name string) (fs.File, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if node, ok := m.mem.nodes[name]; ok && node.spill != "" {
+		file, err := os.Open(node.spill)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: unwrapPathError(err)}
+		}
+		return &spillFile{File: file, info: node.info()}, nil
+	}
+	return m.mem.Open(name)
+}
+
+// Stat implements fs.StatFS.
+func (m *MemLayer) Stat(name string) (fs.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.mem.Stat(name)
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (m *MemLayer) ReadFile(name string) ([]byte, error) {
+	m.mu.RLock()
+	node, ok := m.mem.nodes[name]
+	if !ok || node.spill == "" {
+		defer m.mu.RUnlock()
+		return m.mem.ReadFile(name)
+	}
+	// open under the lock, read without it: spilled files are never
+	// modified in place, only replaced
+	file, err := os.Open(node.spill)
+	m.mu.RUnlock()
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: unwrapPathError(err)}
+	}
+	defer file.Close()
+
+	data := make([]byte, 0, node.size)
+	buf := bytes.NewBuffer(data)
+	if _, err := buf.ReadFrom(file); err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: unwrapPathError(err)}
+	}
+	return buf.Bytes(), nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (m *MemLayer) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.mem.ReadDir(name)
+}
+
+// WriteFile writes data to the named file, replacing any existing
+// content. The parent directory must exist.
+func (m *MemLayer) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+
+	node := &memNode{name: name, mode: perm.Perm(), modTime: time.Now()}
+	if m.spillThreshold > 0 && int64(len(data)) > m.spillThreshold {
+		// spill before taking the lock, so readers are not blocked
+		// while a large file is written out
+		spill, err := m.spill(data)
+		if err != nil {
+			return &fs.PathError{Op: "write", Path: name, Err: unwrapPathError(err)}
+		}
+		node.spill = spill
+		node.size = int64(len(data))
+	} else {
+		node.data = append([]byte(nil), data...)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkParent("write", name); err != nil {
+		node.drop()
+		return err
+	}
+	if old, ok := m.mem.nodes[name]; ok {
+		if old.mode.IsDir() {
+			node.drop()
+			return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+		}
+		old.drop()
+	}
+	m.mem.nodes[name] = node
+	return nil
+}
+
+// Mkdir creates the named directory. The parent directory must exist.
+func (m *MemLayer) Mkdir(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.mem.nodes[name]; ok {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if err := m.checkParent("mkdir", name); err != nil {
+		return err
+	}
+	m.mem.nodes[name] = &memNode{name: name, mode: fs.ModeDir | perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+// MkdirAll creates the named directory along with any missing parents.
+func (m *MemLayer) MkdirAll(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// find the deepest existing ancestor first, so nothing is created
+	// when a file is in the way
+	var missing []string
+	for dir := name; ; dir = path.Dir(dir) {
+		if node, ok := m.mem.nodes[dir]; ok {
+			if !node.mode.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+			}
+			break
+		}
+		missing = append(missing, dir)
+	}
+
+	now := time.Now()
+	for i := len(missing) - 1; i >= 0; i-- {
+		dir := missing[i]
+		m.mem.nodes[dir] = &memNode{name: dir, mode: fs.ModeDir | perm.Perm(), modTime: now}
+	}
+	return nil
+}
+
+// Remove removes the named file or empty directory.
+func (m *MemLayer) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, ok := m.mem.nodes[name]
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if node.mode.IsDir() && m.hasChildren(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrExist}
+	}
+	node.drop()
+	delete(m.mem.nodes, name)
+	return nil
+}
+
+// Rename renames oldname to newname, replacing newname if it exists.
+// Directories are moved with everything below them; they may only
+// replace empty directories.
+func (m *MemLayer) Rename(oldname, newname string) error {
+	if !fs.ValidPath(oldname) || oldname == "." || !fs.ValidPath(newname) || newname == "." {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, ok := m.mem.nodes[oldname]
+	if !ok {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrNotExist}
+	}
+	if oldname == newname {
+		return nil
+	}
+	if err := m.checkParent("rename", newname); err != nil {
+		return err
+	}
+	if node.mode.IsDir() && strings.HasPrefix(newname, oldname+"/") {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrInvalid}
+	}
+	if target, ok := m.mem.nodes[newname]; ok {
+		if target.mode.IsDir() != node.mode.IsDir() {
+			return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrExist}
+		}
+		if target.mode.IsDir() && m.hasChildren(newname) {
+			return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrExist}
+		}
+		target.drop()
+	}
+
+	var moved []*memNode
+	prefix := oldname + "/"
+	for p, n := range m.mem.nodes {
+		if p == oldname || strings.HasPrefix(p, prefix) {
+			delete(m.mem.nodes, p)
+			copied := *n
+			copied.name = newname + strings.TrimPrefix(p, oldname)
+			moved = append(moved, &copied)
+		}
+	}
+	for _, n := range moved {
+		m.mem.nodes[n.name] = n
+	}
+	return nil
+}
+
+// Chmod changes the permission bits of the named file.
+func (m *MemLayer) Chmod(name string, mode fs.FileMode) error {
+	return m.update("chmod", name, func(n *memNode) {
+		n.mode = n.mode.Type() | mode.Perm()
+	})
+}
+
+// Chtimes changes the modification time of the named file. Access
+// times are not tracked.
+func (m *MemLayer) Chtimes(name string, atime, mtime time.Time) error {
+	return m.update("chtimes", name, func(n *memNode) {
+		n.modTime = mtime
+	})
+}
+
+// Sub returns a writable view of the layer rooted at dir, sharing its
+// content, so a Sub of a composite writing to a MemLayer stays writable.
+func (m *MemLayer) Sub(dir string) (fs.FS, error) {
+	info, err := m.Stat(dir)
+	if err != nil {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: unwrapPathError(err)}
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: errors.New("not a directory")}
+	}
+	if dir == "." {
+		return m, nil
+	}
+	return &memSubLayer{prefixFS: &prefixFS{fsys: m, dir: dir}, m: m}, nil
+}
+
+// memSubLayer is a MemLayer rooted at a directory.
+type memSubLayer struct {
+	*prefixFS
+	m *MemLayer
+}
+
+func (s *memSubLayer) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	full, err := s.full("write", name)
+	if err != nil {
+		return err
+	}
+	return s.shorten(s.m.WriteFile(full, data, perm))
+}
+
+func (s *memSubLayer) Mkdir(name string, perm fs.FileMode) error {
+	full, err := s.full("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return s.shorten(s.m.Mkdir(full, perm))
+}
+
+func (s *memSubLayer) MkdirAll(name string, perm fs.FileMode) error {
+	full, err := s.full("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return s.shorten(s.m.MkdirAll(full, perm))
+}
+
+func (s *memSubLayer) Remove(name string) error {
+	full, err := s.full("remove", name)
+	if err != nil || full == s.dir {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	return s.shorten(s.m.Remove(full))
+}
+
+func (s *memSubLayer) Rename(oldname, newname string) error {
+	oldfull, err := s.full("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newfull, err := s.full("rename", newname)
+	if err != nil {
+		return err
+	}
+	if oldfull == s.dir || newfull == s.dir {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrInvalid}
+	}
+	return s.shorten(s.m.Rename(oldfull, newfull))
+}
+
+func (s *memSubLayer) Chmod(name string, mode fs.FileMode) error {
+	full, err := s.full("chmod", name)
+	if err != nil {
+		return err
+	}
+	return s.shorten(s.m.Chmod(full, mode))
+}
+
+func (s *memSubLayer) Chtimes(name string, atime, mtime time.Time) error {
+	full, err := s.full("chtimes", name)
+	if err != nil {
+		return err
+	}
+	return s.shorten(s.m.Chtimes(full, atime, mtime))
+}
+
+func (s *memSubLayer) Sub(dir string) (fs.FS, error) {
+	full, err := s.full("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := s.m.Sub(full)
+	return sub, s.shorten(err)
+}
+
+// update replaces the node at name with a modified copy, so files that
+// are already open keep the info they were opened with.
+func (m *MemLayer) update(op, name string, fn func(*memNode)) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, ok := m.mem.nodes[name]
+	if !ok {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	updated := *node
+	fn(&updated)
+	m.mem.nodes[name] = &updated
+	return nil
+}
+
+// checkParent reports an error unless the parent of name is an existing
+// directory. The caller must hold the write lock.
+func (m *MemLayer) checkParent(op, name string) error {
+	parent, ok := m.mem.nodes[path.Dir(name)]
+	if !ok {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !parent.mode.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return nil
+}
+
+func (m *MemLayer) hasChildren(dir string) bool {
+	for p := range m.mem.nodes {
+		if p != "." && path.Dir(p) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// spill writes data to a new file in the spill directory and returns
+// its path.
+func (m *MemLayer) spill(data []byte) (string, error) {
+	m.spillMu.Lock()
+	if m.spillDir == "" {
+		dir, err := os.MkdirTemp(m.spillParent, "cfs-mem-*")
+		if err != nil {
+			m.spillMu.Unlock()
+			return "", err
+		}
+		m.spillDir = dir
+	}
+	dir := m.spillDir
+	m.spillMu.Unlock()
+
+	file, err := os.CreateTemp(dir, "spill-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// drop removes the spilled data of the node, if any. Files that are
+// still open keep reading the removed data where the platform allows it.
+func (n *memNode) drop() {
+	if n.spill != "" {
+		os.Remove(n.spill)
+	}
+}
+
+// spillFile is an open spilled file, reporting the info of its node
+// rather than that of the temporary file.
+type spillFile struct {
+	*os.File
+	info fs.FileInfo
+}
+
+func (f *spillFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
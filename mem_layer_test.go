@@ -0,0 +1,251 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestMemLayerAsWriteLayer(t *testing.T) {
+	base := fstest.MapFS{
+		"views/home.html":  &fstest.MapFile{Data: []byte("base home")},
+		"views/about.html": &fstest.MapFile{Data: []byte("base about")},
+	}
+	layer := cfs.NewMemLayer()
+	composite := cfs.NewWritableFS(layer, base)
+
+	if err := composite.WriteFile("views/home.html", []byte("override home"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := composite.WriteFile("views/new.html", []byte("new"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	testReadFile(t, composite, "views/home.html", "override home")
+	testReadFile(t, composite, "views/about.html", "base about")
+	testReadFile(t, layer, "views/new.html", "new")
+
+	entries, err := fs.ReadDir(composite, "views")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 merged entries, got %d", len(entries))
+	}
+
+	if err := composite.Remove("views/home.html"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	testReadFile(t, composite, "views/home.html", "base home")
+
+	// renaming a lower-layer file copies it up into the memory layer
+	if err := composite.Rename("views/about.html", "views/info.html"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	testReadFile(t, layer, "views/info.html", "base about")
+
+	if err := composite.Chmod("views/new.html", 0o600); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := composite.Chtimes("views/new.html", mtime, mtime); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	info, err := layer.Stat("views/new.html")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode() != 0o600 || !info.ModTime().Equal(mtime) {
+		t.Fatalf("Expected mode 0600 and time %v, got %v and %v", mtime, info.Mode(), info.ModTime())
+	}
+}
+
+func TestMemLayerErrors(t *testing.T) {
+	layer := cfs.NewMemLayer()
+
+	if err := layer.WriteFile("missing/a.txt", nil, 0o644); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected ErrNotExist for missing parent, got %v", err)
+	}
+	if err := layer.MkdirAll("a/b", 0o755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := layer.Mkdir("a", 0o755); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("Expected ErrExist for existing directory, got %v", err)
+	}
+	if err := layer.WriteFile("a/b/c.txt", []byte("c"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := layer.Remove("a/b"); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("Expected non-empty directory removal to fail, got %v", err)
+	}
+	if err := layer.MkdirAll("a/b/c.txt/d", 0o755); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("Expected ErrInvalid for file in path, got %v", err)
+	}
+	if err := layer.Rename("a", "a/b/x"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("Expected renaming into itself to fail, got %v", err)
+	}
+
+	if err := layer.Rename("a", "z"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	testReadFile(t, layer, "z/b/c.txt", "c")
+	if _, err := layer.Stat("a/b"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected old tree to be gone, got %v", err)
+	}
+
+	if err := fstest.TestFS(layer, "z/b/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemLayerConcurrentUse(t *testing.T) {
+	layer := cfs.NewMemLayer()
+	composite := cfs.NewWritableFS(layer)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("dir%d/file.txt", i)
+			for j := 0; j < 50; j++ {
+				if err := composite.WriteFile(name, []byte("data"), 0o644); err != nil {
+					t.Errorf("WriteFile failed: %v", err)
+					return
+				}
+				if _, err := fs.ReadFile(composite, name); err != nil {
+					t.Errorf("ReadFile failed: %v", err)
+					return
+				}
+				if _, err := fs.ReadDir(composite, "."); err != nil {
+					t.Errorf("ReadDir failed: %v", err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	entries, err := layer.ReadDir(".")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 8 {
+		t.Fatalf("Expected 8 directories, got %d", len(entries))
+	}
+}
+
+func TestMemLayerSpillsLargeFiles(t *testing.T) {
+	spillParent := t.TempDir()
+	layer := cfs.NewMemLayer(cfs.MemSpillThreshold(8), cfs.MemSpillDir(spillParent))
+	composite := cfs.NewWritableFS(layer)
+
+	large := strings.Repeat("x", 64)
+	if err := composite.WriteFile("small.txt", []byte("small"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := composite.WriteFile("large.txt", []byte(large), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	spilled := func() int {
+		matches, err := filepath.Glob(filepath.Join(spillParent, "*", "*"))
+		if err != nil {
+			t.Fatalf("Glob failed: %v", err)
+		}
+		return len(matches)
+	}
+	if n := spilled(); n != 1 {
+		t.Fatalf("Expected 1 spilled file, got %d", n)
+	}
+
+	testReadFile(t, composite, "small.txt", "small")
+	testReadFile(t, composite, "large.txt", large)
+
+	info, err := composite.Stat("large.txt")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Name() != "large.txt" || info.Size() != int64(len(large)) {
+		t.Fatalf("Expected large.txt of %d bytes, got %s of %d", len(large), info.Name(), info.Size())
+	}
+
+	file, err := layer.Open("large.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	fileInfo, err := file.Stat()
+	file.Close()
+	if err != nil || fileInfo.Name() != "large.txt" {
+		t.Fatalf("Expected open file to report its layer name, got %v, %v", fileInfo, err)
+	}
+
+	if err := composite.Rename("large.txt", "moved.txt"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	testReadFile(t, composite, "moved.txt", large)
+
+	// overwriting with small content releases the spilled file
+	if err := composite.WriteFile("moved.txt", []byte("tiny"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if n := spilled(); n != 0 {
+		t.Fatalf("Expected no spilled files after overwrite, got %d", n)
+	}
+
+	if err := composite.WriteFile("again.txt", []byte(large), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := layer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	entries, err := os.ReadDir(spillParent)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("Expected Close to remove the spill directory, got %d entries", len(entries))
+	}
+}
+
+func TestMemLayerSub(t *testing.T) {
+	layer := cfs.NewMemLayer()
+	composite := cfs.NewWritableFS(layer, fstest.MapFS{
+		"views/about.html": &fstest.MapFile{Data: []byte("base about")},
+	})
+	if err := composite.WriteFile("views/home.html", []byte("mem home"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	sub, err := composite.Sub("views")
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	testReadFile(t, sub, "home.html", "mem home")
+	testReadFile(t, sub, "about.html", "base about")
+
+	// the sub view keeps writing to the same layer
+	writable, ok := sub.(cfs.WriteFS)
+	if !ok {
+		t.Fatalf("Expected the sub view to be writable, got %T", sub)
+	}
+	if err := writable.WriteFile("new.html", []byte("new"), 0o644); err != nil {
+		t.Fatalf("WriteFile through the sub view failed: %v", err)
+	}
+	testReadFile(t, layer, "views/new.html", "new")
+
+	if _, err := layer.Sub("views/home.html"); err == nil {
+		t.Fatal("Expected Sub of a file to fail")
+	}
+}
@@ -0,0 +1,223 @@
+package cfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+)
+
+// Conflict markers written by Merge3, in the diff3 style of git.
+const (
+	mergeMarkerOurs   = "<<<<<<< ours\n"
+	mergeMarkerBase   = "||||||| base\n"
+	mergeMarkerSep    = "=======\n"
+	mergeMarkerTheirs = ">>>>>>> theirs\n"
+)
+
+// MergeResult is the outcome of a three-way merge.
+type MergeResult struct {
+	// Content is the merged content, with conflict markers around the
+	// regions both sides changed differently.
+	Content []byte
+	// Conflicts is the number of conflicting regions.
+	Conflicts int
+}
+
+// Merge3 merges the changes ours and theirs made to base, line by line.
+// Regions changed by one side only take that side; regions both sides
+// changed identically are taken once. Regions changed differently are
+// kept with diff3-style conflict markers listing ours, base and theirs.
+func Merge3(base, ours, theirs io.Reader) (MergeResult, error) {
+	var texts [3][]byte
+	for i, r := range []io.Reader{base, ours, theirs} {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return MergeResult{}, err
+		}
+		texts[i] = data
+	}
+	return merge3(splitLines(texts[0]), splitLines(texts[1]), splitLines(texts[2])), nil
+}
+
+// MergeOverride merges a customization across an upgrade of the layers
+// below it. The version of name in the top layer, the write layer when
+// there is one, is taken as ours, the version the layers below it
+// provide now as theirs, and the version in oldBase, the lower layers
+// before the upgrade, as the common base. Missing base versions merge as
+// empty files. The result is not written; store it with WriteFile once
+// conflicts are resolved.
+func (cfs *CompositeFS) MergeOverride(name string, oldBase fs.FS) (MergeResult, error) {
+	name, err := cfs.lookupName("merge", name)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	if len(cfs.filesystems) == 0 {
+		return MergeResult{}, &fs.PathError{Op: "merge", Path: name, Err: fs.ErrNotExist}
+	}
+	top, topIndex := cfs.topLayer()
+
+	ours, err := fs.ReadFile(top, name)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	base, err := readOptional(oldBase, name)
+	if err != nil {
+		return MergeResult{}, err
+	}
+
+	var theirs []byte
+	file, err := cfs.OpenParent(name, topIndex)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return MergeResult{}, err
+	default:
+		theirs, err = io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return MergeResult{}, err
+		}
+	}
+	return merge3(splitLines(base), splitLines(ours), splitLines(theirs)), nil
+}
+
+// readOptional reads name from fsys, treating a missing file as empty.
+func readOptional(fsys fs.FS, name string) ([]byte, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func merge3(base, ours, theirs []string) MergeResult {
+	matchOurs := matchLines(base, ours)
+	matchTheirs := matchLines(base, theirs)
+
+	var out bytes.Buffer
+	var result MergeResult
+	i, a, b := 0, 0, 0
+	for {
+		// lines unchanged on both sides
+		for i < len(base) && matchOurs[i] == a && matchTheirs[i] == b {
+			out.WriteString(base[i])
+			i, a, b = i+1, a+1, b+1
+		}
+		if i == len(base) && a == len(ours) && b == len(theirs) {
+			break
+		}
+
+		// the changed region ends at the next base line both sides kept
+		j, oursEnd, theirsEnd := len(base), len(ours), len(theirs)
+		for k := i; k < len(base); k++ {
+			if matchOurs[k] >= 0 && matchTheirs[k] >= 0 {
+				j, oursEnd, theirsEnd = k, matchOurs[k], matchTheirs[k]
+				break
+			}
+		}
+		baseChunk, oursChunk, theirsChunk := base[i:j], ours[a:oursEnd], theirs[b:theirsEnd]
+		switch {
+		case slices.Equal(oursChunk, baseChunk):
+			writeLines(&out, theirsChunk)
+		case slices.Equal(theirsChunk, baseChunk), slices.Equal(oursChunk, theirsChunk):
+			writeLines(&out, oursChunk)
+		default:
+			result.Conflicts++
+			out.WriteString(mergeMarkerOurs)
+			writeLines(&out, oursChunk)
+			terminateLine(&out)
+			out.WriteString(mergeMarkerBase)
+			writeLines(&out, baseChunk)
+			terminateLine(&out)
+			out.WriteString(mergeMarkerSep)
+			writeLines(&out, theirsChunk)
+			terminateLine(&out)
+			out.WriteString(mergeMarkerTheirs)
+		}
+		i, a, b = j, oursEnd, theirsEnd
+	}
+	result.Content = out.Bytes()
+	return result
+}
+
+// matchLines returns, for every line of a, the index of the line of b it
+// is matched with in a longest common subsequence, or -1.
+func matchLines(a, b []string) []int {
+	match := make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+
+	// common prefix and suffix need no table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		match[prefix] = prefix
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		match[len(a)-1-suffix] = len(b) - 1 - suffix
+		suffix++
+	}
+
+	ra, rb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(ra), len(rb)
+	if n == 0 || m == 0 {
+		return match
+	}
+
+	// lcs[x*(m+1)+y] is the LCS length of ra[x:] and rb[y:]
+	lcs := make([]int32, (n+1)*(m+1))
+	for x := n - 1; x >= 0; x-- {
+		for y := m - 1; y >= 0; y-- {
+			if ra[x] == rb[y] {
+				lcs[x*(m+1)+y] = lcs[(x+1)*(m+1)+y+1] + 1
+			} else {
+				lcs[x*(m+1)+y] = max(lcs[(x+1)*(m+1)+y], lcs[x*(m+1)+y+1])
+			}
+		}
+	}
+	for x, y := 0, 0; x < n && y < m; {
+		switch {
+		case ra[x] == rb[y]:
+			match[prefix+x] = prefix + y
+			x, y = x+1, y+1
+		case lcs[(x+1)*(m+1)+y] >= lcs[x*(m+1)+y+1]:
+			x++
+		default:
+			y++
+		}
+	}
+	return match
+}
+
+// splitLines splits data after every newline, keeping the newlines so
+// the lines concatenate back to data.
+func splitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		n := bytes.IndexByte(data, '\n') + 1
+		if n == 0 {
+			n = len(data)
+		}
+		lines = append(lines, string(data[:n]))
+		data = data[n:]
+	}
+	return lines
+}
+
+func writeLines(buf *bytes.Buffer, lines []string) {
+	for _, line := range lines {
+		buf.WriteString(line)
+	}
+}
+
+// terminateLine ends the last line of buf, so a conflict marker starts
+// on a line of its own.
+func terminateLine(buf *bytes.Buffer) {
+	if b := buf.Bytes(); len(b) > 0 && b[len(b)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+}
@@ -0,0 +1,95 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestMerge3(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      string
+		ours      string
+		theirs    string
+		want      string
+		conflicts int
+	}{
+		{
+			name:   "separate changes",
+			base:   "a\nb\nc\nd\n",
+			ours:   "a\nB\nc\nd\n",
+			theirs: "a\nb\nc\nD\n",
+			want:   "a\nB\nc\nD\n",
+		},
+		{
+			name:   "same change",
+			base:   "a\nb\n",
+			ours:   "a\nx\n",
+			theirs: "a\nx\n",
+			want:   "a\nx\n",
+		},
+		{
+			name:   "insertions at both ends",
+			base:   "b\n",
+			ours:   "a\nb\n",
+			theirs: "b\nc",
+			want:   "a\nb\nc",
+		},
+		{
+			name:      "conflict",
+			base:      "a\nb\nc\n",
+			ours:      "a\nours\nc\n",
+			theirs:    "a\ntheirs\nc\n",
+			want:      "a\n<<<<<<< ours\nours\n||||||| base\nb\n=======\ntheirs\n>>>>>>> theirs\nc\n",
+			conflicts: 1,
+		},
+		{
+			name:      "conflict without trailing newline",
+			base:      "a",
+			ours:      "b",
+			theirs:    "c",
+			want:      "<<<<<<< ours\nb\n||||||| base\na\n=======\nc\n>>>>>>> theirs\n",
+			conflicts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := cfs.Merge3(strings.NewReader(tt.base), strings.NewReader(tt.ours), strings.NewReader(tt.theirs))
+			if err != nil {
+				t.Fatalf("Merge3 failed: %v", err)
+			}
+			if string(result.Content) != tt.want || result.Conflicts != tt.conflicts {
+				t.Fatalf("Expected %q with %d conflicts, got %q with %d", tt.want, tt.conflicts, result.Content, result.Conflicts)
+			}
+		})
+	}
+}
+
+func TestMergeOverrideAcrossUpgrade(t *testing.T) {
+	oldTheme := fstest.MapFS{
+		"layout.html": &fstest.MapFile{Data: []byte("<html>\n<head>\n<title>Site</title>\n</head>\n<body>\n</body>\n</html>\n")},
+	}
+	newTheme := fstest.MapFS{
+		"layout.html": &fstest.MapFile{Data: []byte("<!doctype html>\n<html>\n<head>\n<title>Site</title>\n</head>\n<body>\n</body>\n</html>\n")},
+	}
+	writer := cfs.NewMemLayer()
+	if err := writer.WriteFile("layout.html", []byte("<html>\n<head>\n<title>My Site</title>\n</head>\n<body>\n</body>\n</html>\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	composite := cfs.NewWritableFS(writer, newTheme)
+
+	result, err := composite.MergeOverride("layout.html", oldTheme)
+	if err != nil {
+		t.Fatalf("MergeOverride failed: %v", err)
+	}
+	want := "<!doctype html>\n<html>\n<head>\n<title>My Site</title>\n</head>\n<body>\n</body>\n</html>\n"
+	if result.Conflicts != 0 || string(result.Content) != want {
+		t.Fatalf("Expected clean merge %q, got %q with %d conflicts", want, result.Content, result.Conflicts)
+	}
+}
@@ -0,0 +1,63 @@
+package cfs
+
+import (
+	"io/fs"
+	"sync"
+)
+
+// WithEagerEntryInfo returns a copy of the composite that captures the
+// FileInfo of every merged directory entry while the listing is built,
+// so later Info calls never go back to the layer. Without it the info
+// is fetched on the first Info call, and entries whose info was never
+// fetched report the layer error once the layer can no longer answer.
+func (cfs *CompositeFS) WithEagerEntryInfo() *CompositeFS {
+	c := cfs.clone()
+	c.eagerInfo = true
+	return c
+}
+
+// mergedEntry is a directory entry merged from one of the layers. Its
+// Info method falls back to the FileInfo captured earlier, at merge time
+// or by a previous call, when the layer can no longer answer, for
+// example because it has been swapped out or its files were removed.
+type mergedEntry struct {
+	fs.DirEntry
+
+	mu sync.Mutex
+	// info is the FileInfo returned by the layer, nil until captured.
+	info fs.FileInfo
+}
+
+// mergeEntry wraps an entry merged from a layer.
+func (cfs *CompositeFS) mergeEntry(entry fs.DirEntry) fs.DirEntry {
+	if m, ok := entry.(*mergedEntry); ok {
+		// already merged by a nested composite
+		return m
+	}
+	m := &mergedEntry{DirEntry: entry}
+	if cfs.eagerInfo {
+		if info, err := entry.Info(); err == nil {
+			m.info = info
+		}
+	}
+	return m
+}
+
+func (m *mergedEntry) Info() (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.info != nil {
+		return m.info, nil
+	}
+	info, err := m.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	m.info = info
+	return info, nil
+}
+
+func (m *mergedEntry) String() string {
+	return fs.FormatDirEntry(m)
+}
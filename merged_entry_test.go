@@ -0,0 +1,113 @@
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+// vanishingFS lists its entries normally, but once gone is set their
+// Info method fails as if the files had been removed.
+type vanishingFS struct {
+	fstest.MapFS
+	gone *atomic.Bool
+}
+
+type vanishingEntry struct {
+	fs.DirEntry
+	gone *atomic.Bool
+}
+
+func (v vanishingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := v.MapFS.ReadDir(name)
+	for i, entry := range entries {
+		entries[i] = vanishingEntry{entry, v.gone}
+	}
+	return entries, err
+}
+
+func (e vanishingEntry) Info() (fs.FileInfo, error) {
+	if e.gone.Load() {
+		return nil, &fs.PathError{Op: "stat", Path: e.Name(), Err: fs.ErrNotExist}
+	}
+	return e.DirEntry.Info()
+}
+
+func newVanishingFS() vanishingFS {
+	return vanishingFS{
+		MapFS: fstest.MapFS{
+			"assets/app.js":     &fstest.MapFile{Data: []byte("console.log(1)")},
+			"assets/img/a.png":  &fstest.MapFile{Data: []byte("png")},
+			"assets/style.css":  &fstest.MapFile{Data: []byte("body{}")},
+			"assets/other.html": &fstest.MapFile{Data: []byte("<p>")},
+		},
+		gone: new(atomic.Bool),
+	}
+}
+
+func entryInfo(t *testing.T, entries []fs.DirEntry, name string) fs.FileInfo {
+	t.Helper()
+	for _, entry := range entries {
+		if entry.Name() != name {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			t.Fatalf("Info(%s) failed: %v", name, err)
+		}
+		return info
+	}
+	t.Fatalf("Expected entry %s", name)
+	return nil
+}
+
+func TestMergedEntryInfoFallsBack(t *testing.T) {
+	layer := newVanishingFS()
+	composite := cfs.NewCompositeFS(layer, fstest.MapFS{})
+
+	entries, err := composite.ReadDir("assets")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+
+	// info fetched before the layer goes away is kept
+	if info := entryInfo(t, entries, "app.js"); info.Size() != 14 {
+		t.Errorf("Expected size 14, got %d", info.Size())
+	}
+	layer.gone.Store(true)
+	if info := entryInfo(t, entries, "app.js"); info.Size() != 14 {
+		t.Errorf("Expected cached size 14, got %d", info.Size())
+	}
+
+	// otherwise the layer error is reported rather than made-up info
+	for _, entry := range entries {
+		if entry.Name() != "style.css" {
+			continue
+		}
+		if info, err := entry.Info(); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Expected fs.ErrNotExist, got %v, %v", info, err)
+		}
+	}
+}
+
+func TestWithEagerEntryInfo(t *testing.T) {
+	layer := newVanishingFS()
+	composite := cfs.NewOverlayFS(layer, fstest.MapFS{}).WithEagerEntryInfo()
+
+	entries, err := fs.ReadDir(composite, "assets")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	layer.gone.Store(true)
+
+	if info := entryInfo(t, entries, "style.css"); info.Size() != 6 {
+		t.Errorf("Expected size 6, got %d", info.Size())
+	}
+	if info := entryInfo(t, entries, "other.html"); info.Size() != 3 {
+		t.Errorf("Expected size 3, got %d", info.Size())
+	}
+}
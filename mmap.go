@@ -0,0 +1,83 @@
+package cfs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Mmap wraps a layer backed by an OS directory, such as os.DirFS or
+// DirWriteFS, so regular files of at least threshold bytes are served
+// from read-only memory mappings: large assets are then read straight
+// from the page cache, and copied to writers such as network
+// connections through WriteTo without an intermediate buffer. Files
+// are still opened through the layer, so wrapping the result in Jail
+// keeps its checks. Files below the threshold, directories and every
+// other operation are delegated as is. On platforms without mmap, and
+// when a file cannot be mapped, files are opened normally. Layers
+// without a known OS root are returned unchanged. Mapped files must not
+// be truncated while open. Composites wrap the files they open to
+// report their layer; use WithPlainFiles to keep WriteTo reachable.
+func Mmap(fsys fs.FS, threshold int64) fs.FS {
+	root := osRoot(fsys)
+	if root == "" {
+		return fsys
+	}
+	return &mmapFS{fsys: fsys, root: root, threshold: threshold}
+}
+
+type mmapFS struct {
+	fsys      fs.FS
+	root      string
+	threshold int64
+}
+
+// Root returns the directory the layer is rooted at, so Jail and
+// WithSymlinkProtection apply to it.
+func (m *mmapFS) Root() string {
+	return m.root
+}
+
+// Name implements NamedFS by delegating to the wrapped layer.
+func (m *mmapFS) Name() string {
+	return LayerName(m.fsys)
+}
+
+func (m *mmapFS) Open(name string) (fs.File, error) {
+	file, err := m.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	// files are opened through the layer, so wrappers such as Jail
+	// still check the path; only the returned OS file is mapped
+	f, ok := file.(*os.File)
+	if !ok {
+		return file, nil
+	}
+	if mapped, ok := mapFile(f, name, m.threshold); ok {
+		f.Close()
+		return mapped, nil
+	}
+	return file, nil
+}
+
+func (m *mmapFS) Stat(name string) (fs.FileInfo, error) {
+	return statLayer(m.fsys, name)
+}
+
+func (m *mmapFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(m.fsys, name)
+}
+
+func (m *mmapFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return ReadDir(m.fsys, name)
+}
+
+// Sub returns the layer rooted at dir, mapping files the same way.
+func (m *mmapFS) Sub(dir string) (fs.FS, error) {
+	sub, err := fs.Sub(m.fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &mmapFS{fsys: sub, root: filepath.Join(m.root, filepath.FromSlash(dir)), threshold: m.threshold}, nil
+}
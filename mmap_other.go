@@ -0,0 +1,14 @@
+//go:build !unix || tinygo || cfs_tiny
+
+package cfs
+
+import (
+	"io/fs"
+	"os"
+)
+
+// mapFile reports false: memory mappings are not supported on this
+// platform, so files are opened normally.
+func mapFile(f *os.File, name string, threshold int64) (fs.File, bool) {
+	return nil, false
+}
@@ -0,0 +1,79 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestMmap(t *testing.T) {
+	root := t.TempDir()
+	large := bytes.Repeat([]byte("asset"), 1000)
+	writeTestFile(t, filepath.Join(root, "assets", "app.js"), large)
+	writeTestFile(t, filepath.Join(root, "assets", "small.css"), []byte("body{}"))
+
+	mapped := cfs.Mmap(os.DirFS(root), 1024)
+	if err := fstest.TestFS(mapped, "assets/app.js", "assets/small.css"); err != nil {
+		t.Fatalf("TestFS failed: %v", err)
+	}
+	testReadFile(t, mapped, "assets/small.css", "body{}")
+
+	f, err := mapped.Open("assets/app.js")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err := f.Read(make([]byte, 5)); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	var out bytes.Buffer
+	if _, err := io.Copy(&out, f); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), large[5:]) {
+		t.Fatalf("Expected the rest of the file, got %d bytes", out.Len())
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := f.Close(); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("Expected ErrClosed, got %v", err)
+	}
+
+	composite := cfs.NewCompositeFS(cfs.NewMemLayer(), mapped).WithPlainFiles()
+	data, err := fs.ReadFile(composite, "assets/app.js")
+	if err != nil || !bytes.Equal(data, large) {
+		t.Fatalf("Expected the content through the composite, got %d bytes, %v", len(data), err)
+	}
+}
+
+func TestMmapKeepsJailChecks(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	writeTestFile(t, filepath.Join(root, "public", "index.html"), []byte("index"))
+	writeTestFile(t, filepath.Join(base, "outside.txt"), bytes.Repeat([]byte("x"), 2048))
+	if err := os.Symlink(filepath.Join(base, "outside.txt"), filepath.Join(root, "escape.txt")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	jailed := cfs.Jail(cfs.Mmap(os.DirFS(root), 1024))
+	if _, err := jailed.Open("escape.txt"); !errors.Is(err, cfs.ErrSymlinkEscape) {
+		t.Fatalf("Expected ErrSymlinkEscape, got %v", err)
+	}
+	testReadFile(t, jailed, "public/index.html", "index")
+}
+
+func TestMmapLeavesOtherLayersUnchanged(t *testing.T) {
+	layer := fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("a")}}
+	if _, ok := cfs.Mmap(layer, 0).(fstest.MapFS); !ok {
+		t.Fatal("Expected layers without an OS root to be returned unchanged")
+	}
+}
@@ -0,0 +1,55 @@
+//go:build unix && !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// mapFile maps f, opened as name, into memory when it is a regular file
+// of at least threshold bytes. It reports false when f is not mapped;
+// the mapping outlives f, which the caller closes.
+func mapFile(f *os.File, name string, threshold int64) (fs.File, bool) {
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, false
+	}
+	size := info.Size()
+	if size < threshold || size <= 0 || int64(int(size)) != size {
+		return nil, false
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, false
+	}
+	return &mappedFile{memFile: memFile{name: name, info: info, data: data}}, true
+}
+
+// mappedFile is a file served from a read-only memory mapping.
+type mappedFile struct {
+	memFile
+}
+
+// WriteTo implements io.WriterTo, writing the rest of the file straight
+// from the mapping.
+func (f *mappedFile) WriteTo(w io.Writer) (int64, error) {
+	if f.offset >= int64(len(f.data)) {
+		return 0, nil
+	}
+	n, err := w.Write(f.data[f.offset:])
+	f.offset += int64(n)
+	return int64(n), err
+}
+
+// Close unmaps the file. Reads after Close report io.EOF.
+func (f *mappedFile) Close() error {
+	if f.data == nil {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	data := f.data
+	f.data = nil
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,509 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"net"
+	"path"
+)
+
+// 9P2000 message types.
+const (
+	p9Tversion = 100
+	p9Tauth    = 102
+	p9Tattach  = 104
+	p9Rerror   = 107
+	p9Tflush   = 108
+	p9Twalk    = 110
+	p9Topen    = 112
+	p9Tcreate  = 114
+	p9Tread    = 116
+	p9Twrite   = 118
+	p9Tclunk   = 120
+	p9Tremove  = 122
+	p9Tstat    = 124
+	p9Twstat   = 126
+)
+
+const (
+	p9Version  = "9P2000"
+	p9MaxSize  = 64 << 10
+	p9MinSize  = 256
+	p9IOHeader = 24
+	p9QTDir    = 0x80
+	p9DMDir    = 0x80000000
+	p9OpenMode = 0x03
+	p9OWrite   = 0x01
+	p9ORdwr    = 0x02
+	p9OTrunc   = 0x10
+	p9ORClose  = 0x40
+	p9MaxWalk  = 16
+	p9Owner    = "cfs"
+	p9MsgLimit = 8 << 20
+)
+
+// Error strings understood by common 9P clients, such as the Linux
+// kernel, which map them back to errno values.
+var (
+	p9ErrNotExist   = errors.New("No such file or directory")
+	p9ErrPermission = errors.New("Permission denied")
+	p9ErrReadOnly   = errors.New("Read-only file system")
+	p9ErrInvalid    = errors.New("Invalid argument")
+	p9ErrNotDir     = errors.New("Not a directory")
+	p9ErrUnknownFid = errors.New("unknown fid")
+	p9ErrFidInUse   = errors.New("fid already in use")
+	p9ErrNoAuth     = errors.New("authentication not required")
+)
+
+// Serve9P exports fsys read-only over the 9P2000 protocol on l, so
+// containers and virtual machines can mount the merged view of a
+// composite directly (for example with mount -t 9p -o trans=tcp on
+// Linux). Every connection is served until the client disconnects.
+// Serve9P returns when l fails to accept a connection, such as after
+// l is closed. The export is experimental: it implements plain 9P2000
+// without authentication, and all files are reported as owned by "cfs".
+func Serve9P(l net.Listener, fsys fs.FS) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			s := &p9Session{fsys: fsys, fids: make(map[uint32]*p9Fid), msize: p9MaxSize}
+			s.serve(conn)
+		}()
+	}
+}
+
+// p9Session holds the state of one connection. Requests are handled
+// in order by a single goroutine.
+type p9Session struct {
+	fsys  fs.FS
+	msize uint32
+	fids  map[uint32]*p9Fid
+}
+
+type p9Fid struct {
+	path string
+	dir  bool
+
+	// set once the fid is opened
+	file    fs.File
+	pos     int64
+	dirData []byte
+}
+
+func (f *p9Fid) close() {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+	f.dirData = nil
+}
+
+func (s *p9Session) serve(rw io.ReadWriter) {
+	defer func() {
+		for _, f := range s.fids {
+			f.close()
+		}
+	}()
+
+	var header [4]byte
+	for {
+		if _, err := io.ReadFull(rw, header[:]); err != nil {
+			return
+		}
+		size := binary.LittleEndian.Uint32(header[:])
+		if size < 7 || size > p9MsgLimit {
+			return
+		}
+		msg := make([]byte, size-4)
+		if _, err := io.ReadFull(rw, msg); err != nil {
+			return
+		}
+
+		typ, tag := msg[0], binary.LittleEndian.Uint16(msg[1:3])
+		r := &p9Reader{buf: msg[3:]}
+		w := &p9Writer{}
+		rtype, err := s.handle(typ, r, w)
+		if err == nil && r.err != nil {
+			err = p9ErrInvalid
+		}
+		if err != nil {
+			w = &p9Writer{}
+			w.string(err.Error())
+			rtype = p9Rerror
+		}
+		if _, err := rw.Write(w.frame(rtype, tag)); err != nil {
+			return
+		}
+	}
+}
+
+// handle executes one request, writing the reply body to w and
+// returning the reply type.
+func (s *p9Session) handle(typ byte, r *p9Reader, w *p9Writer) (byte, error) {
+	switch typ {
+	case p9Tversion:
+		msize, version := r.uint32(), r.string()
+		// the msize must leave room for the I/O header and a stat entry
+		if msize < p9MinSize {
+			return 0, p9ErrInvalid
+		}
+		s.msize = min(msize, p9MaxSize)
+		for _, f := range s.fids {
+			f.close()
+		}
+		clear(s.fids)
+		if version != p9Version {
+			version = "unknown"
+		}
+		w.uint32(s.msize)
+		w.string(version)
+	case p9Tauth:
+		return 0, p9ErrNoAuth
+	case p9Tattach:
+		fid := r.uint32()
+		r.uint32() // afid
+		r.string() // uname
+		r.string() // aname
+		if _, ok := s.fids[fid]; ok {
+			return 0, p9ErrFidInUse
+		}
+		info, err := statLayer(s.fsys, ".")
+		if err != nil {
+			return 0, p9Error(err)
+		}
+		s.fids[fid] = &p9Fid{path: ".", dir: true}
+		w.qid(".", info)
+	case p9Tflush:
+		// requests are handled in order, so there is nothing to cancel
+		r.uint16()
+	case p9Twalk:
+		return s.walk(r, w)
+	case p9Topen:
+		return s.open(r, w)
+	case p9Tread:
+		return s.read(r, w)
+	case p9Tstat:
+		f, err := s.fid(r.uint32())
+		if err != nil {
+			return 0, err
+		}
+		info, err := statLayer(s.fsys, f.path)
+		if err != nil {
+			return 0, p9Error(err)
+		}
+		stat := &p9Writer{}
+		stat.stat(f.path, info)
+		w.uint16(uint16(len(stat.buf)))
+		w.bytes(stat.buf)
+	case p9Tclunk:
+		fid := r.uint32()
+		f, err := s.fid(fid)
+		if err != nil {
+			return 0, err
+		}
+		f.close()
+		delete(s.fids, fid)
+	case p9Tremove:
+		// remove clunks the fid even when it fails
+		fid := r.uint32()
+		if f, ok := s.fids[fid]; ok {
+			f.close()
+			delete(s.fids, fid)
+		}
+		return 0, p9ErrReadOnly
+	case p9Tcreate, p9Twrite, p9Twstat:
+		return 0, p9ErrReadOnly
+	default:
+		return 0, p9ErrInvalid
+	}
+	return typ + 1, nil
+}
+
+func (s *p9Session) walk(r *p9Reader, w *p9Writer) (byte, error) {
+	fid, newfid, n := r.uint32(), r.uint32(), int(r.uint16())
+	if n > p9MaxWalk {
+		return 0, p9ErrInvalid
+	}
+	names := make([]string, n)
+	for i := range names {
+		names[i] = r.string()
+	}
+
+	f, err := s.fid(fid)
+	if err != nil {
+		return 0, err
+	}
+	if f.file != nil || f.dirData != nil {
+		return 0, p9ErrInvalid
+	}
+	if _, ok := s.fids[newfid]; ok && newfid != fid {
+		return 0, p9ErrFidInUse
+	}
+
+	current, dir := f.path, f.dir
+	var qids []*p9Writer
+	for i, name := range names {
+		if !dir {
+			err = p9ErrNotDir
+		} else if name == "" || name == "." || path.Base(name) != name {
+			err = p9ErrInvalid
+		}
+		next := path.Join(current, name)
+		var info fs.FileInfo
+		if err == nil {
+			info, err = statLayer(s.fsys, next)
+		}
+		if err != nil {
+			if i == 0 {
+				return 0, p9Error(err)
+			}
+			break
+		}
+		current, dir = next, info.IsDir()
+		q := &p9Writer{}
+		q.qid(current, info)
+		qids = append(qids, q)
+	}
+
+	w.uint16(uint16(len(qids)))
+	for _, q := range qids {
+		w.bytes(q.buf)
+	}
+	if len(qids) == n {
+		s.fids[newfid] = &p9Fid{path: current, dir: dir}
+	}
+	return p9Twalk + 1, nil
+}
+
+func (s *p9Session) open(r *p9Reader, w *p9Writer) (byte, error) {
+	fid, mode := r.uint32(), r.uint8()
+	f, err := s.fid(fid)
+	if err != nil {
+		return 0, err
+	}
+	if f.file != nil || f.dirData != nil {
+		return 0, p9ErrInvalid
+	}
+	if m := mode & p9OpenMode; m == p9OWrite || m == p9ORdwr || mode&(p9OTrunc|p9ORClose) != 0 {
+		return 0, p9ErrReadOnly
+	}
+
+	info, err := statLayer(s.fsys, f.path)
+	if err != nil {
+		return 0, p9Error(err)
+	}
+	if info.IsDir() {
+		entries, err := ReadDir(s.fsys, f.path)
+		if err != nil {
+			return 0, p9Error(err)
+		}
+		data := &p9Writer{}
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			data.stat(path.Join(f.path, entry.Name()), info)
+		}
+		// an empty directory still needs to be marked as opened
+		f.dirData = append(make([]byte, 0, len(data.buf)), data.buf...)
+	} else {
+		file, err := s.fsys.Open(f.path)
+		if err != nil {
+			return 0, p9Error(err)
+		}
+		f.file = file
+	}
+
+	w.qid(f.path, info)
+	w.uint32(s.msize - p9IOHeader)
+	return p9Topen + 1, nil
+}
+
+func (s *p9Session) read(r *p9Reader, w *p9Writer) (byte, error) {
+	fid, offset, count := r.uint32(), r.uint64(), r.uint32()
+	f, err := s.fid(fid)
+	if err != nil {
+		return 0, err
+	}
+	count = min(count, s.msize-p9IOHeader)
+
+	var data []byte
+	switch {
+	case f.dirData != nil:
+		// directory reads return whole stat entries only, so the
+		// offset must be 0 or where the previous read stopped
+		if offset != 0 && offset != uint64(f.pos) {
+			return 0, p9ErrInvalid
+		}
+		rest := f.dirData[offset:]
+		end := 0
+		for end+2 <= len(rest) {
+			size := 2 + int(binary.LittleEndian.Uint16(rest[end:]))
+			if end+size > int(count) || end+size > len(rest) {
+				break
+			}
+			end += size
+		}
+		data = rest[:end]
+		f.pos = int64(offset) + int64(end)
+	case f.file != nil:
+		data, err = readFileAt(f, int64(offset), int(count))
+		if err != nil {
+			return 0, p9Error(err)
+		}
+	default:
+		return 0, p9ErrInvalid
+	}
+
+	w.uint32(uint32(len(data)))
+	w.bytes(data)
+	return p9Tread + 1, nil
+}
+
+// readFileAt reads up to count bytes at offset from an opened fid,
+// seeking when the file supports it.
+func readFileAt(f *p9Fid, offset int64, count int) ([]byte, error) {
+	buf := make([]byte, count)
+	var n int
+	var err error
+	switch file := f.file.(type) {
+	case io.ReaderAt:
+		n, err = file.ReadAt(buf, offset)
+	case io.Seeker:
+		if _, err = file.Seek(offset, io.SeekStart); err == nil {
+			n, err = io.ReadFull(f.file, buf)
+		}
+	default:
+		if offset != f.pos {
+			return nil, p9ErrInvalid
+		}
+		n, err = io.ReadFull(f.file, buf)
+		f.pos += int64(n)
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return buf[:n], err
+}
+
+func (s *p9Session) fid(fid uint32) (*p9Fid, error) {
+	f, ok := s.fids[fid]
+	if !ok {
+		return nil, p9ErrUnknownFid
+	}
+	return f, nil
+}
+
+// p9Error maps filesystem errors to the strings clients understand.
+func p9Error(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return p9ErrNotExist
+	case errors.Is(err, fs.ErrPermission):
+		return p9ErrPermission
+	case errors.Is(err, fs.ErrInvalid):
+		return p9ErrInvalid
+	default:
+		return err
+	}
+}
+
+// p9Reader decodes the little-endian fields of a message. Reading past
+// the end sets err and yields zero values.
+type p9Reader struct {
+	buf []byte
+	err error
+}
+
+func (r *p9Reader) next(n int) []byte {
+	if r.err != nil || len(r.buf) < n {
+		r.err = io.ErrUnexpectedEOF
+		return make([]byte, n)
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *p9Reader) uint8() uint8   { return r.next(1)[0] }
+func (r *p9Reader) uint16() uint16 { return binary.LittleEndian.Uint16(r.next(2)) }
+func (r *p9Reader) uint32() uint32 { return binary.LittleEndian.Uint32(r.next(4)) }
+func (r *p9Reader) uint64() uint64 { return binary.LittleEndian.Uint64(r.next(8)) }
+func (r *p9Reader) string() string { return string(r.next(int(r.uint16()))) }
+
+// p9Writer encodes the fields of a reply.
+type p9Writer struct {
+	buf []byte
+}
+
+func (w *p9Writer) uint8(v uint8)   { w.buf = append(w.buf, v) }
+func (w *p9Writer) uint16(v uint16) { w.buf = binary.LittleEndian.AppendUint16(w.buf, v) }
+func (w *p9Writer) uint32(v uint32) { w.buf = binary.LittleEndian.AppendUint32(w.buf, v) }
+func (w *p9Writer) uint64(v uint64) { w.buf = binary.LittleEndian.AppendUint64(w.buf, v) }
+func (w *p9Writer) bytes(b []byte)  { w.buf = append(w.buf, b...) }
+
+func (w *p9Writer) string(s string) {
+	w.uint16(uint16(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+// qid identifies a file by a hash of its path, with the modification
+// time as version.
+func (w *p9Writer) qid(name string, info fs.FileInfo) {
+	var typ uint8
+	if info.IsDir() {
+		typ = p9QTDir
+	}
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	w.uint8(typ)
+	w.uint32(uint32(info.ModTime().Unix()))
+	w.uint64(h.Sum64())
+}
+
+func (w *p9Writer) stat(name string, info fs.FileInfo) {
+	start := len(w.buf)
+	w.uint16(0) // size, patched below
+	w.uint16(0) // type
+	w.uint32(0) // dev
+	w.qid(name, info)
+	mode := uint32(info.Mode().Perm())
+	length := uint64(info.Size())
+	if info.IsDir() {
+		mode |= p9DMDir
+		length = 0
+	}
+	w.uint32(mode)
+	mtime := uint32(info.ModTime().Unix())
+	w.uint32(mtime) // atime
+	w.uint32(mtime)
+	w.uint64(length)
+	base := path.Base(name)
+	if name == "." {
+		base = "/"
+	}
+	w.string(base)
+	w.string(p9Owner) // uid
+	w.string(p9Owner) // gid
+	w.string(p9Owner) // muid
+	binary.LittleEndian.PutUint16(w.buf[start:], uint16(len(w.buf)-start-2))
+}
+
+// frame prefixes the reply body with its size, type and tag.
+func (w *p9Writer) frame(typ byte, tag uint16) []byte {
+	out := make([]byte, 0, 7+len(w.buf))
+	out = binary.LittleEndian.AppendUint32(out, uint32(7+len(w.buf)))
+	out = append(out, typ)
+	out = binary.LittleEndian.AppendUint16(out, tag)
+	return append(out, w.buf...)
+}
@@ -0,0 +1,190 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"net"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+// p9Client is a minimal 9P2000 client for exercising Serve9P.
+type p9Client struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+func dial9P(t *testing.T, fsys fs.FS) *p9Client {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go cfs.Serve9P(l, fsys)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &p9Client{t: t, conn: conn}
+}
+
+func p9String(s string) []byte {
+	return append(binary.LittleEndian.AppendUint16(nil, uint16(len(s))), s...)
+}
+
+func p9Uint32(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+
+// call sends a request and returns the reply type and body.
+func (c *p9Client) call(typ byte, body ...[]byte) (byte, []byte) {
+	c.t.Helper()
+	var payload []byte
+	for _, b := range body {
+		payload = append(payload, b...)
+	}
+	msg := binary.LittleEndian.AppendUint32(nil, uint32(7+len(payload)))
+	msg = append(msg, typ, 1, 0)
+	msg = append(msg, payload...)
+	if _, err := c.conn.Write(msg); err != nil {
+		c.t.Fatalf("Write failed: %v", err)
+	}
+
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		c.t.Fatalf("Read failed: %v", err)
+	}
+	reply := make([]byte, binary.LittleEndian.Uint32(header)-7)
+	if _, err := io.ReadFull(c.conn, reply); err != nil {
+		c.t.Fatalf("Read failed: %v", err)
+	}
+	return header[4], reply
+}
+
+// expect sends a request and fails unless the reply has type want.
+func (c *p9Client) expect(want byte, typ byte, body ...[]byte) []byte {
+	c.t.Helper()
+	got, reply := c.call(typ, body...)
+	if got != want {
+		c.t.Fatalf("Expected reply %d to request %d, got %d (%q)", want, typ, got, reply)
+	}
+	return reply
+}
+
+func (c *p9Client) walk(fid, newfid uint32, names ...string) {
+	c.t.Helper()
+	body := [][]byte{p9Uint32(fid), p9Uint32(newfid), binary.LittleEndian.AppendUint16(nil, uint16(len(names)))}
+	for _, name := range names {
+		body = append(body, p9String(name))
+	}
+	reply := c.expect(111, 110, body...)
+	if n := binary.LittleEndian.Uint16(reply); int(n) != len(names) {
+		c.t.Fatalf("Expected %d qids, got %d", len(names), n)
+	}
+}
+
+func (c *p9Client) readAll(fid uint32) []byte {
+	c.t.Helper()
+	var out []byte
+	for {
+		reply := c.expect(117, 116, p9Uint32(fid), binary.LittleEndian.AppendUint64(nil, uint64(len(out))), p9Uint32(5))
+		n := binary.LittleEndian.Uint32(reply)
+		if n == 0 {
+			return out
+		}
+		out = append(out, reply[4:4+n]...)
+	}
+}
+
+func TestServe9PExportsMergedView(t *testing.T) {
+	composite := cfs.NewCompositeFS(
+		fstest.MapFS{"views/home.html": &fstest.MapFile{Data: []byte("theme home page")}},
+		fstest.MapFS{
+			"views/home.html":  &fstest.MapFile{Data: []byte("base home")},
+			"views/about.html": &fstest.MapFile{Data: []byte("about")},
+		},
+	)
+	c := dial9P(t, composite)
+
+	reply := c.expect(101, 100, p9Uint32(8192), p9String("9P2000"))
+	if binary.LittleEndian.Uint32(reply) != 8192 || string(reply[6:]) != "9P2000" {
+		t.Fatalf("Unexpected version reply %q", reply)
+	}
+	c.expect(105, 104, p9Uint32(0), p9Uint32(^uint32(0)), p9String("user"), p9String(""))
+
+	// read a file through the merged view, 5 bytes at a time
+	c.walk(0, 1, "views", "home.html")
+	c.expect(113, 112, p9Uint32(1), []byte{0})
+	if data := c.readAll(1); string(data) != "theme home page" {
+		t.Fatalf("Expected the theme file, got %q", data)
+	}
+	c.expect(121, 120, p9Uint32(1))
+
+	// list the merged directory
+	c.walk(0, 2, "views")
+	c.expect(113, 112, p9Uint32(2), []byte{0})
+	reply = c.expect(117, 116, p9Uint32(2), make([]byte, 8), p9Uint32(8192))
+	data := reply[4:]
+	var names []string
+	for len(data) > 0 {
+		size := int(binary.LittleEndian.Uint16(data)) + 2
+		stat := data[:size]
+		// name follows size, type, dev, qid, mode, atime, mtime, length
+		off := 2 + 2 + 4 + 13 + 4 + 4 + 4 + 8
+		n := int(binary.LittleEndian.Uint16(stat[off:]))
+		names = append(names, string(stat[off+2:off+2+n]))
+		data = data[size:]
+	}
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "about.html" || names[1] != "home.html" {
+		t.Fatalf("Expected about.html and home.html, got %v", names)
+	}
+
+	// writes and missing paths are rejected
+	c.walk(0, 3, "views", "about.html")
+	if typ, reply := c.call(112, p9Uint32(3), []byte{1}); typ != 107 || string(reply[2:]) != "Read-only file system" {
+		t.Fatalf("Expected a read-only error, got %d %q", typ, reply)
+	}
+	if typ, reply := c.call(110, p9Uint32(0), p9Uint32(4), []byte{1, 0}, p9String("missing")); typ != 107 || string(reply[2:]) != "No such file or directory" {
+		t.Fatalf("Expected a not-exist error, got %d %q", typ, reply)
+	}
+}
+
+func TestServe9PRejectsMidEntryDirectoryOffset(t *testing.T) {
+	c := dial9P(t, fstest.MapFS{
+		"views/home.html":  &fstest.MapFile{Data: []byte("home")},
+		"views/about.html": &fstest.MapFile{Data: []byte("about")},
+	})
+	c.expect(101, 100, p9Uint32(8192), p9String("9P2000"))
+	c.expect(105, 104, p9Uint32(0), p9Uint32(^uint32(0)), p9String("user"), p9String(""))
+	c.walk(0, 1, "views")
+	c.expect(113, 112, p9Uint32(1), []byte{0})
+
+	// an offset inside the first stat entry is rejected
+	if typ, reply := c.call(116, p9Uint32(1), binary.LittleEndian.AppendUint64(nil, 3), p9Uint32(8192)); typ != 107 || string(reply[2:]) != "Invalid argument" {
+		t.Fatalf("Expected an invalid argument error, got %d %q", typ, reply)
+	}
+
+	// reading on from where the previous read stopped still works
+	reply := c.expect(117, 116, p9Uint32(1), make([]byte, 8), p9Uint32(8192))
+	n := binary.LittleEndian.Uint32(reply)
+	reply = c.expect(117, 116, p9Uint32(1), binary.LittleEndian.AppendUint64(nil, uint64(n)), p9Uint32(8192))
+	if binary.LittleEndian.Uint32(reply) != 0 {
+		t.Fatalf("Expected the listing to end, got %q", reply)
+	}
+}
+
+func TestServe9PRejectsTinyMsize(t *testing.T) {
+	c := dial9P(t, fstest.MapFS{"home.html": &fstest.MapFile{Data: []byte("home")}})
+	if typ, reply := c.call(100, p9Uint32(8), p9String("9P2000")); typ != 107 || string(reply[2:]) != "Invalid argument" {
+		t.Fatalf("Expected an invalid argument error, got %d %q", typ, reply)
+	}
+	c.expect(101, 100, p9Uint32(8192), p9String("9P2000"))
+}
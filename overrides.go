@@ -0,0 +1,300 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ErrOverrideConflict is returned by ImportOverrides when the bundle
+// conflicts with the composite and the policy is ConflictFail.
+var ErrOverrideConflict = errors.New("override bundle conflicts with current content")
+
+// Override bundles are zip archives holding a manifest followed by the
+// customized files below a files/ prefix.
+const (
+	overrideManifestName = "manifest.json"
+	overrideFilesDir     = "files/"
+)
+
+// overrideManifest describes the files of an override bundle.
+type overrideManifest struct {
+	Files map[string]overrideEntry `json:"files"`
+}
+
+type overrideEntry struct {
+	// Hash is the SHA-256 of the customized content.
+	Hash string `json:"hash"`
+	// Base is the SHA-256 of the lower-layer version the customization
+	// was made against, empty for files the lower layers do not have.
+	Base string      `json:"base,omitempty"`
+	Mode fs.FileMode `json:"mode"`
+}
+
+// ExportOverrides writes the customizations of the composite to w as a
+// zip bundle: the files of the top layer, the write layer when there is
+// one, whose content differs from what the lower layers provide, along
+// with a manifest.json recording the SHA-256 of each file and of the
+// base version it overrides. Customers can share the bundle and re-apply
+// it after upgrades with ImportOverrides.
+func (cfs *CompositeFS) ExportOverrides(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	if len(cfs.filesystems) == 0 {
+		return zw.Close()
+	}
+	top, topIndex := cfs.topLayer()
+
+	manifest := overrideManifest{Files: make(map[string]overrideEntry)}
+	var names []string
+	err := fs.WalkDir(top, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || !cfs.visible(name) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		sum, err := layerSum(top, name)
+		if err != nil {
+			return err
+		}
+		base, _, err := cfs.parentSum(name, topIndex)
+		if err != nil {
+			return err
+		}
+		if sum == base {
+			return nil
+		}
+		manifest.Files[name] = overrideEntry{Hash: sum, Base: base, Mode: info.Mode().Perm()}
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	mw, err := zw.Create(overrideManifestName)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := cfs.exportOverride(zw, top, name); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// exportOverride copies the file name of fsys into the bundle.
+func (cfs *CompositeFS) exportOverride(zw *zip.Writer, fsys fs.FS, name string) error {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = path.Join(overrideFilesDir, name)
+	hdr.Method = zip.Deflate
+	fw, err := zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = cfs.copyFile(fw, file)
+	return err
+}
+
+// ConflictPolicy decides how ImportOverrides handles files that changed
+// both in the bundle and in the composite since the bundle was exported.
+type ConflictPolicy int
+
+const (
+	// ConflictFail applies nothing when any file conflicts.
+	ConflictFail ConflictPolicy = iota
+	// ConflictKeepCurrent leaves conflicting files alone and applies the
+	// others.
+	ConflictKeepCurrent
+	// ConflictTakeIncoming applies every file, replacing the current
+	// content of conflicting ones.
+	ConflictTakeIncoming
+)
+
+// OverrideConflict describes a file that changed both in a bundle and in
+// the composite. Hashes are hex encoded SHA-256 sums; empty hashes stand
+// for files that do not exist.
+type OverrideConflict struct {
+	Name string
+	// Base is the content the customization was made against.
+	Base string
+	// Current is the content the composite serves now.
+	Current string
+	// Incoming is the customized content in the bundle.
+	Incoming string
+}
+
+// ImportResult reports what ImportOverrides did.
+type ImportResult struct {
+	// Applied lists the files written to the write layer.
+	Applied []string
+	// Unchanged lists the files whose bundle content is served already.
+	Unchanged []string
+	// Conflicts lists the files that changed on both sides, whether or
+	// not the policy applied them.
+	Conflicts []OverrideConflict
+}
+
+// ImportOverrides applies a bundle created by ExportOverrides to the
+// write layer. Each file is merged three ways: a file the composite
+// still serves in its base version at export is replaced, and one that
+// already has the bundle content is left alone. Any other file changed
+// on both sides, for example because an upgrade changed the base
+// template, and is reported as a conflict and handled according to
+// policy. With ConflictFail, nothing is written when there are conflicts
+// and the error matches ErrOverrideConflict.
+func (cfs *CompositeFS) ImportOverrides(r io.Reader, policy ConflictPolicy) (ImportResult, error) {
+	var result ImportResult
+	if cfs.writer == nil {
+		return result, &fs.PathError{Op: "import", Path: ".", Err: ErrReadOnly}
+	}
+
+	bundle, err := readOverrideBundle(r)
+	if err != nil {
+		return result, err
+	}
+
+	var apply []string
+	for _, name := range bundle.names {
+		entry := bundle.manifest.Files[name]
+		current, err := cfs.HashFile(name, sha256.New())
+		if errors.Is(err, fs.ErrNotExist) {
+			current = ""
+		} else if err != nil {
+			return result, err
+		}
+
+		switch current {
+		case entry.Hash:
+			result.Unchanged = append(result.Unchanged, name)
+		case entry.Base:
+			apply = append(apply, name)
+		default:
+			result.Conflicts = append(result.Conflicts, OverrideConflict{
+				Name:     name,
+				Base:     entry.Base,
+				Current:  current,
+				Incoming: entry.Hash,
+			})
+			if policy == ConflictTakeIncoming {
+				apply = append(apply, name)
+			}
+		}
+	}
+	if len(result.Conflicts) > 0 && policy == ConflictFail {
+		return result, fmt.Errorf("%w: %d files", ErrOverrideConflict, len(result.Conflicts))
+	}
+
+	for _, name := range apply {
+		mode := bundle.manifest.Files[name].Mode
+		if mode == 0 {
+			mode = 0o644
+		}
+		if err := cfs.WriteFile(name, bundle.data[name], mode); err != nil {
+			return result, err
+		}
+		result.Applied = append(result.Applied, name)
+	}
+	return result, nil
+}
+
+// overrideBundle is a bundle read into memory and checked against its
+// manifest.
+type overrideBundle struct {
+	manifest overrideManifest
+	names    []string
+	data     map[string][]byte
+}
+
+func readOverrideBundle(r io.Reader) (*overrideBundle, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("read override bundle: %w", err)
+	}
+
+	bundle := &overrideBundle{data: make(map[string][]byte)}
+	haveManifest := false
+	for _, f := range zr.File {
+		data, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case f.Name == overrideManifestName:
+			if err := json.Unmarshal(data, &bundle.manifest); err != nil {
+				return nil, fmt.Errorf("read override manifest: %w", err)
+			}
+			haveManifest = true
+		case strings.HasPrefix(f.Name, overrideFilesDir):
+			bundle.data[strings.TrimPrefix(f.Name, overrideFilesDir)] = data
+		}
+	}
+	if !haveManifest {
+		return nil, fmt.Errorf("read override bundle: missing %s", overrideManifestName)
+	}
+
+	for name, entry := range bundle.manifest.Files {
+		if !fs.ValidPath(name) || name == "." {
+			return nil, &fs.PathError{Op: "import", Path: name, Err: fs.ErrInvalid}
+		}
+		data, ok := bundle.data[name]
+		if !ok {
+			return nil, &fs.PathError{Op: "import", Path: name, Err: fs.ErrNotExist}
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != entry.Hash {
+			return nil, fmt.Errorf("import %s: content does not match manifest hash", name)
+		}
+		bundle.names = append(bundle.names, name)
+	}
+	sort.Strings(bundle.names)
+	return bundle, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
@@ -0,0 +1,172 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestExportOverridesBundlesCustomizedFiles(t *testing.T) {
+	base := fstest.MapFS{
+		"views/home.html":  &fstest.MapFile{Data: []byte("home")},
+		"views/about.html": &fstest.MapFile{Data: []byte("about")},
+	}
+	writer := cfs.NewMemLayer()
+	composite := cfs.NewWritableFS(writer, base)
+
+	writes := map[string]string{
+		"views/home.html":  "home",
+		"views/about.html": "custom about",
+		"views/extra.html": "extra",
+	}
+	for name, data := range writes {
+		if err := composite.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatalf("WriteFile %s failed: %v", name, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := composite.ExportOverrides(&buf); err != nil {
+		t.Fatalf("ExportOverrides failed: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Invalid zip: %v", err)
+	}
+	contents := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Open %s failed: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("Read %s failed: %v", f.Name, err)
+		}
+		contents[f.Name] = string(data)
+	}
+
+	if len(zr.File) != 3 || zr.File[0].Name != "manifest.json" {
+		t.Fatalf("Expected manifest and 2 files, got %v", contents)
+	}
+	if contents["files/views/about.html"] != "custom about" || contents["files/views/extra.html"] != "extra" {
+		t.Fatalf("Unexpected bundle contents %v", contents)
+	}
+
+	var manifest struct {
+		Files map[string]struct {
+			Hash string `json:"hash"`
+			Base string `json:"base"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal([]byte(contents["manifest.json"]), &manifest); err != nil {
+		t.Fatalf("Invalid manifest: %v", err)
+	}
+	about := manifest.Files["views/about.html"]
+	if about.Hash != sha256Hex("custom about") || about.Base != sha256Hex("about") {
+		t.Fatalf("Unexpected manifest entry %+v", about)
+	}
+	if extra := manifest.Files["views/extra.html"]; extra.Base != "" {
+		t.Fatalf("Expected new file without base hash, got %+v", extra)
+	}
+	if _, ok := manifest.Files["views/home.html"]; ok {
+		t.Fatal("Expected unchanged copy to be left out")
+	}
+}
+
+func TestImportOverridesDetectsConflicts(t *testing.T) {
+	oldBase := fstest.MapFS{
+		"views/home.html":  &fstest.MapFile{Data: []byte("home v1")},
+		"views/about.html": &fstest.MapFile{Data: []byte("about")},
+	}
+	source := cfs.NewWritableFS(cfs.NewMemLayer(), oldBase)
+	writes := map[string]string{
+		"views/home.html":  "custom home",
+		"views/about.html": "custom about",
+		"views/extra.html": "extra",
+	}
+	for name, data := range writes {
+		if err := source.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatalf("WriteFile %s failed: %v", name, err)
+		}
+	}
+	var bundle bytes.Buffer
+	if err := source.ExportOverrides(&bundle); err != nil {
+		t.Fatalf("ExportOverrides failed: %v", err)
+	}
+
+	// the upgrade changed the home template the customization was based on
+	newBase := fstest.MapFS{
+		"views/home.html":  &fstest.MapFile{Data: []byte("home v2")},
+		"views/about.html": &fstest.MapFile{Data: []byte("about")},
+	}
+
+	target := cfs.NewWritableFS(cfs.NewMemLayer(), newBase)
+	result, err := target.ImportOverrides(bytes.NewReader(bundle.Bytes()), cfs.ConflictFail)
+	if !errors.Is(err, cfs.ErrOverrideConflict) {
+		t.Fatalf("Expected ErrOverrideConflict, got %v", err)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Name != "views/home.html" {
+		t.Fatalf("Expected a conflict on views/home.html, got %+v", result.Conflicts)
+	}
+	conflict := result.Conflicts[0]
+	if conflict.Base != sha256Hex("home v1") || conflict.Current != sha256Hex("home v2") || conflict.Incoming != sha256Hex("custom home") {
+		t.Fatalf("Unexpected conflict hashes %+v", conflict)
+	}
+	if len(result.Applied) != 0 {
+		t.Fatalf("Expected nothing to be applied, got %v", result.Applied)
+	}
+	testReadFile(t, target, "views/about.html", "about")
+
+	result, err = target.ImportOverrides(bytes.NewReader(bundle.Bytes()), cfs.ConflictKeepCurrent)
+	if err != nil {
+		t.Fatalf("ImportOverrides failed: %v", err)
+	}
+	if !slices.Equal(result.Applied, []string{"views/about.html", "views/extra.html"}) {
+		t.Fatalf("Unexpected applied files %v", result.Applied)
+	}
+	testReadFile(t, target, "views/home.html", "home v2")
+	testReadFile(t, target, "views/about.html", "custom about")
+	testReadFile(t, target, "views/extra.html", "extra")
+
+	result, err = target.ImportOverrides(bytes.NewReader(bundle.Bytes()), cfs.ConflictTakeIncoming)
+	if err != nil {
+		t.Fatalf("ImportOverrides failed: %v", err)
+	}
+	if !slices.Equal(result.Applied, []string{"views/home.html"}) || len(result.Unchanged) != 2 {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+	testReadFile(t, target, "views/home.html", "custom home")
+}
+
+func TestImportOverridesRejectsTamperedBundle(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	mw, _ := zw.Create("manifest.json")
+	mw.Write([]byte(`{"files":{"a.txt":{"hash":"` + sha256Hex("original") + `","mode":420}}}`))
+	fw, _ := zw.Create("files/a.txt")
+	fw.Write([]byte("tampered"))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	target := cfs.NewWritableFS(cfs.NewMemLayer())
+	if _, err := target.ImportOverrides(&buf, cfs.ConflictTakeIncoming); err == nil {
+		t.Fatal("Expected tampered bundle to be rejected")
+	}
+	if _, err := target.Stat("a.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected nothing to be written, got %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package cfs
+
+import "io/fs"
+
+// OpenParent opens the next version of name below the layer at index
+// belowLayer, skipping that layer and every layer above it, so an
+// override can wrap the original instead of fully replacing it, as in
+// "extends parent" template inheritance. Layers are "below" when they
+// come later in the probe order of name. The index of the layer serving
+// a file is reported by LayeredFile.Layer or Which. A belowLayer of -1
+// behaves like Open without merging directories.
+func (cfs *CompositeFS) OpenParent(name string, belowLayer int) (fs.File, error) {
+	name, err := cfs.lookupName("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if belowLayer < -1 || belowLayer >= len(cfs.filesystems) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if !cfs.visible(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	start := probePosition(cfs.probeOrder(name), belowLayer) + 1
+	file, err := cfs.openFrom(name, start)
+	if err != nil {
+		return nil, err
+	}
+	return cfs.wrapDir(name, file)
+}
@@ -0,0 +1,57 @@
+package cfs_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestOpenParent(t *testing.T) {
+	site := fstest.MapFS{
+		"views/layout.html": &fstest.MapFile{Data: []byte("site layout")},
+	}
+	theme := fstest.MapFS{
+		"views/layout.html": &fstest.MapFile{Data: []byte("theme layout")},
+	}
+	base := fstest.MapFS{
+		"views/layout.html": &fstest.MapFile{Data: []byte("base layout")},
+		"views/home.html":   &fstest.MapFile{Data: []byte("base home")},
+	}
+	composite := cfs.NewCompositeFS(site, theme, base)
+
+	// walk the inheritance chain from the override down to the base
+	var chain []string
+	below := -1
+	for {
+		file, err := composite.OpenParent("views/layout.html", below)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("OpenParent failed: %v", err)
+		}
+		data, _ := io.ReadAll(file)
+		file.Close()
+		chain = append(chain, string(data))
+		below = file.(cfs.LayeredFile).Layer().Index
+	}
+	if len(chain) != 3 || chain[0] != "site layout" || chain[2] != "base layout" {
+		t.Fatalf("Unexpected inheritance chain %q", chain)
+	}
+
+	file, err := composite.OpenParent("views/home.html", 0)
+	if err != nil {
+		t.Fatalf("OpenParent failed: %v", err)
+	}
+	file.Close()
+	if _, err := composite.OpenParent("views/home.html", 2); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist below the last layer, got %v", err)
+	}
+	if _, err := composite.OpenParent("views/home.html", 3); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Expected fs.ErrInvalid for an unknown layer, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package cfs
+
+import (
+	"path"
+	"strings"
+)
+
+// validPattern reports whether every element of pattern is valid for
+// path.Match.
+func validPattern(pattern string) error {
+	for _, elem := range strings.Split(pattern, "/") {
+		if _, err := path.Match(elem, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchPattern reports whether name matches pattern, a path.Match
+// pattern in which a "**" element matches any number of path elements,
+// as in "public/**" or "**/*.html".
+func matchPattern(pattern, name string) bool {
+	return matchElems(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// patternMayMatchBelow reports whether pattern may match paths below
+// the directory dir, so walks can skip directories no pattern reaches.
+func patternMayMatchBelow(pattern, dir string) bool {
+	if dir == "." {
+		return true
+	}
+	elems := strings.Split(pattern, "/")
+	for _, elem := range strings.Split(dir, "/") {
+		if len(elems) == 0 {
+			return false
+		}
+		if elems[0] == "**" {
+			return true
+		}
+		if ok, _ := path.Match(elems[0], elem); !ok {
+			return false
+		}
+		elems = elems[1:]
+	}
+	return len(elems) > 0
+}
@@ -0,0 +1,77 @@
+package cfs
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// PermissionMode selects how permission errors from a layer are treated.
+type PermissionMode int
+
+const (
+	// PermissionDefault treats permission errors like any other layer
+	// error: they stop the lookup unless the composite is best-effort.
+	PermissionDefault PermissionMode = iota
+	// PermissionFatal always stops the lookup, even in best-effort
+	// composites.
+	PermissionFatal
+	// PermissionSkip moves on to the next layer, so a read-protected
+	// file does not hide a readable fallback.
+	PermissionSkip
+	// PermissionReport moves on to the next layer like PermissionSkip
+	// and reports the error to PermissionPolicy.Report.
+	PermissionReport
+)
+
+// PermissionPolicy configures how permission errors (errors matching
+// fs.ErrPermission) from a layer are treated.
+type PermissionPolicy struct {
+	Mode PermissionMode
+	// Report is called for every permission error skipped in
+	// PermissionReport mode. It may be called concurrently.
+	Report func(PermissionEvent)
+}
+
+// PermissionEvent describes a permission error skipped in
+// PermissionReport mode.
+type PermissionEvent struct {
+	// Layer is the index of the layer that denied access.
+	Layer int
+	// LayerName is the name of that layer, if it is named.
+	LayerName string
+	// Path is the path that was looked up.
+	Path string
+	// Err is the error returned by the layer.
+	Err error
+}
+
+// WithPermissionPolicy returns a copy of the composite treating
+// permission errors from its layers according to policy, e.g. so a
+// read-protected file in a development layer falls through to the
+// embedded fallback. When no layer serves the path, the skipped errors
+// are still part of the returned error.
+func (cfs *CompositeFS) WithPermissionPolicy(policy PermissionPolicy) *CompositeFS {
+	c := cfs.clone()
+	c.permissions = policy
+	return c
+}
+
+// stopOn reports whether err, returned by layer i for name and not a
+// not-exist error, ends the lookup.
+func (cfs *CompositeFS) stopOn(i int, name string, err error) bool {
+	if !errors.Is(err, fs.ErrPermission) {
+		return !cfs.bestEffort
+	}
+	switch cfs.permissions.Mode {
+	case PermissionFatal:
+		return true
+	case PermissionSkip:
+		return false
+	case PermissionReport:
+		if report := cfs.permissions.Report; report != nil {
+			report(PermissionEvent{Layer: i, LayerName: LayerName(cfs.filesystems[i]), Path: name, Err: err})
+		}
+		return false
+	}
+	return !cfs.bestEffort
+}
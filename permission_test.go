@@ -0,0 +1,79 @@
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestWithPermissionPolicy(t *testing.T) {
+	fallback := fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("embedded home")},
+	}
+	dev := cfs.NewLayer("dev", permissionFS{})
+
+	tests := []struct {
+		name      string
+		composite *cfs.CompositeFS
+		wantErr   bool
+	}{
+		{"strict default", cfs.NewCompositeFS(dev, fallback), true},
+		{"best-effort default", cfs.NewCompositeFSBestEffort(dev, fallback), false},
+		{"fatal", cfs.NewCompositeFSBestEffort(dev, fallback).
+			WithPermissionPolicy(cfs.PermissionPolicy{Mode: cfs.PermissionFatal}), true},
+		{"skip", cfs.NewCompositeFS(dev, fallback).
+			WithPermissionPolicy(cfs.PermissionPolicy{Mode: cfs.PermissionSkip}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, openErr := tt.composite.Open("views/home.html")
+			_, statErr := tt.composite.Stat("views/home.html")
+			_, readErr := tt.composite.ReadFile("views/home.html")
+			for _, err := range []error{openErr, statErr, readErr} {
+				if tt.wantErr && !errors.Is(err, fs.ErrPermission) {
+					t.Errorf("Expected fs.ErrPermission, got %v", err)
+				}
+				if !tt.wantErr && err != nil {
+					t.Errorf("Expected the fallback to be served, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestWithPermissionPolicyReport(t *testing.T) {
+	fallback := fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("embedded home")},
+	}
+
+	var mu sync.Mutex
+	var events []cfs.PermissionEvent
+	composite := cfs.NewCompositeFS(cfs.NewLayer("dev", permissionFS{}), fallback).
+		WithPermissionPolicy(cfs.PermissionPolicy{
+			Mode: cfs.PermissionReport,
+			Report: func(e cfs.PermissionEvent) {
+				mu.Lock()
+				defer mu.Unlock()
+				events = append(events, e)
+			},
+		})
+
+	testReadFile(t, composite, "views/home.html", "embedded home")
+	if len(events) != 1 {
+		t.Fatalf("Expected one event, got %d", len(events))
+	}
+	e := events[0]
+	if e.Layer != 0 || e.LayerName != "dev" || e.Path != "views/home.html" || !errors.Is(e.Err, fs.ErrPermission) {
+		t.Errorf("Unexpected event %+v", e)
+	}
+
+	// skipped errors still explain a lookup that fails everywhere
+	_, err := composite.Open("missing.html")
+	if !errors.Is(err, fs.ErrPermission) || errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected the skipped permission error, got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package cfs
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// pinDigestPrefix marks pins that are content digests, as returned by
+// LayerDigest, rather than version identifiers.
+const pinDigestPrefix = "sha256:"
+
+// VersionedFS is implemented by layers that report the version of the
+// content they serve, such as a release tag or commit, so they can be
+// pinned to a version identifier with WithPin.
+type VersionedFS interface {
+	fs.FS
+	Version() string
+}
+
+// WithPin pins the layer to the content it must serve, for reproducible
+// stacks built from archives or remote layers. A pin of the form
+// "sha256:<hex>" is compared with the LayerDigest of the layer; any
+// other pin is compared with the version the layer reports through
+// VersionedFS. Pins are checked by Verify. Layers returned by Sub are
+// not pinned, since they serve only part of the content.
+func WithPin(pin string) LayerOption {
+	return func(l *Layer) {
+		l.pin = pin
+	}
+}
+
+// Pin returns the pin set with WithPin, or an empty string.
+func (l *Layer) Pin() string {
+	return l.pin
+}
+
+// LayerDigest returns the content digest of fsys, "sha256:" followed by
+// the hex encoded SHA-256 of the sorted paths and SHA-256 sums of every
+// file, for recording as a pin. Directories and metadata such as modes
+// and times do not contribute, so the digest of an archive matches that
+// of its extracted tree.
+func LayerDigest(fsys fs.FS) (string, error) {
+	sums, err := NewCompositeFS(fsys).HashTree(".", crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(sums))
+	for name := range sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s  %s\n", sums[name], name)
+	}
+	return pinDigestPrefix + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checkPin returns why l does not match its pin, or an empty string.
+func checkPin(l *Layer) string {
+	if digest, ok := strings.CutPrefix(l.pin, pinDigestPrefix); ok {
+		got, err := LayerDigest(l.FS())
+		if err != nil {
+			return fmt.Sprintf("content could not be hashed to check pin: %v", err)
+		}
+		if got != pinDigestPrefix+digest {
+			return fmt.Sprintf("content %s does not match pin %s", got, l.pin)
+		}
+		return ""
+	}
+
+	v, ok := layerVersion(l.FS())
+	if !ok {
+		return fmt.Sprintf("layer does not report a version to check pin %q", l.pin)
+	}
+	if v != l.pin {
+		return fmt.Sprintf("version %q does not match pin %q", v, l.pin)
+	}
+	return ""
+}
+
+// layerVersion returns the version reported by fsys or a filesystem it
+// wraps.
+func layerVersion(fsys fs.FS) (string, bool) {
+	if v, ok := fsys.(VersionedFS); ok {
+		return v.Version(), true
+	}
+	if _, ok := fsys.(*CompositeFS); ok {
+		return "", false
+	}
+	for _, inner := range wrappedLayers(fsys) {
+		if v, ok := layerVersion(inner); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// pinnedLayers returns the pinned layers in fsys, looking through the
+// wrappers of this package.
+func pinnedLayers(fsys fs.FS) []*Layer {
+	if l, ok := fsys.(*Layer); ok && l.pin != "" {
+		return []*Layer{l}
+	}
+	var pinned []*Layer
+	for _, inner := range wrappedLayers(fsys) {
+		pinned = append(pinned, pinnedLayers(inner)...)
+	}
+	return pinned
+}
+
+// needsPin reports whether RequirePins applies to layer fsys: content
+// compiled into the binary and the write layer are exempt.
+func (cfs *CompositeFS) needsPin(fsys fs.FS) bool {
+	if cfs.writer != nil && sameLayer(fsys, cfs.writer) {
+		return false
+	}
+	for {
+		switch v := unwrapLayer(fsys).(type) {
+		case embed.FS:
+			return false
+		case *Layer:
+			fsys = v.FS()
+		default:
+			return true
+		}
+	}
+}
@@ -0,0 +1,80 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+type versionedFS struct {
+	fstest.MapFS
+	version string
+}
+
+func (v versionedFS) Version() string { return v.version }
+
+func TestVerifyChecksContentPins(t *testing.T) {
+	theme := fstest.MapFS{
+		"layout.html": &fstest.MapFile{Data: []byte("layout")},
+		"css/app.css": &fstest.MapFile{Data: []byte("body{}")},
+	}
+	digest, err := cfs.LayerDigest(theme)
+	if err != nil {
+		t.Fatalf("LayerDigest failed: %v", err)
+	}
+	if !strings.HasPrefix(digest, "sha256:") {
+		t.Fatalf("Expected sha256 digest, got %q", digest)
+	}
+
+	composite := cfs.NewCompositeFS(cfs.NewLayer("theme", theme, cfs.WithPin(digest)))
+	if err := composite.Verify(cfs.Policy{RequirePins: true}); err != nil {
+		t.Fatalf("Expected pinned stack to verify, got %v", err)
+	}
+
+	theme["layout.html"] = &fstest.MapFile{Data: []byte("tampered")}
+	err = composite.Verify(cfs.Policy{})
+	var policyErr *cfs.PolicyError
+	if !errors.As(err, &policyErr) || !errors.Is(err, cfs.ErrPolicyViolation) {
+		t.Fatalf("Expected pin mismatch to be reported, got %v", err)
+	}
+	if policyErr.Name != "theme" || !strings.Contains(policyErr.Reason, digest) {
+		t.Fatalf("Unexpected policy error %v", policyErr)
+	}
+}
+
+func TestVerifyChecksVersionPins(t *testing.T) {
+	remote := versionedFS{MapFS: fstest.MapFS{}, version: "v1.2.0"}
+
+	pinned := cfs.NewCompositeFS(cfs.NewLayer("remote", remote, cfs.WithPin("v1.2.0")))
+	if err := pinned.Verify(cfs.Policy{}); err != nil {
+		t.Fatalf("Expected matching version to verify, got %v", err)
+	}
+
+	stale := cfs.NewCompositeFS(cfs.NewLayer("remote", remote, cfs.WithPin("v1.1.0")))
+	if err := stale.Verify(cfs.Policy{}); !errors.Is(err, cfs.ErrPolicyViolation) {
+		t.Fatalf("Expected version mismatch, got %v", err)
+	}
+
+	unversioned := cfs.NewCompositeFS(cfs.NewLayer("base", fstest.MapFS{}, cfs.WithPin("v1.0.0")))
+	if err := unversioned.Verify(cfs.Policy{}); !errors.Is(err, cfs.ErrPolicyViolation) {
+		t.Fatalf("Expected layers without a version to fail version pins, got %v", err)
+	}
+}
+
+func TestVerifyRequirePins(t *testing.T) {
+	composite := cfs.NewWritableFS(cfs.NewMemLayer(), embeddedFS, cfs.NewLayer("theme", fstest.MapFS{}))
+
+	err := composite.Verify(cfs.Policy{RequirePins: true})
+	var policyErr *cfs.PolicyError
+	if !errors.As(err, &policyErr) || policyErr.Name != "theme" {
+		t.Fatalf("Expected only the unpinned theme layer to be reported, got %v", err)
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok && len(joined.Unwrap()) != 1 {
+		t.Fatalf("Expected a single violation, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package cfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+)
+
+// ErrPolicyViolation is matched by every error reported by Verify.
+var ErrPolicyViolation = errors.New("composite filesystem violates policy")
+
+// Policy describes constraints a composite must satisfy, typically
+// checked once at startup of production binaries.
+type Policy struct {
+	// ForbidOSLayers rejects layers backed by the operating system's
+	// filesystem, such as os.DirFS, NewDirWriteFS or a DiskCache, so
+	// only embedded or otherwise immutable content is served.
+	ForbidOSLayers bool
+	// RequireWriteRole rejects write layers that are not tagged with
+	// RoleWrite, so writes never land in a layer serving another role.
+	RequireWriteRole bool
+	// RequirePins rejects layers that are not pinned with WithPin, so
+	// the whole stack is reproducible. Embedded layers, compiled into
+	// the binary, and the write layer are exempt.
+	RequirePins bool
+}
+
+// OSBackedFS is implemented by custom layers to declare whether they
+// read from the operating system's filesystem.
+type OSBackedFS interface {
+	fs.FS
+	OSBacked() bool
+}
+
+// PolicyError reports a layer violating a Policy.
+type PolicyError struct {
+	// Layer is the index of the offending layer in the composite.
+	Layer int
+	// Name is the layer name, if it is named.
+	Name   string
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("layer %d (%s): %s", e.Layer, e.Name, e.Reason)
+	}
+	return fmt.Sprintf("layer %d: %s", e.Layer, e.Reason)
+}
+
+func (e *PolicyError) Unwrap() error {
+	return ErrPolicyViolation
+}
+
+// Verify checks the composite against policy and returns a *PolicyError
+// for every offending layer, joined, or nil when the policy holds.
+// Layers pinned with WithPin are always checked against their pin,
+// which hashes the content of layers pinned to a digest.
+func (cfs *CompositeFS) Verify(policy Policy) error {
+	var errs []error
+	for i, fsys := range cfs.filesystems {
+		if policy.RequireWriteRole && cfs.writer != nil && sameLayer(fsys, cfs.writer) && !HasRole(fsys, RoleWrite) {
+			errs = append(errs, &PolicyError{
+				Layer:  i,
+				Name:   LayerName(fsys),
+				Reason: "write layer is not tagged with the write role",
+			})
+		}
+		if policy.ForbidOSLayers && osBacked(fsys) {
+			errs = append(errs, &PolicyError{
+				Layer:  i,
+				Name:   LayerName(fsys),
+				Reason: fmt.Sprintf("%T is backed by the OS filesystem", fsys),
+			})
+		}
+		pinned := pinnedLayers(fsys)
+		if policy.RequirePins && len(pinned) == 0 && cfs.needsPin(fsys) {
+			errs = append(errs, &PolicyError{
+				Layer:  i,
+				Name:   LayerName(fsys),
+				Reason: "layer is not pinned",
+			})
+		}
+		for _, l := range pinned {
+			if reason := checkPin(l); reason != "" {
+				errs = append(errs, &PolicyError{Layer: i, Name: LayerName(fsys), Reason: reason})
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// osBacked reports whether fsys, or any filesystem it wraps, reads from
+// the operating system's filesystem.
+func osBacked(fsys fs.FS) bool {
+	if o, ok := fsys.(OSBackedFS); ok {
+		return o.OSBacked()
+	}
+	for _, inner := range wrappedLayers(fsys) {
+		if osBacked(inner) {
+			return true
+		}
+	}
+
+	if profileOSBacked(fsys) {
+		return true
+	}
+	t := reflect.TypeOf(fsys)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.PkgPath() == "os"
+}
+
+// wrappedLayers returns the filesystems wrapped by the layer types of
+// this package.
+func wrappedLayers(fsys fs.FS) []fs.FS {
+	switch v := fsys.(type) {
+	case *CompositeFS:
+		return v.filesystems
+	case *Layer:
+		return []fs.FS{v.FS()}
+	case *archiveFS:
+		return []fs.FS{v.fsys}
+	case *filterFS:
+		return []fs.FS{v.fsys}
+	case *BloomLayer:
+		return []fs.FS{v.fsys}
+	case *jailFS:
+		return []fs.FS{v.fsys}
+	case *adaptedFS:
+		return []fs.FS{v.fsys}
+	case *transformFS:
+		return []fs.FS{v.fsys}
+	case *budgetFS:
+		return []fs.FS{v.fsys}
+	case *mmapFS:
+		return []fs.FS{v.fsys}
+	}
+	return profileWrappedLayers(fsys)
+}
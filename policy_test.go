@@ -0,0 +1,48 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestVerifyForbidOSLayers(t *testing.T) {
+	embedded := fstest.MapFS{"index.html": &fstest.MapFile{Data: []byte("index")}}
+	policy := cfs.Policy{ForbidOSLayers: true}
+
+	if err := cfs.NewCompositeFS(embedded).Verify(policy); err != nil {
+		t.Fatalf("Expected embedded-only stack to pass, got %v", err)
+	}
+
+	dir := t.TempDir()
+	composite := cfs.NewCompositeFS(
+		embedded,
+		cfs.NewLayer("local", os.DirFS(dir)),
+		cfs.NewCompositeFSBestEffort(cfs.HideLargeFiles(os.DirFS(dir), 10)),
+	)
+	err := composite.Verify(policy)
+	if !errors.Is(err, cfs.ErrPolicyViolation) {
+		t.Fatalf("Expected ErrPolicyViolation, got %v", err)
+	}
+
+	var policyErr *cfs.PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("Expected *PolicyError, got %T", err)
+	}
+	if policyErr.Layer != 1 || policyErr.Name != "local" {
+		t.Fatalf("Expected layer 1 (local) to be reported first, got %+v", policyErr)
+	}
+
+	writable := cfs.NewWritableFS(cfs.NewDirWriteFS(dir), embedded)
+	if err := writable.Verify(policy); !errors.Is(err, cfs.ErrPolicyViolation) {
+		t.Fatalf("Expected the write layer to violate the policy, got %v", err)
+	}
+	if err := writable.Verify(cfs.Policy{}); err != nil {
+		t.Fatalf("Expected empty policy to pass, got %v", err)
+	}
+}
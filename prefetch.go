@@ -0,0 +1,74 @@
+package cfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// Prefetcher is implemented by layers that can warm their own caches
+// for a path ahead of time. Prefetch calls it for every layer that
+// implements it, in addition to reading the winning file.
+type Prefetcher interface {
+	Prefetch(ctx context.Context, name string) error
+}
+
+// Prefetch resolves every file matching the given fs.Glob patterns and
+// reads it through the composite, so caches and indexes along the way
+// are populated before the first request arrives. Layers implementing
+// Prefetcher are asked to prefetch each match as well. Errors for
+// individual files are collected and returned together; the context
+// stops the work early and selects the layers, see WithLayerSelector.
+func (cfs *CompositeFS) Prefetch(ctx context.Context, patterns ...string) error {
+	cfs = cfs.ForContext(ctx)
+	var errs []error
+	seen := make(map[string]struct{})
+
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(cfs, pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("prefetch %q: %w", pattern, err))
+			continue
+		}
+
+		for _, name := range matches {
+			if err := ctx.Err(); err != nil {
+				return errors.Join(append(errs, err)...)
+			}
+			name = path.Clean(name)
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+
+			if err := cfs.prefetch(ctx, name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (cfs *CompositeFS) prefetch(ctx context.Context, name string) error {
+	info, err := cfs.Stat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return nil
+	}
+
+	for _, fsys := range cfs.filesystems {
+		if p, ok := fsys.(Prefetcher); ok {
+			if err := p.Prefetch(ctx, name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+	}
+
+	_, err = cfs.ReadFile(name)
+	return err
+}
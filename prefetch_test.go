@@ -0,0 +1,68 @@
+package cfs_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+type prefetchingFS struct {
+	fstest.MapFS
+	mu    sync.Mutex
+	names []string
+}
+
+func (p *prefetchingFS) Prefetch(ctx context.Context, name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.names = append(p.names, name)
+	return nil
+}
+
+func TestPrefetchReadsMatchingFiles(t *testing.T) {
+	counting := &countingFS{MapFS: fstest.MapFS{
+		"views/home.html":  &fstest.MapFile{Data: []byte("home")},
+		"views/about.html": &fstest.MapFile{Data: []byte("about")},
+		"assets/site.css":  &fstest.MapFile{Data: []byte("css")},
+	}}
+	prefetcher := &prefetchingFS{MapFS: fstest.MapFS{}}
+
+	composite := cfs.NewCompositeFS(prefetcher, counting)
+
+	counting.calls.Store(0)
+	if err := composite.Prefetch(context.Background(), "views/*.html", "views/home.html"); err != nil {
+		t.Fatalf("Prefetch failed: %v", err)
+	}
+
+	if len(prefetcher.names) != 2 {
+		t.Fatalf("Expected 2 prefetched paths, got %v", prefetcher.names)
+	}
+	if counting.calls.Load() == 0 {
+		t.Fatal("Expected matched files to be read")
+	}
+}
+
+func TestPrefetchHonorsContext(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("a")},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := composite.Prefetch(ctx, "*.txt"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestPrefetchBadPattern(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{})
+
+	if err := composite.Prefetch(context.Background(), "["); err == nil {
+		t.Fatal("Expected error for malformed pattern")
+	}
+}
@@ -0,0 +1,27 @@
+package cfs
+
+import "io/fs"
+
+// PreviewLayerName is the name given to draft layers added by WithPreview.
+const PreviewLayerName = "preview"
+
+// WithPreview returns a read-only view of the composite with draft
+// layered on top of the shared stack. It is meant to be created per
+// request, for example when editors preview unpublished template
+// changes: the original composite, and live traffic served by it, are
+// never affected. Creating a preview only allocates the new layer list.
+// The draft is wrapped like the layers of NewCompositeFS, and probe
+// rules keep applying to the layers they name.
+func (cfs *CompositeFS) WithPreview(draft fs.FS) fs.FS {
+	c := cfs.clone()
+
+	// previews must never write through to the live write layer
+	c.writer = nil
+	c.journal = nil
+	c.onCopyUp = nil
+
+	layers := make([]fs.FS, 0, len(cfs.filesystems)+1)
+	layers = append(layers, c.wrapLayer(NewLayer(PreviewLayerName, draft)))
+	c.setLayers(append(layers, cfs.filesystems...))
+	return c
+}
@@ -0,0 +1,59 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestWithPreviewOverlaysDraft(t *testing.T) {
+	writeDir := t.TempDir()
+	live := cfs.NewWritableFS(cfs.NewDirWriteFS(writeDir), fstest.MapFS{
+		"views/home.html":  &fstest.MapFile{Data: []byte("live home")},
+		"views/about.html": &fstest.MapFile{Data: []byte("live about")},
+	})
+
+	preview := live.WithPreview(fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("draft home")},
+	})
+
+	testReadFile(t, preview, "views/home.html", "draft home")
+	testReadFile(t, preview, "views/about.html", "live about")
+	testReadFile(t, live, "views/home.html", "live home")
+
+	composite, ok := preview.(*cfs.CompositeFS)
+	if !ok {
+		t.Fatalf("Expected *CompositeFS, got %T", preview)
+	}
+	if composite.Writable() {
+		t.Fatal("Expected preview to be read-only")
+	}
+}
+
+func TestWithPreviewWrapsDraftAndKeepsProbeOrder(t *testing.T) {
+	live := cfs.NewCompositeFS(
+		fstest.MapFS{"assets/app.css": &fstest.MapFile{Data: []byte("theme css")}},
+		fstest.MapFS{"assets/app.css": &fstest.MapFile{Data: []byte("embedded css")}},
+	).WithProbeOrder(cfs.ProbeRule{Prefix: "assets", Order: []int{1}}).WithSymlinkProtection()
+
+	dir := t.TempDir()
+	root := filepath.Join(dir, "draft")
+	writeTestFile(t, filepath.Join(root, "assets", "app.css"), []byte("draft css"))
+	writeTestFile(t, filepath.Join(dir, "secret.txt"), []byte("secret"))
+	preview := live.WithPreview(os.DirFS(root))
+	testReadFile(t, preview, "assets/app.css", "embedded css")
+
+	if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(root, "escape.txt")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if _, err := fs.ReadFile(preview, "escape.txt"); !errors.Is(err, cfs.ErrSymlinkEscape) {
+		t.Fatalf("Expected the draft to be jailed, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package cfs
+
+import (
+	"errors"
+	"io/fs"
+	"sort"
+)
+
+// LayerManifest is the name of the optional manifest file at the root
+// of a layer describing it, for example {"priority": 10}.
+const LayerManifest = "layer.json"
+
+// PrioritizedFS is implemented by layers that declare a priority.
+type PrioritizedFS interface {
+	fs.FS
+	Priority() int
+}
+
+// layerManifest is the content of LayerManifest.
+type layerManifest struct {
+	Priority *int `json:"priority"`
+}
+
+// WithPriority sets the priority of a layer, overriding any priority
+// declared in its manifest.
+func WithPriority(priority int) LayerOption {
+	return func(l *Layer) {
+		l.priority = &priority
+	}
+}
+
+// Priority implements PrioritizedFS. It returns the priority set with
+// WithPriority, or the one declared in the layer manifest, or zero.
+func (l *Layer) Priority() int {
+	if l.priority != nil {
+		return *l.priority
+	}
+	return layerPriority(l.FS())
+}
+
+// layerPriority returns the priority declared by fsys.
+func layerPriority(fsys fs.FS) int {
+	fsys = unwrapLayer(fsys)
+	if p, ok := fsys.(PrioritizedFS); ok {
+		return p.Priority()
+	}
+	if m := readLayerManifest(fsys); m.Priority != nil {
+		return *m.Priority
+	}
+	return 0
+}
+
+// WithPriorityOrder returns a copy of the composite whose layers are
+// probed by descending priority instead of registration order. Layers
+// with equal priority keep their registration order and the write layer
+// always stays on top. Priorities are read when the layer list is built;
+// call Reorder after they change.
+func (cfs *CompositeFS) WithPriorityOrder() *CompositeFS {
+	c := cfs.clone()
+	c.byPriority = true
+	c.setLayers(c.sortByPriority(append([]fs.FS(nil), cfs.filesystems...)))
+	return c
+}
+
+// Reorder returns a copy of the composite with its layers sorted again
+// by their current priorities, for example after a dynamic layer
+// changed its manifest. It returns ErrNoPriorityOrder when the composite
+// was not configured with WithPriorityOrder.
+func (cfs *CompositeFS) Reorder() (*CompositeFS, error) {
+	if !cfs.byPriority {
+		return nil, ErrNoPriorityOrder
+	}
+	c := cfs.clone()
+	c.setLayers(c.sortByPriority(append([]fs.FS(nil), cfs.filesystems...)))
+	return c, nil
+}
+
+// ErrNoPriorityOrder is returned by Reorder on composites that keep
+// registration order.
+var ErrNoPriorityOrder = errors.New("composite filesystem is not ordered by priority")
+
+// sortByPriority returns layers sorted by priority when priority order
+// is enabled. Sub composites keep the order of their parent instead.
+func (cfs *CompositeFS) sortByPriority(layers []fs.FS) []fs.FS {
+	if !cfs.byPriority {
+		return layers
+	}
+
+	priorities := make(map[int]int, len(layers))
+	for i, fsys := range layers {
+		priorities[i] = layerPriority(fsys)
+	}
+	indices := make([]int, len(layers))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(a, b int) bool {
+		ia, ib := indices[a], indices[b]
+		wa := cfs.writer != nil && sameLayer(layers[ia], cfs.writer)
+		wb := cfs.writer != nil && sameLayer(layers[ib], cfs.writer)
+		if wa != wb {
+			return wa
+		}
+		return priorities[ia] > priorities[ib]
+	})
+
+	out := make([]fs.FS, len(layers))
+	for i, idx := range indices {
+		out[i] = layers[idx]
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestWithPriorityOrder(t *testing.T) {
+	core := cfs.NewLayer("core", fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("core")},
+	}, cfs.WithPriority(0))
+	plugin := fstest.MapFS{
+		"layer.json":      &fstest.MapFile{Data: []byte(`{"priority": 10}`)},
+		"views/home.html": &fstest.MapFile{Data: []byte("plugin")},
+	}
+	theme := cfs.NewLayer("theme", fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("theme")},
+	}, cfs.WithPriority(5))
+
+	composite := cfs.NewCompositeFS(core, theme, plugin)
+	testReadFile(t, composite, "views/home.html", "core")
+
+	ordered := composite.WithPriorityOrder()
+	testReadFile(t, ordered, "views/home.html", "plugin")
+
+	// layers added later are placed by priority too
+	extra := ordered.WithLayerAppended(cfs.NewLayer("urgent", fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("urgent")},
+	}, cfs.WithPriority(20)))
+	testReadFile(t, extra, "views/home.html", "urgent")
+
+	sub, err := ordered.Sub("views")
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	testReadFile(t, sub, "home.html", "plugin")
+}
+
+func TestReorderPicksUpChangedPriorities(t *testing.T) {
+	a := fstest.MapFS{
+		"layer.json": &fstest.MapFile{Data: []byte(`{"priority": 1}`)},
+		"file.txt":   &fstest.MapFile{Data: []byte("a")},
+	}
+	b := fstest.MapFS{
+		"layer.json": &fstest.MapFile{Data: []byte(`{"priority": 2}`)},
+		"file.txt":   &fstest.MapFile{Data: []byte("b")},
+	}
+	composite := cfs.NewCompositeFS(a, b).WithPriorityOrder()
+	testReadFile(t, composite, "file.txt", "b")
+
+	a["layer.json"] = &fstest.MapFile{Data: []byte(`{"priority": 3}`)}
+	testReadFile(t, composite, "file.txt", "b")
+
+	reordered, err := composite.Reorder()
+	if err != nil {
+		t.Fatalf("Reorder failed: %v", err)
+	}
+	testReadFile(t, reordered, "file.txt", "a")
+
+	if _, err := cfs.NewCompositeFS(a).Reorder(); !errors.Is(err, cfs.ErrNoPriorityOrder) {
+		t.Fatalf("Expected ErrNoPriorityOrder, got %v", err)
+	}
+}
+
+func TestPriorityOrderKeepsWriterOnTop(t *testing.T) {
+	writer := cfs.NewDirWriteFS(t.TempDir())
+	lower := cfs.NewLayer("lower", fstest.MapFS{
+		"file.txt": &fstest.MapFile{Data: []byte("lower")},
+	}, cfs.WithPriority(100))
+
+	composite := cfs.NewWritableFS(writer, lower).WithPriorityOrder()
+	if err := composite.WriteFile("file.txt", []byte("written"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	testReadFile(t, composite, "file.txt", "written")
+}
@@ -0,0 +1,210 @@
+package cfs
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ProbeRule sets the order in which layers are probed for a class of
+// paths, for example to serve static assets from the embedded layer
+// first while templates prefer the development layer.
+type ProbeRule struct {
+	// Prefix selects the paths the rule applies to: Prefix itself and
+	// everything below it. An empty Prefix matches every path.
+	Prefix string
+	// Order lists layer indexes in probe order. Layers left out are
+	// probed afterwards in their usual order; out of range and repeated
+	// indexes are ignored.
+	Order []int
+}
+
+// probeRule is a ProbeRule resolved against the layers of a composite.
+type probeRule struct {
+	prefix string
+	order  []int // a permutation of the layer indexes
+	// explicit counts the leading indexes of order the rule listed;
+	// the others were completed in registration order
+	explicit int
+}
+
+// WithProbeOrder returns a copy of the composite probing layers in the
+// order of the rule matching each path, instead of registration order.
+// When several rules match, the one with the longest prefix wins. Layer
+// indexes refer to the layers of the composite at the time of the call,
+// as counted by LayerCount. Sub composites keep the rules, relative to
+// their root, as long as they keep every layer. Copies with a different
+// layer list, such as those of WithLayerPrepended or WithPreview, keep
+// the listed layers they still have in the same order; layers they add
+// are probed after them, in their usual order.
+func (cfs *CompositeFS) WithProbeOrder(rules ...ProbeRule) *CompositeFS {
+	c := cfs.clone()
+	c.probeRules = nil
+	for _, rule := range rules {
+		order, explicit := probePermutation(rule.Order, len(c.filesystems))
+		c.probeRules = append(c.probeRules, probeRule{
+			prefix:   cleanPrefix(rule.Prefix),
+			order:    order,
+			explicit: explicit,
+		})
+	}
+	sort.SliceStable(c.probeRules, func(i, j int) bool {
+		return len(c.probeRules[i].prefix) > len(c.probeRules[j].prefix)
+	})
+	return c
+}
+
+func cleanPrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return "."
+	}
+	return path.Clean(prefix)
+}
+
+// probePermutation completes order into a permutation of n layers. It
+// also returns how many indexes of order were kept.
+func probePermutation(order []int, n int) ([]int, int) {
+	seen := make([]bool, n)
+	out := make([]int, 0, n)
+	for _, i := range order {
+		if i < 0 || i >= n || seen[i] {
+			continue
+		}
+		seen[i] = true
+		out = append(out, i)
+	}
+	explicit := len(out)
+	for i := range n {
+		if !seen[i] {
+			out = append(out, i)
+		}
+	}
+	return out, explicit
+}
+
+// remapProbeRules returns the rules of cfs for the layer list layers,
+// which replaces cfs.filesystems. The layers a rule listed keep their
+// order at their new indexes; the others, including new layers, are
+// probed after them in registration order. Layers are matched below
+// the wrappers the composite adds.
+func (cfs *CompositeFS) remapProbeRules(layers []fs.FS) []probeRule {
+	if len(cfs.probeRules) == 0 {
+		return nil
+	}
+	moved := make([]int, len(cfs.filesystems))
+	for i, old := range cfs.filesystems {
+		moved[i] = -1
+		for j, fsys := range layers {
+			if sameLayer(unwrapLayer(old), unwrapLayer(fsys)) {
+				moved[i] = j
+				break
+			}
+		}
+	}
+
+	out := make([]probeRule, 0, len(cfs.probeRules))
+	for _, rule := range cfs.probeRules {
+		var listed []int
+		for _, i := range rule.order[:rule.explicit] {
+			if i < len(moved) && moved[i] >= 0 {
+				listed = append(listed, moved[i])
+			}
+		}
+		order, explicit := probePermutation(listed, len(layers))
+		out = append(out, probeRule{prefix: rule.prefix, order: order, explicit: explicit})
+	}
+	return out
+}
+
+// setLayers replaces the layer list of cfs, a fresh copy, keeping its
+// probe rules in step with the new indexes.
+func (cfs *CompositeFS) setLayers(layers []fs.FS) {
+	cfs.probeRules = cfs.remapProbeRules(layers)
+	cfs.filesystems = layers
+}
+
+// matches reports whether name is the rule prefix or below it.
+func (r probeRule) matches(name string) bool {
+	return r.prefix == "." || name == r.prefix || strings.HasPrefix(name, r.prefix+"/")
+}
+
+// probeOrder returns the layer indexes to probe for name, or nil when
+// layers are probed in registration order.
+func (cfs *CompositeFS) probeOrder(name string) []int {
+	for _, rule := range cfs.probeRules {
+		if rule.matches(name) {
+			return rule.order
+		}
+	}
+	return nil
+}
+
+// probeClass returns the prefix of the rule matching name, or an empty
+// string when none does.
+func (cfs *CompositeFS) probeClass(name string) string {
+	for _, rule := range cfs.probeRules {
+		if rule.matches(name) {
+			return rule.prefix
+		}
+	}
+	return ""
+}
+
+// layerAt returns the index of the layer probed at position n of order.
+// Positions past the end of order, which only a stale order can have,
+// fall back to registration order.
+func layerAt(order []int, n int) int {
+	if n >= len(order) {
+		return n
+	}
+	return order[n]
+}
+
+// probePosition returns the position of layer index in order.
+func probePosition(order []int, index int) int {
+	if index >= len(order) {
+		return index
+	}
+	for n, i := range order {
+		if i == index {
+			return n
+		}
+	}
+	return -1
+}
+
+// subProbeRules rewrites the rules for a Sub composite rooted at dir
+// with n layers. Rules are dropped when layers were left out, since
+// their indexes no longer match.
+func (cfs *CompositeFS) subProbeRules(dir string, n int) []probeRule {
+	if len(cfs.probeRules) == 0 || n != len(cfs.filesystems) {
+		return nil
+	}
+	var out []probeRule
+	for _, rule := range cfs.probeRules {
+		prefix := rule.prefix
+		switch {
+		case rule.matches(dir):
+			prefix = "."
+		case dir == ".":
+		case strings.HasPrefix(prefix, dir+"/"):
+			prefix = strings.TrimPrefix(prefix, dir+"/")
+		default:
+			continue
+		}
+		out = append(out, probeRule{prefix: prefix, order: rule.order, explicit: rule.explicit})
+	}
+	return out
+}
+
+// probeKey describes the rules for stack hashes.
+func (cfs *CompositeFS) probeKey() string {
+	var b strings.Builder
+	for _, rule := range cfs.probeRules {
+		fmt.Fprintf(&b, "%s=%v;", rule.prefix, rule.order)
+	}
+	return b.String()
+}
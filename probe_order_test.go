@@ -0,0 +1,143 @@
+package cfs_test
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func newProbeStack() *cfs.CompositeFS {
+	dev := fstest.MapFS{
+		"assets/app.css":  &fstest.MapFile{Data: []byte("dev css")},
+		"views/home.html": &fstest.MapFile{Data: []byte("dev home")},
+	}
+	theme := fstest.MapFS{
+		"assets/app.css":  &fstest.MapFile{Data: []byte("theme css")},
+		"views/home.html": &fstest.MapFile{Data: []byte("theme home")},
+	}
+	embedded := fstest.MapFS{
+		"assets/app.css":  &fstest.MapFile{Data: []byte("embedded css")},
+		"views/home.html": &fstest.MapFile{Data: []byte("embedded home")},
+	}
+	return cfs.NewCompositeFS(
+		cfs.NewLayer("dev", dev),
+		cfs.NewLayer("theme", theme),
+		cfs.NewLayer("embedded", embedded),
+	)
+}
+
+func TestWithProbeOrder(t *testing.T) {
+	composite := newProbeStack().WithProbeOrder(cfs.ProbeRule{Prefix: "assets/", Order: []int{2, 0}})
+
+	testReadFile(t, composite, "assets/app.css", "embedded css")
+	testReadFile(t, composite, "views/home.html", "dev home")
+
+	data, err := composite.ReadFile("assets/app.css")
+	if err != nil || string(data) != "embedded css" {
+		t.Errorf("Expected embedded css from ReadFile, got %q, %v", data, err)
+	}
+
+	m, err := composite.Which("assets/app.css")
+	if err != nil {
+		t.Fatalf("Which failed: %v", err)
+	}
+	if m.Layer != 2 || len(m.Shadows) != 2 || m.Shadows[0] != 0 || m.Shadows[1] != 1 {
+		t.Errorf("Expected layer 2 shadowing [0 1], got %d %v", m.Layer, m.Shadows)
+	}
+
+	matches, err := composite.GlobDetailed("*/*")
+	if err != nil {
+		t.Fatalf("GlobDetailed failed: %v", err)
+	}
+	for _, m := range matches {
+		want := map[string]string{"assets/app.css": "embedded", "views/home.html": "dev"}[m.Path]
+		if m.LayerName != want {
+			t.Errorf("Expected %s from %s, got %s", m.Path, want, m.LayerName)
+		}
+	}
+
+	// unlisted layers are probed after the listed ones
+	parent, err := composite.OpenParent("assets/app.css", 0)
+	if err != nil {
+		t.Fatalf("OpenParent failed: %v", err)
+	}
+	defer parent.Close()
+	if layer := parent.(cfs.LayeredFile).Layer(); layer.Name != "theme" {
+		t.Errorf("Expected the parent from theme, got %q", layer.Name)
+	}
+
+	infos, errs := composite.StatAll([]string{"assets/app.css", "views/home.html", "views/missing.html"})
+	if len(infos) != 2 || len(errs) != 1 {
+		t.Errorf("Expected 2 infos and 1 error, got %v %v", infos, errs)
+	}
+	if infos["assets/app.css"].Size() != int64(len("embedded css")) {
+		t.Errorf("Expected the embedded css to be stat'ed, got size %d", infos["assets/app.css"].Size())
+	}
+}
+
+func TestWithProbeOrderLongestPrefixWins(t *testing.T) {
+	composite := newProbeStack().WithProbeOrder(
+		cfs.ProbeRule{Order: []int{1}},
+		cfs.ProbeRule{Prefix: "assets", Order: []int{2}},
+	)
+
+	testReadFile(t, composite, "assets/app.css", "embedded css")
+	testReadFile(t, composite, "views/home.html", "theme home")
+}
+
+func TestWithProbeOrderSub(t *testing.T) {
+	composite := newProbeStack().WithProbeOrder(cfs.ProbeRule{Prefix: "assets", Order: []int{2}})
+
+	sub, err := fs.Sub(composite, "assets")
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	testReadFile(t, sub, "app.css", "embedded css")
+
+	if composite.StackHash() == newProbeStack().StackHash() {
+		t.Error("Expected probe rules to change the stack hash")
+	}
+}
+
+func TestWithProbeOrderFollowsReorderedLayers(t *testing.T) {
+	low := cfs.NewLayer("low", fstest.MapFS{"assets/app.css": &fstest.MapFile{Data: []byte("low css")}})
+	mid := cfs.NewLayer("mid", fstest.MapFS{"assets/app.css": &fstest.MapFile{Data: []byte("mid css")}})
+	high := cfs.NewLayer("high", fstest.MapFS{"assets/app.css": &fstest.MapFile{Data: []byte("high css")}}, cfs.WithPriority(10))
+
+	// the rule prefers "low", whose index changes once sorted by priority
+	composite := cfs.NewCompositeFS(low, mid, high).
+		WithProbeOrder(cfs.ProbeRule{Prefix: "assets", Order: []int{0}}).
+		WithPriorityOrder()
+	testReadFile(t, composite, "assets/app.css", "low css")
+}
+
+func TestWithProbeOrderAfterLayerChanges(t *testing.T) {
+	composite := newProbeStack().WithProbeOrder(cfs.ProbeRule{Prefix: "assets", Order: []int{2}})
+	draft := fstest.MapFS{"assets/app.css": &fstest.MapFile{Data: []byte("draft css")}}
+
+	prepended := composite.WithLayerPrepended(draft)
+	testReadFile(t, prepended, "assets/app.css", "embedded css")
+	testReadFile(t, prepended, "views/home.html", "dev home")
+
+	appended := composite.WithLayerAppended(draft)
+	testReadFile(t, appended, "assets/app.css", "embedded css")
+
+	without := composite.WithoutLayer("embedded")
+	testReadFile(t, without, "assets/app.css", "dev css")
+	if _, err := without.ReadFile("views/missing.html"); err == nil {
+		t.Fatal("Expected a missing file to fail")
+	}
+
+	// layers the rule does not list keep their usual order
+	reordered := composite.WithoutLayer("dev").WithLayerPrepended(draft)
+	testReadFile(t, reordered, "assets/app.css", "embedded css")
+	m, err := reordered.Which("assets/app.css")
+	if err != nil {
+		t.Fatalf("Which failed: %v", err)
+	}
+	if len(m.Shadows) != 2 || m.Shadows[0] != 0 || m.Shadows[1] != 1 {
+		t.Fatalf("Expected the embedded layer to shadow [0 1], got %v", m.Shadows)
+	}
+}
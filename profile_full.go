@@ -0,0 +1,41 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"encoding/json"
+	"io/fs"
+)
+
+// profileWrappedLayers returns the filesystems wrapped by the layer types
+// left out of the reduced build profile, see profile_tiny.go.
+func profileWrappedLayers(fsys fs.FS) []fs.FS {
+	if v, ok := fsys.(*IndexedLayer); ok {
+		return []fs.FS{v.fsys}
+	}
+	return nil
+}
+
+// profileOSBacked reports whether fsys is one of the disk-backed layer
+// types left out of the reduced build profile.
+func profileOSBacked(fsys fs.FS) bool {
+	switch fsys.(type) {
+	case *DirWriteFS, *cachedFS:
+		return true
+	}
+	return false
+}
+
+// readLayerManifest reads the manifest of fsys. A missing or malformed
+// manifest yields an empty one.
+func readLayerManifest(fsys fs.FS) layerManifest {
+	var m layerManifest
+	data, err := fs.ReadFile(fsys, LayerManifest)
+	if err != nil {
+		return m
+	}
+	if json.Unmarshal(data, &m) != nil {
+		return layerManifest{}
+	}
+	return m
+}
@@ -0,0 +1,40 @@
+//go:build tinygo || cfs_tiny
+
+package cfs
+
+import (
+	"context"
+	"io/fs"
+)
+
+// The reduced build profile, selected by TinyGo or the cfs_tiny build
+// tag, keeps the composite core (layered Open, ReadDir and Stat, the
+// write layer and the options of CompositeFS) for embed-style layers
+// on targets such as firmware web UIs. It leaves out the subsystems
+// depending on the operating system, the network, regexp or
+// reflection-heavy packages such as encoding/json and html/template:
+// DirWriteFS, DiskCache, IndexedLayer, MemLayer, CopyFile, CopyAll,
+// WithCopyBuffer, DiscoverLayers, NewFromEnv, Grep, content types, the
+// HTTP and 9P handlers, remote layers, override bundles, tar export,
+// metadata sidecars and attributes. The functions below stand in for
+// the hooks the core calls them through.
+
+func profileWrappedLayers(fs.FS) []fs.FS { return nil }
+
+func profileOSBacked(fs.FS) bool { return false }
+
+func diskCacheStats(fs.FS) *CacheStats { return nil }
+
+func waitDiskCaches(context.Context, []fs.FS) error { return nil }
+
+// attrIndex stands in for the attribute index of FindByAttr, which
+// needs encoding/json.
+type attrIndex struct{}
+
+func (*attrIndex) fresh() *attrIndex { return nil }
+
+func (*attrIndex) invalidate() {}
+
+// readLayerManifest ignores layer manifests, which need encoding/json;
+// priorities come from WithPriority and PrioritizedFS only.
+func readLayerManifest(fs.FS) layerManifest { return layerManifest{} }
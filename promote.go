@@ -0,0 +1,149 @@
+package cfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// ErrUnknownLayer is returned when no layer of a composite carries the
+// requested name.
+var ErrUnknownLayer = errors.New("no layer with that name")
+
+// ErrLayerNotWritable is returned when a file is moved into or out of a
+// layer that does not implement WriteFS.
+var ErrLayerNotWritable = errors.New("layer is not writable")
+
+// Promote moves the winning version of the file name into the layer
+// named toLayer, for example to publish a draft override from a preview
+// layer into the persistent theme layer. The target layer must be
+// writable. The file is removed from the layer it came from when that
+// layer is writable, and copied otherwise. Promoting a file into the
+// layer that already serves it does nothing.
+func (cfs *CompositeFS) Promote(name string, toLayer string) error {
+	name, err := cfs.movePath("promote", name)
+	if err != nil {
+		return err
+	}
+
+	target := -1
+	for i, fsys := range cfs.filesystems {
+		if LayerName(fsys) == toLayer {
+			target = i
+			break
+		}
+	}
+	if target < 0 {
+		return &fs.PathError{Op: "promote", Path: name, Err: fmt.Errorf("%w: %q", ErrUnknownLayer, toLayer)}
+	}
+	if _, ok := layerWriter(cfs.filesystems[target]); !ok {
+		return &fs.PathError{Op: "promote", Path: name, Err: ErrLayerNotWritable}
+	}
+
+	source, info, err := cfs.resolve(name)
+	if err != nil {
+		return err
+	}
+	if source == target {
+		return nil
+	}
+	return cfs.moveFile("promote", name, info, source, target)
+}
+
+// Demote moves the winning version of the file name into the next
+// writable layer below the one serving it, in the probe order of name.
+// The layer serving the file must be writable.
+func (cfs *CompositeFS) Demote(name string) error {
+	name, err := cfs.movePath("demote", name)
+	if err != nil {
+		return err
+	}
+
+	source, info, err := cfs.resolve(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := layerWriter(cfs.filesystems[source]); !ok {
+		return &fs.PathError{Op: "demote", Path: name, Err: ErrLayerNotWritable}
+	}
+
+	order := cfs.probeOrder(name)
+	for n := probePosition(order, source) + 1; n < len(cfs.filesystems); n++ {
+		target := layerAt(order, n)
+		if _, ok := layerWriter(cfs.filesystems[target]); ok {
+			return cfs.moveFile("demote", name, info, source, target)
+		}
+	}
+	return &fs.PathError{Op: "demote", Path: name, Err: ErrLayerNotWritable}
+}
+
+func (cfs *CompositeFS) movePath(op, name string) (string, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	name, err := cfs.lookupName(op, name)
+	if err != nil {
+		return "", err
+	}
+	if !cfs.visible(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return name, nil
+}
+
+// moveFile writes the file name of layer source into layer target and
+// removes it from source when source is writable.
+func (cfs *CompositeFS) moveFile(op, name string, info fs.FileInfo, source, target int) error {
+	if info.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	from := cfs.filesystems[source]
+	to, _ := layerWriter(cfs.filesystems[target])
+
+	data, err := fs.ReadFile(from, name)
+	if err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: unwrapPathError(err)}
+	}
+
+	if cfs.writer != nil && (sameLayer(from, cfs.writer) || sameLayer(to, cfs.writer)) {
+		defer cfs.record(op, name)()
+	}
+	defer cfs.Invalidate(name)
+
+	if dir := path.Dir(name); dir != "." {
+		if err := to.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	if err := to.WriteFile(name, data, info.Mode().Perm()); err != nil {
+		return err
+	}
+	if meta, ok := to.(MetadataFS); ok && !info.ModTime().IsZero() {
+		if err := meta.Chtimes(name, info.ModTime(), info.ModTime()); err != nil {
+			return err
+		}
+	}
+
+	if w, ok := layerWriter(from); ok {
+		return w.Remove(name)
+	}
+	return nil
+}
+
+// layerWriter returns the WriteFS behind fsys, looking through named
+// layers. Nested composites are writable when they have a write layer.
+func layerWriter(fsys fs.FS) (WriteFS, bool) {
+	for {
+		switch v := fsys.(type) {
+		case *CompositeFS:
+			return v, v.Writable()
+		case WriteFS:
+			return v, true
+		case *Layer:
+			fsys = v.FS()
+		default:
+			return nil, false
+		}
+	}
+}
@@ -0,0 +1,84 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestPromoteAndDemote(t *testing.T) {
+	draft := cfs.NewMemLayer()
+	theme := cfs.NewMemLayer()
+	base := fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("base home")},
+	}
+	if err := draft.MkdirAll("views", 0o755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := draft.WriteFile("views/home.html", []byte("draft home"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	composite := cfs.NewCompositeFS(
+		cfs.NewLayer("draft", draft),
+		cfs.NewLayer("theme", theme),
+		cfs.NewLayer("base", base),
+	)
+
+	if err := composite.Promote("views/home.html", "theme"); err != nil {
+		t.Fatalf("Promote failed: %v", err)
+	}
+	testReadFile(t, theme, "views/home.html", "draft home")
+	testReadFile(t, composite, "views/home.html", "draft home")
+	if _, err := draft.Stat("views/home.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected file to be moved out of the draft layer, got %v", err)
+	}
+
+	// promoting into the serving layer is a no-op
+	if err := composite.Promote("views/home.html", "theme"); err != nil {
+		t.Fatalf("Promote failed: %v", err)
+	}
+
+	// back up into the draft layer, then down again
+	if err := composite.Promote("views/home.html", "draft"); err != nil {
+		t.Fatalf("Promote failed: %v", err)
+	}
+	testReadFile(t, draft, "views/home.html", "draft home")
+	if err := composite.Demote("views/home.html"); err != nil {
+		t.Fatalf("Demote failed: %v", err)
+	}
+	testReadFile(t, theme, "views/home.html", "draft home")
+
+	// the base layer below theme is read-only
+	if err := composite.Demote("views/home.html"); !errors.Is(err, cfs.ErrLayerNotWritable) {
+		t.Fatalf("Expected ErrLayerNotWritable, got %v", err)
+	}
+	if err := composite.Promote("views/home.html", "base"); !errors.Is(err, cfs.ErrLayerNotWritable) {
+		t.Fatalf("Expected ErrLayerNotWritable, got %v", err)
+	}
+	if err := composite.Promote("views/home.html", "missing"); !errors.Is(err, cfs.ErrUnknownLayer) {
+		t.Fatalf("Expected ErrUnknownLayer, got %v", err)
+	}
+	if err := composite.Promote("views/missing.html", "theme"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected ErrNotExist, got %v", err)
+	}
+}
+
+func TestPromoteCopiesFromReadOnlyLayer(t *testing.T) {
+	theme := cfs.NewMemLayer()
+	base := fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("base")},
+	}
+	composite := cfs.NewCompositeFS(cfs.NewLayer("theme", theme), cfs.NewLayer("base", base))
+
+	if err := composite.Promote("a.txt", "theme"); err != nil {
+		t.Fatalf("Promote failed: %v", err)
+	}
+	testReadFile(t, theme, "a.txt", "base")
+	testReadFile(t, base, "a.txt", "base")
+}
@@ -0,0 +1,89 @@
+package cfs
+
+import "sync"
+
+// WithReadCoalescing returns a copy of the composite that coalesces
+// concurrent ReadFile calls for the same path, such as every request
+// parsing the same template at cold start, into a single read of the
+// layers. Callers joining a read in flight receive their own copy of
+// the content, so the result stays theirs to modify. Writes through the
+// composite and Invalidate detach reads in flight, so later callers
+// start a fresh read. Copies of the composite coalesce separately.
+func (cfs *CompositeFS) WithReadCoalescing() *CompositeFS {
+	c := cfs.clone()
+	c.reads = newReadGroup()
+	return c
+}
+
+// readGroup tracks the reads in flight per path.
+type readGroup struct {
+	mu    sync.Mutex
+	calls map[string]*readCall
+}
+
+type readCall struct {
+	done   chan struct{}
+	data   []byte
+	err    error
+	shared bool // guarded by the group
+}
+
+func newReadGroup() *readGroup {
+	return &readGroup{calls: make(map[string]*readCall)}
+}
+
+// fresh returns an empty group, for copies of a composite.
+func (g *readGroup) fresh() *readGroup {
+	if g == nil {
+		return nil
+	}
+	return newReadGroup()
+}
+
+// do runs read for name unless a read of name is in flight, in which
+// case it waits for that read. Whenever a read is shared, every caller
+// gets its own copy of the content.
+func (g *readGroup) do(name string, read func(string) ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if call, ok := g.calls[name]; ok {
+		call.shared = true
+		g.mu.Unlock()
+		<-call.done
+		if call.err != nil {
+			return nil, call.err
+		}
+		return append([]byte(nil), call.data...), nil
+	}
+	call := &readCall{done: make(chan struct{})}
+	g.calls[name] = call
+	g.mu.Unlock()
+	defer close(call.done)
+
+	call.data, call.err = read(name)
+
+	g.mu.Lock()
+	if g.calls[name] == call {
+		delete(g.calls, name)
+	}
+	shared := call.shared
+	g.mu.Unlock()
+
+	if shared && call.err == nil {
+		return append([]byte(nil), call.data...), nil
+	}
+	return call.data, call.err
+}
+
+// forget detaches the reads in flight of names and their descendants.
+func (g *readGroup) forget(names ...string) {
+	if g == nil || len(names) == 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for name := range g.calls {
+		if matchesChanged(name, names) {
+			delete(g.calls, name)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package cfs_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+// gatedFS counts ReadFile calls and holds them until released.
+type gatedFS struct {
+	fstest.MapFS
+	reads   atomic.Int32
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (g *gatedFS) ReadFile(name string) ([]byte, error) {
+	g.reads.Add(1)
+	g.entered <- struct{}{}
+	<-g.release
+	return g.MapFS.ReadFile(name)
+}
+
+func TestWithReadCoalescing(t *testing.T) {
+	layer := &gatedFS{
+		MapFS:   fstest.MapFS{"views/home.html": &fstest.MapFile{Data: []byte("home")}},
+		entered: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+	composite := cfs.NewCompositeFS(layer).WithReadCoalescing()
+
+	const readers = 5
+	results := make([][]byte, readers)
+	var wg sync.WaitGroup
+	for i := range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := composite.ReadFile("views/home.html")
+			if err != nil {
+				t.Errorf("ReadFile failed: %v", err)
+			}
+			results[i] = data
+		}()
+	}
+	<-layer.entered
+	// give the other readers time to join the read in flight
+	time.Sleep(20 * time.Millisecond)
+	close(layer.release)
+	wg.Wait()
+
+	if got := layer.reads.Load(); got != 1 {
+		t.Fatalf("Expected one underlying read, got %d", got)
+	}
+	results[0][0] = 'H'
+	for i, data := range results[1:] {
+		if string(data) != "home" {
+			t.Fatalf("Expected reader %d to get its own copy, got %q", i+1, data)
+		}
+	}
+
+	// reads that do not overlap are not coalesced
+	if data, err := composite.ReadFile("views/home.html"); err != nil || string(data) != "home" {
+		t.Fatalf("ReadFile returned %q, %v", data, err)
+	}
+	if got := layer.reads.Load(); got != 2 {
+		t.Fatalf("Expected a fresh read, got %d reads", got)
+	}
+}
@@ -0,0 +1,71 @@
+package cfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+)
+
+// ReadFileRange reads up to n bytes of the winning version of name,
+// starting at offset off, without buffering the rest of the file. The
+// layer file is read with ReadAt when it implements io.ReaderAt, or
+// positioned with Seek, or read and discarded up to off otherwise.
+// Ranges reaching past the end of the file return the bytes up to the
+// end, and no error.
+func (cfs *CompositeFS) ReadFileRange(name string, off, n int64) ([]byte, error) {
+	if off < 0 || n < 0 {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+
+	file, err := cfs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+
+	var data []byte
+	switch f := file.(type) {
+	case io.ReaderAt:
+		// the size bounds the buffer, so huge ranges do not allocate
+		if rest := info.Size() - off; rest < n {
+			n = max(rest, 0)
+		}
+		if n == 0 {
+			return []byte{}, nil
+		}
+		data = make([]byte, n)
+		var read int
+		read, err = f.ReadAt(data, off)
+		data = data[:read]
+	case io.Seeker:
+		if off >= info.Size() {
+			return []byte{}, nil
+		}
+		if _, err = f.Seek(off, io.SeekStart); err == nil {
+			data, err = io.ReadAll(io.LimitReader(file, n))
+		}
+	default:
+		if _, err = io.CopyN(io.Discard, file, off); err == nil {
+			data, err = io.ReadAll(io.LimitReader(file, n))
+		}
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	if err != nil {
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) {
+			err = &fs.PathError{Op: "read", Path: name, Err: err}
+		}
+		return nil, err
+	}
+	return data, nil
+}
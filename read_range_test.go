@@ -0,0 +1,81 @@
+package cfs_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+// seekOnlyFS serves files that can seek but not ReadAt.
+type seekOnlyFS struct {
+	files fstest.MapFS
+}
+
+type seekOnlyFile struct {
+	fs.File
+}
+
+func (f seekOnlyFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+func (s seekOnlyFS) Open(name string) (fs.File, error) {
+	file, err := s.files.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return seekOnlyFile{file}, nil
+}
+
+func TestReadFileRange(t *testing.T) {
+	files := fstest.MapFS{"video.bin": &fstest.MapFile{Data: []byte("0123456789")}}
+
+	for name, layer := range map[string]fs.FS{
+		"readerat": files,
+		"seeker":   seekOnlyFS{files: files},
+		"stream":   openOnlyFS{files: files},
+	} {
+		t.Run(name, func(t *testing.T) {
+			composite := cfs.NewCompositeFS(fstest.MapFS{}, layer)
+
+			for _, tc := range []struct {
+				off, n int64
+				want   string
+			}{
+				{0, 4, "0123"},
+				{3, 4, "3456"},
+				{8, 100, "89"},
+				{10, 4, ""},
+				{20, 4, ""},
+			} {
+				data, err := composite.ReadFileRange("video.bin", tc.off, tc.n)
+				if err != nil {
+					t.Fatalf("ReadFileRange(%d, %d) failed: %v", tc.off, tc.n, err)
+				}
+				if string(data) != tc.want {
+					t.Errorf("ReadFileRange(%d, %d) = %q, want %q", tc.off, tc.n, data, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadFileRangeErrors(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("home")},
+	})
+
+	if _, err := composite.ReadFileRange("views/home.html", -1, 2); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Expected ErrInvalid for a negative offset, got %v", err)
+	}
+	if _, err := composite.ReadFileRange("views", 0, 2); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Expected ErrInvalid for a directory, got %v", err)
+	}
+	if _, err := composite.ReadFileRange("missing.html", 0, 2); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected ErrNotExist, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package cfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// DetailedEntry is a merged directory entry returned by ReadDirDetailed
+// together with its provenance.
+type DetailedEntry struct {
+	fs.DirEntry
+	// Layer is the index of the layer that provides the entry, or -1
+	// for virtual links.
+	Layer int
+	// LayerName is the name of that layer, if it is named.
+	LayerName string
+	// Shadows lists the indices of lower layers that also contain an
+	// entry of the same name and are hidden by Layer.
+	Shadows []int
+	// TypeConflict reports whether any of the shadowed entries differs
+	// in type, a file hiding a directory or the other way around.
+	TypeConflict bool
+}
+
+// Shadowing reports whether the entry overrides lower layers.
+func (e DetailedEntry) Shadowing() bool {
+	return len(e.Shadows) > 0
+}
+
+// ReadDirDetailed returns the merged contents of the named directory
+// like ReadDir, sorted by name, recording for each entry which layer
+// provides it, which lower layers it shadows and whether their types
+// conflict, e.g. to render override badges in admin UIs.
+func (cfs *CompositeFS) ReadDirDetailed(name string) ([]DetailedEntry, error) {
+	name, err := cfs.lookupName("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfs.visible(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	if err := cfs.checkDepth("readdir", name); err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*DetailedEntry)
+	var foundAny bool
+	var errs []error
+	allNotExist := true
+
+	order := cfs.probeOrder(name)
+	for n := range cfs.filesystems {
+		i := layerAt(order, n)
+		fsys := cfs.filesystems[i]
+		if skipLayer(fsys, name) {
+			continue
+		}
+
+		entries, err := ReadDir(fsys, name)
+		if err == nil {
+			foundAny = true
+			allNotExist = false
+			for _, entry := range entries {
+				if e, ok := byName[entry.Name()]; ok {
+					e.Shadows = append(e.Shadows, i)
+					if entry.IsDir() != e.IsDir() {
+						e.TypeConflict = true
+					}
+					continue
+				}
+				byName[entry.Name()] = &DetailedEntry{
+					DirEntry:  cfs.mergeEntry(entry),
+					Layer:     i,
+					LayerName: LayerName(fsys),
+				}
+			}
+			if err := cfs.checkEntries("readdir", name, len(byName)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("filesystem %d: %w", i, err))
+			continue
+		}
+
+		allNotExist = false
+		wrapped := fmt.Errorf("filesystem %d: %w", i, err)
+		if cfs.stopOn(i, name, err) {
+			return nil, wrapped
+		}
+		errs = append(errs, wrapped)
+	}
+
+	if !foundAny {
+		return nil, notFoundError("directory", name, errs, allNotExist)
+	}
+
+	// virtual links shadow every layer entry of the same name
+	for _, entry := range cfs.linkEntries(name) {
+		link := &DetailedEntry{DirEntry: entry, Layer: -1}
+		if e, ok := byName[entry.Name()]; ok {
+			link.Shadows = append([]int{e.Layer}, e.Shadows...)
+		}
+		byName[entry.Name()] = link
+	}
+
+	out := make([]DetailedEntry, 0, len(byName))
+	for entryName, e := range byName {
+		if cfs.visible(path.Join(name, entryName)) {
+			out = append(out, *e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name() < out[j].Name()
+	})
+	return out, nil
+}
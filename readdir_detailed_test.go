@@ -0,0 +1,100 @@
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+type entrySummary struct {
+	Name         string
+	Layer        int
+	LayerName    string
+	Shadows      []int
+	TypeConflict bool
+}
+
+func summarize(entries []cfs.DetailedEntry) []entrySummary {
+	out := make([]entrySummary, len(entries))
+	for i, e := range entries {
+		out[i] = entrySummary{e.Name(), e.Layer, e.LayerName, e.Shadows, e.TypeConflict}
+	}
+	return out
+}
+
+func TestReadDirDetailedReportsOverrides(t *testing.T) {
+	theme := fstest.MapFS{
+		"views/home.html":     &fstest.MapFile{Data: []byte("theme home")},
+		"views/partials":      &fstest.MapFile{Data: []byte("not a dir")},
+		"views/layout.html":   &fstest.MapFile{Data: []byte("theme layout")},
+		"views/.theme.config": &fstest.MapFile{Data: []byte("hidden")},
+	}
+	base := fstest.MapFS{
+		"views/home.html":         &fstest.MapFile{Data: []byte("base home")},
+		"views/partials/nav.html": &fstest.MapFile{Data: []byte("nav")},
+		"views/contact.html":      &fstest.MapFile{Data: []byte("contact")},
+	}
+	fallback := fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("fallback home")},
+	}
+	composite := cfs.NewCompositeFS(cfs.NewLayer("theme", theme), cfs.NewLayer("base", base), fallback).
+		WithHideDotfiles()
+
+	entries, err := composite.ReadDirDetailed("views")
+	if err != nil {
+		t.Fatalf("ReadDirDetailed failed: %v", err)
+	}
+
+	expected := []entrySummary{
+		{Name: "contact.html", Layer: 1, LayerName: "base"},
+		{Name: "home.html", Layer: 0, LayerName: "theme", Shadows: []int{1, 2}},
+		{Name: "layout.html", Layer: 0, LayerName: "theme"},
+		{Name: "partials", Layer: 0, LayerName: "theme", Shadows: []int{1}, TypeConflict: true},
+	}
+	if got := summarize(entries); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, got)
+	}
+	if !entries[1].Shadowing() || entries[0].Shadowing() {
+		t.Fatal("Expected only home.html to shadow a lower layer")
+	}
+	if entries[3].IsDir() {
+		t.Fatal("Expected the winning partials entry to be a file")
+	}
+}
+
+func TestReadDirDetailedLinks(t *testing.T) {
+	base := fstest.MapFS{
+		"docs/latest":  &fstest.MapFile{Data: []byte("stale")},
+		"docs/v2/a.md": &fstest.MapFile{Data: []byte("a")},
+	}
+	composite := cfs.NewCompositeFS(base)
+	if err := composite.Link("docs/latest", "docs/v2"); err != nil {
+		t.Fatalf("Link failed: %v", err)
+	}
+
+	entries, err := composite.ReadDirDetailed("docs")
+	if err != nil {
+		t.Fatalf("ReadDirDetailed failed: %v", err)
+	}
+	expected := []entrySummary{
+		{Name: "latest", Layer: -1, Shadows: []int{0}},
+		{Name: "v2", Layer: 0},
+	}
+	if got := summarize(entries); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, got)
+	}
+	if entries[0].Type()&fs.ModeSymlink == 0 {
+		t.Fatalf("Expected a symlink entry, got %v", entries[0].Type())
+	}
+}
+
+func TestReadDirDetailedMissing(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{})
+	if _, err := composite.ReadDirDetailed("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected fs.ErrNotExist, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package cfs
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OnReload calls fn with the paths changed below dir once no further
+// change has been seen for debounce, so the bursts of events produced
+// by editors saving a file are coalesced into a single callback. Use
+// "." for the whole composite. The returned function stops the
+// notifications; a pending callback is dropped.
+func (w *Watcher) OnReload(dir string, debounce time.Duration, fn func(changed []string)) (cancel func()) {
+	dir = path.Clean(dir)
+	prefix := dir + "/"
+
+	var (
+		mu      sync.Mutex
+		pending = make(map[string]struct{})
+		timer   *time.Timer
+		stopped bool
+	)
+
+	flush := func() {
+		mu.Lock()
+		if stopped || len(pending) == 0 {
+			mu.Unlock()
+			return
+		}
+		changed := make([]string, 0, len(pending))
+		for name := range pending {
+			changed = append(changed, name)
+		}
+		clear(pending)
+		mu.Unlock()
+
+		sort.Strings(changed)
+		fn(changed)
+	}
+
+	unsubscribe := w.Subscribe(func(changes []Change) {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			return
+		}
+
+		matched := false
+		for _, c := range changes {
+			if dir == "." || c.Path == dir || strings.HasPrefix(c.Path, prefix) {
+				pending[c.Path] = struct{}{}
+				matched = true
+			}
+		}
+		if !matched {
+			return
+		}
+		if timer == nil {
+			timer = time.AfterFunc(debounce, flush)
+		} else {
+			timer.Reset(debounce)
+		}
+	})
+
+	return func() {
+		unsubscribe()
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
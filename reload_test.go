@@ -0,0 +1,68 @@
+package cfs_test
+
+import (
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestOnReloadCoalescesBursts(t *testing.T) {
+	lower := fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("home")},
+	}
+	watcher := cfs.NewCompositeFS(lower).Watch(0)
+	t.Cleanup(func() { watcher.Close() })
+
+	reloads := make(chan []string, 4)
+	cancel := watcher.OnReload("views", 50*time.Millisecond, func(changed []string) {
+		reloads <- changed
+	})
+	t.Cleanup(cancel)
+
+	// an editor saving twice, plus a change outside the watched directory
+	lower["views/home.html"] = &fstest.MapFile{Data: []byte("home v2")}
+	watcher.Poll()
+	lower["views/about.html"] = &fstest.MapFile{Data: []byte("about")}
+	lower["assets/app.css"] = &fstest.MapFile{Data: []byte("css")}
+	watcher.Poll()
+
+	select {
+	case changed := <-reloads:
+		want := []string{"views/about.html", "views/home.html"}
+		if !slices.Equal(changed, want) {
+			t.Fatalf("Expected %v, got %v", want, changed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a reload callback")
+	}
+
+	select {
+	case changed := <-reloads:
+		t.Fatalf("Expected a single callback, got another with %v", changed)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestOnReloadCancelDropsPending(t *testing.T) {
+	lower := fstest.MapFS{}
+	watcher := cfs.NewCompositeFS(lower).Watch(0)
+	t.Cleanup(func() { watcher.Close() })
+
+	reloads := make(chan []string, 1)
+	cancel := watcher.OnReload(".", 20*time.Millisecond, func(changed []string) {
+		reloads <- changed
+	})
+
+	lower["index.html"] = &fstest.MapFile{Data: []byte("index")}
+	watcher.Poll()
+	cancel()
+
+	select {
+	case changed := <-reloads:
+		t.Fatalf("Expected no callback after cancel, got %v", changed)
+	case <-time.After(60 * time.Millisecond):
+	}
+}
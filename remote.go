@@ -0,0 +1,231 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// remoteInfo is the wire form of an fs.FileInfo.
+type remoteInfo struct {
+	Name    string      `json:"name"`
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mod_time"`
+}
+
+func newRemoteInfo(info fs.FileInfo) remoteInfo {
+	return remoteInfo{Name: info.Name(), Size: info.Size(), Mode: info.Mode(), ModTime: info.ModTime()}
+}
+
+func (r remoteInfo) info() fs.FileInfo {
+	return memInfo{name: r.Name, size: r.Size, mode: r.Mode, modTime: r.ModTime}
+}
+
+// NewRemoteHandler returns an http.Handler exposing the read operations
+// of fsys, typically a composite, so other services can mount it as a
+// layer with NewRemoteFS. Requests carry the operation and path in the
+// query: op=stat and op=readdir answer with JSON, op=read with the file
+// content. Errors are reported with a status code that NewRemoteFS maps
+// back to fs.ErrNotExist, fs.ErrPermission or fs.ErrInvalid.
+func NewRemoteHandler(fsys fs.FS) http.Handler {
+	return &remoteHandler{fsys: fsys}
+}
+
+type remoteHandler struct {
+	fsys fs.FS
+}
+
+func (h *remoteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	name := query.Get("name")
+	// layers are not required to reject paths outside of io/fs rules
+	if !fs.ValidPath(name) {
+		http.Error(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+	switch query.Get("op") {
+	case "stat":
+		info, err := statLayer(h.fsys, name)
+		if err != nil {
+			remoteError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newRemoteInfo(info))
+	case "readdir":
+		entries, err := ReadDir(h.fsys, name)
+		if err != nil {
+			remoteError(w, err)
+			return
+		}
+		infos := make([]remoteInfo, 0, len(entries))
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil {
+				remoteError(w, err)
+				return
+			}
+			infos = append(infos, newRemoteInfo(info))
+		}
+		sort.Slice(infos, func(i, j int) bool {
+			return infos[i].Name < infos[j].Name
+		})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(infos)
+	case "read":
+		data, err := fs.ReadFile(h.fsys, name)
+		if err != nil {
+			remoteError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(data)
+	default:
+		http.Error(w, "unknown operation", http.StatusBadRequest)
+	}
+}
+
+func remoteError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		status = http.StatusNotFound
+	case errors.Is(err, fs.ErrPermission):
+		status = http.StatusForbidden
+	case errors.Is(err, fs.ErrInvalid):
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// NewRemoteFS returns a read-only layer backed by a NewRemoteHandler
+// served at baseURL. A nil client uses http.DefaultClient. Every call
+// is a round trip, so remote layers are usually wrapped in a DiskCache
+// or combined with WithStatCache.
+func NewRemoteFS(baseURL string, client *http.Client) fs.FS {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &remoteFS{base: baseURL, client: client}
+}
+
+type remoteFS struct {
+	base   string
+	client *http.Client
+}
+
+func (f *remoteFS) Open(name string) (fs.File, error) {
+	info, err := f.Stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.Unwrap(err)}
+	}
+	if info.IsDir() {
+		entries, err := f.ReadDir(name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: errors.Unwrap(err)}
+		}
+		return &overlayDirFile{name: name, info: info, entries: entries}, nil
+	}
+	data, err := f.ReadFile(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.Unwrap(err)}
+	}
+	return &memFile{name: name, info: info, data: data}, nil
+}
+
+func (f *remoteFS) Stat(name string) (fs.FileInfo, error) {
+	var info remoteInfo
+	if err := f.getJSON("stat", name, &info); err != nil {
+		return nil, err
+	}
+	return info.info(), nil
+}
+
+func (f *remoteFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	var infos []remoteInfo
+	if err := f.getJSON("readdir", name, &infos); err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info.info())
+	}
+	return entries, nil
+}
+
+func (f *remoteFS) ReadFile(name string) ([]byte, error) {
+	body, err := f.get("read", name)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return data, nil
+}
+
+// Sub returns the layer rooted at dir, served by the same handler.
+func (f *remoteFS) Sub(dir string) (fs.FS, error) {
+	return subLayer(f, dir)
+}
+
+func (f *remoteFS) getJSON(op, name string, v any) error {
+	body, err := f.get(op, name)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return nil
+}
+
+// get performs a remote operation, returning the response body or the
+// error reported by the server.
+func (f *remoteFS) get(op, name string) (io.ReadCloser, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	u := f.base + "?" + url.Values{"op": {op}, "name": {name}}.Encode()
+	resp, err := f.client.Get(u)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp.Body, nil
+	}
+	defer resp.Body.Close()
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	var remoteErr error
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		remoteErr = fs.ErrNotExist
+	case http.StatusForbidden:
+		remoteErr = fs.ErrPermission
+	case http.StatusBadRequest:
+		remoteErr = fs.ErrInvalid
+	default:
+		remoteErr = fmt.Errorf("remote: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil, &fs.PathError{Op: op, Path: name, Err: remoteErr}
+}
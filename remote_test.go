@@ -0,0 +1,96 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestRemoteFSMountsComposite(t *testing.T) {
+	theme := fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("theme home")},
+	}
+	base := fstest.MapFS{
+		"views/home.html":  &fstest.MapFile{Data: []byte("base home")},
+		"views/about.html": &fstest.MapFile{Data: []byte("base about"), Mode: 0o640},
+		"assets/app.css":   &fstest.MapFile{Data: []byte("css")},
+	}
+	server := httptest.NewServer(cfs.NewRemoteHandler(cfs.NewCompositeFS(theme, base)))
+	t.Cleanup(server.Close)
+
+	remote := cfs.NewRemoteFS(server.URL, server.Client())
+	if err := fstest.TestFS(remote, "views/home.html", "views/about.html", "assets/app.css"); err != nil {
+		t.Fatalf("TestFS failed: %v", err)
+	}
+
+	// the remote composite is usable as a layer of a local one
+	local := cfs.NewCompositeFS(fstest.MapFS{
+		"assets/app.css": &fstest.MapFile{Data: []byte("local css")},
+	}, remote)
+	testReadFile(t, local, "views/home.html", "theme home")
+	testReadFile(t, local, "assets/app.css", "local css")
+
+	info, err := fs.Stat(remote, "views/about.html")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0o640 || info.Size() != int64(len("base about")) {
+		t.Fatalf("Expected remote metadata, got mode %v size %d", info.Mode(), info.Size())
+	}
+
+	if _, err := fs.ReadFile(remote, "views/missing.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected fs.ErrNotExist, got %v", err)
+	}
+	if _, err := fs.Stat(remote, "../secret"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("Expected fs.ErrInvalid, got %v", err)
+	}
+}
+
+func TestRemoteFSMapsPermissionErrors(t *testing.T) {
+	server := httptest.NewServer(cfs.NewRemoteHandler(permissionFS{}))
+	t.Cleanup(server.Close)
+
+	remote := cfs.NewRemoteFS(server.URL, server.Client())
+	if _, err := fs.ReadFile(remote, "secret.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("Expected fs.ErrPermission, got %v", err)
+	}
+}
+
+func TestRemoteFSSub(t *testing.T) {
+	server := httptest.NewServer(cfs.NewRemoteHandler(fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("home")},
+	}))
+	t.Cleanup(server.Close)
+
+	composite := cfs.NewCompositeFS(cfs.NewRemoteFS(server.URL, server.Client()))
+	sub, err := composite.Sub("views")
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	testReadFile(t, sub, "home.html", "home")
+	if _, err := fs.Stat(sub, "missing.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestRemoteHandlerRejectsInvalidPaths(t *testing.T) {
+	handler := cfs.NewRemoteHandler(permissiveFS{fstest.MapFS{
+		"secret.txt": &fstest.MapFile{Data: []byte("secret")},
+	}})
+
+	for _, name := range []string{"../x", "/etc/passwd"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?op=read&name="+url.QueryEscape(name), nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("read %q: expected status 400, got %d", name, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package cfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// RequireError is returned by Require and RequireGlob when required
+// content is absent. It matches fs.ErrNotExist with errors.Is when
+// anything is missing.
+type RequireError struct {
+	// Missing lists the paths or patterns that resolved to nothing, in
+	// the order they were given.
+	Missing []string
+	// Errors holds the other failures, such as permission errors or
+	// malformed patterns, keyed by path or pattern.
+	Errors map[string]error
+}
+
+func (e *RequireError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("missing %s", strings.Join(e.Missing, ", ")))
+	}
+	keys := make([]string, 0, len(e.Errors))
+	for key := range e.Errors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s: %v", key, e.Errors[key]))
+	}
+	return "required content unavailable: " + strings.Join(parts, "; ")
+}
+
+// Unwrap returns one error per missing path and failure.
+func (e *RequireError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Missing)+len(e.Errors))
+	for _, name := range e.Missing {
+		errs = append(errs, &fs.PathError{Op: "require", Path: name, Err: fs.ErrNotExist})
+	}
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func (e *RequireError) add(name string, err error) {
+	if errors.Is(err, fs.ErrNotExist) {
+		e.Missing = append(e.Missing, name)
+		return
+	}
+	if e.Errors == nil {
+		e.Errors = make(map[string]error)
+	}
+	e.Errors[name] = err
+}
+
+func (e *RequireError) orNil() error {
+	if len(e.Missing) == 0 && len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Require verifies that every path resolves in the composite, as a file
+// or a directory. It is meant to run at startup, so missing templates
+// fail fast instead of at first render. All paths are checked, and the
+// problems are reported together as a *RequireError.
+func (cfs *CompositeFS) Require(paths ...string) error {
+	_, errs := cfs.StatAll(paths)
+	report := &RequireError{}
+	for _, name := range paths {
+		if err, ok := errs[name]; ok {
+			report.add(name, err)
+			// report duplicates once
+			delete(errs, name)
+		}
+	}
+	return report.orNil()
+}
+
+// RequireGlob verifies that every pattern, in fs.Glob syntax, matches at
+// least one path of the composite. Problems are reported together as a
+// *RequireError.
+func (cfs *CompositeFS) RequireGlob(patterns ...string) error {
+	report := &RequireError{}
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(cfs, pattern)
+		switch {
+		case err != nil:
+			report.add(pattern, err)
+		case len(matches) == 0:
+			report.add(pattern, fs.ErrNotExist)
+		}
+	}
+	return report.orNil()
+}
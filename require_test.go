@@ -0,0 +1,71 @@
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestRequire(t *testing.T) {
+	composite := cfs.NewCompositeFS(
+		fstest.MapFS{"views/home.html": &fstest.MapFile{Data: []byte("home")}},
+		fstest.MapFS{"views/layout.html": &fstest.MapFile{Data: []byte("layout")}},
+	)
+
+	if err := composite.Require("views/home.html", "views/layout.html", "views"); err != nil {
+		t.Fatalf("Expected all paths to resolve, got %v", err)
+	}
+
+	err := composite.Require("views/home.html", "views/about.html", "partials/nav.html", "views/about.html")
+	var report *cfs.RequireError
+	if !errors.As(err, &report) {
+		t.Fatalf("Expected a *RequireError, got %v", err)
+	}
+	if want := []string{"views/about.html", "partials/nav.html"}; !reflect.DeepEqual(report.Missing, want) {
+		t.Fatalf("Expected missing %v, got %v", want, report.Missing)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("Expected the error to match fs.ErrNotExist")
+	}
+}
+
+func TestRequireReportsOtherFailures(t *testing.T) {
+	composite := cfs.NewCompositeFS(permissionFS{})
+
+	err := composite.Require("secret.txt")
+	var report *cfs.RequireError
+	if !errors.As(err, &report) || len(report.Missing) != 0 || report.Errors["secret.txt"] == nil {
+		t.Fatalf("Expected a failure for secret.txt, got %v", err)
+	}
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("Expected the error to match fs.ErrPermission, got %v", err)
+	}
+}
+
+func TestRequireGlob(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{
+		"views/home.html":    &fstest.MapFile{Data: []byte("home")},
+		"assets/css/app.css": &fstest.MapFile{Data: []byte("css")},
+	})
+
+	if err := composite.RequireGlob("views/*.html", "assets/*/*.css"); err != nil {
+		t.Fatalf("Expected all patterns to match, got %v", err)
+	}
+
+	err := composite.RequireGlob("views/*.html", "emails/*.html", "views/[")
+	var report *cfs.RequireError
+	if !errors.As(err, &report) {
+		t.Fatalf("Expected a *RequireError, got %v", err)
+	}
+	if want := []string{"emails/*.html"}; !reflect.DeepEqual(report.Missing, want) {
+		t.Fatalf("Expected missing %v, got %v", want, report.Missing)
+	}
+	if !errors.Is(report.Errors["views/["], path.ErrBadPattern) {
+		t.Fatalf("Expected a bad pattern error, got %v", report.Errors)
+	}
+}
@@ -0,0 +1,60 @@
+package cfs
+
+import (
+	"io/fs"
+	"slices"
+)
+
+// Well-known layer roles. Any other string may be used as a role too.
+const (
+	// RoleDev marks volatile development layers. Their results are
+	// never cached.
+	RoleDev = "dev"
+	// RoleTheme marks theme layers.
+	RoleTheme = "theme"
+	// RoleBase marks the base content of an application.
+	RoleBase = "base"
+	// RoleCache marks layers holding cached copies of other content.
+	RoleCache = "cache"
+	// RoleWrite marks the layer that receives writes.
+	RoleWrite = "write"
+)
+
+// RoledFS is implemented by layers tagged with roles.
+type RoledFS interface {
+	fs.FS
+	Roles() []string
+}
+
+// WithRoles tags a layer with roles such as RoleDev or RoleTheme, so
+// features can target whole classes of layers.
+func WithRoles(roles ...string) LayerOption {
+	return func(l *Layer) {
+		l.roles = append(l.roles, roles...)
+	}
+}
+
+// Roles implements RoledFS.
+func (l *Layer) Roles() []string {
+	return slices.Clone(l.roles)
+}
+
+// HasRole reports whether fsys is tagged with role.
+func HasRole(fsys fs.FS, role string) bool {
+	fsys = unwrapLayer(fsys)
+	if r, ok := fsys.(RoledFS); ok {
+		return slices.Contains(r.Roles(), role)
+	}
+	return false
+}
+
+// LayersWithRole returns the layers tagged with role, in probe order.
+func (cfs *CompositeFS) LayersWithRole(role string) []fs.FS {
+	var out []fs.FS
+	for _, fsys := range cfs.filesystems {
+		if HasRole(fsys, role) {
+			out = append(out, fsys)
+		}
+	}
+	return out
+}
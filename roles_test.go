@@ -0,0 +1,61 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestLayersWithRole(t *testing.T) {
+	dev := cfs.NewLayer("dev", fstest.MapFS{}, cfs.WithRoles(cfs.RoleDev))
+	theme := cfs.NewLayer("theme", fstest.MapFS{}, cfs.WithRoles(cfs.RoleTheme, cfs.RoleBase))
+	base := cfs.NewLayer("base", fstest.MapFS{}, cfs.WithRoles(cfs.RoleBase))
+	composite := cfs.NewCompositeFS(dev, theme, fstest.MapFS{}, base)
+
+	layers := composite.LayersWithRole(cfs.RoleBase)
+	if len(layers) != 2 || cfs.LayerName(layers[0]) != "theme" || cfs.LayerName(layers[1]) != "base" {
+		t.Fatalf("Expected theme and base layers, got %v", layers)
+	}
+	if layers := composite.LayersWithRole(cfs.RoleCache); len(layers) != 0 {
+		t.Fatalf("Expected no cache layers, got %v", layers)
+	}
+}
+
+func TestDevRoleIsNeverCached(t *testing.T) {
+	dev := &countingFS{MapFS: fstest.MapFS{
+		"app.css": &fstest.MapFile{Data: []byte("css")},
+	}}
+	composite := cfs.NewCompositeFS(cfs.NewLayer("dev", dev, cfs.WithRoles(cfs.RoleDev))).
+		WithStatCache(time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if _, err := composite.Stat("app.css"); err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+	}
+	if calls := dev.calls.Load(); calls != 3 {
+		t.Fatalf("Expected every Stat to reach the dev layer, got %d calls", calls)
+	}
+}
+
+func TestVerifyRequireWriteRole(t *testing.T) {
+	policy := cfs.Policy{RequireWriteRole: true}
+
+	untagged := cfs.NewWritableFS(cfs.NewDirWriteFS(t.TempDir()), fstest.MapFS{})
+	err := untagged.Verify(policy)
+	var policyErr *cfs.PolicyError
+	if !errors.As(err, &policyErr) || policyErr.Layer != 0 {
+		t.Fatalf("Expected a policy error for layer 0, got %v", err)
+	}
+
+	writer := cfs.NewDirWriteFS(t.TempDir()).WithRoles(cfs.RoleWrite)
+	tagged := cfs.NewWritableFS(writer, fstest.MapFS{})
+	if err := tagged.Verify(policy); err != nil {
+		t.Fatalf("Expected tagged writer to pass, got %v", err)
+	}
+}
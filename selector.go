@@ -0,0 +1,88 @@
+package cfs
+
+import (
+	"context"
+	"io/fs"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// WithLayerSelector returns a copy of the composite consulting selector
+// on context-aware operations, such as ForContext, Prefetch and the
+// handler returned by NewListingHandler, to pick the layers active for
+// the context. A multi-tenant server can so enable the theme layers of
+// the current tenant from one shared composite. The selector returns
+// layer indexes as counted by LayerCount; a nil result keeps every
+// layer active.
+func (cfs *CompositeFS) WithLayerSelector(selector func(ctx context.Context) []int) *CompositeFS {
+	c := cfs.clone()
+	c.selector = selector
+	c.views = new(sync.Map)
+	return c
+}
+
+// ForContext returns the view of the composite for ctx, holding only the
+// layers picked by the layer selector, in their usual order. Without a
+// selector, or when it selects every layer, the composite itself is
+// returned. Views are cached per selection and report layer indexes
+// relative to their own layers. The write layer is only kept when it is
+// selected; otherwise the view is read-only. Layers created with
+// DynamicLayer are bound to ctx in a fresh copy of the view.
+func (cfs *CompositeFS) ForContext(ctx context.Context) *CompositeFS {
+	return cfs.selectView(ctx).bindContext(ctx)
+}
+
+// selectView returns the cached view holding the layers the selector
+// picks for ctx.
+func (cfs *CompositeFS) selectView(ctx context.Context) *CompositeFS {
+	if cfs.selector == nil {
+		return cfs
+	}
+	indexes := cfs.selector(ctx)
+	if indexes == nil {
+		return cfs
+	}
+
+	selected := make([]bool, len(cfs.filesystems))
+	for _, i := range indexes {
+		if i >= 0 && i < len(cfs.filesystems) {
+			selected[i] = true
+		}
+	}
+
+	// views are cached by their sorted selection
+	var key strings.Builder
+	count := 0
+	for i, ok := range selected {
+		if ok {
+			key.WriteString(strconv.Itoa(i))
+			key.WriteByte(',')
+			count++
+		}
+	}
+	if count == len(cfs.filesystems) {
+		return cfs
+	}
+	if view, ok := cfs.views.Load(key.String()); ok {
+		return view.(*CompositeFS)
+	}
+
+	view := cfs.clone()
+	view.selector = nil
+	view.views = nil
+	view.probeRules = nil
+	view.writer = nil
+	view.filesystems = make([]fs.FS, 0, count)
+	for i, fsys := range cfs.filesystems {
+		if !selected[i] {
+			continue
+		}
+		if cfs.writer != nil && sameLayer(fsys, cfs.writer) {
+			view.writer = cfs.writer
+		}
+		view.filesystems = append(view.filesystems, fsys)
+	}
+	actual, _ := cfs.views.LoadOrStore(key.String(), view)
+	return actual.(*CompositeFS)
+}
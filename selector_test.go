@@ -0,0 +1,91 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func newTenantStack() *cfs.CompositeFS {
+	acme := fstest.MapFS{"views/home.html": &fstest.MapFile{Data: []byte("acme home")}}
+	globex := fstest.MapFS{"views/home.html": &fstest.MapFile{Data: []byte("globex home")}}
+	base := fstest.MapFS{
+		"views/home.html":  &fstest.MapFile{Data: []byte("base home")},
+		"views/about.html": &fstest.MapFile{Data: []byte("base about")},
+	}
+	themes := map[string][]int{"acme": {0, 2}, "globex": {1, 2}}
+
+	return cfs.NewCompositeFS(
+		cfs.NewLayer("acme", acme),
+		cfs.NewLayer("globex", globex),
+		cfs.NewLayer("base", base),
+	).WithLayerSelector(func(ctx context.Context) []int {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		return themes[tenant]
+	})
+}
+
+func TestWithLayerSelector(t *testing.T) {
+	composite := newTenantStack()
+	acme := context.WithValue(context.Background(), tenantKey{}, "acme")
+	globex := context.WithValue(context.Background(), tenantKey{}, "globex")
+
+	testReadFile(t, composite.ForContext(acme), "views/home.html", "acme home")
+	testReadFile(t, composite.ForContext(globex), "views/home.html", "globex home")
+	testReadFile(t, composite.ForContext(globex), "views/about.html", "base about")
+
+	// contexts without a selection see every layer
+	if composite.ForContext(context.Background()) != composite {
+		t.Error("Expected the composite itself without a selection")
+	}
+	if composite.ForContext(acme) != composite.ForContext(acme) {
+		t.Error("Expected views to be cached per selection")
+	}
+	if n := composite.ForContext(acme).LayerCount(); n != 2 {
+		t.Errorf("Expected 2 active layers, got %d", n)
+	}
+
+	// deselected layers are not visible
+	m, err := composite.ForContext(globex).Which("views/home.html")
+	if err != nil {
+		t.Fatalf("Which failed: %v", err)
+	}
+	if m.LayerName != "globex" || len(m.Shadows) != 1 {
+		t.Errorf("Expected globex shadowing base only, got %+v", m)
+	}
+}
+
+func TestWithLayerSelectorPrefetch(t *testing.T) {
+	composite := cfs.NewCompositeFS(
+		fstest.MapFS{"views/home.html": &fstest.MapFile{Data: []byte("home")}},
+		errFS{err: errors.New("unavailable")},
+	).WithLayerSelector(func(context.Context) []int { return []int{0} })
+
+	if err := composite.Prefetch(context.Background(), "views/*.html"); err != nil {
+		t.Fatalf("Expected the failing layer to be deselected, got %v", err)
+	}
+	if _, err := composite.ReadFile("views/missing.html"); errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected the failing layer to be probed without a context, got %v", err)
+	}
+}
+
+func TestListingHandlerUsesLayerSelector(t *testing.T) {
+	handler := cfs.NewListingHandler(newTenantStack())
+
+	req := httptest.NewRequest("GET", "/views/home.html", nil)
+	req = req.WithContext(context.WithValue(req.Context(), tenantKey{}, "globex"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if body := rec.Body.String(); !strings.Contains(body, "globex home") {
+		t.Errorf("Expected the globex home page, got %q", body)
+	}
+}
@@ -0,0 +1,168 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// SidecarExt is the extension of metadata sidecar files: the metadata
+// of "views/home.html" lives in "views/home.html.meta".
+const SidecarExt = ".meta"
+
+// WithMetaSidecars returns a copy of the composite that hides metadata
+// sidecar files from Open, Stat, ReadFile, ReadDir and Glob, so they
+// annotate the files they belong to without being served themselves.
+// SidecarMeta reads them either way.
+func (cfs *CompositeFS) WithMetaSidecars() *CompositeFS {
+	return cfs.WithDenied("*" + SidecarExt)
+}
+
+// SidecarMeta returns the metadata attached to the named file by
+// sidecar files, such as titles, content types or cache hints shipped
+// by a theme. Every layer may provide a sidecar for the file, whether or
+// not it provides the file itself; their top-level keys are merged, the
+// layers probed first winning, and nested objects are merged the same
+// way. Files without sidecars return an empty map. Meta, in contrast,
+// returns the front matter inside the file.
+//
+// Sidecars hold a JSON object, or a YAML mapping of keys to scalar
+// values, one "key: value" per line. Nested YAML is not supported;
+// write such sidecars as JSON. Numbers decode as float64 in both
+// formats, as with encoding/json. Malformed sidecars return an error
+// naming the sidecar.
+func (cfs *CompositeFS) SidecarMeta(name string) (map[string]any, error) {
+	if err := cfs.life.enter("meta", name); err != nil {
+		return nil, err
+	}
+	defer cfs.life.leave()
+
+	name, err := cfs.lookupName("meta", name)
+	if err != nil {
+		return nil, err
+	}
+	if !cfs.visible(name) {
+		return nil, &fs.PathError{Op: "meta", Path: name, Err: fs.ErrNotExist}
+	}
+	if _, _, err := cfs.resolve(name); err != nil {
+		return nil, err
+	}
+	return cfs.sidecarMeta(name)
+}
+
+// sidecarMeta merges the sidecars of name from the bottom layer up.
+func (cfs *CompositeFS) sidecarMeta(name string) (map[string]any, error) {
+	sidecar := name + SidecarExt
+	meta := make(map[string]any)
+	order := cfs.probeOrder(sidecar)
+	for n := len(cfs.filesystems) - 1; n >= 0; n-- {
+		i := layerAt(order, n)
+		fsys := cfs.filesystems[i]
+		if skipLayer(fsys, sidecar) {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, sidecar)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("filesystem %d: %w", i, err)
+		}
+		layer, err := parseSidecar(data)
+		if err != nil {
+			return nil, &fs.PathError{Op: "meta", Path: sidecar, Err: err}
+		}
+		mergeMeta(meta, layer)
+	}
+	return meta, nil
+}
+
+// mergeMeta copies the keys of src into dst, merging nested objects.
+func mergeMeta(dst, src map[string]any) {
+	for key, value := range src {
+		inner, ok := value.(map[string]any)
+		if existing, isMap := dst[key].(map[string]any); ok && isMap {
+			mergeMeta(existing, inner)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// parseSidecar decodes a JSON object or a flat YAML mapping.
+func parseSidecar(data []byte) (map[string]any, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return map[string]any{}, nil
+	}
+	if trimmed[0] == '{' {
+		var meta map[string]any
+		if err := json.Unmarshal(trimmed, &meta); err != nil {
+			return nil, err
+		}
+		return meta, nil
+	}
+	return parseFlatYAML(trimmed)
+}
+
+// parseFlatYAML decodes "key: value" lines with scalar values. Blank
+// lines, comments and a leading document marker are skipped.
+func parseFlatYAML(data []byte) (map[string]any, error) {
+	meta := make(map[string]any)
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || (n == 0 && trimmed == "---") {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			return nil, fmt.Errorf("line %d: nested YAML is not supported", n+1)
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found || key == "" {
+			return nil, fmt.Errorf("line %d: expected key: value", n+1)
+		}
+		scalar, err := yamlScalar(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		meta[strings.TrimSpace(key)] = scalar
+	}
+	return meta, nil
+}
+
+// yamlScalar decodes a plain, single-quoted or double-quoted scalar.
+func yamlScalar(value string) (any, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		return strconv.Unquote(value)
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return nil, errors.New("unterminated quoted value")
+		}
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	case value == "" || value == "~" || value == "null":
+		return nil, nil
+	case value == "true":
+		return true, nil
+	case value == "false":
+		return false, nil
+	case value == "|" || value == ">" || strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{"):
+		return nil, errors.New("nested YAML is not supported")
+	}
+	if comment := strings.Index(value, " #"); comment >= 0 {
+		value = strings.TrimSpace(value[:comment])
+	}
+	if strings.IndexByte("+-.0123456789", value[0]) >= 0 {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f, nil
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,77 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestSidecarMeta(t *testing.T) {
+	theme := fstest.MapFS{
+		"views/home.html.meta": &fstest.MapFile{Data: []byte(`{"title": "Welcome", "cache": {"max_age": 60}}`)},
+	}
+	base := fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("home")},
+		"views/home.html.meta": &fstest.MapFile{Data: []byte(`---
+# shipped with the base theme
+title: Home
+content_type: "text/html; charset=utf-8"
+weight: 3
+draft: false
+`)},
+		"views/about.html": &fstest.MapFile{Data: []byte("about")},
+	}
+	composite := cfs.NewCompositeFS(theme, base)
+
+	meta, err := composite.SidecarMeta("views/home.html")
+	if err != nil {
+		t.Fatalf("SidecarMeta failed: %v", err)
+	}
+	want := map[string]any{
+		"title":        "Welcome",
+		"content_type": "text/html; charset=utf-8",
+		"weight":       float64(3),
+		"draft":        false,
+		"cache":        map[string]any{"max_age": float64(60)},
+	}
+	if !reflect.DeepEqual(meta, want) {
+		t.Fatalf("Expected %v, got %v", want, meta)
+	}
+
+	if meta, err := composite.SidecarMeta("views/about.html"); err != nil || len(meta) != 0 {
+		t.Fatalf("Expected no metadata, got %v, %v", meta, err)
+	}
+	if _, err := composite.SidecarMeta("views/missing.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected ErrNotExist, got %v", err)
+	}
+
+	hidden := composite.WithMetaSidecars()
+	if _, err := hidden.Stat("views/home.html.meta"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected the sidecar to be hidden, got %v", err)
+	}
+	entries, err := hidden.ReadDir("views")
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Expected the two views only, got %v, %v", entries, err)
+	}
+	if meta, err := hidden.SidecarMeta("views/home.html"); err != nil || meta["title"] != "Welcome" {
+		t.Fatalf("Expected hidden sidecars to be read, got %v, %v", meta, err)
+	}
+}
+
+func TestSidecarMetaRejectsNestedYAML(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{
+		"a.txt":      &fstest.MapFile{Data: []byte("a")},
+		"a.txt.meta": &fstest.MapFile{Data: []byte("cache:\n  max_age: 60\n")},
+	})
+	_, err := composite.SidecarMeta("a.txt")
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) || pathErr.Path != "a.txt.meta" {
+		t.Fatalf("Expected an error naming the sidecar, got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package cfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/fs"
+	"reflect"
+	"sort"
+)
+
+// StackHash returns a stable hash of the composite's structure: the
+// options it was created with and, for each layer, its position, type,
+// name and layer options. Nested composites and named layers are hashed
+// recursively. Hooks, such as a layer selector or a copy-up hook, are
+// hashed by their presence only, as functions have no stable identity.
+// Content is not hashed, so the value only changes when the stack
+// composition changes.
+func (cfs *CompositeFS) StackHash() string {
+	h := sha256.New()
+	cfs.writeStack(h)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeStack hashes the options and layers of cfs. Options that change
+// what the composite serves belong here as well as in canInline.
+func (cfs *CompositeFS) writeStack(h hash.Hash) {
+	fmt.Fprintf(h, "composite bestEffort=%t mergeDirs=%t writable=%t layers=%d\n",
+		cfs.bestEffort, cfs.mergeDirs, cfs.writer != nil, len(cfs.filesystems))
+	fmt.Fprintf(h, "hideDotfiles=%t denied=%q root=%q\n",
+		cfs.visibility.hideDotfiles, cfs.visibility.denied, cfs.visibility.root)
+	fmt.Fprintf(h, "limits=%d/%d\n", cfs.limits.MaxDirEntries, cfs.limits.MaxDepth)
+	if cfs.byPriority {
+		fmt.Fprintf(h, "byPriority=true\n")
+	}
+	if cfs.jailOS {
+		fmt.Fprintf(h, "jailOS=true\n")
+	}
+	if cfs.archives {
+		fmt.Fprintf(h, "archives=true\n")
+	}
+	if cfs.eagerInfo {
+		fmt.Fprintf(h, "eagerInfo=true\n")
+	}
+	if cfs.unsorted {
+		fmt.Fprintf(h, "unsorted=true\n")
+	}
+	if len(cfs.transforms) > 0 {
+		fmt.Fprintf(h, "transforms=%q\n", cfs.transformList())
+	}
+	if len(cfs.frontMatter) > 0 {
+		fmt.Fprintf(h, "frontMatter=%q\n", cfs.frontMatter)
+	}
+	if cfs.permissions.Mode != PermissionDefault {
+		fmt.Fprintf(h, "permissions=%d\n", cfs.permissions.Mode)
+	}
+	if len(cfs.probeRules) > 0 {
+		fmt.Fprintf(h, "probe=%s\n", cfs.probeKey())
+	}
+	if cfs.dirMode != 0 {
+		fmt.Fprintf(h, "dirMode=%v\n", cfs.dirMode)
+	}
+	if cfs.budget != nil {
+		fmt.Fprintf(h, "budget=%s\n", cfs.budget.key())
+	}
+	if len(cfs.aliases) > 0 {
+		fmt.Fprintf(h, "aliases=%q\n", cfs.aliasList())
+	}
+	if links := cfs.links.list(); len(links) > 0 {
+		fmt.Fprintf(h, "links=%q\n", links)
+	}
+	if cfs.statCache != nil {
+		fmt.Fprintf(h, "statCache=%s\n", cfs.statCache.ttl)
+	}
+	if cfs.content != nil {
+		fmt.Fprintf(h, "contentCache=%d/%d\n", cfs.content.maxBytes, cfs.content.maxFileSize)
+	}
+	if cfs.attrs != nil {
+		fmt.Fprintf(h, "attrIndex=true\n")
+	}
+	if cfs.reads != nil {
+		fmt.Fprintf(h, "coalesceReads=true\n")
+	}
+	if cfs.plainFiles {
+		fmt.Fprintf(h, "plainFiles=true\n")
+	}
+	if cfs.copyBuffer != 0 {
+		fmt.Fprintf(h, "copyBuffer=%d\n", cfs.copyBuffer)
+	}
+	if len(cfs.mimeTypes) > 0 {
+		fmt.Fprintf(h, "mimeTypes=%q\n", mimeList(cfs.mimeTypes))
+	}
+	if cfs.selector != nil {
+		fmt.Fprintf(h, "selector=true\n")
+	}
+	if cfs.onCopyUp != nil {
+		fmt.Fprintf(h, "onCopyUp=true\n")
+	}
+	if cfs.journal != nil {
+		fmt.Fprintf(h, "journal=true\n")
+	}
+	for i, fsys := range cfs.filesystems {
+		fmt.Fprintf(h, "layer %d\n", i)
+		writeLayer(h, fsys)
+	}
+}
+
+func writeLayer(h hash.Hash, fsys fs.FS) {
+	fmt.Fprintf(h, "type=%T name=%q\n", fsys, LayerName(fsys))
+
+	switch v := fsys.(type) {
+	case *CompositeFS:
+		v.writeStack(h)
+		return
+	case *Layer:
+		if v.pin != "" {
+			fmt.Fprintf(h, "pin=%q\n", v.pin)
+		}
+		if v.uncached {
+			fmt.Fprintf(h, "uncached=true\n")
+		}
+		if v.priority != nil {
+			fmt.Fprintf(h, "priority=%d\n", *v.priority)
+		}
+		if len(v.roles) > 0 {
+			fmt.Fprintf(h, "roles=%q\n", v.roles)
+		}
+		if v.cacheHint != nil {
+			fmt.Fprintf(h, "cacheHint=%q\n", *v.cacheHint)
+		}
+		writeLayer(h, v.FS())
+		return
+	case *archiveFS:
+		writeLayer(h, v.fsys)
+		return
+	case *jailFS:
+		writeLayer(h, v.fsys)
+		return
+	case *adaptedFS:
+		writeLayer(h, v.fsys)
+		return
+	case *transformFS:
+		writeLayer(h, v.fsys)
+		return
+	case *budgetFS:
+		writeLayer(h, v.fsys)
+		return
+	case *mmapFS:
+		fmt.Fprintf(h, "threshold=%d\n", v.threshold)
+		writeLayer(h, v.fsys)
+		return
+	case interface{ Root() string }:
+		fmt.Fprintf(h, "root=%q\n", v.Root())
+		return
+	}
+
+	// string-backed filesystems such as os.DirFS are identified by
+	// their root directory
+	if rv := reflect.ValueOf(fsys); rv.Kind() == reflect.String {
+		fmt.Fprintf(h, "root=%q\n", rv.String())
+	}
+}
+
+// mimeList returns the content type overrides as sorted ext=type pairs.
+func mimeList(types map[string]string) []string {
+	out := make([]string, 0, len(types))
+	for ext, ctype := range types {
+		out = append(out, ext+"="+ctype)
+	}
+	sort.Strings(out)
+	return out
+}
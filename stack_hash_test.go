@@ -0,0 +1,107 @@
+package cfs_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestStackHashIsStable(t *testing.T) {
+	build := func() *cfs.CompositeFS {
+		return cfs.NewCompositeFS(
+			cfs.NewLayer("theme", fstest.MapFS{}),
+			cfs.NewLayer("base", fstest.MapFS{}),
+		)
+	}
+
+	if build().StackHash() != build().StackHash() {
+		t.Fatal("Expected identical stacks to hash the same")
+	}
+}
+
+func TestStackHashDetectsChanges(t *testing.T) {
+	base := cfs.NewCompositeFS(
+		cfs.NewLayer("theme", fstest.MapFS{}),
+		cfs.NewLayer("base", fstest.MapFS{}),
+	)
+
+	variants := map[string]*cfs.CompositeFS{
+		"renamed layer": cfs.NewCompositeFS(
+			cfs.NewLayer("theme-v2", fstest.MapFS{}),
+			cfs.NewLayer("base", fstest.MapFS{}),
+		),
+		"reordered layers": cfs.NewCompositeFS(
+			cfs.NewLayer("base", fstest.MapFS{}),
+			cfs.NewLayer("theme", fstest.MapFS{}),
+		),
+		"different options": cfs.NewOverlayFS(
+			cfs.NewLayer("theme", fstest.MapFS{}),
+			cfs.NewLayer("base", fstest.MapFS{}),
+		),
+		"different layer type": cfs.NewCompositeFS(
+			cfs.NewLayer("theme", os.DirFS(t.TempDir())),
+			cfs.NewLayer("base", fstest.MapFS{}),
+		),
+	}
+
+	for name, variant := range variants {
+		if variant.StackHash() == base.StackHash() {
+			t.Errorf("%s: expected stack hash to change", name)
+		}
+	}
+}
+
+func TestStackHashIncludesDirRoot(t *testing.T) {
+	a := cfs.NewCompositeFS(os.DirFS(t.TempDir()))
+	b := cfs.NewCompositeFS(os.DirFS(t.TempDir()))
+
+	if a.StackHash() == b.StackHash() {
+		t.Fatal("Expected different roots to produce different hashes")
+	}
+}
+
+func TestLayerName(t *testing.T) {
+	layer := cfs.NewLayer("theme", fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("a")},
+	})
+
+	if got := cfs.LayerName(layer); got != "theme" {
+		t.Fatalf("Expected layer name %q, got %q", "theme", got)
+	}
+	if got := cfs.LayerName(fstest.MapFS{}); got != "" {
+		t.Fatalf("Expected empty name for unnamed layer, got %q", got)
+	}
+
+	testReadFile(t, cfs.NewCompositeFS(layer), "a.txt", "a")
+}
+
+func TestStackHashIncludesOptions(t *testing.T) {
+	build := func(opts ...cfs.LayerOption) *cfs.CompositeFS {
+		return cfs.NewCompositeFS(
+			cfs.NewLayer("theme", fstest.MapFS{}, opts...),
+			cfs.NewLayer("base", fstest.MapFS{}),
+		)
+	}
+	base := build()
+
+	variants := map[string]*cfs.CompositeFS{
+		"read coalescing": base.WithReadCoalescing(),
+		"plain files":     base.WithPlainFiles(),
+		"layer selector": base.WithLayerSelector(func(context.Context) []int {
+			return nil
+		}),
+		"layer roles":      build(cfs.WithRoles("templates")),
+		"layer cache hint": build(cfs.WithCacheHint("no-store")),
+		"uncached layer":   build(cfs.Uncached()),
+		"layer priority":   build(cfs.WithPriority(5)),
+	}
+
+	for name, variant := range variants {
+		if variant.StackHash() == base.StackHash() {
+			t.Errorf("%s: expected stack hash to change", name)
+		}
+	}
+}
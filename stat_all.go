@@ -0,0 +1,175 @@
+package cfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// Exists reports whether name resolves in any layer.
+func (cfs *CompositeFS) Exists(name string) bool {
+	_, err := cfs.Stat(name)
+	return err == nil
+}
+
+// StatAll resolves many paths in one pass. Paths sharing a parent
+// directory are answered from a single listing of that directory per
+// layer instead of one Stat call per path and layer. Each name appears
+// in exactly one of the returned maps, with the info or error Stat
+// would return for it.
+func (cfs *CompositeFS) StatAll(names []string) (map[string]fs.FileInfo, map[string]error) {
+	infos := make(map[string]fs.FileInfo, len(names))
+	errs := make(map[string]error)
+
+	if err := cfs.life.enter("stat", "."); err != nil {
+		for _, name := range names {
+			errs[name] = err
+		}
+		return infos, errs
+	}
+	defer cfs.life.leave()
+
+	// paths are grouped by parent directory and probe order
+	groups := make(map[string][]*statRequest)
+	var order []string
+	for _, name := range names {
+		clean, err := cfs.lookupName("stat", name)
+		if err != nil {
+			errs[name] = err
+			continue
+		}
+		if !cfs.visible(clean) {
+			errs[name] = &fs.PathError{Op: "stat", Path: clean, Err: fs.ErrNotExist}
+			continue
+		}
+		if clean == "." {
+			if info, err := cfs.stat(clean); err != nil {
+				errs[name] = err
+			} else {
+				infos[name] = info
+			}
+			continue
+		}
+		key := path.Dir(clean) + "\x00" + cfs.probeClass(clean)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], &statRequest{name: name, clean: clean, allNotExist: true})
+	}
+
+	for _, key := range order {
+		group := groups[key]
+		if len(group) == 1 {
+			for _, req := range group {
+				if info, err := cfs.stat(req.clean); err != nil {
+					errs[req.name] = err
+				} else {
+					infos[req.name] = info
+				}
+			}
+			continue
+		}
+
+		cfs.statGroup(path.Dir(group[0].clean), group)
+		for _, req := range group {
+			switch {
+			case req.info != nil:
+				infos[req.name] = cfs.statResult(req.layer, req.clean, req.info)
+			case req.err != nil:
+				errs[req.name] = req.err
+			default:
+				errs[req.name] = notFoundError("file", req.clean, req.errs, req.allNotExist)
+			}
+		}
+	}
+	return infos, errs
+}
+
+// statRequest tracks the resolution of one path across layers.
+type statRequest struct {
+	name        string
+	clean       string
+	layer       int // the layer info comes from
+	info        fs.FileInfo
+	err         error
+	errs        []error
+	allNotExist bool
+}
+
+func (r *statRequest) done() bool {
+	return r.info != nil || r.err != nil
+}
+
+// statGroup resolves paths that share the parent directory dir and the
+// probe order.
+func (cfs *CompositeFS) statGroup(dir string, group []*statRequest) {
+	order := cfs.probeOrder(group[0].clean)
+	for n := range cfs.filesystems {
+		i := layerAt(order, n)
+		fsys := cfs.filesystems[i]
+		var pending []*statRequest
+		for _, req := range group {
+			if !req.done() && !skipLayer(fsys, req.clean) {
+				pending = append(pending, req)
+			}
+		}
+		if len(pending) == 0 {
+			continue
+		}
+
+		entries, err := ReadDir(fsys, dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			// the listing is unavailable, probe each path instead
+			for _, req := range pending {
+				info, err := cfs.statLayerCached(i, fsys, req.clean)
+				cfs.statStep(i, req, info, err)
+			}
+			continue
+		}
+
+		byName := make(map[string]fs.DirEntry, len(entries))
+		for _, entry := range entries {
+			byName[entry.Name()] = entry
+		}
+
+		for _, req := range pending {
+			entry, ok := byName[path.Base(req.clean)]
+			if !ok {
+				notExist := &fs.PathError{Op: "stat", Path: req.clean, Err: fs.ErrNotExist}
+				cfs.statStep(i, req, nil, notExist)
+				continue
+			}
+			if entry.Type()&fs.ModeSymlink != 0 {
+				// listings describe the link, Stat follows it
+				info, err := cfs.statLayerCached(i, fsys, req.clean)
+				cfs.statStep(i, req, info, err)
+				continue
+			}
+			info, err := entry.Info()
+			cfs.statStep(i, req, info, err)
+		}
+	}
+}
+
+// statStep records the result of probing layer i, mirroring resolve.
+func (cfs *CompositeFS) statStep(i int, req *statRequest, info fs.FileInfo, err error) {
+	if err == nil {
+		req.info = info
+		req.layer = i
+		return
+	}
+
+	wrapped := fmt.Errorf("filesystem %d: %w", i, err)
+	if errors.Is(err, fs.ErrNotExist) {
+		req.errs = append(req.errs, wrapped)
+		return
+	}
+
+	req.allNotExist = false
+	if cfs.stopOn(i, req.clean, err) {
+		req.err = wrapped
+		return
+	}
+	req.errs = append(req.errs, wrapped)
+}
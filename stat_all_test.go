@@ -0,0 +1,141 @@
+package cfs_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestStatAllResolvesAcrossLayers(t *testing.T) {
+	top := fstest.MapFS{
+		"assets/app.css": &fstest.MapFile{Data: []byte("top css")},
+	}
+	bottom := fstest.MapFS{
+		"assets/app.css": &fstest.MapFile{Data: []byte("bottom css")},
+		"assets/app.js":  &fstest.MapFile{Data: []byte("js")},
+		"index.html":     &fstest.MapFile{Data: []byte("index")},
+	}
+	composite := cfs.NewCompositeFS(top, bottom)
+
+	names := []string{"assets/app.css", "assets/app.js", "assets/missing.png", "index.html", "."}
+	infos, errs := composite.StatAll(names)
+
+	if len(infos)+len(errs) != len(names) {
+		t.Fatalf("Expected %d results, got %d infos and %d errors", len(names), len(infos), len(errs))
+	}
+	if infos["assets/app.css"].Size() != int64(len("top css")) {
+		t.Errorf("Expected top layer to win for app.css, got size %d", infos["assets/app.css"].Size())
+	}
+	if infos["assets/app.js"] == nil || infos["index.html"] == nil {
+		t.Errorf("Expected app.js and index.html to resolve, got %v", errs)
+	}
+	if infos["."] == nil || !infos["."].IsDir() {
+		t.Errorf("Expected root directory info, got %v", infos["."])
+	}
+	if err := errs["assets/missing.png"]; !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist for missing.png, got %v", err)
+	}
+}
+
+func TestStatAllSharesDirectoryListings(t *testing.T) {
+	static := fstest.MapFS{}
+	var names []string
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("img/icon%03d.png", i)
+		names = append(names, name)
+		if i%2 == 0 {
+			static[name] = &fstest.MapFile{Data: []byte("png")}
+		}
+	}
+	counting := &countingFS{MapFS: static}
+	composite := cfs.NewCompositeFS(fstest.MapFS{}, counting)
+
+	infos, errs := composite.StatAll(names)
+	if len(infos) != 50 || len(errs) != 50 {
+		t.Fatalf("Expected 50 hits and 50 misses, got %d and %d", len(infos), len(errs))
+	}
+	// countingFS does not count ReadDir, so any call is a per-path probe
+	if calls := counting.calls.Load(); calls != 0 {
+		t.Fatalf("Expected paths to be answered from the listing, got %d probes", calls)
+	}
+}
+
+func TestStatAllRespectsVisibility(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{
+		".env":     &fstest.MapFile{Data: []byte("secret")},
+		"app.yaml": &fstest.MapFile{Data: []byte("app")},
+	}).WithHideDotfiles()
+
+	infos, errs := composite.StatAll([]string{".env", "app.yaml"})
+	if infos["app.yaml"] == nil {
+		t.Fatalf("Expected app.yaml to resolve, got %v", errs["app.yaml"])
+	}
+	if !errors.Is(errs[".env"], fs.ErrNotExist) {
+		t.Fatalf("Expected hidden .env to be missing, got %v", errs[".env"])
+	}
+}
+
+func TestExists(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("a")},
+	})
+
+	if !composite.Exists("a.txt") {
+		t.Error("Expected a.txt to exist")
+	}
+	if composite.Exists("b.txt") {
+		t.Error("Expected b.txt not to exist")
+	}
+}
+
+func TestStatAllMatchesStatInOverlayMode(t *testing.T) {
+	newer := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	top := fstest.MapFS{
+		"views":           &fstest.MapFile{Mode: fs.ModeDir | 0o755},
+		"views/home.html": &fstest.MapFile{Data: []byte("home")},
+		"index.html":      &fstest.MapFile{Data: []byte("index")},
+	}
+	bottom := fstest.MapFS{
+		"views":            &fstest.MapFile{Mode: fs.ModeDir | 0o755, ModTime: newer},
+		"views/about.html": &fstest.MapFile{Data: []byte("about")},
+	}
+	composite := cfs.NewOverlayFS(top, bottom)
+
+	// views and index.html share a listing, "." is resolved on its own
+	infos, errs := composite.StatAll([]string{"views", "index.html", "."})
+	if len(errs) != 0 {
+		t.Fatalf("StatAll failed: %v", errs)
+	}
+	for name, info := range infos {
+		want, err := composite.Stat(name)
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if !info.ModTime().Equal(want.ModTime()) || info.Mode() != want.Mode() {
+			t.Errorf("Expected StatAll to match Stat for %s, got %v %v, want %v %v",
+				name, info.Mode(), info.ModTime(), want.Mode(), want.ModTime())
+		}
+	}
+	if !infos["views"].ModTime().Equal(newer) {
+		t.Errorf("Expected the merged directory ModTime %v, got %v", newer, infos["views"].ModTime())
+	}
+}
+
+func TestStatAllAfterClose(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{
+		"index.html": &fstest.MapFile{Data: []byte("index")},
+	})
+	if err := composite.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	infos, errs := composite.StatAll([]string{"index.html"})
+	if len(infos) != 0 || !errors.Is(errs["index.html"], fs.ErrClosed) {
+		t.Fatalf("Expected fs.ErrClosed, got %v %v", infos, errs)
+	}
+}
@@ -0,0 +1,209 @@
+package cfs
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheableFS is implemented by layers that control whether their
+// results may be cached by the composite. Layers that do not implement
+// it are cacheable.
+type CacheableFS interface {
+	fs.FS
+	Cacheable() bool
+}
+
+// statCache remembers per-layer Stat results for a limited time.
+type statCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]map[int]statCacheEntry
+	hits    map[int]int64
+	misses  map[int]int64
+	gen     uint64 // bumped by invalidate, so stale lookups are dropped
+}
+
+type statCacheEntry struct {
+	info    fs.FileInfo
+	err     error
+	expires time.Time
+	hashes  map[string]string // content sums by algorithm, see HashTree
+}
+
+// WithStatCache returns a copy of the composite that caches Stat
+// results for ttl. Only successful lookups and not-exist results are
+// cached, and layers whose Cacheable method reports false are always
+// probed. Changes made through the composite invalidate the affected
+// entries; use InvalidateStat for changes made behind its back.
+func (cfs *CompositeFS) WithStatCache(ttl time.Duration) *CompositeFS {
+	c := cfs.clone()
+	c.statCache = newStatCache(ttl)
+	return c
+}
+
+// InvalidateStat drops cached Stat results for the given paths and
+// everything below them. Without arguments the whole cache is dropped.
+func (cfs *CompositeFS) InvalidateStat(names ...string) {
+	cfs.statCache.invalidate(names...)
+}
+
+// statLayerCached stats name in layer i, consulting the stat cache.
+func (cfs *CompositeFS) statLayerCached(i int, fsys fs.FS, name string) (fs.FileInfo, error) {
+	c := cfs.statCache
+	if c == nil || !cacheable(fsys) {
+		return statLayer(fsys, name)
+	}
+
+	info, err, gen, ok := c.get(i, name)
+	if ok {
+		return info, err
+	}
+	info, err = statLayer(fsys, name)
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		c.put(i, name, info, err, gen)
+	}
+	return info, err
+}
+
+func cacheable(fsys fs.FS) bool {
+	if c, ok := fsys.(CacheableFS); ok {
+		return c.Cacheable()
+	}
+	return true
+}
+
+func newStatCache(ttl time.Duration) *statCache {
+	return &statCache{
+		ttl:     ttl,
+		entries: make(map[string]map[int]statCacheEntry),
+		hits:    make(map[int]int64),
+		misses:  make(map[int]int64),
+	}
+}
+
+// fresh returns an empty cache with the same settings. Copies of a
+// composite may probe different layers, so they never share entries.
+func (c *statCache) fresh() *statCache {
+	if c == nil {
+		return nil
+	}
+	return newStatCache(c.ttl)
+}
+
+// get returns the cached result of name in layer i. On a miss it
+// returns the generation to pass to put.
+func (c *statCache) get(i int, name string) (fs.FileInfo, error, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[name][i]
+	if !ok {
+		c.misses[i]++
+		return nil, nil, c.gen, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries[name], i)
+		c.misses[i]++
+		return nil, nil, c.gen, false
+	}
+	c.hits[i]++
+	return entry.info, entry.err, c.gen, true
+}
+
+// stats returns the hits and misses of layer i.
+func (c *statCache) stats(i int) CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return newCacheStats(c.hits[i], c.misses[i])
+}
+
+// put caches the result of a lookup that missed at generation gen. It
+// is dropped when the cache was invalidated since, as the lookup may
+// have raced with the change.
+func (c *statCache) put(i int, name string, info fs.FileInfo, err error, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.gen != gen {
+		return
+	}
+	layers, ok := c.entries[name]
+	if !ok {
+		layers = make(map[int]statCacheEntry)
+		c.entries[name] = layers
+	}
+	layers[i] = statCacheEntry{info: info, err: err, expires: time.Now().Add(c.ttl)}
+}
+
+// getHash returns the sum of name in layer i kept with its Stat result.
+func (c *statCache) getHash(i int, name, algo string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[name][i]
+	if !ok || time.Now().After(entry.expires) {
+		return "", false
+	}
+	sum, ok := entry.hashes[algo]
+	return sum, ok
+}
+
+// putHash keeps the sum of name in layer i with its Stat result, so it
+// expires and is invalidated along with it.
+func (c *statCache) putHash(i int, name, algo, sum string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[name][i]
+	if !ok || entry.err != nil {
+		return
+	}
+	if entry.hashes == nil {
+		entry.hashes = make(map[string]string)
+	}
+	entry.hashes[algo] = sum
+	c.entries[name][i] = entry
+}
+
+func (c *statCache) invalidate(names ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	if len(names) == 0 {
+		c.entries = make(map[string]map[int]statCacheEntry)
+		return
+	}
+	for _, name := range names {
+		name = path.Clean(name)
+		if name == "." {
+			c.entries = make(map[string]map[int]statCacheEntry)
+			return
+		}
+		prefix := name + "/"
+		for cached := range c.entries {
+			if cached == name || strings.HasPrefix(cached, prefix) {
+				delete(c.entries, cached)
+			}
+		}
+		// parents may have been created or removed along with name
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			delete(c.entries, dir)
+		}
+		delete(c.entries, ".")
+	}
+}
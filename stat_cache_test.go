@@ -0,0 +1,147 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestStatCacheAvoidsRepeatedProbes(t *testing.T) {
+	lower := &countingFS{MapFS: fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("home")},
+	}}
+	composite := cfs.NewCompositeFS(lower).WithStatCache(time.Minute)
+
+	for i := 0; i < 5; i++ {
+		if _, err := composite.Stat("views/home.html"); err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if _, err := composite.Stat("views/missing.html"); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("Expected fs.ErrNotExist, got %v", err)
+		}
+	}
+	if calls := lower.calls.Load(); calls != 2 {
+		t.Fatalf("Expected 2 layer calls, got %d", calls)
+	}
+
+	composite.InvalidateStat("views")
+	if _, err := composite.Stat("views/home.html"); err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if calls := lower.calls.Load(); calls != 3 {
+		t.Fatalf("Expected invalidation to probe again, got %d calls", calls)
+	}
+}
+
+func TestStatCacheSkipsUncachedLayers(t *testing.T) {
+	dev := &countingFS{MapFS: fstest.MapFS{}}
+	static := fstest.MapFS{
+		"app.css": &fstest.MapFile{Data: []byte("css")},
+	}
+	composite := cfs.NewCompositeFS(cfs.NewLayer("dev", dev, cfs.Uncached()), static).
+		WithStatCache(time.Minute)
+
+	if _, err := composite.Stat("app.css"); err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	dev.MapFS["app.css"] = &fstest.MapFile{Data: []byte("dev css")}
+
+	info, err := composite.Stat("app.css")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Size() != int64(len("dev css")) {
+		t.Fatalf("Expected the dev layer to be probed again, got size %d", info.Size())
+	}
+}
+
+func TestStatCacheInvalidatedByWrites(t *testing.T) {
+	composite := cfs.NewWritableFS(cfs.NewDirWriteFS(t.TempDir())).WithStatCache(time.Minute)
+
+	if _, err := composite.Stat("docs/page.md"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected fs.ErrNotExist, got %v", err)
+	}
+	if err := composite.WriteFile("docs/page.md", []byte("page"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := composite.Stat("docs/page.md"); err != nil {
+		t.Fatalf("Expected written file to be visible, got %v", err)
+	}
+
+	if err := composite.Remove("docs/page.md"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := composite.Stat("docs/page.md"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected removed file to be gone, got %v", err)
+	}
+}
+
+func TestStatCacheExpires(t *testing.T) {
+	lower := fstest.MapFS{}
+	composite := cfs.NewCompositeFS(lower).WithStatCache(10 * time.Millisecond)
+
+	if _, err := composite.Stat("late.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected fs.ErrNotExist, got %v", err)
+	}
+	lower["late.txt"] = &fstest.MapFile{Data: []byte("late")}
+
+	time.Sleep(20 * time.Millisecond)
+	if _, err := composite.Stat("late.txt"); err != nil {
+		t.Fatalf("Expected expired entry to be refreshed, got %v", err)
+	}
+}
+
+func TestCachesNotRefilledByLookupsRacingWrites(t *testing.T) {
+	composite := cfs.NewWritableFS(cfs.NewDirWriteFS(t.TempDir())).
+		WithStatCache(time.Minute).
+		WithContentCache(1<<20, 1<<10)
+
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !stop.Load() {
+				composite.Stat("page.md")
+				if view, err := composite.ReadFileShared("page.md"); err == nil {
+					view.Release()
+				}
+			}
+		}()
+	}
+
+	const versions = 200
+	for i := 1; i <= versions; i++ {
+		if err := composite.WriteFile("page.md", []byte(strings.Repeat("x", i)), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	stop.Store(true)
+	wg.Wait()
+
+	info, err := composite.Stat("page.md")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Size() != versions {
+		t.Fatalf("Expected the size of the last write, got %d", info.Size())
+	}
+	view, err := composite.ReadFileShared("page.md")
+	if err != nil {
+		t.Fatalf("ReadFileShared failed: %v", err)
+	}
+	defer view.Release()
+	if view.Len() != versions {
+		t.Fatalf("Expected the content of the last write, got %d bytes", view.Len())
+	}
+}
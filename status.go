@@ -0,0 +1,124 @@
+package cfs
+
+import (
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// StackStatus reports the health of a composite, for health and debug
+// endpoints. It is designed to be marshaled to JSON.
+type StackStatus struct {
+	// Healthy is false when any layer is disabled by its error budget.
+	Healthy bool          `json:"healthy"`
+	Layers  []LayerStatus `json:"layers"`
+}
+
+// LayerStatus reports the health of a single layer.
+type LayerStatus struct {
+	Index int      `json:"index"`
+	Name  string   `json:"name,omitempty"`
+	Type  string   `json:"type"`
+	Roles []string `json:"roles,omitempty"`
+	// Health is only reported for composites with an error budget.
+	Health *LayerHealth `json:"health,omitempty"`
+	// StatCache is only reported for composites with a stat cache.
+	StatCache *CacheStats `json:"stat_cache,omitempty"`
+	// DiskCache is only reported for layers wrapped by a DiskCache.
+	DiskCache *CacheStats `json:"disk_cache,omitempty"`
+}
+
+// LayerHealth reports the calls and errors tracked by an error budget.
+type LayerHealth struct {
+	// Disabled reports whether the layer is disabled, its circuit open.
+	Disabled bool `json:"disabled"`
+	// ProbeAt is when a disabled layer is probed next.
+	ProbeAt *time.Time `json:"probe_at,omitempty"`
+	Calls   int64      `json:"calls"`
+	Errors  int64      `json:"errors"`
+	// ConsecutiveErrors counts the failures since the last success.
+	ConsecutiveErrors int        `json:"consecutive_errors"`
+	LastError         string     `json:"last_error,omitempty"`
+	LastErrorAt       *time.Time `json:"last_error_at,omitempty"`
+}
+
+// CacheStats counts the lookups answered by a cache.
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	// HitRate is Hits divided by all lookups, zero without lookups.
+	HitRate float64 `json:"hit_rate"`
+}
+
+func newCacheStats(hits, misses int64) CacheStats {
+	s := CacheStats{Hits: hits, Misses: misses}
+	if total := hits + misses; total > 0 {
+		s.HitRate = float64(hits) / float64(total)
+	}
+	return s
+}
+
+// Status reports the health of every layer: whether it is disabled by
+// an error budget together with its last error, and the hit rates of
+// the stat cache and of disk caches.
+func (cfs *CompositeFS) Status() StackStatus {
+	status := StackStatus{Healthy: true, Layers: make([]LayerStatus, 0, len(cfs.filesystems))}
+	for i, fsys := range cfs.filesystems {
+		layer := LayerStatus{
+			Index: i,
+			Name:  LayerName(fsys),
+			Type:  fmt.Sprintf("%T", unwrapLayer(fsys)),
+		}
+		if r, ok := unwrapLayer(fsys).(RoledFS); ok {
+			layer.Roles = r.Roles()
+		}
+		if health := layerHealthOf(fsys); health != nil {
+			layer.Health = health.status()
+			if layer.Health.Disabled {
+				status.Healthy = false
+			}
+		}
+		if cfs.statCache != nil {
+			stats := cfs.statCache.stats(i)
+			layer.StatCache = &stats
+		}
+		layer.DiskCache = diskCacheStats(fsys)
+		status.Layers = append(status.Layers, layer)
+	}
+	return status
+}
+
+// layerHealthOf returns the error budget tracking of fsys, if any.
+func layerHealthOf(fsys fs.FS) *layerHealth {
+	for {
+		switch v := fsys.(type) {
+		case *budgetFS:
+			return v.health
+		case *transformFS:
+			fsys = v.fsys
+		default:
+			return nil
+		}
+	}
+}
+
+func (h *layerHealth) status() *LayerHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := &LayerHealth{
+		Disabled:          h.disabled,
+		Calls:             h.calls,
+		Errors:            h.errorCount,
+		ConsecutiveErrors: h.consecutive,
+	}
+	if h.disabled {
+		probeAt := h.probeAt
+		s.ProbeAt = &probeAt
+	}
+	if h.lastErr != nil {
+		lastErrAt := h.lastErrAt
+		s.LastError = h.lastErr.Error()
+		s.LastErrorAt = &lastErrAt
+	}
+	return s
+}
@@ -0,0 +1,81 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"encoding/json"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestStatus(t *testing.T) {
+	flaky := &flakyFS{fsys: fstest.MapFS{}}
+	flaky.failing.Store(true)
+	base := fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("base")}}
+
+	composite := cfs.NewCompositeFSBestEffort(
+		cfs.NewLayer("zip", flaky),
+		cfs.NewLayer("base", base, cfs.WithRoles(cfs.RoleBase)),
+	).WithErrorBudget(cfs.ErrorBudget{MaxConsecutive: 2, ProbeInterval: time.Hour}).
+		WithStatCache(time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if _, err := composite.Stat("a.txt"); err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+	}
+
+	status := composite.Status()
+	if status.Healthy {
+		t.Error("Expected an unhealthy stack with a disabled layer")
+	}
+	if len(status.Layers) != 2 {
+		t.Fatalf("Expected 2 layers, got %d", len(status.Layers))
+	}
+
+	zipStatus := status.Layers[0]
+	if zipStatus.Name != "zip" || zipStatus.Health == nil || !zipStatus.Health.Disabled || zipStatus.Health.ProbeAt == nil {
+		t.Fatalf("Expected zip to be disabled, got %+v", zipStatus.Health)
+	}
+	if zipStatus.Health.Errors != 2 || zipStatus.Health.LastError == "" || zipStatus.Health.LastErrorAt == nil {
+		t.Errorf("Expected the errors of zip, got %+v", zipStatus.Health)
+	}
+
+	baseStatus := status.Layers[1]
+	if baseStatus.Health == nil || baseStatus.Health.Disabled || baseStatus.Health.Errors != 0 {
+		t.Errorf("Expected base to be healthy, got %+v", baseStatus.Health)
+	}
+	if len(baseStatus.Roles) != 1 || baseStatus.Roles[0] != cfs.RoleBase {
+		t.Errorf("Expected the base role, got %v", baseStatus.Roles)
+	}
+	// the first Stat misses the cache, the next two hit it
+	if baseStatus.StatCache == nil || baseStatus.StatCache.Hits != 2 || baseStatus.StatCache.Misses != 1 {
+		t.Errorf("Unexpected stat cache stats %+v", baseStatus.StatCache)
+	}
+
+	if _, err := json.Marshal(status); err != nil {
+		t.Fatalf("Expected the status to marshal to JSON: %v", err)
+	}
+}
+
+func TestStatusDiskCache(t *testing.T) {
+	cache := cfs.NewDiskCache(t.TempDir(), time.Hour)
+	t.Cleanup(cache.Wait)
+	remote := fstest.MapFS{"a.txt": &fstest.MapFile{Data: []byte("remote")}}
+	composite := cfs.NewCompositeFS(cfs.NewLayer("cdn", cache.Wrap(remote)))
+
+	testReadFile(t, composite, "a.txt", "remote")
+	testReadFile(t, composite, "a.txt", "remote")
+
+	status := composite.Status()
+	if !status.Healthy || status.Layers[0].Health != nil {
+		t.Errorf("Expected no health tracking without a budget, got %+v", status.Layers[0].Health)
+	}
+	stats := status.Layers[0].DiskCache
+	if stats == nil || stats.Hits != 1 || stats.Misses != 1 || stats.HitRate != 0.5 {
+		t.Fatalf("Unexpected disk cache stats %+v", stats)
+	}
+}
@@ -0,0 +1,116 @@
+package cfs
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// subLayer roots fsys at dir for layers whose lookups depend on the
+// full path, such as path filters, caches keyed by name and remote
+// layers, by forwarding every call to fsys with dir prefixed. Unlike
+// fs.Sub, the filter of fsys stays in front of the view, and dir is
+// rejected right away when the filter rules it out.
+func subLayer(fsys fs.FS, dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	if dir == "." {
+		return fsys, nil
+	}
+	if skipLayer(fsys, dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrNotExist}
+	}
+	return &prefixFS{fsys: fsys, dir: dir}, nil
+}
+
+// prefixFS is a layer rooted at dir in fsys.
+type prefixFS struct {
+	fsys fs.FS
+	dir  string
+}
+
+// full returns the path of name in the wrapped layer.
+func (p *prefixFS) full(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(p.dir, name), nil
+}
+
+// shorten maps paths in the errors of the wrapped layer back below dir.
+func (p *prefixFS) shorten(err error) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		if rel, ok := strings.CutPrefix(pe.Path, p.dir+"/"); ok {
+			pe.Path = rel
+		} else if pe.Path == p.dir {
+			pe.Path = "."
+		}
+	}
+	return err
+}
+
+// Name implements NamedFS by delegating to the wrapped layer.
+func (p *prefixFS) Name() string {
+	return LayerName(p.fsys)
+}
+
+// MayContain implements PathFilter by asking the wrapped layer.
+func (p *prefixFS) MayContain(name string) bool {
+	return !skipLayer(p.fsys, path.Join(p.dir, name))
+}
+
+func (p *prefixFS) Open(name string) (fs.File, error) {
+	full, err := p.full("open", name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := p.fsys.Open(full)
+	return file, p.shorten(err)
+}
+
+func (p *prefixFS) Stat(name string) (fs.FileInfo, error) {
+	full, err := p.full("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := statLayer(p.fsys, full)
+	return info, p.shorten(err)
+}
+
+func (p *prefixFS) ReadFile(name string) ([]byte, error) {
+	full, err := p.full("read", name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := fs.ReadFile(p.fsys, full)
+	return data, p.shorten(err)
+}
+
+func (p *prefixFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	full, err := p.full("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ReadDir(p.fsys, full)
+	return entries, p.shorten(err)
+}
+
+// InvalidatePaths implements Invalidator by delegating the full paths.
+func (p *prefixFS) InvalidatePaths(names ...string) {
+	full := make([]string, len(names))
+	for i, name := range names {
+		full[i] = path.Join(p.dir, name)
+	}
+	invalidateLayer(p.fsys, full)
+}
+
+func (p *prefixFS) Sub(dir string) (fs.FS, error) {
+	full, err := p.full("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	return subLayer(p.fsys, full)
+}
@@ -0,0 +1,55 @@
+package cfs
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// SwapLayer atomically replaces the filesystem behind the layer named
+// name, created with NewLayer, for zero-downtime theme updates. Each
+// operation reads the layer once, so operations already running finish
+// against the old filesystem and files opened from it stay valid, while
+// operations starting afterwards see newFS. The layer keeps its
+// options, such as roles and pins, and the swap is visible to every
+// composite sharing it. Cached Stat results and content are dropped;
+// indexes that wrappers outside the layer built over its content, such
+// as NewBloomLayer, are not rebuilt.
+func (cfs *CompositeFS) SwapLayer(name string, newFS fs.FS) error {
+	layers := namedLayers(cfs.filesystems, name)
+	if len(layers) == 0 {
+		return fmt.Errorf("swap layer: %w: %q", ErrUnknownLayer, name)
+	}
+	if cfs.jailOS {
+		newFS = jailOSLayer(newFS)
+	}
+	for _, l := range layers {
+		l.fsys.Store(&newFS)
+	}
+
+	cfs.statCache.invalidate()
+	cfs.content.invalidate()
+	cfs.attrs.invalidate()
+	if cfs.views != nil {
+		cfs.views.Range(func(_, view any) bool {
+			view.(*CompositeFS).statCache.invalidate()
+			view.(*CompositeFS).content.invalidate()
+			view.(*CompositeFS).attrs.invalidate()
+			return true
+		})
+	}
+	return nil
+}
+
+// namedLayers returns the layers called name in layers, looking through
+// wrappers and nested composites.
+func namedLayers(layers []fs.FS, name string) []*Layer {
+	var found []*Layer
+	for _, fsys := range layers {
+		if l, ok := fsys.(*Layer); ok && l.name == name {
+			found = append(found, l)
+			continue
+		}
+		found = append(found, namedLayers(wrappedLayers(fsys), name)...)
+	}
+	return found
+}
@@ -0,0 +1,93 @@
+package cfs_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestSwapLayer(t *testing.T) {
+	oldTheme := fstest.MapFS{
+		"layout.html": &fstest.MapFile{Data: []byte("old layout")},
+		"old.css":     &fstest.MapFile{Data: []byte("old")},
+	}
+	newTheme := fstest.MapFS{
+		"layout.html": &fstest.MapFile{Data: []byte("new layout")},
+	}
+	composite := cfs.NewCompositeFS(
+		cfs.NewLayer("theme", oldTheme, cfs.WithRoles("theme")),
+		fstest.MapFS{"base.html": &fstest.MapFile{Data: []byte("base")}},
+	).WithStatCache(time.Minute)
+
+	if _, err := composite.Stat("old.css"); err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	inFlight, err := composite.Open("layout.html")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer inFlight.Close()
+
+	if err := composite.SwapLayer("theme", newTheme); err != nil {
+		t.Fatalf("SwapLayer failed: %v", err)
+	}
+
+	data, err := io.ReadAll(inFlight)
+	if err != nil || string(data) != "old layout" {
+		t.Fatalf("Expected open file to keep reading the old layer, got %q (%v)", data, err)
+	}
+	testReadFile(t, composite, "layout.html", "new layout")
+	testReadFile(t, composite, "base.html", "base")
+	if _, err := composite.Stat("old.css"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected cached Stat of the old layer to be dropped, got %v", err)
+	}
+	if layers := composite.LayersWithRole("theme"); len(layers) != 1 {
+		t.Fatalf("Expected the swapped layer to keep its roles, got %d layers", len(layers))
+	}
+
+	if err := composite.SwapLayer("missing", newTheme); !errors.Is(err, cfs.ErrUnknownLayer) {
+		t.Fatalf("Expected ErrUnknownLayer, got %v", err)
+	}
+}
+
+func TestSwapLayerConcurrentReads(t *testing.T) {
+	versions := []fs.FS{
+		fstest.MapFS{"page.html": &fstest.MapFile{Data: []byte("a")}},
+		fstest.MapFS{"page.html": &fstest.MapFile{Data: []byte("b")}},
+	}
+	composite := cfs.NewCompositeFS(cfs.NewLayer("theme", versions[0]))
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				data, err := composite.ReadFile("page.html")
+				if err != nil || (string(data) != "a" && string(data) != "b") {
+					t.Errorf("Unexpected read %q (%v)", data, err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		if err := composite.SwapLayer("theme", versions[i%2]); err != nil {
+			t.Fatalf("SwapLayer failed: %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
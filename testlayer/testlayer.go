@@ -0,0 +1,143 @@
+// Package testlayer builds in-memory layers for tests of code using
+// composite filesystems, including entries that fail with a chosen
+// error, without hand-rolling fstest.MapFS values and broken fs.FS
+// types.
+//
+//	layer := testlayer.New().
+//		File("views/home.html", "home").ModTime(yesterday).
+//		Dir("views/partials").
+//		Error("views/broken.html", fs.ErrPermission).
+//		Build()
+package testlayer
+
+import (
+	"io/fs"
+	"maps"
+	"path"
+	"testing/fstest"
+	"time"
+)
+
+// Builder assembles a layer. Its methods return the builder so calls can
+// be chained; Build may be called repeatedly.
+type Builder struct {
+	files   fstest.MapFS
+	errs    map[string]error
+	last    string
+	modTime time.Time
+}
+
+// New returns an empty builder.
+func New() *Builder {
+	return &Builder{
+		files: fstest.MapFS{},
+		errs:  make(map[string]error),
+	}
+}
+
+// File adds a file with the given content and mode 0644.
+func (b *Builder) File(name, content string) *Builder {
+	return b.Bytes(name, []byte(content))
+}
+
+// Bytes adds a file with the given content and mode 0644.
+func (b *Builder) Bytes(name string, data []byte) *Builder {
+	return b.add(name, &fstest.MapFile{Data: data, Mode: 0o644})
+}
+
+// Dir adds an empty directory with mode 0755. Parents of added entries
+// exist implicitly, so Dir is only needed for empty directories or to
+// set their metadata.
+func (b *Builder) Dir(name string) *Builder {
+	return b.add(name, &fstest.MapFile{Mode: fs.ModeDir | 0o755})
+}
+
+// Error adds a file at name for which every operation fails with err.
+// The file still appears in the listing of its directory, like an
+// unreadable file would.
+func (b *Builder) Error(name string, err error) *Builder {
+	b.add(name, &fstest.MapFile{Mode: 0o644})
+	b.errs[path.Clean(name)] = err
+	return b
+}
+
+// Mode sets the permission bits of the most recently added entry,
+// keeping its type.
+func (b *Builder) Mode(mode fs.FileMode) *Builder {
+	if f, ok := b.files[b.last]; ok {
+		f.Mode = f.Mode.Type() | mode.Perm()
+	}
+	return b
+}
+
+// ModTime sets the modification time of the most recently added entry.
+// Before any entry is added it sets the default for all later entries.
+func (b *Builder) ModTime(t time.Time) *Builder {
+	if f, ok := b.files[b.last]; ok {
+		f.ModTime = t
+		return b
+	}
+	b.modTime = t
+	return b
+}
+
+func (b *Builder) add(name string, f *fstest.MapFile) *Builder {
+	name = path.Clean(name)
+	f.ModTime = b.modTime
+	b.files[name] = f
+	delete(b.errs, name)
+	b.last = name
+	return b
+}
+
+// Build returns the layer. Later changes to the builder do not affect
+// layers already built.
+func (b *Builder) Build() fs.FS {
+	files := make(fstest.MapFS, len(b.files))
+	for name, f := range b.files {
+		copied := *f
+		files[name] = &copied
+	}
+	return &layer{files: files, errs: maps.Clone(b.errs)}
+}
+
+// layer is a fstest.MapFS with failing entries.
+type layer struct {
+	files fstest.MapFS
+	errs  map[string]error
+}
+
+func (l *layer) fail(op, name string) error {
+	if err, ok := l.errs[name]; ok {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return nil
+}
+
+func (l *layer) Open(name string) (fs.File, error) {
+	if err := l.fail("open", name); err != nil {
+		return nil, err
+	}
+	return l.files.Open(name)
+}
+
+func (l *layer) Stat(name string) (fs.FileInfo, error) {
+	if err := l.fail("stat", name); err != nil {
+		return nil, err
+	}
+	return l.files.Stat(name)
+}
+
+func (l *layer) ReadFile(name string) ([]byte, error) {
+	if err := l.fail("read", name); err != nil {
+		return nil, err
+	}
+	return l.files.ReadFile(name)
+}
+
+func (l *layer) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := l.fail("readdir", name); err != nil {
+		return nil, err
+	}
+	return l.files.ReadDir(name)
+}
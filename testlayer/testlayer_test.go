@@ -0,0 +1,70 @@
+package testlayer_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+	"github.com/goliatone/go-composite-fs/testlayer"
+)
+
+func TestBuilder(t *testing.T) {
+	yesterday := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	layer := testlayer.New().
+		File("views/home.html", "home").ModTime(yesterday).
+		Dir("views/partials").Mode(0o700).
+		Error("views/broken.html", fs.ErrPermission).
+		Build()
+
+	data, err := fs.ReadFile(layer, "views/home.html")
+	if err != nil || string(data) != "home" {
+		t.Fatalf("Expected home, got %q (%v)", data, err)
+	}
+	info, err := fs.Stat(layer, "views/home.html")
+	if err != nil || !info.ModTime().Equal(yesterday) || info.Mode() != 0o644 {
+		t.Fatalf("Unexpected metadata %v %v (%v)", info.ModTime(), info.Mode(), err)
+	}
+
+	info, err = fs.Stat(layer, "views/partials")
+	if err != nil || !info.IsDir() || info.Mode().Perm() != 0o700 {
+		t.Fatalf("Expected a 0700 directory, got %v (%v)", info, err)
+	}
+
+	entries, err := fs.ReadDir(layer, "views")
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %v (%v)", entries, err)
+	}
+	if _, err := fs.ReadFile(layer, "views/broken.html"); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("Expected fs.ErrPermission, got %v", err)
+	}
+}
+
+func TestBuilderDefaultModTimeAndReuse(t *testing.T) {
+	epoch := time.Unix(0, 0).UTC()
+	builder := testlayer.New().ModTime(epoch).File("a.txt", "a")
+	first := builder.Build()
+	builder.File("b.txt", "b")
+
+	info, err := fs.Stat(first, "a.txt")
+	if err != nil || !info.ModTime().Equal(epoch) {
+		t.Fatalf("Expected the default mod time, got %v (%v)", info, err)
+	}
+	if _, err := fs.Stat(first, "b.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected built layers to be unaffected by later changes, got %v", err)
+	}
+}
+
+func TestBuilderWithComposite(t *testing.T) {
+	broken := testlayer.New().Error("config.json", errors.New("disk on fire")).Build()
+	base := testlayer.New().File("config.json", "{}").Build()
+
+	if _, err := cfs.NewCompositeFS(broken, base).ReadFile("config.json"); err == nil {
+		t.Fatal("Expected the broken upper layer to fail the read")
+	}
+	data, err := cfs.NewCompositeFSBestEffort(broken, base).ReadFile("config.json")
+	if err != nil || string(data) != "{}" {
+		t.Fatalf("Expected best effort to fall through, got %q (%v)", data, err)
+	}
+}
@@ -0,0 +1,193 @@
+package testlayer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// UpdateGoldenEnv names the environment variable that makes AssertTree
+// rewrite golden files from the current tree instead of comparing.
+const UpdateGoldenEnv = "CFS_UPDATE_GOLDEN"
+
+// treeEntry is one line of a golden tree manifest.
+type treeEntry struct {
+	path string
+	dir  bool
+	size int64
+	hash string
+}
+
+func (e treeEntry) String() string {
+	if e.dir {
+		return e.path + "/"
+	}
+	if e.hash == "" {
+		return fmt.Sprintf("%s %d", e.path, e.size)
+	}
+	return fmt.Sprintf("%s %d %s", e.path, e.size, e.hash)
+}
+
+// AssertTree compares the tree below root in fsys, typically a
+// composite, with the golden manifest file, failing t with a diff of
+// missing, unexpected and changed entries. The manifest has one line per
+// entry, sorted by path: directories end with a slash, files are
+// followed by their size and optionally their SHA-256 hash, which is
+// only compared when present. Blank lines and lines starting with "#"
+// are ignored.
+//
+// With UpdateGoldenEnv set to a non-empty value the golden file is
+// rewritten from the tree, including hashes.
+func AssertTree(t testing.TB, fsys fs.FS, root, golden string) {
+	t.Helper()
+
+	actual, err := collectTree(fsys, root)
+	if err != nil {
+		t.Fatalf("AssertTree: %v", err)
+	}
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := writeGolden(golden, actual); err != nil {
+			t.Fatalf("AssertTree: %v", err)
+		}
+		return
+	}
+
+	data, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("AssertTree: %v (set %s=1 to create it)", err, UpdateGoldenEnv)
+	}
+	expected, err := parseGolden(data)
+	if err != nil {
+		t.Fatalf("AssertTree: %s: %v", golden, err)
+	}
+
+	if diff := diffTree(expected, actual); diff != "" {
+		t.Errorf("AssertTree: %s does not match %s (-golden +actual):\n%s", root, golden, diff)
+	}
+}
+
+func collectTree(fsys fs.FS, root string) ([]treeEntry, error) {
+	var entries []treeEntry
+	err := fs.WalkDir(fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		rel := strings.TrimPrefix(p, root+"/")
+		if root == "." {
+			rel = p
+		}
+		if d.IsDir() {
+			entries = append(entries, treeEntry{path: rel, dir: true})
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		entries = append(entries, treeEntry{path: rel, size: int64(len(data)), hash: hex.EncodeToString(sum[:])})
+		return nil
+	})
+	return entries, err
+}
+
+func parseGolden(data []byte) ([]treeEntry, error) {
+	var entries []treeEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if dir, ok := strings.CutSuffix(fields[0], "/"); ok {
+			if len(fields) != 1 {
+				return nil, fmt.Errorf("line %d: unexpected fields after directory %s", n, fields[0])
+			}
+			entries = append(entries, treeEntry{path: path.Clean(dir), dir: true})
+			continue
+		}
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("line %d: expected path, size and optional hash", n)
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		e := treeEntry{path: path.Clean(fields[0]), size: size}
+		if len(fields) == 3 {
+			e.hash = fields[2]
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+func writeGolden(name string, entries []treeEntry) error {
+	var b bytes.Buffer
+	b.WriteString("# path size sha256, directories end with a slash\n")
+	for _, e := range entries {
+		b.WriteString(e.String())
+		b.WriteByte('\n')
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(name, b.Bytes(), 0o644)
+}
+
+// diffTree lists the differences between two manifests, one line per
+// path, or returns an empty string when they match.
+func diffTree(expected, actual []treeEntry) string {
+	want := make(map[string]treeEntry, len(expected))
+	for _, e := range expected {
+		want[e.path] = e
+	}
+	got := make(map[string]treeEntry, len(actual))
+	for _, e := range actual {
+		got[e.path] = e
+	}
+
+	paths := make([]string, 0, len(want)+len(got))
+	for p := range want {
+		paths = append(paths, p)
+	}
+	for p := range got {
+		if _, ok := want[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	for _, p := range paths {
+		w, inWant := want[p]
+		g, inGot := got[p]
+		switch {
+		case !inGot:
+			fmt.Fprintf(&b, "- %s\n", w)
+		case !inWant:
+			fmt.Fprintf(&b, "+ %s\n", g)
+		case w.dir != g.dir || w.size != g.size || (w.hash != "" && w.hash != g.hash):
+			if w.hash == "" {
+				g.hash = ""
+			}
+			fmt.Fprintf(&b, "- %s\n+ %s\n", w, g)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package testlayer_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+	"github.com/goliatone/go-composite-fs/testlayer"
+)
+
+// recorder captures failures reported by AssertTree.
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// assertTree runs AssertTree against a recorder and returns the failures.
+func assertTree(t *testing.T, composite *cfs.CompositeFS, root, golden string) []string {
+	r := &recorder{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		testlayer.AssertTree(r, composite, root, golden)
+	}()
+	<-done
+	return r.failures
+}
+
+func TestAssertTree(t *testing.T) {
+	composite := cfs.NewCompositeFS(
+		testlayer.New().File("views/home.html", "theme home").Build(),
+		testlayer.New().File("views/home.html", "home").File("views/about.html", "about").Dir("views/partials").Build(),
+	)
+	golden := filepath.Join(t.TempDir(), "tree.golden")
+	os.WriteFile(golden, []byte(`# merged views
+about.html 5
+home.html 10
+partials/
+`), 0o644)
+
+	if failures := assertTree(t, composite, "views", golden); len(failures) != 0 {
+		t.Fatalf("Expected the tree to match, got %v", failures)
+	}
+
+	os.WriteFile(golden, []byte(`about.html 5
+contact.html 7
+home.html 4 deadbeef
+`), 0o644)
+	failures := assertTree(t, composite, "views", golden)
+	if len(failures) != 1 {
+		t.Fatalf("Expected one failure, got %v", failures)
+	}
+	for _, want := range []string{
+		"- contact.html 7\n",
+		"- home.html 4 deadbeef\n+ home.html 10 ",
+		"+ partials/\n",
+	} {
+		if !strings.Contains(failures[0], want) {
+			t.Errorf("Expected the diff to contain %q, got:\n%s", want, failures[0])
+		}
+	}
+}
+
+func TestAssertTreeUpdatesGolden(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{
+		"a.txt":     &fstest.MapFile{Data: []byte("a")},
+		"dir/b.txt": &fstest.MapFile{Data: []byte("bb")},
+	})
+	golden := filepath.Join(t.TempDir(), "testdata", "tree.golden")
+
+	if failures := assertTree(t, composite, ".", golden); len(failures) != 1 || !strings.Contains(failures[0], testlayer.UpdateGoldenEnv) {
+		t.Fatalf("Expected a missing golden failure, got %v", failures)
+	}
+
+	t.Setenv(testlayer.UpdateGoldenEnv, "1")
+	if failures := assertTree(t, composite, ".", golden); len(failures) != 0 {
+		t.Fatalf("Expected the update to succeed, got %v", failures)
+	}
+	data, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !strings.Contains(string(data), "dir/\ndir/b.txt 2 ") {
+		t.Fatalf("Unexpected golden content:\n%s", data)
+	}
+
+	t.Setenv(testlayer.UpdateGoldenEnv, "")
+	if failures := assertTree(t, composite, ".", golden); len(failures) != 0 {
+		t.Fatalf("Expected the updated golden to match, got %v", failures)
+	}
+}
@@ -0,0 +1,268 @@
+package cfs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"sort"
+)
+
+// TransformFunc rewrites the content of the file at path, read from r.
+// The path is relative to the root of the layer.
+type TransformFunc func(path string, r io.Reader) (io.Reader, error)
+
+// TransformOption configures a transform registered with WithTransform.
+type TransformOption func(*layerTransform)
+
+// TransformStreaming makes a transform stream file content through the
+// transform function instead of buffering it. Stat and directory
+// entries then report the size of the untransformed file, so callers
+// must not rely on it, much like a chunked HTTP response. Use it for
+// large files or transforms that produce unbounded output.
+func TransformStreaming() TransformOption {
+	return func(t *layerTransform) {
+		t.streaming = true
+	}
+}
+
+type layerTransform struct {
+	fn        TransformFunc
+	streaming bool
+}
+
+// WithTransform returns a copy of the composite where the content of
+// regular files served by the layer named layer is rewritten by fn on
+// read, e.g. to inject a banner into templates of a development layer or
+// to rewrite absolute URLs in a theme. Only named layers can be
+// transformed, see NewLayer.
+//
+// By default the transformed content is buffered, so Stat, directory
+// entries and opened files report its exact size, at the cost of
+// running fn on Stat too. See TransformStreaming for a chunked mode.
+// Calling WithTransform again for the same layer chains the functions
+// in call order; the options of the last call apply.
+func (cfs *CompositeFS) WithTransform(layer string, fn TransformFunc, opts ...TransformOption) *CompositeFS {
+	t := layerTransform{fn: fn}
+	if prev, ok := cfs.transforms[layer]; ok {
+		t.fn = chainTransforms(prev.fn, fn)
+	}
+	for _, opt := range opts {
+		opt(&t)
+	}
+
+	c := cfs.clone()
+	c.transforms = maps.Clone(cfs.transforms)
+	if c.transforms == nil {
+		c.transforms = make(map[string]layerTransform)
+	}
+	c.transforms[layer] = t
+	c.filesystems = c.flatten(cfs.filesystems)
+	return c
+}
+
+func chainTransforms(first, second TransformFunc) TransformFunc {
+	return func(name string, r io.Reader) (io.Reader, error) {
+		r, err := first(name, r)
+		if err != nil {
+			return nil, err
+		}
+		return second(name, r)
+	}
+}
+
+// transformFor returns the transform applied to the layer named name,
+// with front matter stripped first when WithFrontMatter is configured.
+func (cfs *CompositeFS) transformFor(name string) (layerTransform, bool) {
+	t, ok := cfs.transforms[name]
+	if len(cfs.frontMatter) == 0 {
+		return t, ok
+	}
+	if !ok {
+		return layerTransform{fn: cfs.stripFrontMatter}, true
+	}
+	t.fn = chainTransforms(cfs.stripFrontMatter, t.fn)
+	return t, true
+}
+
+// transformList returns the transformed layers in a stable order for
+// stack hashes.
+func (cfs *CompositeFS) transformList() []string {
+	out := make([]string, 0, len(cfs.transforms))
+	for name, t := range cfs.transforms {
+		out = append(out, fmt.Sprintf("%s streaming=%t", name, t.streaming))
+	}
+	sort.Strings(out)
+	return out
+}
+
+// transformFS applies a transform to the regular files of a layer.
+type transformFS struct {
+	fsys fs.FS
+	t    layerTransform
+	// prefix is the directory the layer was rooted at by Sub, so the
+	// transform always sees paths relative to the original layer.
+	prefix string
+}
+
+// Name implements NamedFS by delegating to the wrapped layer.
+func (t *transformFS) Name() string {
+	return LayerName(t.fsys)
+}
+
+// transform runs the transform function over the content of name.
+func (t *transformFS) transform(op, name string, r io.Reader) (io.Reader, error) {
+	out, err := t.t.fn(path.Join(t.prefix, name), r)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return out, nil
+}
+
+// read returns the transformed content of the regular file name.
+func (t *transformFS) read(op, name string) ([]byte, error) {
+	file, err := t.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r, err := t.transform(op, name, file)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return data, nil
+}
+
+func (t *transformFS) Open(name string) (fs.File, error) {
+	file, err := t.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return file, nil
+	}
+
+	if t.t.streaming {
+		r, err := t.transform("open", name, file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &transformFile{File: file, r: r}, nil
+	}
+
+	file.Close()
+	data, err := t.read("open", name)
+	if err != nil {
+		return nil, err
+	}
+	info = transformedInfo{FileInfo: info, size: int64(len(data))}
+	return &memFile{name: name, info: info, data: data}, nil
+}
+
+func (t *transformFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := statLayer(t.fsys, name)
+	if err != nil || t.t.streaming || !info.Mode().IsRegular() {
+		return info, err
+	}
+	data, err := t.read("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return transformedInfo{FileInfo: info, size: int64(len(data))}, nil
+}
+
+func (t *transformFS) ReadFile(name string) ([]byte, error) {
+	info, err := statLayer(t.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return fs.ReadFile(t.fsys, name)
+	}
+	return t.read("read", name)
+}
+
+func (t *transformFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := ReadDir(t.fsys, name)
+	if err != nil || t.t.streaming {
+		return entries, err
+	}
+	// the slice may belong to the wrapped layer
+	entries = slices.Clone(entries)
+	for i, entry := range entries {
+		if entry.Type().IsRegular() {
+			entries[i] = transformEntry{DirEntry: entry, fsys: t, name: path.Join(name, entry.Name())}
+		}
+	}
+	return entries, nil
+}
+
+func (t *transformFS) Sub(dir string) (fs.FS, error) {
+	sub, err := fs.Sub(t.fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &transformFS{fsys: sub, t: t.t, prefix: path.Join(t.prefix, dir)}, nil
+}
+
+// InvalidatePaths implements Invalidator by delegating to the wrapped
+// layer. Transformed content is never cached.
+func (t *transformFS) InvalidatePaths(names ...string) {
+	invalidateLayer(t.fsys, names)
+}
+
+// MayContain implements PathFilter by delegating to the wrapped layer.
+func (t *transformFS) MayContain(name string) bool {
+	return !skipLayer(t.fsys, name)
+}
+
+// transformFile streams the transformed content of a layer file.
+type transformFile struct {
+	fs.File
+	r io.Reader
+}
+
+func (f *transformFile) Read(b []byte) (int, error) {
+	return f.r.Read(b)
+}
+
+func (f *transformFile) Close() error {
+	if c, ok := f.r.(io.Closer); ok {
+		c.Close()
+	}
+	return f.File.Close()
+}
+
+// transformEntry reports the transformed size of a directory entry.
+type transformEntry struct {
+	fs.DirEntry
+	fsys *transformFS
+	name string
+}
+
+func (e transformEntry) Info() (fs.FileInfo, error) {
+	return e.fsys.Stat(e.name)
+}
+
+func (e transformEntry) String() string {
+	return fs.FormatDirEntry(e)
+}
+
+// transformedInfo overrides the size of a layer file.
+type transformedInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i transformedInfo) Size() int64 {
+	return i.size
+}
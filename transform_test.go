@@ -0,0 +1,166 @@
+package cfs_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func bannerTransform(name string, r io.Reader) (io.Reader, error) {
+	if !strings.HasSuffix(name, ".html") {
+		return r, nil
+	}
+	return io.MultiReader(strings.NewReader("<!-- dev -->"), r), nil
+}
+
+func newTransformStack() *cfs.CompositeFS {
+	dev := fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("<p>home</p>")},
+		"views/site.css":  &fstest.MapFile{Data: []byte("body{}")},
+	}
+	base := fstest.MapFS{
+		"views/about.html": &fstest.MapFile{Data: []byte("<p>about</p>")},
+	}
+	return cfs.NewCompositeFS(cfs.NewLayer("dev", dev), cfs.NewLayer("base", base))
+}
+
+func TestWithTransformBuffered(t *testing.T) {
+	composite := newTransformStack().WithTransform("dev", bannerTransform)
+
+	testReadFile(t, composite, "views/home.html", "<!-- dev --><p>home</p>")
+	testReadFile(t, composite, "views/site.css", "body{}")
+	testReadFile(t, composite, "views/about.html", "<p>about</p>")
+
+	data, err := composite.ReadFile("views/home.html")
+	if err != nil || string(data) != "<!-- dev --><p>home</p>" {
+		t.Fatalf("Expected transformed content, got %q, %v", data, err)
+	}
+
+	want := int64(len("<!-- dev --><p>home</p>"))
+	info, err := composite.Stat("views/home.html")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Size() != want {
+		t.Errorf("Expected size %d, got %d", want, info.Size())
+	}
+
+	entries, err := composite.ReadDir("views")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != "home.html" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.Size() != want {
+			t.Errorf("Expected entry size %d, got %v, %v", want, info, err)
+		}
+	}
+
+	// Sub composites keep transforming and pass layer-relative paths
+	sub, err := composite.Sub("views")
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	testReadFile(t, sub, "home.html", "<!-- dev --><p>home</p>")
+}
+
+// sharedDirFS returns the same entries slice from every ReadDir call.
+type sharedDirFS struct {
+	fstest.MapFS
+	entries []fs.DirEntry
+}
+
+func (s sharedDirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return s.entries, nil
+}
+
+func (s sharedDirFS) Open(name string) (fs.File, error) {
+	file, err := s.MapFS.Open(name)
+	if err != nil || name != "." {
+		return file, err
+	}
+	return &sharedDirFile{File: file, entries: s.entries}, nil
+}
+
+// sharedDirFile lists the shared entries slice in a single call.
+type sharedDirFile struct {
+	fs.File
+	entries []fs.DirEntry
+	done    bool
+}
+
+func (f *sharedDirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if f.done {
+		return nil, io.EOF
+	}
+	f.done = true
+	return f.entries, nil
+}
+
+func TestWithTransformKeepsLayerEntries(t *testing.T) {
+	mapFS := fstest.MapFS{"home.html": &fstest.MapFile{Data: []byte("<p>home</p>")}}
+	entries, _ := mapFS.ReadDir(".")
+	layer := sharedDirFS{MapFS: mapFS, entries: entries}
+	composite := cfs.NewCompositeFS(cfs.NewLayer("dev", layer)).WithTransform("dev", bannerTransform)
+
+	if _, err := composite.ReadDir("."); err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	info, err := layer.entries[0].Info()
+	if err != nil || info.Size() != int64(len("<p>home</p>")) {
+		t.Fatalf("Expected the layer entries to be left alone, got %v, %v", info, err)
+	}
+}
+
+func TestWithTransformStreaming(t *testing.T) {
+	upper := func(name string, r io.Reader) (io.Reader, error) {
+		data, err := io.ReadAll(r)
+		return bytes.NewReader(bytes.ToUpper(data)), err
+	}
+	composite := newTransformStack().
+		WithTransform("dev", bannerTransform).
+		WithTransform("dev", upper, cfs.TransformStreaming())
+
+	// transforms chain in call order
+	testReadFile(t, composite, "views/home.html", "<!-- DEV --><P>HOME</P>")
+
+	info, err := composite.Stat("views/home.html")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Size() != int64(len("<p>home</p>")) {
+		t.Errorf("Expected the untransformed size, got %d", info.Size())
+	}
+}
+
+func TestWithTransformError(t *testing.T) {
+	errBoom := errors.New("boom")
+	composite := newTransformStack().WithTransform("dev", func(string, io.Reader) (io.Reader, error) {
+		return nil, errBoom
+	})
+
+	if _, err := composite.Open("views/home.html"); !errors.Is(err, errBoom) {
+		t.Fatalf("Expected transform error, got %v", err)
+	}
+	var pathErr *fs.PathError
+	if _, err := composite.ReadFile("views/home.html"); !errors.As(err, &pathErr) || pathErr.Path != "views/home.html" {
+		t.Fatalf("Expected a PathError for views/home.html, got %v", err)
+	}
+	testReadFile(t, composite, "views/about.html", "<p>about</p>")
+}
+
+func TestWithTransformChangesStackHash(t *testing.T) {
+	stack := newTransformStack()
+	if stack.StackHash() == stack.WithTransform("dev", bannerTransform).StackHash() {
+		t.Fatal("Expected transforms to change the stack hash")
+	}
+}
@@ -0,0 +1,237 @@
+package cfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTxDone is returned by operations on a transaction that has already
+// been committed or rolled back.
+var ErrTxDone = errors.New("transaction has already been committed or rolled back")
+
+// txStagingPrefix prefixes the staging directories created in the write
+// layer while a transaction is open.
+const txStagingPrefix = ".cfs-tx-"
+
+var txCounter atomic.Uint64
+
+// Tx stages a set of writes and removals against the write layer of a
+// CompositeFS and applies them all-or-nothing on Commit. Commit is not
+// atomic to readers: changes are applied one at a time, so lookups
+// running meanwhile may see some of them and not others.
+// A Tx is not safe for concurrent use.
+type Tx interface {
+	// WriteFile stages a write of data to name.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	// Remove stages the removal of name from the write layer.
+	Remove(name string) error
+	// Commit applies every staged change. If any change fails the
+	// changes already applied are reverted before returning the error.
+	Commit() error
+	// Rollback discards every staged change.
+	Rollback() error
+}
+
+type txOp struct {
+	name   string
+	remove bool
+}
+
+type tx struct {
+	cfs     *CompositeFS
+	staging string
+	ops     []txOp
+	index   map[string]int
+	done    bool
+}
+
+// Begin starts a transaction on the write layer. Staged content is kept
+// in a staging directory inside the write layer so that Commit only
+// needs renames to publish it. Composites never serve or list staging
+// directories, including ones left behind by a crash.
+func (cfs *CompositeFS) Begin() (Tx, error) {
+	if cfs.writer == nil {
+		return nil, &fs.PathError{Op: "begin", Path: ".", Err: ErrReadOnly}
+	}
+
+	id := strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatUint(txCounter.Add(1), 36)
+	staging := txStagingPrefix + id
+	if err := cfs.writer.MkdirAll(path.Join(staging, "files"), 0o700); err != nil {
+		return nil, err
+	}
+	if err := cfs.writer.MkdirAll(path.Join(staging, "backup"), 0o700); err != nil {
+		removeAll(cfs.writer, staging)
+		return nil, err
+	}
+
+	return &tx{
+		cfs:     cfs,
+		staging: staging,
+		index:   make(map[string]int),
+	}, nil
+}
+
+func (t *tx) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	name, err := t.check("write", name)
+	if err != nil {
+		return err
+	}
+
+	staged := path.Join(t.staging, "files", name)
+	if err := t.cfs.writer.MkdirAll(path.Dir(staged), 0o700); err != nil {
+		return err
+	}
+	if err := t.cfs.writer.WriteFile(staged, data, perm); err != nil {
+		return err
+	}
+	t.record(txOp{name: name})
+	return nil
+}
+
+func (t *tx) Remove(name string) error {
+	name, err := t.check("remove", name)
+	if err != nil {
+		return err
+	}
+
+	// drop content staged earlier for the same path
+	if i, ok := t.index[name]; ok && !t.ops[i].remove {
+		t.cfs.writer.Remove(path.Join(t.staging, "files", name))
+	}
+	t.record(txOp{name: name, remove: true})
+	return nil
+}
+
+func (t *tx) Commit() error {
+	if t.done {
+		return ErrTxDone
+	}
+	t.done = true
+	defer removeAll(t.cfs.writer, t.staging)
+
+	names := make([]string, len(t.ops))
+	for i, op := range t.ops {
+		names[i] = op.name
+	}
+	defer t.cfs.record("commit", names...)()
+
+	w := t.cfs.writer
+	var applied []txOp
+	backedUp := make(map[string]bool)
+
+	revert := func(cause error) error {
+		for i := len(applied) - 1; i >= 0; i-- {
+			op := applied[i]
+			if !op.remove {
+				w.Remove(op.name)
+			}
+			if backedUp[op.name] {
+				w.Rename(path.Join(t.staging, "backup", op.name), op.name)
+			}
+		}
+		return fmt.Errorf("commit transaction: %w", cause)
+	}
+
+	for _, op := range t.ops {
+		_, err := fs.Stat(w, op.name)
+		exists := err == nil
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return revert(err)
+		}
+		if op.remove && !exists {
+			return revert(&fs.PathError{Op: "remove", Path: op.name, Err: fs.ErrNotExist})
+		}
+
+		if exists {
+			backup := path.Join(t.staging, "backup", op.name)
+			if err := w.MkdirAll(path.Dir(backup), 0o700); err != nil {
+				return revert(err)
+			}
+			if err := w.Rename(op.name, backup); err != nil {
+				return revert(err)
+			}
+			backedUp[op.name] = true
+		}
+
+		if !op.remove {
+			if err := t.cfs.ensureParent(op.name); err != nil {
+				applied = append(applied, txOp{name: op.name, remove: true})
+				return revert(err)
+			}
+			if err := w.Rename(path.Join(t.staging, "files", op.name), op.name); err != nil {
+				applied = append(applied, txOp{name: op.name, remove: true})
+				return revert(err)
+			}
+		}
+		applied = append(applied, op)
+	}
+
+	return nil
+}
+
+func (t *tx) Rollback() error {
+	if t.done {
+		return ErrTxDone
+	}
+	t.done = true
+	return removeAll(t.cfs.writer, t.staging)
+}
+
+func (t *tx) check(op, name string) (string, error) {
+	if t.done {
+		return "", ErrTxDone
+	}
+	if !fs.ValidPath(name) || name == "." {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	name = path.Clean(name)
+	if strings.HasPrefix(name, txStagingPrefix) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return name, nil
+}
+
+// record stores op, replacing any earlier operation on the same path.
+func (t *tx) record(op txOp) {
+	if i, ok := t.index[op.name]; ok {
+		t.ops[i] = op
+		return
+	}
+	t.index[op.name] = len(t.ops)
+	t.ops = append(t.ops, op)
+}
+
+// removeAll removes name and everything below it from a WriteFS.
+func removeAll(w WriteFS, name string) error {
+	var paths []string
+	err := fs.WalkDir(w, name, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	// deepest paths first so directories are empty when removed
+	sort.Slice(paths, func(i, j int) bool {
+		return len(paths[i]) > len(paths[j])
+	})
+
+	var errs []error
+	for _, p := range paths {
+		if err := w.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
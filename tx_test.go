@@ -0,0 +1,191 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestTxCommitAppliesAllChanges(t *testing.T) {
+	writeDir := t.TempDir()
+	base := fstest.MapFS{
+		"theme/main.css": &fstest.MapFile{Data: []byte("base css")},
+	}
+	composite := cfs.NewWritableFS(cfs.NewDirWriteFS(writeDir), base)
+
+	if err := composite.WriteFile("theme/old.css", []byte("old"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	tx, err := composite.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if err := tx.WriteFile("theme/main.css", []byte("new css"), 0o644); err != nil {
+		t.Fatalf("Tx WriteFile failed: %v", err)
+	}
+	if err := tx.WriteFile("theme/extra.css", []byte("extra"), 0o644); err != nil {
+		t.Fatalf("Tx WriteFile failed: %v", err)
+	}
+	if err := tx.Remove("theme/old.css"); err != nil {
+		t.Fatalf("Tx Remove failed: %v", err)
+	}
+
+	// nothing is visible before commit
+	testReadFile(t, composite, "theme/main.css", "base css")
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	testReadFile(t, composite, "theme/main.css", "new css")
+	testReadFile(t, composite, "theme/extra.css", "extra")
+	if _, err := composite.Stat("theme/old.css"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected old.css to be removed, got %v", err)
+	}
+
+	assertNoStagingDirs(t, writeDir)
+
+	if err := tx.Commit(); !errors.Is(err, cfs.ErrTxDone) {
+		t.Fatalf("Expected ErrTxDone, got %v", err)
+	}
+}
+
+func TestTxCommitFailureRevertsChanges(t *testing.T) {
+	writeDir := t.TempDir()
+	composite := cfs.NewWritableFS(cfs.NewDirWriteFS(writeDir))
+
+	if err := composite.WriteFile("a.txt", []byte("original"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	tx, err := composite.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if err := tx.WriteFile("a.txt", []byte("changed"), 0o644); err != nil {
+		t.Fatalf("Tx WriteFile failed: %v", err)
+	}
+	if err := tx.WriteFile("b.txt", []byte("new"), 0o644); err != nil {
+		t.Fatalf("Tx WriteFile failed: %v", err)
+	}
+	// removing a file that is not in the write layer fails the commit
+	if err := tx.Remove("missing.txt"); err != nil {
+		t.Fatalf("Tx Remove failed: %v", err)
+	}
+
+	if err := tx.Commit(); err == nil {
+		t.Fatal("Expected commit to fail")
+	}
+
+	testReadFile(t, composite, "a.txt", "original")
+	if _, err := composite.Stat("b.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected b.txt not to exist, got %v", err)
+	}
+	assertNoStagingDirs(t, writeDir)
+}
+
+func TestTxRollbackDiscardsChanges(t *testing.T) {
+	writeDir := t.TempDir()
+	composite := cfs.NewWritableFS(cfs.NewDirWriteFS(writeDir))
+
+	tx, err := composite.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if err := tx.WriteFile("a.txt", []byte("data"), 0o644); err != nil {
+		t.Fatalf("Tx WriteFile failed: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	if _, err := composite.Stat("a.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected a.txt not to exist, got %v", err)
+	}
+	assertNoStagingDirs(t, writeDir)
+}
+
+func TestBeginWithoutWriteLayer(t *testing.T) {
+	_, err := cfs.NewCompositeFS(fstest.MapFS{}).Begin()
+	if !errors.Is(err, cfs.ErrReadOnly) {
+		t.Fatalf("Expected ErrReadOnly, got %v", err)
+	}
+}
+
+func assertNoStagingDirs(t *testing.T, dir string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && len(entry.Name()) > 0 && entry.Name()[0] == '.' {
+			t.Errorf("Expected staging directory to be cleaned up, found %s", entry.Name())
+		}
+	}
+}
+
+func TestTxHidesStagingDirectory(t *testing.T) {
+	writeDir := t.TempDir()
+	composite := cfs.NewWritableFS(cfs.NewDirWriteFS(writeDir), fstest.MapFS{
+		"index.html": &fstest.MapFile{Data: []byte("index")},
+	})
+
+	tx, err := composite.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	defer tx.Rollback()
+	if err := tx.WriteFile("index.html", []byte("staged"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	dirs, err := os.ReadDir(writeDir)
+	if err != nil || len(dirs) != 1 {
+		t.Fatalf("Expected a staging directory in the write layer, got %v, %v", dirs, err)
+	}
+	staging := dirs[0].Name()
+
+	entries, err := fs.ReadDir(composite, ".")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "index.html" {
+		t.Fatalf("Expected the staging directory not to be listed, got %v", entries)
+	}
+	if _, err := composite.Open(staging + "/files/index.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected staged content not to be served, got %v", err)
+	}
+	if matches, _ := fs.Glob(composite, ".*"); len(matches) != 0 {
+		t.Fatalf("Expected the staging directory not to match, got %v", matches)
+	}
+	testReadFile(t, composite, "index.html", "index")
+}
+
+func TestStagingPrefixServedOutsideWriteLayer(t *testing.T) {
+	layer := fstest.MapFS{
+		".cfs-tx-notes.txt":   &fstest.MapFile{Data: []byte("notes")},
+		".cfs-tx-docs/a.html": &fstest.MapFile{Data: []byte("a")},
+	}
+
+	// read-only stacks have no staging directories to hide
+	readOnly := cfs.NewCompositeFS(layer)
+	testReadFile(t, readOnly, ".cfs-tx-notes.txt", "notes")
+	testReadFile(t, readOnly, ".cfs-tx-docs/a.html", "a")
+	if entries, err := fs.ReadDir(readOnly, "."); err != nil || len(entries) != 2 {
+		t.Fatalf("Expected both entries to be listed, got %v, %v", entries, err)
+	}
+
+	// writable stacks only hide directories of the write layer
+	writable := cfs.NewWritableFS(cfs.NewDirWriteFS(t.TempDir()), layer)
+	testReadFile(t, writable, ".cfs-tx-notes.txt", "notes")
+	testReadFile(t, writable, ".cfs-tx-docs/a.html", "a")
+}
@@ -0,0 +1,198 @@
+package cfs
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// visibility holds the composite-wide rules that hide paths regardless
+// of which layer provides them.
+type visibility struct {
+	hideDotfiles bool
+	denied       []string
+	// root is the path of a Sub composite within its parent, so rules
+	// and limits keep applying to the paths they were written for
+	root string
+}
+
+func (v visibility) empty() bool {
+	return !v.hideDotfiles && len(v.denied) == 0
+}
+
+func (v visibility) sub(dir string) visibility {
+	v.root = path.Join(v.root, dir)
+	return v
+}
+
+// WithHideDotfiles returns a copy of the composite that hides every
+// path with a dot-prefixed element (".git", ".DS_Store", editor swap
+// files) from Open, Stat, ReadFile, ReadDir and Glob across all layers.
+func (cfs *CompositeFS) WithHideDotfiles() *CompositeFS {
+	c := cfs.clone()
+	c.visibility.hideDotfiles = true
+	return c
+}
+
+// WithDenied returns a copy of the composite where paths matching any of
+// the given patterns report fs.ErrNotExist regardless of which layer
+// provides them. It is meant as a single enforcement point against
+// serving files such as ".env", "*.pem" or "config/secrets.*".
+//
+// Patterns use path.Match syntax. A pattern without a slash matches any
+// path element, so "*.pem" denies PEM files at every depth. A pattern
+// with a slash matches the path from the root; denying a directory also
+// denies everything below it. WithDenied panics if a pattern is
+// malformed.
+func (cfs *CompositeFS) WithDenied(patterns ...string) *CompositeFS {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			panic(fmt.Sprintf("cfs: invalid deny pattern %q: %v", pattern, err))
+		}
+	}
+
+	c := cfs.clone()
+	c.visibility.denied = append(append([]string(nil), cfs.visibility.denied...), patterns...)
+	return c
+}
+
+// visible reports whether name may be served by the composite.
+func (cfs *CompositeFS) visible(name string) bool {
+	v := &cfs.visibility
+	full := name
+	if v.root != "" {
+		full = path.Join(v.root, name)
+	}
+	if cfs.staged(full) {
+		return false
+	}
+	if v.empty() || full == "." {
+		return true
+	}
+
+	elems := strings.Split(full, "/")
+
+	if v.hideDotfiles {
+		for _, elem := range elems {
+			if strings.HasPrefix(elem, ".") {
+				return false
+			}
+		}
+	}
+
+	for _, pattern := range v.denied {
+		if deniedBy(pattern, elems) {
+			return false
+		}
+	}
+	return true
+}
+
+func deniedBy(pattern string, elems []string) bool {
+	if !strings.Contains(pattern, "/") {
+		for _, elem := range elems {
+			if ok, _ := path.Match(pattern, elem); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	// match the path and each of its parents
+	for i := len(elems); i > 0; i-- {
+		if ok, _ := path.Match(pattern, strings.Join(elems[:i], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// filterEntries drops the entries of dir that are not visible.
+func (cfs *CompositeFS) filterEntries(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	if cfs.visibility.empty() && !cfs.atRoot(dir) {
+		return entries
+	}
+
+	// the slice may belong to a layer, so filter into a new one
+	out := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		if cfs.visible(path.Join(dir, entry.Name())) {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+// atRoot reports whether dir is the root of a writable top composite,
+// where the staging directories of transactions are filtered from
+// listings.
+func (cfs *CompositeFS) atRoot(dir string) bool {
+	root := cfs.visibility.root
+	return cfs.writer != nil && (root == "" || root == ".") && dir == "."
+}
+
+// staged reports whether full lies in a staging directory that Begin
+// created in the write layer. Such directories are never served, while
+// paths with the same prefix in other layers are left alone.
+func (cfs *CompositeFS) staged(full string) bool {
+	if cfs.writer == nil || !strings.HasPrefix(full, txStagingPrefix) {
+		return false
+	}
+	if root := cfs.visibility.root; root != "" && root != "." {
+		// Sub refuses staging directories, so views below the root
+		// never reach one
+		return false
+	}
+	top, _, _ := strings.Cut(full, "/")
+	info, err := statLayer(cfs.writer, top)
+	return err == nil && info.IsDir()
+}
+
+// wrapDir makes directories opened from a layer honor the visibility
+// rules and limits when their entries are listed.
+func (cfs *CompositeFS) wrapDir(name string, file fs.File) (fs.File, error) {
+	if cfs.visibility.empty() && cfs.limits.empty() && !cfs.atRoot(name) {
+		return file, nil
+	}
+	if _, ok := file.(*overlayDirFile); ok {
+		// overlay entries are filtered and counted when merged
+		return file, nil
+	}
+	dir, ok := file.(fs.ReadDirFile)
+	if !ok {
+		return file, nil
+	}
+	if info, err := file.Stat(); err != nil || !info.IsDir() {
+		return file, nil
+	}
+	if err := cfs.checkDepth("open", name); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &visibleDirFile{ReadDirFile: dir, cfs: cfs, name: name}, nil
+}
+
+// visibleDirFile filters and counts the entries of a directory opened
+// from a single layer.
+type visibleDirFile struct {
+	fs.ReadDirFile
+	cfs   *CompositeFS
+	name  string
+	count int
+}
+
+func (d *visibleDirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	for {
+		entries, err := d.ReadDirFile.ReadDir(n)
+		filtered := d.cfs.filterEntries(d.name, entries)
+		d.count += len(filtered)
+		if limitErr := d.cfs.checkEntries("readdir", d.name, d.count); limitErr != nil {
+			return nil, limitErr
+		}
+		if n > 0 && len(filtered) == 0 && err == nil {
+			continue
+		}
+		return filtered, err
+	}
+}
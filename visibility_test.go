@@ -0,0 +1,190 @@
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func dotfileLayers() (fstest.MapFS, fstest.MapFS) {
+	dev := fstest.MapFS{
+		".git/config":          &fstest.MapFile{Data: []byte("git")},
+		"views/.DS_Store":      &fstest.MapFile{Data: []byte("ds")},
+		"views/.home.html.swp": &fstest.MapFile{Data: []byte("swap")},
+		"views/home.html":      &fstest.MapFile{Data: []byte("home")},
+	}
+	base := fstest.MapFS{
+		"views/about.html": &fstest.MapFile{Data: []byte("about")},
+		".env":             &fstest.MapFile{Data: []byte("SECRET=1")},
+	}
+	return dev, base
+}
+
+func TestWithHideDotfiles(t *testing.T) {
+	dev, base := dotfileLayers()
+	composite := cfs.NewCompositeFS(dev, base).WithHideDotfiles()
+
+	for _, name := range []string{".git/config", ".git", "views/.DS_Store", ".env"} {
+		if _, err := composite.Open(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Expected %s to be hidden on Open, got %v", name, err)
+		}
+		if _, err := composite.Stat(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Expected %s to be hidden on Stat, got %v", name, err)
+		}
+		if _, err := composite.ReadFile(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Expected %s to be hidden on ReadFile, got %v", name, err)
+		}
+	}
+
+	testReadFile(t, composite, "views/home.html", "home")
+
+	entries, err := composite.ReadDir("views")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 visible entries, got %v", entries)
+	}
+
+	root, err := composite.ReadDir(".")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(root) != 1 || root[0].Name() != "views" {
+		t.Fatalf("Expected only views at root, got %v", root)
+	}
+
+	matches, err := fs.Glob(composite, "views/*")
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("Expected 2 glob matches, got %v", matches)
+	}
+}
+
+func TestWithHideDotfilesOpenedDirectory(t *testing.T) {
+	dev, base := dotfileLayers()
+
+	for name, composite := range map[string]*cfs.CompositeFS{
+		"first match": cfs.NewCompositeFS(dev, base).WithHideDotfiles(),
+		"overlay":     cfs.NewOverlayFS(dev, base).WithHideDotfiles(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			file, err := composite.Open("views")
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			defer file.Close()
+
+			entries, err := file.(fs.ReadDirFile).ReadDir(-1)
+			if err != nil {
+				t.Fatalf("ReadDir failed: %v", err)
+			}
+			for _, entry := range entries {
+				if entry.Name()[0] == '.' {
+					t.Errorf("Expected dotfile %s to be hidden", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestWithHideDotfilesSub(t *testing.T) {
+	dev, base := dotfileLayers()
+	composite := cfs.NewCompositeFS(dev, base).WithHideDotfiles()
+
+	sub, err := composite.Sub("views")
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	if _, err := fs.Stat(sub, ".DS_Store"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected dotfile to stay hidden in Sub, got %v", err)
+	}
+	if _, err := composite.Sub(".git"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected hidden directory Sub to fail, got %v", err)
+	}
+}
+
+func TestWithHideDotfilesKeepsLayerEntries(t *testing.T) {
+	mapFS := fstest.MapFS{
+		".env":      &fstest.MapFile{Data: []byte("SECRET=1")},
+		"home.html": &fstest.MapFile{Data: []byte("home")},
+	}
+	entries, _ := mapFS.ReadDir(".")
+	layer := sharedDirFS{MapFS: mapFS, entries: entries}
+	composite := cfs.NewCompositeFS(layer).WithHideDotfiles()
+
+	listed, err := composite.ReadDir(".")
+	if err != nil || len(listed) != 1 || listed[0].Name() != "home.html" {
+		t.Fatalf("Expected only home.html, got %v, %v", listed, err)
+	}
+	if layer.entries[0].Name() != ".env" || layer.entries[1].Name() != "home.html" {
+		t.Fatalf("Expected the layer entries to be left alone, got %v", layer.entries)
+	}
+
+	dir, err := composite.Open(".")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dir.Close()
+	listed, err = dir.(fs.ReadDirFile).ReadDir(-1)
+	if err != nil || len(listed) != 1 || listed[0].Name() != "home.html" {
+		t.Fatalf("Expected only home.html, got %v, %v", listed, err)
+	}
+	if layer.entries[0].Name() != ".env" || layer.entries[1].Name() != "home.html" {
+		t.Fatalf("Expected the opened layer entries to be left alone, got %v", layer.entries)
+	}
+}
+
+func TestWithDenied(t *testing.T) {
+	layer := fstest.MapFS{
+		".env":                   &fstest.MapFile{Data: []byte("SECRET=1")},
+		"certs/server.pem":       &fstest.MapFile{Data: []byte("pem")},
+		"config/secrets.yaml":    &fstest.MapFile{Data: []byte("secret")},
+		"config/app.yaml":        &fstest.MapFile{Data: []byte("app")},
+		"private/keys/id.txt":    &fstest.MapFile{Data: []byte("key")},
+		"public/css/site.css":    &fstest.MapFile{Data: []byte("css")},
+		"public/nested/cert.pem": &fstest.MapFile{Data: []byte("pem")},
+	}
+
+	composite := cfs.NewCompositeFS(layer).WithDenied(".env", "*.pem", "config/secrets.*", "private")
+
+	denied := []string{".env", "certs/server.pem", "public/nested/cert.pem", "config/secrets.yaml", "private", "private/keys/id.txt"}
+	for _, name := range denied {
+		if _, err := composite.Open(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Expected %s to be denied, got %v", name, err)
+		}
+	}
+
+	testReadFile(t, composite, "config/app.yaml", "app")
+	testReadFile(t, composite, "public/css/site.css", "css")
+
+	entries, err := composite.ReadDir("config")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "app.yaml" {
+		t.Fatalf("Expected only app.yaml, got %v", entries)
+	}
+
+	sub, err := composite.Sub("config")
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	if _, err := fs.Stat(sub, "secrets.yaml"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected denied path to stay denied in Sub, got %v", err)
+	}
+}
+
+func TestWithDeniedInvalidPatternPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected invalid pattern to panic")
+		}
+	}()
+	cfs.NewCompositeFS().WithDenied("[")
+}
@@ -0,0 +1,169 @@
+package cfs
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// WithUnsortedDirs returns a copy of the composite that skips sorting
+// merged directory listings. By default ReadDir, and with it fs.WalkDir,
+// returns entries sorted by name whatever order the layers list them
+// in, so walks are deterministic. Without sorting, entries come in
+// layer order, each layer's entries in the order the layer lists them,
+// which saves the sort on very large directories when the order does
+// not matter.
+func (cfs *CompositeFS) WithUnsortedDirs() *CompositeFS {
+	c := cfs.clone()
+	c.unsorted = true
+	return c
+}
+
+// mergedEntries returns the entries collected by merger, sorted unless
+// the composite was created with WithUnsortedDirs, with the hidden ones
+// left out.
+func (cfs *CompositeFS) mergedEntries(dir string, merger *entryMerger) []fs.DirEntry {
+	entries := merger.result()
+	if !cfs.unsorted {
+		slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+			return strings.Compare(a.Name(), b.Name())
+		})
+	}
+	return cfs.filterEntries(dir, entries)
+}
+
+// WalkDirParallel walks the merged tree rooted at root like fs.WalkDir,
+// but lists and visits independent directories on up to workers
+// goroutines. fn must be safe for concurrent use. The order in which
+// paths are visited is not defined, except that a directory is always
+// visited before its contents. Returning fs.SkipDir from fn skips a
+// directory and fs.SkipAll stops the walk; any other error stops the
+// walk and is returned.
+func (cfs *CompositeFS) WalkDirParallel(root string, workers int, fn fs.WalkDirFunc) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	info, err := cfs.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = fn(root, fs.FileInfoToDirEntry(info), nil)
+	}
+	if errors.Is(err, fs.SkipDir) || errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	if err != nil || info == nil || !info.IsDir() {
+		return err
+	}
+
+	w := &parallelWalk{cfs: cfs, fn: fn, queue: []walkItem{{name: root, d: fs.FileInfoToDirEntry(info)}}}
+	w.cond = sync.NewCond(&w.mu)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work()
+		}()
+	}
+	wg.Wait()
+	return w.err
+}
+
+type walkItem struct {
+	name string
+	d    fs.DirEntry
+}
+
+// parallelWalk is a shared queue of directories still to be listed.
+type parallelWalk struct {
+	cfs *CompositeFS
+	fn  fs.WalkDirFunc
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []walkItem
+	active  int
+	stopped bool
+	err     error
+}
+
+func (w *parallelWalk) work() {
+	for {
+		w.mu.Lock()
+		for len(w.queue) == 0 && w.active > 0 && !w.stopped {
+			w.cond.Wait()
+		}
+		if w.stopped || len(w.queue) == 0 {
+			w.cond.Broadcast()
+			w.mu.Unlock()
+			return
+		}
+		item := w.queue[len(w.queue)-1]
+		w.queue = w.queue[:len(w.queue)-1]
+		w.active++
+		w.mu.Unlock()
+
+		dirs, err := w.visit(item)
+
+		w.mu.Lock()
+		w.active--
+		if err != nil && !w.stopped {
+			w.stopped = true
+			if !errors.Is(err, fs.SkipAll) {
+				w.err = err
+			}
+		}
+		w.queue = append(w.queue, dirs...)
+		w.cond.Broadcast()
+		w.mu.Unlock()
+	}
+}
+
+// visit lists dir and calls fn for each entry, returning the
+// subdirectories that still need to be walked.
+func (w *parallelWalk) visit(dir walkItem) ([]walkItem, error) {
+	entries, err := w.cfs.ReadDir(dir.name)
+	if err != nil {
+		err = w.fn(dir.name, dir.d, err)
+		if errors.Is(err, fs.SkipDir) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var dirs []walkItem
+	for _, entry := range entries {
+		if w.isStopped() {
+			return nil, nil
+		}
+		name := path.Join(dir.name, entry.Name())
+		err := w.fn(name, entry, nil)
+		if err != nil {
+			if errors.Is(err, fs.SkipDir) {
+				if !entry.IsDir() {
+					// skip the remaining entries of the parent
+					return dirs, nil
+				}
+				continue
+			}
+			return nil, err
+		}
+		if entry.IsDir() {
+			dirs = append(dirs, walkItem{name: name, d: entry})
+		}
+	}
+	return dirs, nil
+}
+
+func (w *parallelWalk) isStopped() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stopped
+}
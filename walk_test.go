@@ -0,0 +1,183 @@
+package cfs_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestWalkDirParallelMatchesWalkDir(t *testing.T) {
+	top := fstest.MapFS{
+		"a/1.txt": &fstest.MapFile{Data: []byte("top")},
+	}
+	bottom := fstest.MapFS{}
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 10; j++ {
+			bottom[fmt.Sprintf("d%d/sub%d/file.txt", i, j)] = &fstest.MapFile{Data: []byte("x")}
+		}
+	}
+	bottom["a/1.txt"] = &fstest.MapFile{Data: []byte("bottom")}
+	bottom["a/2.txt"] = &fstest.MapFile{Data: []byte("bottom")}
+	composite := cfs.NewCompositeFS(top, bottom)
+
+	var expected []string
+	if err := fs.WalkDir(composite, ".", func(p string, d fs.DirEntry, err error) error {
+		expected = append(expected, p)
+		return err
+	}); err != nil {
+		t.Fatalf("WalkDir failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	var visited []string
+	err := composite.WalkDirParallel(".", 4, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == "a/1.txt" {
+			data, _ := fs.ReadFile(composite, p)
+			if string(data) != "top" {
+				return fmt.Errorf("expected top layer content, got %q", data)
+			}
+		}
+		mu.Lock()
+		visited = append(visited, p)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkDirParallel failed: %v", err)
+	}
+
+	sort.Strings(expected)
+	sort.Strings(visited)
+	if !reflect.DeepEqual(visited, expected) {
+		t.Fatalf("Expected %d paths, got %d", len(expected), len(visited))
+	}
+}
+
+func TestWalkDirParallelSkipDirAndErrors(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{
+		"keep/a.txt": &fstest.MapFile{Data: []byte("a")},
+		"skip/b.txt": &fstest.MapFile{Data: []byte("b")},
+	})
+
+	var mu sync.Mutex
+	var visited []string
+	err := composite.WalkDirParallel(".", 2, func(p string, d fs.DirEntry, err error) error {
+		mu.Lock()
+		visited = append(visited, p)
+		mu.Unlock()
+		if p == "skip" {
+			return fs.SkipDir
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WalkDirParallel failed: %v", err)
+	}
+	sort.Strings(visited)
+	if !reflect.DeepEqual(visited, []string{".", "keep", "keep/a.txt", "skip"}) {
+		t.Fatalf("Unexpected visit set %v", visited)
+	}
+
+	boom := errors.New("boom")
+	err = composite.WalkDirParallel(".", 2, func(p string, d fs.DirEntry, err error) error {
+		if p == "keep/a.txt" {
+			return boom
+		}
+		return err
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("Expected walk error, got %v", err)
+	}
+
+	err = composite.WalkDirParallel("missing", 2, func(p string, d fs.DirEntry, err error) error {
+		return err
+	})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+// reversedFS lists directories in reverse name order.
+type reversedFS struct {
+	fstest.MapFS
+}
+
+func (r reversedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := r.MapFS.ReadDir(name)
+	slices.Reverse(entries)
+	return entries, err
+}
+
+func TestWalkDirSortedAcrossLayers(t *testing.T) {
+	top := reversedFS{fstest.MapFS{
+		"b.txt":     &fstest.MapFile{Data: []byte("b")},
+		"d/2.txt":   &fstest.MapFile{Data: []byte("2")},
+		"a.txt":     &fstest.MapFile{Data: []byte("a")},
+		"d/0.txt":   &fstest.MapFile{Data: []byte("0")},
+		"z/inner.x": &fstest.MapFile{Data: []byte("x")},
+	}}
+	bottom := fstest.MapFS{
+		"c.txt":   &fstest.MapFile{Data: []byte("c")},
+		"d/1.txt": &fstest.MapFile{Data: []byte("1")},
+	}
+	walk := func(fsys fs.FS) []string {
+		t.Helper()
+		var visited []string
+		err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+			visited = append(visited, p)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("WalkDir failed: %v", err)
+		}
+		return visited
+	}
+
+	want := []string{".", "a.txt", "b.txt", "c.txt", "d", "d/0.txt", "d/1.txt", "d/2.txt", "z", "z/inner.x"}
+	composite := cfs.NewCompositeFS(top, bottom)
+	for range 3 {
+		if got := walk(composite); !reflect.DeepEqual(got, want) {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+	}
+
+	dir, err := composite.Open("d")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dir.Close()
+	entries, err := dir.(fs.ReadDirFile).ReadDir(-1)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if !slices.IsSorted(names) {
+		t.Fatalf("Expected the opened directory to list sorted entries, got %v", names)
+	}
+
+	// unsorted listings keep layer order
+	unsorted := walk(composite.WithUnsortedDirs())
+	if unsorted[1] != "z" {
+		t.Fatalf("Expected the top layer's order first, got %v", unsorted)
+	}
+	sort.Strings(unsorted)
+	sorted := slices.Clone(want)
+	sort.Strings(sorted)
+	if !reflect.DeepEqual(unsorted, sorted) {
+		t.Fatalf("Expected the same paths, got %v", unsorted)
+	}
+}
@@ -0,0 +1,340 @@
+package cfs
+
+import (
+	"errors"
+	"io/fs"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Invalidator is implemented by layers that keep caches or indexes over
+// their content. The composite calls InvalidatePaths with layer
+// relative paths that changed behind its back.
+type Invalidator interface {
+	InvalidatePaths(names ...string)
+}
+
+// Invalidate drops everything the composite and its layers remember
+// about the given paths: cached Stat results, including cached misses,
+// disk cache entries and path indexes such as BloomLayer. Only the
+// named paths, their descendants and their parents are affected.
+func (cfs *CompositeFS) Invalidate(names ...string) {
+	if len(names) == 0 {
+		return
+	}
+	cfs.forgetCached(names...)
+	for _, fsys := range cfs.filesystems {
+		invalidateLayer(fsys, names)
+	}
+}
+
+// invalidateLayer forwards changed paths to fsys if it implements
+// Invalidator.
+func invalidateLayer(fsys fs.FS, names []string) {
+	if inv, ok := fsys.(Invalidator); ok {
+		inv.InvalidatePaths(names...)
+	}
+}
+
+// ChangeOp describes how a path changed.
+type ChangeOp int
+
+const (
+	// Created reports a path that did not exist in the previous scan.
+	Created ChangeOp = iota + 1
+	// Modified reports a file whose size, mode or modification time
+	// changed.
+	Modified
+	// Removed reports a path that no longer exists.
+	Removed
+)
+
+func (op ChangeOp) String() string {
+	switch op {
+	case Created:
+		return "created"
+	case Modified:
+		return "modified"
+	case Removed:
+		return "removed"
+	default:
+		return "unknown"
+	}
+}
+
+// Change is a single change observed by a Watcher.
+type Change struct {
+	// Path is the changed path, relative to the composite.
+	Path string
+	// Layer is the index of the layer the change was observed in.
+	Layer int
+	Op    ChangeOp
+}
+
+// WatchOption configures a Watcher.
+type WatchOption func(*Watcher)
+
+// WatchLayers restricts the watcher to the layers at the given indices.
+// Indices outside the stack are ignored. By default every layer is
+// watched.
+func WatchLayers(indices ...int) WatchOption {
+	return func(w *Watcher) {
+		w.layers = slices.Clone(indices)
+	}
+}
+
+// WatchRole restricts the watcher to the layers tagged with role, such
+// as RoleDev.
+func WatchRole(role string) WatchOption {
+	return func(w *Watcher) {
+		w.layers = nil
+		for i, fsys := range w.cfs.filesystems {
+			if HasRole(fsys, role) {
+				w.layers = append(w.layers, i)
+			}
+		}
+	}
+}
+
+// Watcher polls layers for changes, invalidates the caches of its
+// composite for the affected paths and notifies subscribers.
+type Watcher struct {
+	cfs      *CompositeFS
+	interval time.Duration
+	layers   []int
+
+	// pollMu serializes scans, so subscribers see changes in order.
+	pollMu    sync.Mutex
+	snapshots map[int]map[string]watchState
+
+	mu     sync.Mutex
+	subs   map[int]func([]Change)
+	nextID int
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type watchState struct {
+	dir     bool
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// Watch starts watching the layers of the composite, scanning them
+// every interval. Changes invalidate exactly the affected paths in this
+// composite (see Invalidate); copies created by options keep their own
+// caches and are not affected. An interval of zero disables background
+// polling, leaving scans to Poll. Close stops the watcher, as do
+// Shutdown and Close of the composite.
+func (cfs *CompositeFS) Watch(interval time.Duration, opts ...WatchOption) *Watcher {
+	w := &Watcher{
+		cfs:       cfs,
+		interval:  interval,
+		snapshots: make(map[int]map[string]watchState),
+		subs:      make(map[int]func([]Change)),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for i := range cfs.filesystems {
+		w.layers = append(w.layers, i)
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	w.layers = slices.DeleteFunc(w.layers, func(i int) bool {
+		return i < 0 || i >= len(cfs.filesystems)
+	})
+
+	for _, i := range w.layers {
+		if snap, err := scanLayer(cfs.filesystems[i]); err == nil {
+			w.snapshots[i] = snap
+		}
+	}
+
+	if interval > 0 && cfs.life.track(w) {
+		go w.run()
+	} else {
+		close(w.done)
+	}
+	return w
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.Poll()
+		}
+	}
+}
+
+// Subscribe registers fn to receive every non-empty batch of changes.
+// Batches are delivered in order from the polling goroutine, so fn
+// should return quickly. The returned function removes the
+// subscription.
+func (w *Watcher) Subscribe(fn func([]Change)) (cancel func()) {
+	w.mu.Lock()
+	id := w.nextID
+	w.nextID++
+	w.subs[id] = fn
+	w.mu.Unlock()
+
+	return func() {
+		w.mu.Lock()
+		delete(w.subs, id)
+		w.mu.Unlock()
+	}
+}
+
+// Poll scans the watched layers immediately, invalidates the changed
+// paths and notifies subscribers. It returns the changes found, sorted
+// by path. Layers that cannot be scanned keep their previous state.
+func (w *Watcher) Poll() []Change {
+	w.pollMu.Lock()
+	defer w.pollMu.Unlock()
+
+	var changes []Change
+	for _, i := range w.layers {
+		changes = append(changes, w.pollLayer(i)...)
+	}
+
+	changes = slices.DeleteFunc(changes, func(c Change) bool {
+		return !w.cfs.visible(c.Path)
+	})
+	if len(changes) == 0 {
+		return nil
+	}
+	sort.Slice(changes, func(a, b int) bool {
+		if changes[a].Path != changes[b].Path {
+			return changes[a].Path < changes[b].Path
+		}
+		return changes[a].Layer < changes[b].Layer
+	})
+
+	w.mu.Lock()
+	subs := make([]func([]Change), 0, len(w.subs))
+	for _, fn := range w.subs {
+		subs = append(subs, fn)
+	}
+	w.mu.Unlock()
+	for _, fn := range subs {
+		fn(slices.Clone(changes))
+	}
+	return changes
+}
+
+// pollLayer rescans layer i and invalidates the paths that changed.
+func (w *Watcher) pollLayer(i int) []Change {
+	fsys := w.cfs.filesystems[i]
+	var changes []Change
+	for {
+		snap, err := scanLayer(fsys)
+		if err != nil {
+			return changes
+		}
+		prev := w.snapshots[i]
+		w.snapshots[i] = snap
+
+		var names []string
+		newDir := false
+		for name, state := range snap {
+			old, ok := prev[name]
+			switch {
+			case !ok:
+				changes = append(changes, Change{Path: name, Layer: i, Op: Created})
+				newDir = newDir || state.dir
+			case old.dir != state.dir:
+				changes = append(changes, Change{Path: name, Layer: i, Op: Modified})
+				newDir = newDir || state.dir
+			case !state.dir && (old.size != state.size || old.mode != state.mode || !old.modTime.Equal(state.modTime)):
+				changes = append(changes, Change{Path: name, Layer: i, Op: Modified})
+			default:
+				continue
+			}
+			names = append(names, name)
+		}
+		for name := range prev {
+			if _, ok := snap[name]; !ok {
+				changes = append(changes, Change{Path: name, Layer: i, Op: Removed})
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return changes
+		}
+
+		w.cfs.forgetCached(names...)
+		invalidateLayer(fsys, names)
+
+		// path filters hide the content of directories they did not
+		// know about until now, so look again
+		if _, ok := fsys.(PathFilter); !ok || !newDir {
+			return changes
+		}
+	}
+}
+
+// Close stops background polling and waits for an active scan to
+// finish. Subscribers receive no further changes.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+	w.cfs.life.untrack(w)
+	w.mu.Lock()
+	clear(w.subs)
+	w.mu.Unlock()
+	return nil
+}
+
+// scanLayer records the state of every path in fsys. A missing root is
+// an empty layer.
+func scanLayer(fsys fs.FS) (map[string]watchState, error) {
+	snap := make(map[string]watchState)
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// a missing root is empty, other paths were removed
+				// while scanning
+				return fs.SkipDir
+			}
+			return err
+		}
+		if p == "." {
+			return nil
+		}
+		if d.IsDir() {
+			snap[p] = watchState{dir: true}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// removed while scanning
+				return nil
+			}
+			return err
+		}
+		snap[p] = watchState{
+			size:    info.Size(),
+			mode:    info.Mode(),
+			modTime: info.ModTime(),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
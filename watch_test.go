@@ -0,0 +1,117 @@
+package cfs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestWatcherInvalidatesChangedPaths(t *testing.T) {
+	lower := &countingFS{MapFS: fstest.MapFS{
+		"views/home.html": &fstest.MapFile{Data: []byte("home")},
+	}}
+	composite := cfs.NewCompositeFS(lower).WithStatCache(time.Hour)
+	watcher := composite.Watch(0)
+	t.Cleanup(func() { watcher.Close() })
+
+	if _, err := composite.Stat("views/home.html"); err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if _, err := composite.Stat("views/about.html"); err == nil {
+		t.Fatal("Expected about.html to be missing")
+	}
+
+	lower.MapFS["views/about.html"] = &fstest.MapFile{Data: []byte("about")}
+	changes := watcher.Poll()
+	if len(changes) != 1 || changes[0].Path != "views/about.html" || changes[0].Op != cfs.Created {
+		t.Fatalf("Expected one created change, got %v", changes)
+	}
+
+	// the cached miss is gone, the unrelated hit is kept
+	before := lower.calls.Load()
+	if _, err := composite.Stat("views/about.html"); err != nil {
+		t.Fatalf("Expected the new file to be visible, got %v", err)
+	}
+	if _, err := composite.Stat("views/home.html"); err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if calls := lower.calls.Load() - before; calls != 1 {
+		t.Fatalf("Expected only the changed path to be probed, got %d calls", calls)
+	}
+}
+
+func TestWatcherReportsModificationsAndRemovals(t *testing.T) {
+	lower := fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("a")},
+		"b.txt": &fstest.MapFile{Data: []byte("b")},
+	}
+	watcher := cfs.NewCompositeFS(lower).Watch(0)
+	t.Cleanup(func() { watcher.Close() })
+
+	lower["a.txt"] = &fstest.MapFile{Data: []byte("aa")}
+	delete(lower, "b.txt")
+
+	changes := watcher.Poll()
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %v", changes)
+	}
+	if changes[0].Path != "a.txt" || changes[0].Op != cfs.Modified {
+		t.Errorf("Expected a.txt modified, got %v", changes[0])
+	}
+	if changes[1].Path != "b.txt" || changes[1].Op != cfs.Removed {
+		t.Errorf("Expected b.txt removed, got %v", changes[1])
+	}
+	if changes := watcher.Poll(); len(changes) != 0 {
+		t.Fatalf("Expected no further changes, got %v", changes)
+	}
+}
+
+func TestWatcherUpdatesBloomIndex(t *testing.T) {
+	static := fstest.MapFS{
+		"index.html": &fstest.MapFile{Data: []byte("index")},
+	}
+	bloom, err := cfs.NewBloomLayer(static, 0.0001)
+	if err != nil {
+		t.Fatalf("NewBloomLayer failed: %v", err)
+	}
+	composite := cfs.NewCompositeFS(bloom)
+	watcher := composite.Watch(0)
+	t.Cleanup(func() { watcher.Close() })
+
+	static["docs/new.md"] = &fstest.MapFile{Data: []byte("new")}
+	watcher.Poll()
+
+	testReadFile(t, composite, "docs/new.md", "new")
+}
+
+func TestWatcherPollsInBackground(t *testing.T) {
+	dir := t.TempDir()
+	composite := cfs.NewCompositeFS(cfs.NewLayer("dev", os.DirFS(dir), cfs.WithRoles(cfs.RoleDev)), fstest.MapFS{})
+	watcher := composite.Watch(5*time.Millisecond, cfs.WatchRole(cfs.RoleDev))
+	t.Cleanup(func() { watcher.Close() })
+
+	received := make(chan []cfs.Change, 1)
+	watcher.Subscribe(func(changes []cfs.Change) {
+		select {
+		case received <- changes:
+		default:
+		}
+	})
+
+	if err := os.WriteFile(filepath.Join(dir, "app.css"), []byte("css"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	select {
+	case changes := <-received:
+		if len(changes) != 1 || changes[0].Path != "app.css" || changes[0].Layer != 0 {
+			t.Fatalf("Expected app.css created in layer 0, got %v", changes)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a change notification")
+	}
+}
@@ -0,0 +1,234 @@
+package cfs
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// ErrReadOnly is returned by write operations on a CompositeFS
+// that was created without a write layer.
+var ErrReadOnly = errors.New("composite filesystem has no write layer")
+
+// WriteFS is implemented by filesystems that can be used as the
+// write layer of a CompositeFS.
+type WriteFS interface {
+	fs.FS
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	Mkdir(name string, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+	Remove(name string) error
+	Rename(oldname, newname string) error
+}
+
+// MetadataFS is implemented by write layers that can change file modes
+// and timestamps. It is optional: composites delegate Chmod and Chtimes
+// to the write layer only when it implements this interface.
+type MetadataFS interface {
+	Chmod(name string, mode fs.FileMode) error
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+func unwrapPathError(err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
+
+// NewWritableFS creates a CompositeFS whose first layer is the given
+// write layer. Reads resolve across all layers in order, while write
+// operations are applied to the write layer only.
+func NewWritableFS(writer WriteFS, filesystems ...fs.FS) *CompositeFS {
+	cfs := newCompositeFS(false, false, filesystems...)
+
+	all := make([]fs.FS, 0, len(cfs.filesystems)+1)
+	all = append(all, writer)
+	for _, fsys := range cfs.filesystems {
+		if !sameLayer(fsys, writer) {
+			all = append(all, fsys)
+		}
+	}
+
+	cfs.filesystems = all
+	cfs.writer = writer
+	return cfs
+}
+
+// Writable reports whether the composite has a write layer.
+func (cfs *CompositeFS) Writable() bool {
+	return cfs.writer != nil
+}
+
+// WriteFile writes data to the named file in the write layer, creating
+// any parent directories that only exist in lower layers.
+func (cfs *CompositeFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if err := cfs.life.enter("write", name); err != nil {
+		return err
+	}
+	defer cfs.life.leave()
+
+	name, err := cfs.writePath("write", name)
+	if err != nil {
+		return err
+	}
+	if err := cfs.ensureParent(name); err != nil {
+		return err
+	}
+	defer cfs.record("write", name)()
+	return cfs.writer.WriteFile(name, data, perm)
+}
+
+// Mkdir creates the named directory in the write layer.
+func (cfs *CompositeFS) Mkdir(name string, perm fs.FileMode) error {
+	if err := cfs.life.enter("mkdir", name); err != nil {
+		return err
+	}
+	defer cfs.life.leave()
+
+	name, err := cfs.writePath("mkdir", name)
+	if err != nil {
+		return err
+	}
+	if err := cfs.ensureParent(name); err != nil {
+		return err
+	}
+	defer cfs.record("mkdir", name)()
+	return cfs.writer.Mkdir(name, perm)
+}
+
+// MkdirAll creates the named directory and any missing parents in the
+// write layer.
+func (cfs *CompositeFS) MkdirAll(name string, perm fs.FileMode) error {
+	if err := cfs.life.enter("mkdir", name); err != nil {
+		return err
+	}
+	defer cfs.life.leave()
+
+	name, err := cfs.writePath("mkdir", name)
+	if err != nil {
+		return err
+	}
+	defer cfs.record("mkdir", name)()
+	return cfs.writer.MkdirAll(name, perm)
+}
+
+// Remove removes the named file or empty directory from the write layer.
+// Files provided by lower layers are not affected and become visible
+// again once the write layer no longer shadows them.
+func (cfs *CompositeFS) Remove(name string) error {
+	if err := cfs.life.enter("remove", name); err != nil {
+		return err
+	}
+	defer cfs.life.leave()
+
+	name, err := cfs.writePath("remove", name)
+	if err != nil {
+		return err
+	}
+	defer cfs.record("remove", name)()
+	return cfs.writer.Remove(name)
+}
+
+// Rename renames a file within the write layer. Files that only exist
+// in lower layers are copied up first; the lower-layer original stays
+// visible under its old name.
+func (cfs *CompositeFS) Rename(oldname, newname string) error {
+	if err := cfs.life.enter("rename", oldname); err != nil {
+		return err
+	}
+	defer cfs.life.leave()
+
+	oldname, err := cfs.writePath("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newname, err = cfs.writePath("rename", newname)
+	if err != nil {
+		return err
+	}
+	defer cfs.record("rename", oldname, newname)()
+	if err := cfs.copyUp(oldname); err != nil {
+		return err
+	}
+	if err := cfs.ensureParent(newname); err != nil {
+		return err
+	}
+	return cfs.writer.Rename(oldname, newname)
+}
+
+// Chmod changes the mode of the named file in the write layer, copying
+// it up from a lower layer first when needed. It returns
+// errors.ErrUnsupported when the write layer does not implement
+// MetadataFS.
+func (cfs *CompositeFS) Chmod(name string, mode fs.FileMode) error {
+	if err := cfs.life.enter("chmod", name); err != nil {
+		return err
+	}
+	defer cfs.life.leave()
+
+	name, err := cfs.writePath("chmod", name)
+	if err != nil {
+		return err
+	}
+	meta, ok := cfs.writer.(MetadataFS)
+	if !ok {
+		return &fs.PathError{Op: "chmod", Path: name, Err: errors.ErrUnsupported}
+	}
+	defer cfs.record("chmod", name)()
+	if err := cfs.copyUp(name); err != nil {
+		return err
+	}
+	return meta.Chmod(name, mode)
+}
+
+// Chtimes changes the access and modification times of the named file
+// in the write layer, copying it up from a lower layer first when
+// needed. It returns errors.ErrUnsupported when the write layer does
+// not implement MetadataFS.
+func (cfs *CompositeFS) Chtimes(name string, atime, mtime time.Time) error {
+	if err := cfs.life.enter("chtimes", name); err != nil {
+		return err
+	}
+	defer cfs.life.leave()
+
+	name, err := cfs.writePath("chtimes", name)
+	if err != nil {
+		return err
+	}
+	meta, ok := cfs.writer.(MetadataFS)
+	if !ok {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: errors.ErrUnsupported}
+	}
+	defer cfs.record("chtimes", name)()
+	if err := cfs.copyUp(name); err != nil {
+		return err
+	}
+	return meta.Chtimes(name, atime, mtime)
+}
+
+func (cfs *CompositeFS) writePath(op, name string) (string, error) {
+	if cfs.writer == nil {
+		return "", &fs.PathError{Op: op, Path: name, Err: ErrReadOnly}
+	}
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Clean(name), nil
+}
+
+// ensureParent creates the parent directory of name in the write layer
+// when it is missing there, so overrides can target directories that
+// only exist in lower layers.
+func (cfs *CompositeFS) ensureParent(name string) error {
+	dir := path.Dir(name)
+	if dir == "." {
+		return nil
+	}
+	if _, err := fs.Stat(cfs.writer, dir); err == nil {
+		return nil
+	}
+	return cfs.writer.MkdirAll(dir, 0o755)
+}
@@ -0,0 +1,177 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestWritableFSWriteFileShadowsLowerLayer(t *testing.T) {
+	writeDir := t.TempDir()
+	base := fstest.MapFS{
+		"views/home.html": &fstest.MapFile{
+			Data: []byte("base home"),
+		},
+	}
+
+	composite := cfs.NewWritableFS(cfs.NewDirWriteFS(writeDir), base)
+
+	testReadFile(t, composite, "views/home.html", "base home")
+
+	if err := composite.WriteFile("views/home.html", []byte("override home"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	testReadFile(t, composite, "views/home.html", "override home")
+
+	data, err := os.ReadFile(filepath.Join(writeDir, "views", "home.html"))
+	if err != nil {
+		t.Fatalf("Expected file on disk: %v", err)
+	}
+	if string(data) != "override home" {
+		t.Fatalf("Expected disk content %q, got %q", "override home", string(data))
+	}
+
+	if err := composite.Remove("views/home.html"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	testReadFile(t, composite, "views/home.html", "base home")
+}
+
+func TestDirWriteFSWriteFileLeavesNoTempFiles(t *testing.T) {
+	writeDir := t.TempDir()
+	writer := cfs.NewDirWriteFS(writeDir)
+
+	for i := 0; i < 3; i++ {
+		if err := writer.WriteFile("file.txt", []byte("content"), 0o600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(writeDir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "file.txt" {
+		t.Fatalf("Expected only file.txt, got %v", entries)
+	}
+
+	info, err := writer.Stat("file.txt")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestWritableFSRenameAndMkdir(t *testing.T) {
+	composite := cfs.NewWritableFS(cfs.NewDirWriteFS(t.TempDir()))
+
+	if err := composite.MkdirAll("a/b", 0o755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := composite.WriteFile("a/b/old.txt", []byte("data"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := composite.Rename("a/b/old.txt", "c/new.txt"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	testReadFile(t, composite, "c/new.txt", "data")
+
+	if _, err := composite.Stat("a/b/old.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected old name to be gone, got %v", err)
+	}
+}
+
+func TestCompositeFSWriteWithoutWriteLayer(t *testing.T) {
+	composite := cfs.NewCompositeFS(fstest.MapFS{})
+
+	err := composite.WriteFile("file.txt", []byte("data"), 0o644)
+	if !errors.Is(err, cfs.ErrReadOnly) {
+		t.Fatalf("Expected ErrReadOnly, got %v", err)
+	}
+	if composite.Writable() {
+		t.Fatal("Expected composite not to be writable")
+	}
+}
+
+func TestWritableFSSubKeepsWriteLayer(t *testing.T) {
+	writeDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(writeDir, "views"), 0o755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+
+	composite := cfs.NewWritableFS(cfs.NewDirWriteFS(writeDir))
+
+	sub, err := composite.Sub("views")
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+
+	writable, ok := sub.(*cfs.CompositeFS)
+	if !ok || !writable.Writable() {
+		t.Fatalf("Expected writable sub filesystem, got %T", sub)
+	}
+	if err := writable.WriteFile("page.html", []byte("page"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	testReadFile(t, composite, "views/page.html", "page")
+}
+
+func TestWritableFSChmodAndChtimes(t *testing.T) {
+	composite := cfs.NewWritableFS(cfs.NewDirWriteFS(t.TempDir()))
+
+	if err := composite.WriteFile("file.txt", []byte("data"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := composite.Chmod("file.txt", 0o600); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := composite.Chtimes("file.txt", mtime, mtime); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+
+	info, err := composite.Stat("file.txt")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("Expected modtime %v, got %v", mtime, info.ModTime())
+	}
+}
+
+type plainWriteFS struct {
+	fstest.MapFS
+}
+
+func (plainWriteFS) WriteFile(string, []byte, fs.FileMode) error { return nil }
+func (plainWriteFS) Mkdir(string, fs.FileMode) error             { return nil }
+func (plainWriteFS) MkdirAll(string, fs.FileMode) error          { return nil }
+func (plainWriteFS) Remove(string) error                         { return nil }
+func (plainWriteFS) Rename(string, string) error                 { return nil }
+
+func TestWritableFSChmodUnsupported(t *testing.T) {
+	composite := cfs.NewWritableFS(plainWriteFS{fstest.MapFS{}})
+
+	err := composite.Chmod("file.txt", 0o600)
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("Expected errors.ErrUnsupported, got %v", err)
+	}
+}
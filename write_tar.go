@@ -0,0 +1,135 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs
+
+import (
+	"archive/tar"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TarOption configures WriteTar.
+type TarOption func(*tarConfig)
+
+type tarConfig struct {
+	modTime  *time.Time
+	fileMode fs.FileMode
+	dirMode  fs.FileMode
+}
+
+// TarModTime sets the modification time of every entry, for example to
+// the commit time of a release, instead of the times reported by the
+// layers.
+func TarModTime(t time.Time) TarOption {
+	return func(c *tarConfig) {
+		c.modTime = &t
+	}
+}
+
+// TarModes sets the permission bits of every file and directory entry
+// instead of those reported by the layers.
+func TarModes(file, dir fs.FileMode) TarOption {
+	return func(c *tarConfig) {
+		c.fileMode = file.Perm()
+		c.dirMode = dir.Perm()
+	}
+}
+
+// WriteTar writes the merged tree below root to w as a tar archive, with
+// entry names relative to root. The output is deterministic: entries are
+// sorted by path, owner fields are left empty and times are truncated to
+// seconds, so the same content and options always produce the same
+// bytes and the archive can be content-addressed. Use TarModTime and
+// TarModes to also normalize the metadata that differs between
+// checkouts. Entry sizes are taken from Stat, so layers with streaming
+// transforms that change the length cannot be archived.
+func (cfs *CompositeFS) WriteTar(w io.Writer, root string, opts ...TarOption) error {
+	var cfg tarConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	root = path.Clean(root)
+
+	type tarEntry struct {
+		name string
+		info fs.FileInfo
+	}
+	var entries []tarEntry
+	err := fs.WalkDir(cfs, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root || !d.Type().IsRegular() && !d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		name := p
+		if root != "." {
+			name = strings.TrimPrefix(p, root+"/")
+		}
+		entries = append(entries, tarEntry{name: name, info: info})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// walks visit a directory's contents before its later siblings,
+	// and listings may be unsorted; a directory sorts before its
+	// contents since its name is a prefix of theirs
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		hdr := cfg.header(e.name, e.info)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		if _, err := cfs.CopyFile(path.Join(root, e.name), tw); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// header returns the normalized tar header of an entry.
+func (c *tarConfig) header(name string, info fs.FileInfo) *tar.Header {
+	hdr := &tar.Header{Name: name, Typeflag: tar.TypeReg, Size: info.Size()}
+	mode, override := info.Mode().Perm(), c.fileMode
+	if info.IsDir() {
+		hdr.Name += "/"
+		hdr.Typeflag = tar.TypeDir
+		hdr.Size = 0
+		override = c.dirMode
+	}
+	switch {
+	case override != 0:
+		mode = override
+	case mode == 0 && info.IsDir():
+		mode = 0o755
+	case mode == 0:
+		mode = 0o644
+	}
+	hdr.Mode = int64(mode)
+
+	switch {
+	case c.modTime != nil:
+		hdr.ModTime = c.modTime.Truncate(time.Second)
+	case info.ModTime().IsZero():
+		hdr.ModTime = time.Unix(0, 0)
+	default:
+		hdr.ModTime = info.ModTime().Truncate(time.Second)
+	}
+	return hdr
+}
@@ -0,0 +1,93 @@
+//go:build !tinygo && !cfs_tiny
+
+package cfs_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cfs "github.com/goliatone/go-composite-fs"
+)
+
+func TestWriteTarDeterministic(t *testing.T) {
+	older := time.Date(2024, 1, 1, 12, 0, 0, 500, time.UTC)
+	newer := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	theme := fstest.MapFS{
+		"public/css/app.css": &fstest.MapFile{Data: []byte("theme css"), Mode: 0o600, ModTime: newer},
+		"public/index.html":  &fstest.MapFile{Data: []byte("theme index"), ModTime: newer},
+	}
+	base := fstest.MapFS{
+		"public/index.html": &fstest.MapFile{Data: []byte("base index"), ModTime: older},
+		"public/js/app.js":  &fstest.MapFile{Data: []byte("js"), ModTime: older},
+		"private/key.pem":   &fstest.MapFile{Data: []byte("secret")},
+	}
+
+	var first, second bytes.Buffer
+	if err := cfs.NewOverlayFS(theme, base).WriteTar(&first, "public"); err != nil {
+		t.Fatalf("WriteTar failed: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		second.Reset()
+		if err := cfs.NewOverlayFS(theme, base).WriteTar(&second, "public"); err != nil {
+			t.Fatalf("WriteTar failed: %v", err)
+		}
+		if !bytes.Equal(first.Bytes(), second.Bytes()) {
+			t.Fatal("Expected identical archives for identical content")
+		}
+	}
+
+	var names []string
+	tr := tar.NewReader(&first)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		names = append(names, hdr.Name)
+		if hdr.Name == "index.html" {
+			data, _ := io.ReadAll(tr)
+			if string(data) != "theme index" {
+				t.Errorf("Expected the winning version, got %q", data)
+			}
+		}
+		if hdr.Uname != "" || hdr.Uid != 0 {
+			t.Errorf("Expected empty owner fields, got %q %d", hdr.Uname, hdr.Uid)
+		}
+	}
+	want := []string{"css/", "css/app.css", "index.html", "js/", "js/app.js"}
+	if len(names) != len(want) {
+		t.Fatalf("Expected entries %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("Expected entries %v, got %v", want, names)
+		}
+	}
+}
+
+func TestWriteTarNormalizesMetadata(t *testing.T) {
+	stamp := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	composite := cfs.NewCompositeFS(fstest.MapFS{
+		"app.css": &fstest.MapFile{Data: []byte("css"), Mode: 0o600, ModTime: time.Now()},
+	})
+
+	var buf bytes.Buffer
+	err := composite.WriteTar(&buf, ".", cfs.TarModTime(stamp), cfs.TarModes(0o644, 0o755))
+	if err != nil {
+		t.Fatalf("WriteTar failed: %v", err)
+	}
+	hdr, err := tar.NewReader(&buf).Next()
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	if !hdr.ModTime.Equal(stamp) || hdr.Mode != 0o644 {
+		t.Errorf("Expected normalized metadata, got %v %o", hdr.ModTime, hdr.Mode)
+	}
+}